@@ -22,6 +22,9 @@ func AppRegister(router *gin.RouterGroup) {
 	router.GET("/app_stat", app.AppStatistics)
 	router.POST("/app_add", app.AppAdd)
 	router.POST("/app_update", app.AppUpdate)
+	router.GET("/api_key_list", app.ApiKeyList)
+	router.GET("/api_key_delete", app.ApiKeyDelete)
+	router.POST("/api_key_add", app.ApiKeyAdd)
 }
 
 // AppList godoc
@@ -171,13 +174,37 @@ func (app *AppController) AppDelete(c *gin.Context) {
 		middleware.ResponseError(c, 2002, err)
 		return
 	}
+	// 先吊销租户已经签发的全部token，保留到最长的token过期为止
+	// 吊销失败时不删除租户，重试删除时会再次吊销
+	revokeTTL := appInfo.GetTokenExpires()
+	if revokeTTL < appInfo.GetRefreshExpires() {
+		revokeTTL = appInfo.GetRefreshExpires()
+	}
+	if err = public.JwtRevokeApp(appInfo.AppID, revokeTTL); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	if err = public.RefreshTokenRevokeApp(appInfo.AppID); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	// 开启事务，软删除租户并吊销租户的全部API Key
+	tx = tx.Begin()
 	// 将服务信息中的IsDelete置为1，进行软删除
 	appInfo.IsDelete = 1
 	// 保存到数据库
 	if err = appInfo.Save(c, tx); err != nil {
+		tx.Rollback()
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	// 吊销租户的全部API Key
+	if err = (&dao.AppApiKey{}).DeleteByAppID(c, tx, appInfo.AppID); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	tx.Commit()
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -293,12 +320,15 @@ func (app *AppController) AppAdd(c *gin.Context) {
 	// 组装appInfo
 	// todo 结构体中需要添加时间属性
 	appInfo := &dao.App{
-		AppID:    params.AppID,
-		Name:     params.Name,
-		Secret:   params.Secret,
-		WhiteIPS: params.WhiteIPS,
-		Qps:      params.Qps,
-		Qpd:      params.Qpd,
+		AppID:          params.AppID,
+		Name:           params.Name,
+		Secret:         params.Secret,
+		WhiteIPS:       params.WhiteIPS,
+		Qps:            params.Qps,
+		Qpd:            params.Qpd,
+		TokenExpires:   params.TokenExpires,
+		RefreshExpires: params.RefreshExpires,
+		CertIdentity:   params.CertIdentity,
 	}
 	// 将添加租户数据表保存到数据库中
 	if err = appInfo.Save(c, tx); err != nil {
@@ -357,6 +387,9 @@ func (app *AppController) AppUpdate(c *gin.Context) {
 	appInfo.WhiteIPS = params.WhiteIPS
 	appInfo.Qps = params.Qps
 	appInfo.Qpd = params.Qpd
+	appInfo.TokenExpires = params.TokenExpires
+	appInfo.RefreshExpires = params.RefreshExpires
+	appInfo.CertIdentity = params.CertIdentity
 
 	// 将修改租户数据表保存到数据库中
 	if err = appInfo.Save(c, tx); err != nil {
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/middleware"
+)
+
+// ApiKeyList godoc
+// @Summary 租户API Key列表
+// @Description 租户API Key列表，不返回key明文
+// @Tags 租户管理
+// @ID /app/api_key_list
+// @Accept  json
+// @Produce  json
+// @Param app_id query string true "租户id"
+// @Success 200 {object} middleware.Response{data=[]dao.AppApiKey} "success"
+// @Router /app/api_key_list [get]
+func (app *AppController) ApiKeyList(c *gin.Context) {
+	params := &dto.ApiKeyListInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	list, err := (&dao.AppApiKey{}).KeyList(c, tx, params.AppID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, list)
+	return
+}
+
+// ApiKeyAdd godoc
+// @Summary 租户API Key添加
+// @Description 生成API Key，明文只在创建时返回一次
+// @Tags 租户管理
+// @ID /app/api_key_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ApiKeyAddInput true "body"
+// @Success 200 {object} middleware.Response{data=dto.ApiKeyAddOutput} "success"
+// @Router /app/api_key_add [post]
+func (app *AppController) ApiKeyAdd(c *gin.Context) {
+	params := &dto.ApiKeyAddInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.App{AppID: params.AppID}
+	if appInfo, err := search.FindFirst(c, tx, search); err != nil || appInfo.IsDelete == 1 {
+		middleware.ResponseError(c, 2002, errors.New("租户不存在"))
+		return
+	}
+	apiKey, err := dao.GenApiKey()
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	keyInfo := &dao.AppApiKey{
+		AppID:     params.AppID,
+		Label:     params.Label,
+		KeyPrefix: apiKey[:8],
+		KeyHash:   dao.HashApiKey(apiKey),
+		ExpireAt:  params.ExpireAt,
+	}
+	if err := keyInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, dto.ApiKeyAddOutput{
+		ID:     keyInfo.ID,
+		ApiKey: apiKey,
+	})
+	return
+}
+
+// ApiKeyDelete godoc
+// @Summary 租户API Key吊销
+// @Description 租户API Key吊销
+// @Tags 租户管理
+// @ID /app/api_key_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "API Key ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /app/api_key_delete [get]
+func (app *AppController) ApiKeyDelete(c *gin.Context) {
+	params := &dto.ApiKeyDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.AppApiKey{ID: params.ID}
+	keyInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 软删除，代理服务器下次同步时生效
+	keyInfo.IsDelete = 1
+	if err := keyInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
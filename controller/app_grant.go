@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/middleware"
+	"strings"
+)
+
+type AppGrantController struct{}
+
+// AppGrantRegister 租户授权以及接口权限规则路由注册
+func AppGrantRegister(router *gin.RouterGroup) {
+	grant := AppGrantController{}
+	router.GET("/grant_list", grant.GrantList)
+	router.GET("/grant_delete", grant.GrantDelete)
+	router.POST("/grant_add", grant.GrantAdd)
+	router.POST("/grant_update", grant.GrantUpdate)
+	router.GET("/scope_rule_list", grant.ScopeRuleList)
+	router.GET("/scope_rule_delete", grant.ScopeRuleDelete)
+	router.POST("/scope_rule_add", grant.ScopeRuleAdd)
+}
+
+// GrantList godoc
+// @Summary 租户授权列表
+// @Description 租户授权列表，可按租户或者服务筛选
+// @Tags 租户授权管理
+// @ID /app_grant/grant_list
+// @Accept  json
+// @Produce  json
+// @Param app_id query string false "租户ID"
+// @Param service_id query string false "服务ID"
+// @Success 200 {object} middleware.Response{data=dto.AppGrantListOutput} "success"
+// @Router /app_grant/grant_list [get]
+func (grant *AppGrantController) GrantList(c *gin.Context) {
+	params := &dto.AppGrantListInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	grantInfo := &dao.AppGrant{}
+	list, total, err := grantInfo.GrantList(c, tx, params.AppID, params.ServiceID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, dto.AppGrantListOutput{
+		Total: total,
+		List:  list,
+	})
+	return
+}
+
+// GrantDelete godoc
+// @Summary 租户授权删除
+// @Description 租户授权删除
+// @Tags 租户授权管理
+// @ID /app_grant/grant_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "授权ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /app_grant/grant_delete [get]
+func (grant *AppGrantController) GrantDelete(c *gin.Context) {
+	params := &dto.AppGrantDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.AppGrant{ID: params.ID}
+	grantInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 软删除
+	grantInfo.IsDelete = 1
+	if err := grantInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// GrantAdd godoc
+// @Summary 租户授权添加
+// @Description 授权租户访问服务
+// @Tags 租户授权管理
+// @ID /app_grant/grant_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.AppGrantAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /app_grant/grant_add [post]
+func (grant *AppGrantController) GrantAdd(c *gin.Context) {
+	params := &dto.AppGrantAddInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	// 租户和服务都必须存在
+	appSearch := &dao.App{AppID: params.AppID}
+	if appInfo, err := appSearch.FindFirst(c, tx, appSearch); err != nil || appInfo.IsDelete == 1 {
+		middleware.ResponseError(c, 2002, errors.New("租户不存在"))
+		return
+	}
+	serviceSearch := &dao.ServiceInfo{ID: params.ServiceID}
+	if _, err := serviceSearch.FindFirst(c, tx, serviceSearch); err != nil {
+		middleware.ResponseError(c, 2003, errors.New("服务不存在"))
+		return
+	}
+	// 同一租户对同一服务只能有一条授权
+	search := &dao.AppGrant{AppID: params.AppID, ServiceID: params.ServiceID}
+	if exist, err := search.FindFirst(c, tx, search); err == nil && exist.IsDelete == 0 {
+		middleware.ResponseError(c, 2004, errors.New("授权已存在"))
+		return
+	}
+	grantInfo := &dao.AppGrant{
+		AppID:     params.AppID,
+		ServiceID: params.ServiceID,
+		Scope:     params.Scope,
+	}
+	if err := grantInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// GrantUpdate godoc
+// @Summary 租户授权更新
+// @Description 更新授予的权限范围
+// @Tags 租户授权管理
+// @ID /app_grant/grant_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.AppGrantUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /app_grant/grant_update [post]
+func (grant *AppGrantController) GrantUpdate(c *gin.Context) {
+	params := &dto.AppGrantUpdateInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.AppGrant{ID: params.ID}
+	grantInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	grantInfo.Scope = params.Scope
+	if err := grantInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// ScopeRuleList godoc
+// @Summary 接口权限规则列表
+// @Description 接口权限规则列表
+// @Tags 租户授权管理
+// @ID /app_grant/scope_rule_list
+// @Accept  json
+// @Produce  json
+// @Param service_id query string true "服务ID"
+// @Success 200 {object} middleware.Response{data=dto.AppGrantListOutput} "success"
+// @Router /app_grant/scope_rule_list [get]
+func (grant *AppGrantController) ScopeRuleList(c *gin.Context) {
+	params := &dto.ScopeRuleListInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	ruleInfo := &dao.ScopeRule{}
+	list, total, err := ruleInfo.ListBYServiceID(c, tx, params.ServiceID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, dto.AppGrantListOutput{
+		Total: total,
+		List:  list,
+	})
+	return
+}
+
+// ScopeRuleDelete godoc
+// @Summary 接口权限规则删除
+// @Description 接口权限规则删除
+// @Tags 租户授权管理
+// @ID /app_grant/scope_rule_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "规则ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /app_grant/scope_rule_delete [get]
+func (grant *AppGrantController) ScopeRuleDelete(c *gin.Context) {
+	params := &dto.AppGrantDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.ScopeRule{ID: params.ID}
+	ruleInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 软删除
+	ruleInfo.IsDelete = 1
+	if err := ruleInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// ScopeRuleAdd godoc
+// @Summary 接口权限规则添加
+// @Description 为服务的请求方法和路径前缀设置需要的权限范围
+// @Tags 租户授权管理
+// @ID /app_grant/scope_rule_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ScopeRuleAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /app_grant/scope_rule_add [post]
+func (grant *AppGrantController) ScopeRuleAdd(c *gin.Context) {
+	params := &dto.ScopeRuleAddInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceSearch := &dao.ServiceInfo{ID: params.ServiceID}
+	if _, err := serviceSearch.FindFirst(c, tx, serviceSearch); err != nil {
+		middleware.ResponseError(c, 2002, errors.New("服务不存在"))
+		return
+	}
+	ruleInfo := &dao.ScopeRule{
+		ServiceID: params.ServiceID,
+		Method:    strings.ToUpper(params.Method),
+		Path:      params.Path,
+		Scope:     params.Scope,
+	}
+	if err := ruleInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
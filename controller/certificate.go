@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+	"time"
+)
+
+type CertificateController struct{}
+
+// CertificateRegister 证书路由注册
+func CertificateRegister(router *gin.RouterGroup) {
+	cert := CertificateController{}
+	router.GET("/cert_list", cert.CertList)
+	router.GET("/cert_detail", cert.CertDetail)
+	router.GET("/cert_delete", cert.CertDelete)
+	router.POST("/cert_add", cert.CertAdd)
+	router.POST("/cert_update", cert.CertUpdate)
+}
+
+// CertList godoc
+// @Summary 证书列表
+// @Description 证书列表，按照过期时间升序
+// @Tags 证书管理
+// @ID /certificate/cert_list
+// @Accept  json
+// @Produce  json
+// @Param info query string false "关键词"
+// @Param expire_in query int false "多少天内过期"
+// @Param page_size query string true "每页多少条"
+// @Param page_no query string true "页码"
+// @Success 200 {object} middleware.Response{data=dto.CertListOutput} "success"
+// @Router /certificate/cert_list [get]
+func (cert *CertificateController) CertList(c *gin.Context) {
+	params := &dto.CertListInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	list, total, err := (&dao.Certificate{}).CertList(c, tx, params)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	now := time.Now()
+	outPutList := []dto.CertListItemOutput{}
+	for _, item := range list {
+		expireDays := item.ExpireDays(now)
+		outPutList = append(outPutList, dto.CertListItemOutput{
+			ID:         item.ID,
+			Name:       item.Name,
+			Hosts:      item.Hosts,
+			NotBefore:  item.NotBefore,
+			NotAfter:   item.NotAfter,
+			ExpireDays: expireDays,
+			Expiring:   expireDays < public.CertExpireWarnDays,
+			UpdatedAt:  item.UpdatedAt,
+		})
+	}
+	middleware.ResponseSuccess(c, dto.CertListOutput{
+		List:  outPutList,
+		Total: total,
+	})
+	return
+}
+
+// CertDetail godoc
+// @Summary 证书详情
+// @Description 证书详情，不返回私钥
+// @Tags 证书管理
+// @ID /certificate/cert_detail
+// @Accept  json
+// @Produce  json
+// @Param id query string true "证书ID"
+// @Success 200 {object} middleware.Response{data=dao.Certificate} "success"
+// @Router /certificate/cert_detail [get]
+func (cert *CertificateController) CertDetail(c *gin.Context) {
+	params := &dto.CertDetailInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Certificate{ID: params.ID}
+	certInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, certInfo)
+	return
+}
+
+// CertDelete godoc
+// @Summary 证书删除
+// @Description 证书删除
+// @Tags 证书管理
+// @ID /certificate/cert_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "证书ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /certificate/cert_delete [get]
+func (cert *CertificateController) CertDelete(c *gin.Context) {
+	params := &dto.CertDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Certificate{ID: params.ID}
+	certInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 软删除，代理服务器下次重新加载时生效
+	certInfo.IsDelete = 1
+	if err := certInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// CertAdd godoc
+// @Summary 证书添加
+// @Description 证书添加，域名和有效期从证书中解析
+// @Tags 证书管理
+// @ID /certificate/cert_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /certificate/cert_add [post]
+func (cert *CertificateController) CertAdd(c *gin.Context) {
+	params := &dto.CertAddInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	certInfo := &dao.Certificate{Name: params.Name}
+	if err := checkCertPem(certInfo, params.CertPem, params.KeyPem); err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	search := &dao.Certificate{Name: params.Name}
+	if exist, err := search.FindFirst(c, tx, search); err == nil && exist.IsDelete == 0 {
+		middleware.ResponseError(c, 2003, errors.New("证书名称被占用，请重新输入"))
+		return
+	}
+	if err := certInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// CertUpdate godoc
+// @Summary 证书更新
+// @Description 证书更新，用于证书续期，未传私钥时沿用原私钥
+// @Tags 证书管理
+// @ID /certificate/cert_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /certificate/cert_update [post]
+func (cert *CertificateController) CertUpdate(c *gin.Context) {
+	params := &dto.CertUpdateInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Certificate{ID: params.ID}
+	certInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if params.KeyPem == "" {
+		params.KeyPem = certInfo.KeyPem
+	}
+	if err := checkCertPem(certInfo, params.CertPem, params.KeyPem); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	certInfo.Name = params.Name
+	if err := certInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// checkCertPem 校验证书和私钥是否匹配，不允许保存已经过期的证书
+func checkCertPem(certInfo *dao.Certificate, certPem, keyPem string) error {
+	if err := certInfo.SetPem(certPem, keyPem); err != nil {
+		return errors.New("证书或私钥格式错误: " + err.Error())
+	}
+	if certInfo.Hosts == "" {
+		return errors.New("证书中没有域名")
+	}
+	if certInfo.NotAfter.Before(time.Now()) {
+		return errors.New("证书已过期")
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/middleware"
+)
+
+type JwtIssuerController struct{}
+
+// JwtIssuerRegister 外部JWT签发方路由注册
+func JwtIssuerRegister(router *gin.RouterGroup) {
+	issuer := JwtIssuerController{}
+	router.GET("/jwt_issuer_list", issuer.JwtIssuerList)
+	router.GET("/jwt_issuer_delete", issuer.JwtIssuerDelete)
+	router.POST("/jwt_issuer_add", issuer.JwtIssuerAdd)
+	router.POST("/jwt_issuer_update", issuer.JwtIssuerUpdate)
+}
+
+// JwtIssuerList godoc
+// @Summary 外部签发方列表
+// @Description 外部签发方列表
+// @Tags 外部签发方管理
+// @ID /jwt_issuer/jwt_issuer_list
+// @Accept  json
+// @Produce  json
+// @Param service_id query string true "服务ID"
+// @Success 200 {object} middleware.Response{data=dto.JwtIssuerListOutput} "success"
+// @Router /jwt_issuer/jwt_issuer_list [get]
+func (issuer *JwtIssuerController) JwtIssuerList(c *gin.Context) {
+	params := &dto.JwtIssuerListInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	issuerInfo := &dao.JwtIssuer{}
+	list, total, err := issuerInfo.ListBYServiceID(c, tx, params.ServiceID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, dto.JwtIssuerListOutput{
+		Total: total,
+		List:  list,
+	})
+	return
+}
+
+// JwtIssuerDelete godoc
+// @Summary 外部签发方删除
+// @Description 外部签发方删除
+// @Tags 外部签发方管理
+// @ID /jwt_issuer/jwt_issuer_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "签发方ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /jwt_issuer/jwt_issuer_delete [get]
+func (issuer *JwtIssuerController) JwtIssuerDelete(c *gin.Context) {
+	params := &dto.JwtIssuerDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.JwtIssuer{ID: params.ID}
+	issuerInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 软删除
+	issuerInfo.IsDelete = 1
+	if err := issuerInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// JwtIssuerAdd godoc
+// @Summary 外部签发方添加
+// @Description 外部签发方添加
+// @Tags 外部签发方管理
+// @ID /jwt_issuer/jwt_issuer_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.JwtIssuerAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /jwt_issuer/jwt_issuer_add [post]
+func (issuer *JwtIssuerController) JwtIssuerAdd(c *gin.Context) {
+	params := &dto.JwtIssuerAddInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	// 服务必须存在
+	serviceSearch := &dao.ServiceInfo{ID: params.ServiceID}
+	if _, err := serviceSearch.FindFirst(c, tx, serviceSearch); err != nil {
+		middleware.ResponseError(c, 2002, errors.New("服务不存在"))
+		return
+	}
+	// 同一服务下签发方不能重复
+	search := &dao.JwtIssuer{ServiceID: params.ServiceID, Issuer: params.Issuer}
+	if exist, err := search.FindFirst(c, tx, search); err == nil && exist.IsDelete == 0 {
+		middleware.ResponseError(c, 2003, errors.New("签发方已存在"))
+		return
+	}
+	issuerInfo := &dao.JwtIssuer{
+		ServiceID:      params.ServiceID,
+		Issuer:         params.Issuer,
+		JwksURI:        params.JwksURI,
+		Audience:       params.Audience,
+		RequiredClaims: params.RequiredClaims,
+		ClockSkew:      params.ClockSkew,
+		ClaimHeader:    params.ClaimHeader,
+	}
+	if err := issuerInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// JwtIssuerUpdate godoc
+// @Summary 外部签发方更新
+// @Description 外部签发方更新
+// @Tags 外部签发方管理
+// @ID /jwt_issuer/jwt_issuer_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.JwtIssuerUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /jwt_issuer/jwt_issuer_update [post]
+func (issuer *JwtIssuerController) JwtIssuerUpdate(c *gin.Context) {
+	params := &dto.JwtIssuerUpdateInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.JwtIssuer{ID: params.ID}
+	issuerInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	issuerInfo.Issuer = params.Issuer
+	issuerInfo.JwksURI = params.JwksURI
+	issuerInfo.Audience = params.Audience
+	issuerInfo.RequiredClaims = params.RequiredClaims
+	issuerInfo.ClockSkew = params.ClockSkew
+	issuerInfo.ClaimHeader = params.ClaimHeader
+	if err := issuerInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
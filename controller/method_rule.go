@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+	"strings"
+)
+
+type MethodRuleController struct{}
+
+// MethodRuleRegister grpc方法规则路由注册
+func MethodRuleRegister(router *gin.RouterGroup) {
+	rule := MethodRuleController{}
+	router.GET("/method_rule_list", rule.MethodRuleList)
+	router.GET("/method_rule_delete", rule.MethodRuleDelete)
+	router.POST("/method_rule_add", rule.MethodRuleAdd)
+	router.POST("/method_rule_update", rule.MethodRuleUpdate)
+}
+
+// MethodRuleList godoc
+// @Summary grpc方法规则列表
+// @Description grpc方法规则列表
+// @Tags grpc方法规则管理
+// @ID /method_rule/method_rule_list
+// @Accept  json
+// @Produce  json
+// @Param service_id query string true "服务ID"
+// @Success 200 {object} middleware.Response{data=dto.MethodRuleListOutput} "success"
+// @Router /method_rule/method_rule_list [get]
+func (rule *MethodRuleController) MethodRuleList(c *gin.Context) {
+	params := &dto.MethodRuleListInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	ruleInfo := &dao.MethodRule{}
+	list, total, err := ruleInfo.ListBYServiceID(c, tx, params.ServiceID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, dto.MethodRuleListOutput{
+		Total: total,
+		List:  list,
+	})
+	return
+}
+
+// MethodRuleDelete godoc
+// @Summary grpc方法规则删除
+// @Description grpc方法规则删除
+// @Tags grpc方法规则管理
+// @ID /method_rule/method_rule_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "规则ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /method_rule/method_rule_delete [get]
+func (rule *MethodRuleController) MethodRuleDelete(c *gin.Context) {
+	params := &dto.MethodRuleDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.MethodRule{ID: params.ID}
+	ruleInfo, err := search.FindFirst(c, tx, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 软删除
+	ruleInfo.IsDelete = 1
+	if err := ruleInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// MethodRuleAdd godoc
+// @Summary grpc方法规则添加
+// @Description 按照完整方法名或通配为grpc服务设置租户访问控制、限流、超时以及添加的metadata
+// @Tags grpc方法规则管理
+// @ID /method_rule/method_rule_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.MethodRuleAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /method_rule/method_rule_add [post]
+func (rule *MethodRuleController) MethodRuleAdd(c *gin.Context) {
+	params := &dto.MethodRuleAddInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	// 方法规则只对grpc服务生效
+	serviceSearch := &dao.ServiceInfo{ID: params.ServiceID}
+	serviceInfo, err := serviceSearch.FindFirst(c, tx, serviceSearch)
+	if err != nil || serviceInfo.IsDelete == 1 {
+		middleware.ResponseError(c, 2002, errors.New("服务不存在"))
+		return
+	}
+	if serviceInfo.LoadType != public.LoadTypeGRPC {
+		middleware.ResponseError(c, 2003, errors.New("只有grpc服务支持方法规则"))
+		return
+	}
+	// 同一服务下方法规则不能重复
+	search := &dao.MethodRule{ServiceID: params.ServiceID, Method: params.Method}
+	if _, err := search.FindFirst(c, tx.Where("is_delete=0"), search); err == nil {
+		middleware.ResponseError(c, 2004, errors.New("方法规则已存在"))
+		return
+	}
+	ruleInfo := &dao.MethodRule{
+		ServiceID:   params.ServiceID,
+		Method:      params.Method,
+		AllowApp:    trimList(params.AllowApp),
+		DenyApp:     trimList(params.DenyApp),
+		Qps:         params.Qps,
+		Timeout:     params.Timeout,
+		TimeoutMode: params.TimeoutMode,
+		AddMetadata: params.AddMetadata,
+	}
+	if err := ruleInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// MethodRuleUpdate godoc
+// @Summary grpc方法规则更新
+// @Description grpc方法规则更新
+// @Tags grpc方法规则管理
+// @ID /method_rule/method_rule_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.MethodRuleUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /method_rule/method_rule_update [post]
+func (rule *MethodRuleController) MethodRuleUpdate(c *gin.Context) {
+	params := &dto.MethodRuleUpdateInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.MethodRule{ID: params.ID}
+	ruleInfo, err := search.FindFirst(c, tx.Where("is_delete=0"), search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 同一服务下方法规则不能重复
+	methodSearch := &dao.MethodRule{ServiceID: ruleInfo.ServiceID, Method: params.Method}
+	if exist, err := methodSearch.FindFirst(c, tx.Where("is_delete=0"), methodSearch); err == nil && exist.ID != ruleInfo.ID {
+		middleware.ResponseError(c, 2003, errors.New("方法规则已存在"))
+		return
+	}
+	ruleInfo.Method = params.Method
+	ruleInfo.AllowApp = trimList(params.AllowApp)
+	ruleInfo.DenyApp = trimList(params.DenyApp)
+	ruleInfo.Qps = params.Qps
+	ruleInfo.Timeout = params.Timeout
+	ruleInfo.TimeoutMode = params.TimeoutMode
+	ruleInfo.AddMetadata = params.AddMetadata
+	if err := ruleInfo.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// trimList 去掉逗号间隔列表中的空白和空项
+func trimList(list string) string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ",")
+}
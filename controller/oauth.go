@@ -21,11 +21,13 @@ type OAuthController struct{}
 func OAuthRegister(group *gin.RouterGroup) {
 	oauth := &OAuthController{}
 	group.POST("/tokens", oauth.Tokens)
+	group.POST("/revoke", oauth.Revoke)
+	group.GET("/jwks.json", oauth.Jwks)
 }
 
 // Tokens godoc
 // @Summary 获取TOKEN
-// @Description 获取TOKEN
+// @Description 获取TOKEN，grant_type为client_credentials或者refresh_token
 // @Tags OAUTH
 // @ID /oauth/tokens
 // @Accept  json
@@ -42,56 +44,198 @@ func (oauth *OAuthController) Tokens(c *gin.Context) {
 		middleware.ResponseError(c, 2000, err)
 		return
 	}
+	// 通过Basic认证匹配租户
+	appInfo, code, err := oauthClient(c)
+	if err != nil {
+		middleware.ResponseError(c, code, err)
+		return
+	}
+
+	// 租户在服务上被授权的权限范围
+	allowedScopes := dao.ServiceManagerHandler.GetAppScopes(appInfo.AppID)
+	requestScope := params.Scope
+	if params.GrantType == "refresh_token" {
+		if params.RefreshToken == "" {
+			middleware.ResponseError(c, 2007, errors.New("refresh_token不能为空"))
+			return
+		}
+		// 刷新token只能由所属租户使用一次，使用后换发新的刷新token
+		refreshItem, err := public.RefreshTokenTake(params.RefreshToken, appInfo.AppID)
+		if err != nil {
+			middleware.ResponseError(c, 2008, err)
+			return
+		}
+		if refreshItem == nil {
+			middleware.ResponseError(c, 2009, errors.New("refresh_token无效"))
+			return
+		}
+		// 租户被删除后之前签发的刷新token失效
+		revokedAt, err := public.JwtAppRevokedAt(appInfo.AppID)
+		if err != nil {
+			middleware.ResponseError(c, 2008, err)
+			return
+		}
+		if refreshItem.IssuedAt <= revokedAt {
+			middleware.ResponseError(c, 2009, errors.New("refresh_token已被吊销"))
+			return
+		}
+		// 刷新时不能申请超出原有范围的权限
+		if requestScope == "" {
+			requestScope = refreshItem.Scope
+		}
+		originScopes := public.ScopeList(refreshItem.Scope)
+		scopes := []string{}
+		for _, scope := range allowedScopes {
+			if public.InStringSlice(originScopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		allowedScopes = scopes
+	}
+
+	// 只授予申请的且租户在服务上被授权的权限范围
+	scopes := []string{}
+	for _, scope := range public.ScopeList(requestScope) {
+		if public.InStringSlice(allowedScopes, scope) && !public.InStringSlice(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	jti, err := public.GenJwtID()
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	now := time.Now().In(lib.TimeLocation)
+	claims := public.JwtClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Issuer:    appInfo.AppID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Duration(appInfo.GetTokenExpires()) * time.Second).Unix(),
+		},
+		Scope: strings.Join(scopes, " "),
+	}
+	// 基于jwt生成Token
+	token, err := public.JwtEncode(claims)
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	refreshToken, err := public.RefreshTokenSave(&public.RefreshTokenItem{
+		AppID:    appInfo.AppID,
+		Scope:    claims.Scope,
+		IssuedAt: now.Unix(),
+	}, appInfo.GetRefreshExpires())
+	if err != nil {
+		middleware.ResponseError(c, 2008, err)
+		return
+	}
+	output := &dto.TokensOutput{
+		ExpiresIn:    int(appInfo.GetTokenExpires()),
+		TokenType:    "Bearer",
+		AccessToken:  token,
+		Scope:        claims.Scope,
+		RefreshToken: refreshToken,
+	}
+	middleware.ResponseSuccess(c, output)
+}
 
+// Revoke godoc
+// @Summary 吊销TOKEN
+// @Description 按照RFC 7009吊销access_token或者refresh_token，token无效时同样返回成功
+// @Tags OAUTH
+// @ID /oauth/revoke
+// @Accept  json
+// @Produce  json
+// @Param body body dto.RevokeInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /oauth/revoke [post]
+func (oauth *OAuthController) Revoke(c *gin.Context) {
+	params := &dto.RevokeInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	appInfo, code, err := oauthClient(c)
+	if err != nil {
+		middleware.ResponseError(c, code, err)
+		return
+	}
+	// 先按照提示的类型查找，找不到再尝试另一种类型
+	if params.TokenTypeHint != "access_token" {
+		refreshItem, err := public.RefreshTokenGet(params.Token)
+		if err != nil {
+			middleware.ResponseError(c, 2008, err)
+			return
+		}
+		if refreshItem != nil {
+			// 只能吊销属于自己的token
+			if refreshItem.AppID == appInfo.AppID {
+				if err := public.RefreshTokenRevoke(params.Token); err != nil {
+					middleware.ResponseError(c, 2008, err)
+					return
+				}
+			}
+			middleware.ResponseSuccess(c, "")
+			return
+		}
+	}
+	if claims, err := public.JwtDecode(params.Token); err == nil && claims.Issuer == appInfo.AppID {
+		if err := public.JwtDeny(claims.Id, claims.ExpiresAt); err != nil {
+			middleware.ResponseError(c, 2008, err)
+			return
+		}
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+// oauthClient 通过Basic认证头中的app_id和secret匹配租户
+func oauthClient(c *gin.Context) (*dao.App, middleware.ResponseCode, error) {
 	splits := strings.Split(c.GetHeader("Authorization"), " ")
 	if len(splits) != 2 {
-		middleware.ResponseError(c, 2001, errors.New("用户名或密码格式错误"))
-		return
+		return nil, 2001, errors.New("用户名或密码格式错误")
 	}
 
 	appSecret, err := base64.StdEncoding.DecodeString(splits[1])
 	if err != nil {
-		middleware.ResponseError(c, 2002, err)
-		return
+		return nil, 2002, err
 	}
-	fmt.Println("appSecret", string(appSecret))
 
 	// 先取出app_id secret
 	// 生成app_list
 	// 匹配app_id
-	// 基于jwt生成Token
-	// 生成output
-
 	parts := strings.Split(string(appSecret), ":")
 	if len(parts) != 2 {
-		middleware.ResponseError(c, 2003, errors.New("用户名或密码格式错误"))
-		return
+		return nil, 2003, errors.New("用户名或密码格式错误")
 	}
 
-	// 生成app_list,获取租户信息列表
-	appList := dao.AppManagerHandler.GetAppList()
-	for _, appInfo := range appList {
-		// 匹配app_id
-		if appInfo.AppID == parts[0] && appInfo.Secret == parts[1] {
-			claims := jwt.StandardClaims{
-				Issuer:    appInfo.AppID,
-				ExpiresAt: time.Now().Add(public.JwtExpires * time.Second).In(lib.TimeLocation).Unix(),
-			}
-			// 基于jwt生成Token
-			token, err := public.JwtEncode(claims)
-			if err != nil {
-				middleware.ResponseError(c, 2004, err)
-				return
-			}
-			output := &dto.TokensOutput{
-				ExpiresIn:   public.JwtExpires,
-				TokenType:   "Bearer",
-				AccessToken: token,
-				Scope:       "read_write",
-			}
-			middleware.ResponseSuccess(c, output)
+	// 从数据库确认租户未被删除，已删除的租户不能再获取token
+	appInfo, err := dao.AppManagerHandler.ResolveActiveApp(parts[0])
+	if err != nil || appInfo.Secret != parts[1] {
+		return nil, 2005, errors.New("未匹配正确APP信息")
+	}
+	return appInfo, 0, nil
+}
+
+// Jwks godoc
+// @Summary 获取JWT验签公钥
+// @Description 以JWKS格式返回当前及过渡期内的验签公钥，供后端服务自行验证token
+// @Tags OAUTH
+// @ID /oauth/jwks.json
+// @Produce  json
+// @Success 200 {object} public.JSONWebKeySet "success"
+// @Router /oauth/jwks.json [get]
+func (oauth *OAuthController) Jwks(c *gin.Context) {
+	keySet := &public.JSONWebKeySet{Keys: []public.JSONWebKey{}}
+	for _, keyItem := range public.JwtKeyRingHandler.GetVerifyKeyList() {
+		jwk, err := public.NewJSONWebKey(keyItem.Kid, keyItem.Algorithm, keyItem.PublicKey)
+		if err != nil {
+			middleware.ResponseError(c, 2006, err)
 			return
 		}
+		keySet.Keys = append(keySet.Keys, *jwk)
 	}
-	middleware.ResponseError(c, 2005, errors.New("未匹配正确APP信息"))
+	// JWKS需要按照标准格式直接输出，不能包装在Response中
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", public.JwtKeyCheckInterval))
+	c.JSON(200, keySet)
 }
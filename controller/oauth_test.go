@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTokensRejectDeletedApp(t *testing.T) {
+	appInfo := &dao.App{AppID: "app_id_a", Secret: "secret_a"}
+	manager := dao.NewAppManager()
+	manager.AppMap[appInfo.AppID] = appInfo
+	manager.AppSlice = append(manager.AppSlice, appInfo)
+	// 管理后台已经删除租户，代理内存中仍保留着删除前的租户信息
+	manager.LoadApp = func(appID string) (*dao.App, error) {
+		deleted := *appInfo
+		deleted.IsDelete = 1
+		return &deleted, nil
+	}
+	oldManager := dao.AppManagerHandler
+	dao.AppManagerHandler = manager
+	defer func() { dao.AppManagerHandler = oldManager }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.TranslationMiddleware())
+	OAuthRegister(router.Group("/oauth"))
+	for _, body := range []string{"grant_type=client_credentials", "grant_type=refresh_token&refresh_token=token_a"} {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/tokens", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("app_id_a:secret_a")))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		resp := &middleware.Response{}
+		if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.ErrorCode != 2005 {
+			t.Fatalf("%s: errno = %d, want 2005 for deleted app", body, resp.ErrorCode)
+		}
+	}
+	if manager.GetApp("app_id_a") != nil || len(manager.GetAppList()) != 0 {
+		t.Fatal("deleted app should be removed from memory")
+	}
+}
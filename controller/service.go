@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/e421083458/golang_common/lib"
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,10 @@ import (
 	"github.com/zhj/go_gateway/dto"
 	"github.com/zhj/go_gateway/middleware"
 	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/tcp_proxy_middleware"
+	"google.golang.org/grpc/codes"
+	"gorm.io/gorm"
+	"net"
 	"strings"
 	"time"
 )
@@ -29,6 +34,12 @@ func ServiceRegister(group *gin.RouterGroup) {
 	group.POST("/service_update_tcp", service.ServiceUpdateTcp)
 	group.POST("/service_add_grpc", service.ServiceAddGrpc)
 	group.POST("/service_update_grpc", service.ServiceUpdateGrpc)
+	group.POST("/service_add_udp", service.ServiceAddUdp)
+	group.POST("/service_update_udp", service.ServiceUpdateUdp)
+
+	group.POST("/service_descriptor_upload", service.ServiceDescriptorUpload)
+	group.GET("/service_descriptor_detail", service.ServiceDescriptorDetail)
+	group.GET("/service_descriptor_delete", service.ServiceDescriptorDelete)
 }
 
 // ServiceList godoc
@@ -115,6 +126,10 @@ func (service *ServiceController) ServiceList(c *gin.Context) {
 		if serviceDetail.Info.LoadType == public.LoadTypeGRPC {
 			serviceAddr = fmt.Sprintf("%s:%d", clusterIP, serviceDetail.GRPCRule.Port)
 		}
+		// udp类型则用clusterIP+servicePort生成服务地址
+		if serviceDetail.Info.LoadType == public.LoadTypeUDP {
+			serviceAddr = fmt.Sprintf("%s:%d", clusterIP, serviceDetail.UDPRule.Port)
+		}
 		// 获取所有的ip列表
 		ipList := serviceDetail.LoadBalance.GetIPListByModel()
 		// 获取服务计数器
@@ -209,6 +224,18 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		middleware.ResponseError(c, 2000, err)
 		return
 	}
+	if err := checkClientCA(params.ClientVerify, params.ClientCA); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamTls(params.UpstreamCert, params.UpstreamKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamProtocol(params.UpstreamProtocol, dao.HTTPUpstreamTls(params.UpstreamTls, params.NeedHttps)); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
 
 	// ip列表数量与权重列表数量要相同
 	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
@@ -269,6 +296,7 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		NeedWebsocket:  params.NeedWebsocket,
 		UrlRewrite:     params.UrlRewrite,
 		HeaderTransfor: params.HeaderTransfor,
+		GrpcTranscode:  params.GrpcTranscode,
 	}
 	// 将httpRule信息数据表保存到数据库中
 	if err = httpRule.Save(c, tx); err != nil {
@@ -282,8 +310,12 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 	accessControl := &dao.AccessControl{
 		ServiceID:         serviceModel.ID,
 		OpenAuth:          params.OpenAuth,
+		AuthType:          params.AuthType,
 		BlackList:         params.BlackList,
 		WhiteList:         params.WhiteList,
+		ClientCA:          params.ClientCA,
+		ClientVerify:      params.ClientVerify,
+		WhiteCertList:     params.WhiteCertList,
 		ClientIPFlowLimit: params.ClientipFlowLimit,
 		ServiceFlowLimit:  params.ServiceFlowLimit,
 	}
@@ -305,6 +337,15 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		UpstreamConnectTimeout: params.UpstreamConnectTimeout,
 		UpstreamIdleTimeout:    params.UpstreamIdleTimeout,
 		UpstreamMaxIdle:        params.UpstreamMaxIdle,
+		UpstreamProtocol:       params.UpstreamProtocol,
+		UpstreamMaxConns:       params.UpstreamMaxConns,
+		UpstreamStrictStreams:  params.UpstreamStrictStreams,
+		UpstreamTls:            params.UpstreamTls,
+		UpstreamCA:             params.UpstreamCA,
+		UpstreamSkipVerify:     params.UpstreamSkipVerify,
+		UpstreamCert:           params.UpstreamCert,
+		UpstreamKey:            params.UpstreamKey,
+		UpstreamServerName:     params.UpstreamServerName,
 	}
 	// 将负载均衡信息数据表保存到数据库中
 	if err = loadBalance.Save(c, tx); err != nil {
@@ -337,6 +378,18 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 		middleware.ResponseError(c, 2000, err)
 		return
 	}
+	if err := checkClientCA(params.ClientVerify, params.ClientCA); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamTls(params.UpstreamCert, params.UpstreamKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamProtocol(params.UpstreamProtocol, dao.HTTPUpstreamTls(params.UpstreamTls, params.NeedHttps)); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
 
 	// ip列表数量与权重列表数量要相同
 	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
@@ -383,6 +436,15 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 		return
 	}
 
+	// 开启gRPC转码时已上传的描述文件需要能生成转码路由
+	if params.GrpcTranscode == 1 && serviceDetail.HTTPRule.GrpcTranscode != 1 {
+		if err = checkGrpcTranscode(c, tx, info.ID); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2006, err)
+			return
+		}
+	}
+
 	// 更新httpRule信息并保存到数据库中
 	httpRule := serviceDetail.HTTPRule
 	httpRule.NeedHttps = params.NeedHttps
@@ -390,6 +452,7 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 	httpRule.NeedWebsocket = params.NeedWebsocket
 	httpRule.UrlRewrite = params.UrlRewrite
 	httpRule.HeaderTransfor = params.HeaderTransfor
+	httpRule.GrpcTranscode = params.GrpcTranscode
 	if err = httpRule.Save(c, tx); err != nil {
 		// 出现err就回滚事务
 		tx.Rollback()
@@ -401,8 +464,12 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 	// 更新权限控制信息并保存到数据库中
 	accessControl := serviceDetail.AccessControl
 	accessControl.OpenAuth = params.OpenAuth
+	accessControl.AuthType = params.AuthType
 	accessControl.BlackList = params.BlackList
 	accessControl.WhiteList = params.WhiteList
+	accessControl.ClientCA = params.ClientCA
+	accessControl.ClientVerify = params.ClientVerify
+	accessControl.WhiteCertList = params.WhiteCertList
 	accessControl.ClientIPFlowLimit = params.ClientipFlowLimit
 	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
 	if err = accessControl.Save(c, tx); err != nil {
@@ -422,6 +489,15 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 	loadBalance.UpstreamHeaderTimeout = params.UpstreamHeaderTimeout
 	loadBalance.UpstreamIdleTimeout = params.UpstreamIdleTimeout
 	loadBalance.UpstreamMaxIdle = params.UpstreamMaxIdle
+	loadBalance.UpstreamProtocol = params.UpstreamProtocol
+	loadBalance.UpstreamMaxConns = params.UpstreamMaxConns
+	loadBalance.UpstreamStrictStreams = params.UpstreamStrictStreams
+	loadBalance.UpstreamTls = params.UpstreamTls
+	loadBalance.UpstreamCA = params.UpstreamCA
+	loadBalance.UpstreamSkipVerify = params.UpstreamSkipVerify
+	loadBalance.UpstreamCert = params.UpstreamCert
+	loadBalance.UpstreamKey = params.UpstreamKey
+	loadBalance.UpstreamServerName = params.UpstreamServerName
 	if err = loadBalance.Save(c, tx); err != nil {
 		// 出现err就回滚事务
 		tx.Rollback()
@@ -541,10 +617,64 @@ func (service *ServiceController) ServiceStat(c *gin.Context) {
 		todayList = append(todayList, hourData)
 	}
 
-	middleware.ResponseSuccess(c, dto.ServiceStatOutput{
+	output := dto.ServiceStatOutput{
 		Today:     todayList,
 		Yesterday: yesterdayList,
-	})
+	}
+	// TCP服务开启TLS时统计今天的握手失败次数和平均握手耗时
+	if serviceDetail.Info.LoadType == public.LoadTypeTCP && serviceDetail.TCPRule.OpenTls() {
+		errCounter, err := public.FlowCounterHandler.GetCounter(public.FlowTlsErrorPrefix + serviceDetail.Info.ServiceName)
+		if err != nil {
+			middleware.ResponseError(c, 2005, err)
+			return
+		}
+		handshakeStat := &public.TLSHandshakeStat{ServiceName: serviceDetail.Info.ServiceName}
+		for i := 0; i <= currentTime.Hour(); i++ {
+			dateTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+			errData, _ := errCounter.GetHourData(dateTime)
+			output.TLSErrorToday = append(output.TLSErrorToday, errData)
+			avgMs, _ := handshakeStat.GetHourAvgMs(dateTime)
+			output.TLSHandshakeToday = append(output.TLSHandshakeToday, avgMs)
+		}
+	}
+	// TCP服务统计今天每小时的连接、流量和拨号情况，以及各下游今天的汇总和当前并发连接数
+	if serviceDetail.Info.LoadType == public.LoadTypeTCP {
+		connStat := &public.TcpConnStat{Name: serviceDetail.Info.ServiceName}
+		output.TcpActiveConns, _ = connStat.GetActiveData(currentTime)
+		for i := 0; i <= currentTime.Hour(); i++ {
+			dateTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+			hourData, err := connStat.GetHourData(dateTime)
+			if err != nil {
+				hourData = &public.TcpConnStatData{}
+			}
+			output.TcpConnToday = append(output.TcpConnToday, hourData)
+		}
+		for _, addr := range serviceDetail.LoadBalance.GetIPListByModel() {
+			backendStat := &public.TcpConnStat{Name: public.TcpConnStatName(serviceDetail.Info.ServiceName, addr)}
+			backendOutput := &dto.TcpBackendStatOutput{Addr: addr}
+			backendOutput.ActiveConns, _ = backendStat.GetActiveData(currentTime)
+			if backendOutput.Today, err = backendStat.GetDayData(currentTime); err != nil {
+				backendOutput.Today = &public.TcpConnStatData{}
+			}
+			output.TcpBackends = append(output.TcpBackends, backendOutput)
+		}
+	}
+	// gRPC服务统计今天按状态码分组的错误数，包括网关拒绝和下游返回的错误
+	if serviceDetail.Info.LoadType == public.LoadTypeGRPC {
+		output.GrpcErrorToday = map[string]int64{}
+		for code := codes.Canceled; code <= codes.Unauthenticated; code++ {
+			errCounter, err := public.FlowCounterHandler.GetCounter(
+				public.FlowGrpcErrorPrefix + serviceDetail.Info.ServiceName + "_" + code.String())
+			if err != nil {
+				middleware.ResponseError(c, 2006, err)
+				return
+			}
+			if dayData, _ := errCounter.GetDayData(currentTime); dayData > 0 {
+				output.GrpcErrorToday[code.String()] = dayData
+			}
+		}
+	}
+	middleware.ResponseSuccess(c, output)
 }
 
 // ServiceAddTcp godoc
@@ -566,6 +696,34 @@ func (service *ServiceController) ServiceAddTcp(c *gin.Context) {
 		middleware.ResponseError(c, 2000, err)
 		return
 	}
+	if err := checkClientCA(params.ClientVerify, params.ClientCA); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamTls(params.UpstreamCert, params.UpstreamKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpTls(params.NeedTls, params.TlsCert, params.TlsKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpPort(params.Port, params.SniHost, params.SniDefault); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpSni(params.SniHost, params.SniDefault, params.NeedTls, params.UpstreamTls, params.ClientVerify, params.Protocol); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkRedisProtocol(params.Protocol, params.RedisReplicaList); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpRoutes(params.Protocol, params.RouteRule); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
 
 	// 从数据库中读取ServiceInfo
 	// 获取数据库连接池
@@ -585,22 +743,29 @@ func (service *ServiceController) ServiceAddTcp(c *gin.Context) {
 		return
 	}
 
-	// 验证端口是否被占用
-	// 首先验证tcp中是否有该端口
-	tcpRuleSearch := &dao.TcpRule{
-		Port: params.Port,
-	}
-	if _, err = tcpRuleSearch.FindFirst(c, tx, tcpRuleSearch); err == nil {
-		middleware.ResponseError(c, 2003, errors.New("服务端口被占用，请重新输入"))
-		return
-	}
-	// 再验证grpc中是否有该端口
-	grpcRuleSearch := &dao.GrpcRule{
-		Port: params.Port,
-	}
-	if _, err = grpcRuleSearch.FindFirst(c, tx, grpcRuleSearch); err == nil {
-		middleware.ResponseError(c, 2004, errors.New("服务端口被占用，请重新输入"))
-		return
+	// 验证端口是否被占用，只接入SNI路由的服务没有独立端口
+	if params.Port > 0 {
+		// 首先验证tcp中是否有该端口
+		tcpRuleSearch := &dao.TcpRule{
+			Port: params.Port,
+		}
+		if _, err = tcpRuleSearch.FindFirst(c, tx, tcpRuleSearch); err == nil {
+			middleware.ResponseError(c, 2003, errors.New("服务端口被占用，请重新输入"))
+			return
+		}
+		// 再验证grpc中是否有该端口
+		grpcRuleSearch := &dao.GrpcRule{
+			Port: params.Port,
+		}
+		if _, err = grpcRuleSearch.FindFirst(c, tx, grpcRuleSearch); err == nil {
+			middleware.ResponseError(c, 2004, errors.New("服务端口被占用，请重新输入"))
+			return
+		}
+		// 最后验证是否为gRPC服务的gRPC-Web端口
+		if err = checkGrpcWebPortFree(c, tx, params.Port); err != nil {
+			middleware.ResponseError(c, 2004, err)
+			return
+		}
 	}
 
 	// ip列表数量与权重列表数量要相同
@@ -628,11 +793,17 @@ func (service *ServiceController) ServiceAddTcp(c *gin.Context) {
 
 	// 创建负载均衡信息数据表
 	loadBalance := &dao.LoadBalance{
-		ServiceID:  info.ID,
-		RoundType:  params.RoundType,
-		IpList:     params.IpList,
-		WeightList: params.WeightList,
-		ForbidList: params.ForbidList,
+		ServiceID:          info.ID,
+		RoundType:          params.RoundType,
+		IpList:             params.IpList,
+		WeightList:         params.WeightList,
+		ForbidList:         params.ForbidList,
+		UpstreamTls:        params.UpstreamTls,
+		UpstreamCA:         params.UpstreamCA,
+		UpstreamSkipVerify: params.UpstreamSkipVerify,
+		UpstreamCert:       params.UpstreamCert,
+		UpstreamKey:        params.UpstreamKey,
+		UpstreamServerName: params.UpstreamServerName,
 	}
 	// 将负载均衡信息数据表保存到数据库中
 	if err = loadBalance.Save(c, tx); err != nil {
@@ -644,8 +815,27 @@ func (service *ServiceController) ServiceAddTcp(c *gin.Context) {
 
 	// 创建tcpRule信息数据表
 	tcpRule := &dao.TcpRule{
-		ServiceID: info.ID,
-		Port:      params.Port,
+		ServiceID:     info.ID,
+		Port:          params.Port,
+		NeedTls:       params.NeedTls,
+		TlsCert:       params.TlsCert,
+		TlsKey:        params.TlsKey,
+		TlsMinVersion: params.TlsMinVersion,
+		TlsCiphers:    params.TlsCiphers,
+		TlsAlpn:       params.TlsAlpn,
+		SniHost:       params.SniHost,
+		SniDefault:    params.SniDefault,
+
+		Protocol:           params.Protocol,
+		RedisAllowCommands: params.RedisAllowCommands,
+		RedisDenyCommands:  params.RedisDenyCommands,
+		RedisReplicaList:   params.RedisReplicaList,
+
+		IdleTimeout: params.IdleTimeout,
+		MaxLifetime: params.MaxLifetime,
+		MaxConns:    params.MaxConns,
+
+		RouteRule: params.RouteRule,
 	}
 	// 将tcpRule信息数据表保存到数据库中
 	if err = tcpRule.Save(c, tx); err != nil {
@@ -660,7 +850,11 @@ func (service *ServiceController) ServiceAddTcp(c *gin.Context) {
 		OpenAuth:          params.OpenAuth,
 		BlackList:         params.BlackList,
 		WhiteList:         params.WhiteList,
+		ClientCA:          params.ClientCA,
+		ClientVerify:      params.ClientVerify,
+		WhiteCertList:     params.WhiteCertList,
 		WhiteHostName:     params.WhiteHostName,
+		BlackHostName:     params.BlackHostName,
 		ClientIPFlowLimit: params.ClientIPFlowLimit,
 		ServiceFlowLimit:  params.ServiceFlowLimit,
 	}
@@ -695,6 +889,34 @@ func (service *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 		middleware.ResponseError(c, 2000, err)
 		return
 	}
+	if err := checkClientCA(params.ClientVerify, params.ClientCA); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamTls(params.UpstreamCert, params.UpstreamKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpTls(params.NeedTls, params.TlsCert, params.TlsKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpPort(params.Port, params.SniHost, params.SniDefault); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpSni(params.SniHost, params.SniDefault, params.NeedTls, params.UpstreamTls, params.ClientVerify, params.Protocol); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkRedisProtocol(params.Protocol, params.RedisReplicaList); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkTcpRoutes(params.Protocol, params.RouteRule); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
 
 	// ip列表数量与权重列表数量要相同
 	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
@@ -710,6 +932,12 @@ func (service *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 		return
 	}
 
+	// 验证端口是否被gRPC服务的gRPC-Web端口占用
+	if err = checkGrpcWebPortFree(c, tx, params.Port); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+
 	// 开启数据库事务
 	tx = tx.Begin()
 	// 通过服务ID查询数据库
@@ -741,6 +969,22 @@ func (service *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	}
 	tcpRule.ServiceID = info.ID
 	tcpRule.Port = params.Port
+	tcpRule.NeedTls = params.NeedTls
+	tcpRule.TlsCert = params.TlsCert
+	tcpRule.TlsKey = params.TlsKey
+	tcpRule.TlsMinVersion = params.TlsMinVersion
+	tcpRule.TlsCiphers = params.TlsCiphers
+	tcpRule.TlsAlpn = params.TlsAlpn
+	tcpRule.SniHost = params.SniHost
+	tcpRule.SniDefault = params.SniDefault
+	tcpRule.Protocol = params.Protocol
+	tcpRule.RedisAllowCommands = params.RedisAllowCommands
+	tcpRule.RedisDenyCommands = params.RedisDenyCommands
+	tcpRule.RedisReplicaList = params.RedisReplicaList
+	tcpRule.IdleTimeout = params.IdleTimeout
+	tcpRule.MaxLifetime = params.MaxLifetime
+	tcpRule.MaxConns = params.MaxConns
+	tcpRule.RouteRule = params.RouteRule
 	if err = tcpRule.Save(c, tx); err != nil {
 		// 出现err就回滚事务
 		tx.Rollback()
@@ -756,7 +1000,11 @@ func (service *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	accessControl.OpenAuth = params.OpenAuth
 	accessControl.BlackList = params.BlackList
 	accessControl.WhiteList = params.WhiteList
+	accessControl.ClientCA = params.ClientCA
+	accessControl.ClientVerify = params.ClientVerify
+	accessControl.WhiteCertList = params.WhiteCertList
 	accessControl.WhiteHostName = params.WhiteHostName
+	accessControl.BlackHostName = params.BlackHostName
 	accessControl.ClientIPFlowLimit = params.ClientIPFlowLimit
 	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
 	if err = accessControl.Save(c, tx); err != nil {
@@ -776,6 +1024,12 @@ func (service *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	loadBalance.IpList = params.IpList
 	loadBalance.WeightList = params.WeightList
 	loadBalance.ForbidList = params.ForbidList
+	loadBalance.UpstreamTls = params.UpstreamTls
+	loadBalance.UpstreamCA = params.UpstreamCA
+	loadBalance.UpstreamSkipVerify = params.UpstreamSkipVerify
+	loadBalance.UpstreamCert = params.UpstreamCert
+	loadBalance.UpstreamKey = params.UpstreamKey
+	loadBalance.UpstreamServerName = params.UpstreamServerName
 	if err = loadBalance.Save(c, tx); err != nil {
 		// 出现err就回滚事务
 		tx.Rollback()
@@ -807,6 +1061,14 @@ func (service *ServiceController) ServiceAddGrpc(c *gin.Context) {
 		middleware.ResponseError(c, 2000, err)
 		return
 	}
+	if err := checkClientCA(params.ClientVerify, params.ClientCA); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamTls(params.UpstreamCert, params.UpstreamKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
 
 	// 从数据库中读取ServiceInfo
 	// 获取数据库连接池
@@ -843,6 +1105,10 @@ func (service *ServiceController) ServiceAddGrpc(c *gin.Context) {
 		middleware.ResponseError(c, 2004, errors.New("服务端口被占用，请重新输入"))
 		return
 	}
+	if err := checkGrpcWebPort(c, tx, 0, params.Port, params.WebPort); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
 
 	// ip列表数量与权重列表数量要相同
 	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
@@ -869,11 +1135,17 @@ func (service *ServiceController) ServiceAddGrpc(c *gin.Context) {
 
 	// 创建负载均衡信息数据表
 	loadBalance := &dao.LoadBalance{
-		ServiceID:  info.ID,
-		RoundType:  params.RoundType,
-		IpList:     params.IpList,
-		WeightList: params.WeightList,
-		ForbidList: params.ForbidList,
+		ServiceID:          info.ID,
+		RoundType:          params.RoundType,
+		IpList:             params.IpList,
+		WeightList:         params.WeightList,
+		ForbidList:         params.ForbidList,
+		UpstreamTls:        params.UpstreamTls,
+		UpstreamCA:         params.UpstreamCA,
+		UpstreamSkipVerify: params.UpstreamSkipVerify,
+		UpstreamCert:       params.UpstreamCert,
+		UpstreamKey:        params.UpstreamKey,
+		UpstreamServerName: params.UpstreamServerName,
 	}
 	// 将负载均衡信息数据表保存到数据库中
 	if err = loadBalance.Save(c, tx); err != nil {
@@ -888,6 +1160,9 @@ func (service *ServiceController) ServiceAddGrpc(c *gin.Context) {
 		ServiceID:      info.ID,
 		Port:           params.Port,
 		HeaderTransfor: params.HeaderTransfor,
+		WebPort:        params.WebPort,
+		WebCorsOrigin:  params.WebCorsOrigin,
+		ReflectionMode: params.ReflectionMode,
 	}
 	// 将grpcRule信息数据表保存到数据库中
 	if err = grpcRule.Save(c, tx); err != nil {
@@ -900,8 +1175,12 @@ func (service *ServiceController) ServiceAddGrpc(c *gin.Context) {
 	accessControl := &dao.AccessControl{
 		ServiceID:         info.ID,
 		OpenAuth:          params.OpenAuth,
+		AuthType:          params.AuthType,
 		BlackList:         params.BlackList,
 		WhiteList:         params.WhiteList,
+		ClientCA:          params.ClientCA,
+		ClientVerify:      params.ClientVerify,
+		WhiteCertList:     params.WhiteCertList,
 		WhiteHostName:     params.WhiteHostName,
 		ClientIPFlowLimit: params.ClientIPFlowLimit,
 		ServiceFlowLimit:  params.ServiceFlowLimit,
@@ -937,6 +1216,14 @@ func (service *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 		middleware.ResponseError(c, 2000, err)
 		return
 	}
+	if err := checkClientCA(params.ClientVerify, params.ClientCA); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	if err := checkUpstreamTls(params.UpstreamCert, params.UpstreamKey); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
 
 	// ip列表数量与权重列表数量要相同
 	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
@@ -981,9 +1268,17 @@ func (service *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	if serviceDetail.GRPCRule != nil {
 		grpcRule = serviceDetail.GRPCRule
 	}
+	if err := checkGrpcWebPort(c, tx, info.ID, params.Port, params.WebPort); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
 	grpcRule.ServiceID = info.ID
 	grpcRule.Port = params.Port
 	grpcRule.HeaderTransfor = params.HeaderTransfor
+	grpcRule.WebPort = params.WebPort
+	grpcRule.WebCorsOrigin = params.WebCorsOrigin
+	grpcRule.ReflectionMode = params.ReflectionMode
 	if err = grpcRule.Save(c, tx); err != nil {
 		// 出现err就回滚事务
 		tx.Rollback()
@@ -997,8 +1292,12 @@ func (service *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	}
 	accessControl.ServiceID = info.ID
 	accessControl.OpenAuth = params.OpenAuth
+	accessControl.AuthType = params.AuthType
 	accessControl.BlackList = params.BlackList
 	accessControl.WhiteList = params.WhiteList
+	accessControl.ClientCA = params.ClientCA
+	accessControl.ClientVerify = params.ClientVerify
+	accessControl.WhiteCertList = params.WhiteCertList
 	accessControl.WhiteHostName = params.WhiteHostName
 	accessControl.ClientIPFlowLimit = params.ClientIPFlowLimit
 	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
@@ -1019,14 +1318,414 @@ func (service *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	loadBalance.IpList = params.IpList
 	loadBalance.WeightList = params.WeightList
 	loadBalance.ForbidList = params.ForbidList
+	loadBalance.UpstreamTls = params.UpstreamTls
+	loadBalance.UpstreamCA = params.UpstreamCA
+	loadBalance.UpstreamSkipVerify = params.UpstreamSkipVerify
+	loadBalance.UpstreamCert = params.UpstreamCert
+	loadBalance.UpstreamKey = params.UpstreamKey
+	loadBalance.UpstreamServerName = params.UpstreamServerName
+	if err = loadBalance.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+
+	// 提交事务，数据入库
+	tx.Commit()
+	middleware.ResponseSuccess(c, "")
+}
+
+// ServiceAddUdp godoc
+// @Summary UDP服务添加
+// @Description UDP服务添加
+// @Tags 服务管理
+// @ID /service/service_add_udp
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceAddUdpInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_add_udp [post]
+func (service *ServiceController) ServiceAddUdp(c *gin.Context) {
+	// 获取添加UDP服务时输入的各项参数
+	params := &dto.ServiceAddUdpInput{}
+	// 验证参数正确性
+	if err := params.BindValidParam(c); err != nil {
+		// 参数不正确返回错误信息并终止执行
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	// 从数据库中读取ServiceInfo
+	// 获取数据库连接池
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+
+	infoSearch := &dao.ServiceInfo{
+		ServiceName: params.ServiceName,
+		IsDelete:    0,
+	}
+	// 验证serviceName是否被占用过
+	if _, err = infoSearch.FindFirst(c, tx, infoSearch); err == nil {
+		middleware.ResponseError(c, 2002, errors.New("服务名被占用，请重新输入"))
+		return
+	}
+
+	// 验证端口是否被占用，UDP端口与TCP端口互不冲突，只需要在UDP服务之间唯一
+	if err = checkUdpPort(c, tx, 0, params.Port); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	if err = checkGrpcWebPortFree(c, tx, params.Port); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+
+	// ip列表数量与权重列表数量要相同
+	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
+		middleware.ResponseError(c, 2004, errors.New("IP列表与权重列表数量不一致"))
+		return
+	}
+
+	// 开启数据库事务
+	tx = tx.Begin()
+
+	// 创建服务信息数据表
+	info := &dao.ServiceInfo{
+		LoadType:    public.LoadTypeUDP,
+		ServiceName: params.ServiceName,
+		ServiceDesc: params.ServiceDesc,
+	}
+	// 将服务信息数据表保存到数据库中
+	if err = info.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+
+	// 创建负载均衡信息数据表
+	loadBalance := &dao.LoadBalance{
+		ServiceID:  info.ID,
+		RoundType:  params.RoundType,
+		IpList:     params.IpList,
+		WeightList: params.WeightList,
+		ForbidList: params.ForbidList,
+	}
+	// 将负载均衡信息数据表保存到数据库中
 	if err = loadBalance.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+
+	// 创建udpRule信息数据表
+	udpRule := &dao.UdpRule{
+		ServiceID:   info.ID,
+		Port:        params.Port,
+		IdleTimeout: params.IdleTimeout,
+	}
+	// 将udpRule信息数据表保存到数据库中
+	if err = udpRule.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+	// 创建权限控制信息数据表
+	accessControl := &dao.AccessControl{
+		ServiceID:         info.ID,
+		OpenAuth:          params.OpenAuth,
+		BlackList:         params.BlackList,
+		WhiteList:         params.WhiteList,
+		ClientIPFlowLimit: params.ClientIPFlowLimit,
+		ServiceFlowLimit:  params.ServiceFlowLimit,
+	}
+	// 将权限控制信息数据表保存到数据库中
+	if err = accessControl.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2008, err)
+		return
+	}
+	// 提交事务，数据入库
+	tx.Commit()
+	middleware.ResponseSuccess(c, "")
+}
+
+// ServiceUpdateUdp godoc
+// @Summary 修改UDP服务
+// @Description 修改UDP服务
+// @Tags 服务管理
+// @ID /service/service_update_udp
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceUpdateUdpInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_update_udp [post]
+func (service *ServiceController) ServiceUpdateUdp(c *gin.Context) {
+	// 获取修改UDP服务时输入的各项参数
+	params := &dto.ServiceUpdateUdpInput{}
+	// 验证参数正确性
+	if err := params.BindValidParam(c); err != nil {
+		// 参数不正确返回错误信息并终止执行
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	// ip列表数量与权重列表数量要相同
+	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
+		middleware.ResponseError(c, 2001, errors.New("IP列表与权重列表数量不一致"))
+		return
+	}
+
+	//从数据库中读取ServiceInfo
+	//获取数据库连接池
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+
+	// 验证端口是否被其他UDP服务占用
+	if err = checkUdpPort(c, tx, params.ID, params.Port); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	if err = checkGrpcWebPortFree(c, tx, params.Port); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+
+	// 开启数据库事务
+	tx = tx.Begin()
+	// 通过服务ID查询数据库
+	serviceInfo := &dao.ServiceInfo{ID: params.ID}
+
+	//获取服务详细信息
+	serviceDetail, err := serviceInfo.ServiceDetail(c, tx, serviceInfo)
+	if err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+
+	// 将更新后的服务信息保存到数据库中
+	info := serviceDetail.Info
+	info.ServiceDesc = params.ServiceDesc
+	if err = info.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+
+	// 更新udpRule信息并保存到数据库中
+	udpRule := &dao.UdpRule{}
+	if serviceDetail.UDPRule != nil {
+		udpRule = serviceDetail.UDPRule
+	}
+	udpRule.ServiceID = info.ID
+	udpRule.Port = params.Port
+	udpRule.IdleTimeout = params.IdleTimeout
+	if err = udpRule.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+	// 更新权限控制信息并保存到数据库中
+	accessControl := &dao.AccessControl{}
+	if serviceDetail.AccessControl != nil {
+		accessControl = serviceDetail.AccessControl
+	}
+	accessControl.ServiceID = info.ID
+	accessControl.OpenAuth = params.OpenAuth
+	accessControl.BlackList = params.BlackList
+	accessControl.WhiteList = params.WhiteList
+	accessControl.ClientIPFlowLimit = params.ClientIPFlowLimit
+	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
+	if err = accessControl.Save(c, tx); err != nil {
 		// 出现err就回滚事务
 		tx.Rollback()
 		middleware.ResponseError(c, 2007, err)
 		return
 	}
 
+	// 更新负载均衡信息并保存到数据库中
+	loadBalance := &dao.LoadBalance{}
+	if serviceDetail.LoadBalance != nil {
+		loadBalance = serviceDetail.LoadBalance
+	}
+	loadBalance.ServiceID = info.ID
+	loadBalance.RoundType = params.RoundType
+	loadBalance.IpList = params.IpList
+	loadBalance.WeightList = params.WeightList
+	loadBalance.ForbidList = params.ForbidList
+	if err = loadBalance.Save(c, tx); err != nil {
+		// 出现err就回滚事务
+		tx.Rollback()
+		middleware.ResponseError(c, 2008, err)
+		return
+	}
+
 	// 提交事务，数据入库
 	tx.Commit()
 	middleware.ResponseSuccess(c, "")
 }
+
+// checkClientCA 开启客户端证书校验时必须填写CA证书包
+func checkClientCA(clientVerify int, clientCA string) error {
+	if clientVerify != public.ClientVerifyOff && clientCA == "" {
+		return errors.New("开启客户端证书校验需要填写客户端CA证书包")
+	}
+	return nil
+}
+
+// checkTcpTls 服务证书和私钥需要同时填写并且能够匹配
+func checkTcpTls(needTls int, tlsCert, tlsKey string) error {
+	if needTls != 1 || (tlsCert == "" && tlsKey == "") {
+		return nil
+	}
+	if _, err := tls.X509KeyPair([]byte(tlsCert), []byte(tlsKey)); err != nil {
+		return errors.WithMessage(err, "服务证书和私钥不匹配")
+	}
+	return nil
+}
+
+// checkUpstreamTls 连接下游的客户端证书和私钥需要同时填写并且能够匹配
+func checkUpstreamTls(upstreamCert, upstreamKey string) error {
+	if upstreamCert == "" && upstreamKey == "" {
+		return nil
+	}
+	if _, err := public.ParseCertKeyPair(upstreamCert, upstreamKey); err != nil {
+		return errors.WithMessage(err, "下游客户端证书和私钥不匹配")
+	}
+	return nil
+}
+
+// checkUpstreamProtocol HTTP/2需要通过TLS协商，h2c只能使用明文连接下游
+func checkUpstreamProtocol(protocol int, upstreamTls bool) error {
+	if protocol == public.UpstreamProtocolH2 && !upstreamTls {
+		return errors.New("下游使用HTTP/2时需要开启下游TLS，明文HTTP/2请选择h2c")
+	}
+	if protocol == public.UpstreamProtocolH2C && upstreamTls {
+		return errors.New("下游使用h2c时不能开启下游TLS")
+	}
+	return nil
+}
+
+// checkTcpSni 共享端口按照SNI透传TLS流量，不能同时终止TLS、校验客户端证书、使用TLS连接下游或者解析redis协议
+func checkTcpSni(sniHost string, sniDefault, needTls, upstreamTls, clientVerify, protocol int) error {
+	if sniHost == "" && sniDefault != 1 {
+		return nil
+	}
+	if needTls == 1 {
+		return errors.New("SNI透传的服务不能开启TLS终止")
+	}
+	if upstreamTls == 1 {
+		return errors.New("SNI透传的服务不能开启下游TLS")
+	}
+	// 共享端口不终止TLS，无法校验客户端证书
+	if clientVerify != public.ClientVerifyOff {
+		return errors.New("SNI透传的服务不能开启客户端证书校验")
+	}
+	// 共享端口转发的是TLS流量，无法按照redis协议解析
+	if protocol == public.TcpProtocolRedis {
+		return errors.New("SNI透传的服务不能使用redis协议")
+	}
+	return nil
+}
+
+// checkTcpPort 独立端口需要在8001-8999范围内，没有独立端口时必须接入SNI路由
+func checkTcpPort(port int, sniHost string, sniDefault int) error {
+	if port == 0 && sniHost == "" && sniDefault != 1 {
+		return errors.New("没有独立端口的服务需要填写SNI域名或设置为默认服务")
+	}
+	if port != 0 && (port < 8001 || port > 8999) {
+		return errors.New("端口需要设置8001-8999范围内")
+	}
+	return nil
+}
+
+// checkRedisProtocol redis从节点需要是ip:port格式，并且只在redis协议下使用
+func checkRedisProtocol(protocol int, replicaList string) error {
+	replicas := public.SplitList(replicaList)
+	if len(replicas) > 0 && protocol != public.TcpProtocolRedis {
+		return errors.New("只有redis协议的服务可以配置从节点")
+	}
+	for _, replica := range replicas {
+		if _, _, err := net.SplitHostPort(replica); err != nil {
+			return errors.WithMessage(err, "redis从节点需要设置为ip:port格式")
+		}
+	}
+	return nil
+}
+
+// checkTcpRoutes 分流规则需要能够解析，redis协议的服务不能分流，避免绕过命令控制
+func checkTcpRoutes(protocol int, routeRule string) error {
+	routes, err := (&dao.TcpRule{RouteRule: routeRule}).GetTcpRoutes()
+	if err != nil {
+		return err
+	}
+	if len(routes) > 0 && protocol == public.TcpProtocolRedis {
+		return errors.New("redis协议的服务不能配置分流规则")
+	}
+	for _, route := range routes {
+		if _, err := tcp_proxy_middleware.TcpRouteMatchers(route); err != nil {
+			return errors.WithMessage(err, "分流规则条件不正确")
+		}
+	}
+	return nil
+}
+
+// checkGrpcWebPort gRPC-Web端口需要在8001-8999范围内，且不能与gRPC端口、其他服务的端口重复
+func checkGrpcWebPort(c *gin.Context, tx *gorm.DB, serviceID int64, port, webPort int) error {
+	// 服务端口不能被其他服务的gRPC-Web端口占用
+	if exist, err := (&dao.GrpcRule{}).FindFirst(c, tx, &dao.GrpcRule{WebPort: port}); err == nil && exist.ServiceID != serviceID {
+		return errors.New("服务端口被占用，请重新输入")
+	}
+	if webPort == 0 {
+		return nil
+	}
+	if webPort < 8001 || webPort > 8999 {
+		return errors.New("gRPC-Web端口需要设置8001-8999范围内")
+	}
+	if webPort == port {
+		return errors.New("gRPC-Web端口不能与服务端口相同")
+	}
+	if _, err := (&dao.TcpRule{}).FindFirst(c, tx, &dao.TcpRule{Port: webPort}); err == nil {
+		return errors.New("gRPC-Web端口被占用，请重新输入")
+	}
+	if exist, err := (&dao.GrpcRule{}).FindFirst(c, tx, &dao.GrpcRule{Port: webPort}); err == nil && exist.ServiceID != serviceID {
+		return errors.New("gRPC-Web端口被占用，请重新输入")
+	}
+	if exist, err := (&dao.GrpcRule{}).FindFirst(c, tx, &dao.GrpcRule{WebPort: webPort}); err == nil && exist.ServiceID != serviceID {
+		return errors.New("gRPC-Web端口被占用，请重新输入")
+	}
+	return nil
+}
+
+// checkGrpcWebPortFree TCP和UDP服务的端口不能与gRPC服务的gRPC-Web端口相同
+func checkGrpcWebPortFree(c *gin.Context, tx *gorm.DB, port int) error {
+	if port == 0 {
+		return nil
+	}
+	if _, err := (&dao.GrpcRule{}).FindFirst(c, tx, &dao.GrpcRule{WebPort: port}); err == nil {
+		return errors.New("服务端口被gRPC-Web端口占用，请重新输入")
+	}
+	return nil
+}
+
+// checkUdpPort UDP端口不能被其他UDP服务占用
+func checkUdpPort(c *gin.Context, tx *gorm.DB, serviceID int64, port int) error {
+	if exist, err := (&dao.UdpRule{}).FindFirst(c, tx, &dao.UdpRule{Port: port}); err == nil && exist.ServiceID != serviceID {
+		return errors.New("服务端口被占用，请重新输入")
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"encoding/base64"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/reverse_proxy/grpc_transcode"
+	"gorm.io/gorm"
+)
+
+// ServiceDescriptorUpload godoc
+// @Summary 上传protobuf描述文件
+// @Description 上传HTTP服务的protobuf描述文件，按照google.api.http注解将REST/JSON转码为gRPC调用；gRPC服务上传后可以使用描述文件应答反射请求
+// @Tags 服务管理
+// @ID /service/service_descriptor_upload
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceDescriptorUploadInput true "body"
+// @Success 200 {object} middleware.Response{data=dto.ServiceDescriptorOutput} "success"
+// @Router /service/service_descriptor_upload [post]
+func (service *ServiceController) ServiceDescriptorUpload(c *gin.Context) {
+	params := &dto.ServiceDescriptorUploadInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	descriptorSet, err := base64.StdEncoding.DecodeString(params.DescriptorSet)
+	if err != nil {
+		middleware.ResponseError(c, 2001, errors.New("descriptor_set需要base64编码"))
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if err := checkDescriptorService(c, tx, params.ServiceID); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	// 上传前先校验描述文件能否生成反射服务，服务启用了gRPC转码时还需要能生成转码路由
+	out, err := descriptorOutput(&dao.ServiceDescriptor{ServiceID: params.ServiceID, DescriptorSet: descriptorSet},
+		serviceGrpcTranscode(c, tx, params.ServiceID))
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	descriptor, err := (&dao.ServiceDescriptor{}).FindByServiceID(c, tx, params.ServiceID)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+	if err == gorm.ErrRecordNotFound {
+		descriptor = &dao.ServiceDescriptor{ServiceID: params.ServiceID}
+	}
+	descriptor.DescriptorSet = descriptorSet
+	if err := descriptor.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+	out.UpdatedAt = descriptor.UpdatedAt
+	middleware.ResponseSuccess(c, out)
+	return
+}
+
+// ServiceDescriptorDetail godoc
+// @Summary protobuf描述文件详情
+// @Description 查看描述文件生成的转码路由以及定义的gRPC服务
+// @Tags 服务管理
+// @ID /service/service_descriptor_detail
+// @Accept  json
+// @Produce  json
+// @Param service_id query string true "服务ID"
+// @Success 200 {object} middleware.Response{data=dto.ServiceDescriptorOutput} "success"
+// @Router /service/service_descriptor_detail [get]
+func (service *ServiceController) ServiceDescriptorDetail(c *gin.Context) {
+	params := &dto.ServiceDescriptorInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	descriptor, err := (&dao.ServiceDescriptor{}).FindByServiceID(c, tx, params.ServiceID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	out, err := descriptorOutput(descriptor, serviceGrpcTranscode(c, tx, params.ServiceID))
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, out)
+	return
+}
+
+// ServiceDescriptorDelete godoc
+// @Summary protobuf描述文件删除
+// @Description protobuf描述文件删除
+// @Tags 服务管理
+// @ID /service/service_descriptor_delete
+// @Accept  json
+// @Produce  json
+// @Param service_id query string true "服务ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_descriptor_delete [get]
+func (service *ServiceController) ServiceDescriptorDelete(c *gin.Context) {
+	params := &dto.ServiceDescriptorInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	descriptor, err := (&dao.ServiceDescriptor{}).FindByServiceID(c, tx, params.ServiceID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	// 软删除，代理服务器下次加载服务时生效
+	descriptor.IsDelete = 1
+	if err := descriptor.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// checkDescriptorService 描述文件只能上传到未删除的HTTP服务或gRPC服务
+func checkDescriptorService(c *gin.Context, tx *gorm.DB, serviceID int64) error {
+	search := &dao.ServiceInfo{ID: serviceID}
+	serviceInfo, err := search.FindFirst(c, tx, search)
+	if err != nil || serviceInfo.IsDelete == 1 {
+		return errors.New("服务不存在")
+	}
+	if serviceInfo.LoadType != public.LoadTypeHTTP && serviceInfo.LoadType != public.LoadTypeGRPC {
+		return errors.New("只有HTTP服务和gRPC服务支持上传描述文件")
+	}
+	return nil
+}
+
+// serviceGrpcTranscode 服务是否为启用了gRPC转码的HTTP服务
+func serviceGrpcTranscode(c *gin.Context, tx *gorm.DB, serviceID int64) bool {
+	httpRule, err := (&dao.HttpRule{}).FindFirst(c, tx, &dao.HttpRule{ServiceID: serviceID})
+	return err == nil && httpRule.GrpcTranscode == 1
+}
+
+// checkGrpcTranscode 开启gRPC转码前校验已上传的描述文件能否生成转码路由，还没有上传描述文件时不校验
+func checkGrpcTranscode(c *gin.Context, tx *gorm.DB, serviceID int64) error {
+	descriptor, err := (&dao.ServiceDescriptor{}).FindByServiceID(c, tx, serviceID)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := grpc_transcode.NewTranscoder(descriptor.DescriptorSet); err != nil {
+		return errors.WithMessage(err, "描述文件无法用于gRPC转码")
+	}
+	return nil
+}
+
+// descriptorOutput 解析描述文件，生成反射服务，transcode为true时还需要生成转码路由，描述文件无效时返回错误
+func descriptorOutput(descriptor *dao.ServiceDescriptor, transcode bool) (*dto.ServiceDescriptorOutput, error) {
+	reflectionServer, err := grpc_transcode.NewReflectionServer(descriptor.DescriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	out := &dto.ServiceDescriptorOutput{
+		ServiceID: descriptor.ServiceID,
+		UpdatedAt: descriptor.UpdatedAt,
+		Routes:    []dto.ServiceDescriptorRouteOutput{},
+		Services:  reflectionServer.ServiceNames(),
+	}
+	if !transcode {
+		return out, nil
+	}
+	transcoder, err := grpc_transcode.NewTranscoder(descriptor.DescriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range transcoder.Routes() {
+		out.Routes = append(out.Routes, dto.ServiceDescriptorRouteOutput{
+			HttpMethod: route.HttpMethod,
+			Path:       route.Path,
+			GrpcMethod: route.GrpcMethod,
+		})
+	}
+	return out, nil
+}
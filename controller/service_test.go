@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"github.com/zhj/go_gateway/public"
+	"testing"
+)
+
+func TestCheckTcpSni(t *testing.T) {
+	if err := checkTcpSni("", 0, 1, 1, public.ClientVerifyRequired, 0); err != nil {
+		t.Fatalf("service without sni should pass: %v", err)
+	}
+	if err := checkTcpSni("api.example.com", 0, 0, 0, public.ClientVerifyOff, 0); err != nil {
+		t.Fatalf("plain sni service should pass: %v", err)
+	}
+	for _, clientVerify := range []int{public.ClientVerifyOptional, public.ClientVerifyRequired} {
+		if err := checkTcpSni("api.example.com", 0, 0, 0, clientVerify, 0); err == nil {
+			t.Fatalf("sni service with client_verify=%d should be rejected", clientVerify)
+		}
+	}
+	if err := checkTcpSni("", 1, 0, 0, public.ClientVerifyOff, public.TcpProtocolRedis); err == nil {
+		t.Fatal("sni service with redis protocol should be rejected")
+	}
+}
@@ -1,9 +1,12 @@
 package dao
 
 import (
+	"fmt"
 	"github.com/e421083458/golang_common/lib"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/public"
 	"gorm.io/gorm"
 	"net/http/httptest"
 	"sync"
@@ -12,16 +15,19 @@ import (
 
 // App 租户信息结构体
 type App struct {
-	ID        int64     `json:"id" gorm:"primary_key"`
-	AppID     string    `json:"app_id" gorm:"column:app_id" description:"租户id"`
-	Name      string    `json:"name" gorm:"column:name" description:"租户名称"`
-	Secret    string    `json:"secret" gorm:"column:secret" description:"密钥"`
-	WhiteIPS  string    `json:"white_ips" gorm:"column:white_ips" description:"ip白名单，支持前缀匹配"`
-	Qpd       int64     `json:"qpd" gorm:"column:qpd" description:"日请求量限制"`
-	Qps       int64     `json:"qps" gorm:"column:qps" description:"每秒请求量限制"`
-	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
-	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
-	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+	ID             int64     `json:"id" gorm:"primary_key"`
+	AppID          string    `json:"app_id" gorm:"column:app_id" description:"租户id"`
+	Name           string    `json:"name" gorm:"column:name" description:"租户名称"`
+	Secret         string    `json:"secret" gorm:"column:secret" description:"密钥"`
+	WhiteIPS       string    `json:"white_ips" gorm:"column:white_ips" description:"ip白名单，支持前缀匹配"`
+	Qpd            int64     `json:"qpd" gorm:"column:qpd" description:"日请求量限制"`
+	Qps            int64     `json:"qps" gorm:"column:qps" description:"每秒请求量限制"`
+	TokenExpires   int64     `json:"token_expires" gorm:"column:token_expires" description:"access_token有效期，单位s"`
+	RefreshExpires int64     `json:"refresh_expires" gorm:"column:refresh_expires" description:"refresh_token有效期，单位s"`
+	CertIdentity   string    `json:"cert_identity" gorm:"column:cert_identity" description:"客户端证书身份，证书subject CN或SAN，多个以逗号间隔"`
+	CreatedAt      time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt      time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete       int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
 }
 
 // TableName 租户信息对应数据库中的表名
@@ -29,6 +35,22 @@ func (t *App) TableName() string {
 	return "gateway_app"
 }
 
+// GetTokenExpires 获取access_token有效期
+func (t *App) GetTokenExpires() int64 {
+	if t.TokenExpires > 0 {
+		return t.TokenExpires
+	}
+	return public.JwtExpires
+}
+
+// GetRefreshExpires 获取refresh_token有效期
+func (t *App) GetRefreshExpires() int64 {
+	if t.RefreshExpires > 0 {
+		return t.RefreshExpires
+	}
+	return public.JwtRefreshExpires
+}
+
 // Find  方法获得数据库中租户信息
 func (t *App) Find(c *gin.Context, tx *gorm.DB, search *App) (*App, error) {
 	model := &App{}
@@ -93,6 +115,8 @@ type AppManager struct {
 	Locker   sync.RWMutex
 	init     sync.Once
 	err      error
+	// LoadApp 从数据库读取租户信息，包括已经删除的租户，测试时可以替换
+	LoadApp func(appID string) (*App, error)
 }
 
 // NewAppManager 暴露出去的New方法
@@ -102,14 +126,99 @@ func NewAppManager() *AppManager {
 		AppSlice: []*App{},
 		Locker:   sync.RWMutex{},
 		init:     sync.Once{},
+		LoadApp:  loadAppFromDB,
+	}
+}
+
+// loadAppFromDB 从数据库读取租户信息
+func loadAppFromDB(appID string) (*App, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, err
 	}
+	return (&App{}).FindFirst(c, tx, &App{AppID: appID})
 }
 
 // GetAppList 获取租户信息列表
 func (s *AppManager) GetAppList() []*App {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
 	return s.AppSlice
 }
 
+// GetApp 通过app_id获取内存中的租户信息，不存在返回nil
+func (s *AppManager) GetApp(appID string) *App {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	return s.AppMap[appID]
+}
+
+// ResolveApp 通过app_id获取租户信息，内存中不存在时从数据库加载，使启动后新建的租户也能生效
+func (s *AppManager) ResolveApp(appID string) (*App, error) {
+	if appInfo := s.GetApp(appID); appInfo != nil {
+		return appInfo, nil
+	}
+	appInfo, err := s.LoadApp(appID)
+	if err == gorm.ErrRecordNotFound || (err == nil && appInfo.IsDelete == 1) {
+		return nil, errors.New(fmt.Sprintf("app %v not found", appID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if existing, ok := s.AppMap[appID]; ok {
+		return existing, nil
+	}
+	s.AppMap[appID] = appInfo
+	s.AppSlice = append(s.AppSlice, appInfo)
+	return appInfo, nil
+}
+
+// ResolveActiveApp 从数据库确认租户未被删除后返回租户信息，签发token前调用
+// 管理后台删除的租户同时从内存中移除，之前签发的token也无法再匹配到租户
+func (s *AppManager) ResolveActiveApp(appID string) (*App, error) {
+	appInfo, err := s.LoadApp(appID)
+	if err == gorm.ErrRecordNotFound || (err == nil && appInfo.IsDelete == 1) {
+		s.removeApp(appID)
+		return nil, errors.New(fmt.Sprintf("app %v not found", appID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.ResolveApp(appID)
+}
+
+// removeApp 从内存中移除租户
+func (s *AppManager) removeApp(appID string) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if _, ok := s.AppMap[appID]; !ok {
+		return
+	}
+	delete(s.AppMap, appID)
+	appSlice := []*App{}
+	for _, appInfo := range s.AppSlice {
+		if appInfo.AppID != appID {
+			appSlice = append(appSlice, appInfo)
+		}
+	}
+	s.AppSlice = appSlice
+}
+
+// MatchCertApp 通过客户端证书身份匹配租户
+func (s *AppManager) MatchCertApp(identities []string) *App {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for _, appInfo := range s.AppSlice {
+		if appInfo.CertIdentity != "" && public.CertIdentityMatch(identities, appInfo.CertIdentity) {
+			return appInfo
+		}
+	}
+	return nil
+}
+
 // LoadOnce 将租户信息加载到内存
 func (s *AppManager) LoadOnce() error {
 	s.init.Do(func() {
@@ -0,0 +1,249 @@
+package dao
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/public"
+	"gorm.io/gorm"
+	"log"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// AppApiKey 租户API Key结构体，数据库中只保存key的哈希值
+type AppApiKey struct {
+	ID         int64     `json:"id" gorm:"primary_key"`
+	AppID      string    `json:"app_id" gorm:"column:app_id" description:"租户id"`
+	Label      string    `json:"label" gorm:"column:label" description:"标签"`
+	KeyPrefix  string    `json:"key_prefix" gorm:"column:key_prefix" description:"key的前几位，用于辨认"`
+	KeyHash    string    `json:"-" gorm:"column:key_hash" description:"key的sha256哈希值"`
+	ExpireAt   int64     `json:"expire_at" gorm:"column:expire_at" description:"过期时间戳，0表示不过期"`
+	LastUsedAt int64     `json:"last_used_at" gorm:"column:last_used_at" description:"最后使用时间戳"`
+	CreatedAt  time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt  time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete   int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// TableName 对应数据库中的表名
+func (t *AppApiKey) TableName() string {
+	return "gateway_app_api_key"
+}
+
+// FindFirst 方法获得数据库中第一个匹配的key，若不存在则返回ErrRecordNotFound
+func (t *AppApiKey) FindFirst(c *gin.Context, tx *gorm.DB, search *AppApiKey) (*AppApiKey, error) {
+	model := &AppApiKey{}
+	err := tx.WithContext(c).Where(search).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *AppApiKey) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// KeyList 获取未删除的key列表，appID为空时获取全部
+func (t *AppApiKey) KeyList(c *gin.Context, tx *gorm.DB, appID string) ([]AppApiKey, error) {
+	var list []AppApiKey
+	query := tx.WithContext(c).Table(t.TableName()).Where("is_delete=0")
+	if appID != "" {
+		query = query.Where("app_id=?", appID)
+	}
+	if err := query.Order("id desc").Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteByAppID 删除租户下的全部key
+func (t *AppApiKey) DeleteByAppID(c *gin.Context, tx *gorm.DB, appID string) error {
+	return tx.WithContext(c).Table(t.TableName()).
+		Where("app_id=? and is_delete=0", appID).
+		Update("is_delete", 1).Error
+}
+
+// UpdateLastUsed 更新最后使用时间
+func (t *AppApiKey) UpdateLastUsed(c *gin.Context, tx *gorm.DB, id, lastUsedAt int64) error {
+	return tx.WithContext(c).Table(t.TableName()).
+		Where("id=?", id).
+		Update("last_used_at", lastUsedAt).Error
+}
+
+// Expired key是否已经过期
+func (t *AppApiKey) Expired(now time.Time) bool {
+	return t.ExpireAt > 0 && now.Unix() >= t.ExpireAt
+}
+
+// GenApiKey 生成新的API Key，返回明文key，明文只在创建时返回一次
+func GenApiKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return public.ApiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashApiKey 计算API Key的哈希值
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ApiKeyHeaderName 读取API Key的header名
+func ApiKeyHeaderName() string {
+	if name := lib.GetStringConf("proxy.api_key.header"); name != "" {
+		return name
+	}
+	return public.ApiKeyHeader
+}
+
+// ApiKeyQueryName 读取API Key的query参数名，配置为"-"时不从query中读取
+func ApiKeyQueryName() string {
+	name := lib.GetStringConf("proxy.api_key.query")
+	if name == "-" {
+		return ""
+	}
+	if name != "" {
+		return name
+	}
+	return public.ApiKeyQuery
+}
+
+// ApiKeyManagerHandler 暴露出去的Handler
+var ApiKeyManagerHandler *ApiKeyManager
+
+// init 初始化ApiKeyManagerHandler
+func init() {
+	ApiKeyManagerHandler = NewApiKeyManager()
+}
+
+// ApiKeyManager API Key管理，按照哈希值缓存key，并定时同步数据库
+type ApiKeyManager struct {
+	KeyMap     map[string]*AppApiKey
+	Locker     sync.RWMutex
+	usedMap    map[int64]int64 // 待写回数据库的最后使用时间
+	usedMu     sync.Mutex
+	appManager *AppManager // 为空时使用AppManagerHandler
+	init       sync.Once
+	err        error
+}
+
+// NewApiKeyManager 暴露出去的New方法
+func NewApiKeyManager() *ApiKeyManager {
+	return &ApiKeyManager{
+		KeyMap:  map[string]*AppApiKey{},
+		Locker:  sync.RWMutex{},
+		usedMap: map[int64]int64{},
+		init:    sync.Once{},
+	}
+}
+
+// LoadOnce 将key加载到内存，并定时重新加载以便新建或吊销的key生效
+func (s *ApiKeyManager) LoadOnce() error {
+	s.init.Do(func() {
+		if s.err = s.Reload(); s.err != nil {
+			return
+		}
+		go s.watchReload()
+	})
+	return s.err
+}
+
+// Reload 从数据库重新加载key
+func (s *ApiKeyManager) Reload() error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	list, err := (&AppApiKey{}).KeyList(c, tx, "")
+	if err != nil {
+		return err
+	}
+	keyMap := map[string]*AppApiKey{}
+	for _, item := range list {
+		tmpItem := item
+		keyMap[item.KeyHash] = &tmpItem
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.KeyMap = keyMap
+	return nil
+}
+
+// Verify 校验API Key，返回key所属的租户
+func (s *ApiKeyManager) Verify(key string) (*App, error) {
+	s.Locker.RLock()
+	keyItem, ok := s.KeyMap[HashApiKey(key)]
+	s.Locker.RUnlock()
+	if !ok {
+		return nil, errors.New("invalid api key")
+	}
+	now := time.Now()
+	if keyItem.Expired(now) {
+		return nil, errors.New("api key expired")
+	}
+	appManager := s.appManager
+	if appManager == nil {
+		appManager = AppManagerHandler
+	}
+	appInfo, err := appManager.ResolveApp(keyItem.AppID)
+	if err != nil {
+		return nil, err
+	}
+	// 最后使用时间先记录在内存中，定时批量写回数据库
+	s.usedMu.Lock()
+	s.usedMap[keyItem.ID] = now.Unix()
+	s.usedMu.Unlock()
+	return appInfo, nil
+}
+
+// watchReload 定时写回最后使用时间并重新加载key
+func (s *ApiKeyManager) watchReload() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+	ticker := time.NewTicker(time.Duration(public.ApiKeyReloadTime) * time.Second)
+	for {
+		<-ticker.C
+		if err := s.flushLastUsed(); err != nil {
+			log.Printf(" [ERROR] api_key_flush_last_used err:%v\n", err)
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf(" [ERROR] api_key_reload err:%v\n", err)
+		}
+	}
+}
+
+// flushLastUsed 将内存中的最后使用时间写回数据库
+func (s *ApiKeyManager) flushLastUsed() error {
+	s.usedMu.Lock()
+	usedMap := s.usedMap
+	s.usedMap = map[int64]int64{}
+	s.usedMu.Unlock()
+	if len(usedMap) == 0 {
+		return nil
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	for id, lastUsedAt := range usedMap {
+		if err := (&AppApiKey{}).UpdateLastUsed(c, tx, id, lastUsedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
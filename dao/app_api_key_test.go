@@ -0,0 +1,40 @@
+package dao
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApiKeyVerify(t *testing.T) {
+	validKey, err := GenApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredKey, err := GenApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(validKey, "gk_") || validKey == expiredKey {
+		t.Fatalf("unexpected api key %v", validKey)
+	}
+	manager := NewApiKeyManager()
+	manager.appManager = NewAppManager()
+	manager.appManager.AppMap["app_id_a"] = &App{AppID: "app_id_a"}
+	manager.KeyMap[HashApiKey(validKey)] = &AppApiKey{ID: 1, AppID: "app_id_a"}
+	manager.KeyMap[HashApiKey(expiredKey)] = &AppApiKey{ID: 2, AppID: "app_id_a", ExpireAt: time.Now().Add(-time.Second).Unix()}
+
+	appInfo, err := manager.Verify(validKey)
+	if err != nil || appInfo.AppID != "app_id_a" {
+		t.Fatalf("valid key should resolve to app_id_a: %v", err)
+	}
+	if _, ok := manager.usedMap[1]; !ok {
+		t.Fatal("last used time should be recorded")
+	}
+	if _, err := manager.Verify(expiredKey); err == nil {
+		t.Fatal("expired key should be rejected")
+	}
+	if _, err := manager.Verify(validKey + "x"); err == nil {
+		t.Fatal("unknown key should be rejected")
+	}
+}
@@ -0,0 +1,106 @@
+package dao
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+	"gorm.io/gorm"
+)
+
+// AppGrant 租户对服务的授权结构体
+type AppGrant struct {
+	ID        int64  `json:"id" gorm:"primary_key"`
+	AppID     string `json:"app_id" gorm:"column:app_id" description:"租户id"`
+	ServiceID int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Scope     string `json:"scope" gorm:"column:scope" description:"授予的权限范围，以逗号间隔"`
+	IsDelete  int8   `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// TableName 对应数据库中的表名
+func (t *AppGrant) TableName() string {
+	return "gateway_app_grant"
+}
+
+// Find  方法获得数据库中授权的信息
+func (t *AppGrant) Find(c *gin.Context, tx *gorm.DB, search *AppGrant) (*AppGrant, error) {
+	model := &AppGrant{}
+	err := tx.WithContext(c).Where(search).Find(model).Error
+	return model, err
+}
+
+// FindFirst 方法获得数据库中第一个匹配的授权，若不存在则返回ErrRecordNotFound
+func (t *AppGrant) FindFirst(c *gin.Context, tx *gorm.DB, search *AppGrant) (*AppGrant, error) {
+	model := &AppGrant{}
+	err := tx.WithContext(c).Where(search).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *AppGrant) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GrantList 按照租户id或者服务id获取未删除的授权列表，参数为空时不作为筛选条件
+func (t *AppGrant) GrantList(c *gin.Context, tx *gorm.DB, appID string, serviceID int64) ([]AppGrant, int64, error) {
+	var list []AppGrant
+	var count int64
+	query := tx.WithContext(c)
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("is_delete=0")
+	if appID != "" {
+		query = query.Where("app_id=?", appID)
+	}
+	if serviceID != 0 {
+		query = query.Where("service_id=?", serviceID)
+	}
+	err := query.Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	errCount := query.Count(&count).Error
+	if errCount != nil {
+		return nil, 0, errCount
+	}
+	return list, count, nil
+}
+
+// HasScope 授权中是否包含指定权限范围
+func (t *AppGrant) HasScope(scope string) bool {
+	return public.InStringSlice(public.ScopeList(t.Scope), scope)
+}
+
+// GrantRequired 服务是否配置了租户授权，没有配置任何授权的服务允许所有租户访问，兼容开启授权前已有的服务
+func (s *ServiceDetail) GrantRequired() bool {
+	return len(s.AppGrantList) > 0
+}
+
+// MatchAppGrant 获取租户在服务上的授权，未授权返回nil
+func (s *ServiceDetail) MatchAppGrant(appID string) *AppGrant {
+	for _, item := range s.AppGrantList {
+		if item.AppID == appID {
+			return item
+		}
+	}
+	return nil
+}
+
+// GetAppScopes 获取租户在所有服务上被授予的权限范围
+func (s *ServiceManager) GetAppScopes(appID string) []string {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	scopes := []string{}
+	for _, serviceItem := range s.ServiceSlice {
+		grant := serviceItem.MatchAppGrant(appID)
+		if grant == nil {
+			continue
+		}
+		for _, scope := range public.ScopeList(grant.Scope) {
+			if !public.InStringSlice(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,239 @@
+package dao
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/public"
+	"gorm.io/gorm"
+	"log"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Certificate HTTPS代理的服务端证书结构体，按照证书中的域名进行SNI匹配
+type Certificate struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	Name      string    `json:"name" gorm:"column:name" description:"证书名称"`
+	CertPem   string    `json:"cert_pem" gorm:"column:cert_pem" description:"PEM格式证书链"`
+	KeyPem    string    `json:"-" gorm:"column:key_pem" description:"PEM格式私钥"`
+	Hosts     string    `json:"hosts" gorm:"column:hosts" description:"证书中的域名，以逗号间隔，由证书解析得到"`
+	NotBefore time.Time `json:"not_before" gorm:"column:not_before" description:"生效时间"`
+	NotAfter  time.Time `json:"not_after" gorm:"column:not_after" description:"过期时间"`
+	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// TableName 证书信息对应数据库中的表名
+func (t *Certificate) TableName() string {
+	return "gateway_certificate"
+}
+
+// Find  方法获得数据库中证书信息
+func (t *Certificate) Find(c *gin.Context, tx *gorm.DB, search *Certificate) (*Certificate, error) {
+	model := &Certificate{}
+	err := tx.WithContext(c).Where(search).Find(model).Error
+	return model, err
+}
+
+// FindFirst  方法获得数据库中第一个匹配的证书，若不存在则返回ErrRecordNotFound
+func (t *Certificate) FindFirst(c *gin.Context, tx *gorm.DB, search *Certificate) (*Certificate, error) {
+	model := &Certificate{}
+	err := tx.WithContext(c).Where(search).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *Certificate) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetPem 解析证书和私钥，并根据证书内容更新域名和有效期
+func (t *Certificate) SetPem(certPem, keyPem string) error {
+	cert, err := public.ParseCertKeyPair(certPem, keyPem)
+	if err != nil {
+		return err
+	}
+	t.CertPem = certPem
+	t.KeyPem = keyPem
+	t.Hosts = strings.Join(public.CertHosts(cert.Leaf), ",")
+	t.NotBefore = cert.Leaf.NotBefore
+	t.NotAfter = cert.Leaf.NotAfter
+	return nil
+}
+
+// ExpireDays 证书剩余有效天数，已过期时为负数
+func (t *Certificate) ExpireDays(now time.Time) int {
+	return int(math.Floor(t.NotAfter.Sub(now).Hours() / 24))
+}
+
+// CertList 获取证书列表信息并分页，expire_in大于0时只返回该天数内过期的证书
+func (t *Certificate) CertList(c *gin.Context, tx *gorm.DB, param *dto.CertListInput) ([]Certificate, int64, error) {
+	var total int64
+	var list []Certificate
+	offset := (param.PageNo - 1) * param.PageSize
+	query := tx.WithContext(c)
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("is_delete = 0")
+	if param.Info != "" {
+		query = query.Where("name like ? or hosts like ?", "%"+param.Info+"%", "%"+param.Info+"%")
+	}
+	if param.ExpireIn > 0 {
+		query = query.Where("not_after < ?", time.Now().AddDate(0, 0, param.ExpireIn))
+	}
+	// 分页前先统计总数
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	// 按照过期时间升序，最先过期的证书排在前面
+	if err := query.Limit(param.PageSize).Offset(offset).
+		Order("not_after asc").Find(&list).Error; err != nil &&
+		err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// CertManagerHandler 暴露出去的Handler
+var CertManagerHandler *CertManager
+
+// init 初始化CertManagerHandler
+func init() {
+	CertManagerHandler = NewCertManager()
+}
+
+// CertManager 证书管理，HTTPS握手时按照SNI选择证书，并定时从数据库和磁盘重新加载
+type CertManager struct {
+	Store  *public.CertStore
+	Locker sync.RWMutex
+	warned map[int64]int // 证书已经告警过的最小阈值
+	warnMu sync.Mutex
+	init   sync.Once
+	err    error
+}
+
+// NewCertManager 暴露出去的New方法
+func NewCertManager() *CertManager {
+	return &CertManager{
+		Store:  public.NewCertStore(nil),
+		Locker: sync.RWMutex{},
+		warned: map[int64]int{},
+		init:   sync.Once{},
+	}
+}
+
+// LoadOnce 将证书加载到内存，并定时重新加载以便新增、更新的证书无需重启即可生效
+func (s *CertManager) LoadOnce() error {
+	s.init.Do(func() {
+		if s.err = s.Reload(); s.err != nil {
+			return
+		}
+		go s.watchReload()
+	})
+	return s.err
+}
+
+// Reload 从数据库重新加载证书，配置文件中的证书作为默认证书
+func (s *CertManager) Reload() error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	var list []Certificate
+	if err := tx.WithContext(c).Where("is_delete = 0").Order("id desc").Find(&list).Error; err != nil {
+		return err
+	}
+	var fallback *tls.Certificate
+	if cert, err := public.LoadServerCert(); err == nil {
+		fallback = &cert
+	} else {
+		log.Printf(" [ERROR] cert_load_default err:%v\n", err)
+	}
+	store := public.NewCertStore(fallback)
+	now := time.Now()
+	expireDays := map[int64]int{}
+	for _, item := range list {
+		cert, err := public.ParseCertKeyPair(item.CertPem, item.KeyPem)
+		if err != nil {
+			log.Printf(" [ERROR] cert_load %v err:%v\n", item.Name, err)
+			continue
+		}
+		days := item.ExpireDays(now)
+		expireDays[item.ID] = days
+		if s.shouldWarnExpiring(item.ID, days) {
+			log.Printf(" [WARN] cert_expiring %v hosts:%v expire_days:%v\n", item.Name, item.Hosts, days)
+		}
+		store.Add(cert, public.CertHosts(cert.Leaf))
+	}
+	s.pruneWarned(expireDays)
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.Store = store
+	return nil
+}
+
+// shouldWarnExpiring 证书剩余天数进入新的告警阈值时返回true，同一个阈值内重新加载不再告警
+func (s *CertManager) shouldWarnExpiring(id int64, days int) bool {
+	threshold := -1
+	for _, item := range public.CertExpireWarnThresholds {
+		if days < item {
+			threshold = item
+		}
+	}
+	s.warnMu.Lock()
+	defer s.warnMu.Unlock()
+	if threshold < 0 {
+		delete(s.warned, id)
+		return false
+	}
+	if warned, ok := s.warned[id]; ok && warned == threshold {
+		return false
+	}
+	s.warned[id] = threshold
+	return true
+}
+
+// pruneWarned 删除已经不存在的证书的告警记录
+func (s *CertManager) pruneWarned(expireDays map[int64]int) {
+	s.warnMu.Lock()
+	defer s.warnMu.Unlock()
+	for id := range s.warned {
+		if _, ok := expireDays[id]; !ok {
+			delete(s.warned, id)
+		}
+	}
+}
+
+// GetCertificate 作为tls.Config的GetCertificate，按照SNI选择证书
+func (s *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.Locker.RLock()
+	store := s.Store
+	s.Locker.RUnlock()
+	return store.Get(hello.ServerName)
+}
+
+// watchReload 定时重新加载证书
+func (s *CertManager) watchReload() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+	ticker := time.NewTicker(time.Duration(public.CertReloadTime) * time.Second)
+	for {
+		<-ticker.C
+		if err := s.Reload(); err != nil {
+			log.Printf(" [ERROR] cert_reload err:%v\n", err)
+		}
+	}
+}
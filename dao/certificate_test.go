@@ -0,0 +1,32 @@
+package dao
+
+import "testing"
+
+func TestCertWarnExpiringOnce(t *testing.T) {
+	manager := NewCertManager()
+	steps := []struct {
+		days int
+		warn bool
+	}{
+		{60, false},
+		{29, true},
+		{28, false},
+		{6, true},
+		{6, false},
+		{0, true},
+		{-1, true},
+		{-2, false},
+		// 证书更新后重新开始告警
+		{365, false},
+		{20, true},
+	}
+	for i, step := range steps {
+		if warn := manager.shouldWarnExpiring(1, step.days); warn != step.warn {
+			t.Fatalf("step %d days %d warn %v, want %v", i, step.days, warn, step.warn)
+		}
+	}
+	manager.pruneWarned(map[int64]int{})
+	if len(manager.warned) != 0 {
+		t.Fatal("removed cert should be pruned")
+	}
+}
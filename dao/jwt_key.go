@@ -0,0 +1,290 @@
+package dao
+
+import (
+	"fmt"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+	"gorm.io/gorm"
+	"log"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// JwtKey JWT签名密钥信息结构体
+type JwtKey struct {
+	ID         int64     `json:"id" gorm:"primary_key"`
+	Kid        string    `json:"kid" gorm:"column:kid" description:"密钥id，写入token头部"`
+	Algorithm  string    `json:"algorithm" gorm:"column:algorithm" description:"签名算法 RS256/ES256"`
+	PrivateKey string    `json:"-" gorm:"column:private_key" description:"PEM格式私钥"`
+	PublicKey  string    `json:"public_key" gorm:"column:public_key" description:"PEM格式公钥"`
+	RetireAt   int64     `json:"retire_at" gorm:"column:retire_at" description:"停止验签的时间戳，0表示当前签名密钥"`
+	CreatedAt  time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt  time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete   int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// TableName 对应数据库中的表名
+func (t *JwtKey) TableName() string {
+	return "gateway_jwt_key"
+}
+
+// Find 方法获得数据库中密钥信息
+func (t *JwtKey) Find(c *gin.Context, tx *gorm.DB, search *JwtKey) (*JwtKey, error) {
+	model := &JwtKey{}
+	err := tx.WithContext(c).Where(search).Find(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *JwtKey) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidList 获取仍然可以验签的密钥列表，按照id降序
+func (t *JwtKey) ValidList(c *gin.Context, tx *gorm.DB, now int64) ([]JwtKey, error) {
+	var list []JwtKey
+	query := tx.WithContext(c).Table(t.TableName()).Where("is_delete=0")
+	query = query.Where("retire_at=0 or retire_at>?", now)
+	if err := query.Order("id desc").Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+// RetireSignKeys 将当前签名密钥改为只验签，并设置停止验签的时间
+func (t *JwtKey) RetireSignKeys(c *gin.Context, tx *gorm.DB, retireAt int64) error {
+	return tx.WithContext(c).Table(t.TableName()).
+		Where("is_delete=0 and retire_at=0").
+		Update("retire_at", retireAt).Error
+}
+
+// JwtKeyManagerHandler 暴露出去的Handler
+var JwtKeyManagerHandler *JwtKeyManager
+
+// init 初始化JwtKeyManagerHandler
+func init() {
+	JwtKeyManagerHandler = NewJwtKeyManager()
+}
+
+// JwtKeyManager JWT签名密钥管理，负责加载、定时轮换并同步到public.JwtKeyRingHandler
+type JwtKeyManager struct {
+	Locker sync.Mutex
+	init   sync.Once
+	err    error
+}
+
+// NewJwtKeyManager 暴露出去的New方法
+func NewJwtKeyManager() *JwtKeyManager {
+	return &JwtKeyManager{
+		Locker: sync.Mutex{},
+		init:   sync.Once{},
+	}
+}
+
+// LoadOnce 将密钥加载到内存，没有签名密钥时生成一个，并启动定时轮换
+// 返回前签名密钥已经可用，不需要等待下一次轮换检查
+func (s *JwtKeyManager) LoadOnce() error {
+	s.init.Do(func() {
+		s.err = s.loadSignKey()
+		go s.watchRotate()
+	})
+	return s.err
+}
+
+// loadSignKey 其他节点持有轮换锁时等待其写入新密钥，超时或者redis不可用时由当前节点直接生成
+func (s *JwtKeyManager) loadSignKey() error {
+	deadline := time.Now().Add(public.JwtKeyLoadWait * time.Second)
+	for {
+		err := s.checkRotate()
+		if err == nil {
+			if _, err = public.JwtKeyRingHandler.GetSignKey(); err == nil {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			log.Printf(" [WARN] jwt_key_load sign key not ready err:%v, rotate locally\n", err)
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if err := s.rotate(); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// Rotate 生成新的签名密钥，旧的签名密钥在过渡期内仍可以验签
+func (s *JwtKeyManager) Rotate() error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	if err := s.rotate(); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// Reload 从数据库重新加载密钥
+func (s *JwtKeyManager) Reload() error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	return s.reload()
+}
+
+// watchRotate 定时检查签名密钥是否需要轮换，同时同步其他节点轮换出的新密钥
+func (s *JwtKeyManager) watchRotate() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+	ticker := time.NewTicker(time.Duration(public.JwtKeyCheckInterval) * time.Second)
+	for {
+		<-ticker.C
+		if err := s.checkRotate(); err != nil {
+			log.Printf(" [ERROR] jwt_key_check_rotate err:%v\n", err)
+		}
+	}
+}
+
+// checkRotate 签名密钥不存在或超过轮换周期则轮换，否则只重新加载
+func (s *JwtKeyManager) checkRotate() error {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	signKey, err := s.currentSignKey()
+	if err != nil {
+		return err
+	}
+	if signKey == nil || time.Since(signKey.CreatedAt) > rotateInterval() {
+		// 多个代理节点共用一个数据库，使用redis锁保证同一时间只有一个节点轮换
+		reply, err := public.RedisConfDo("SET", public.RedisJwtKeyRotateKey, 1, "EX", public.JwtKeyCheckInterval, "NX")
+		if err != nil {
+			return err
+		}
+		if reply != nil {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+	return s.reload()
+}
+
+// currentSignKey 从数据库获取当前签名密钥，没有则返回nil
+func (s *JwtKeyManager) currentSignKey() (*JwtKey, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, err
+	}
+	list, err := (&JwtKey{}).ValidList(c, tx, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range list {
+		if item.RetireAt == 0 {
+			tmpItem := item
+			return &tmpItem, nil
+		}
+	}
+	return nil, nil
+}
+
+// rotate 在事务中退役旧签名密钥并写入新密钥
+func (s *JwtKeyManager) rotate() error {
+	algorithm := lib.GetStringConf("proxy.jwt.algorithm")
+	if algorithm == "" {
+		algorithm = public.JwtAlgorithmRS256
+	}
+	privatePEM, publicPEM, err := public.GenJwtKeyPair(algorithm)
+	if err != nil {
+		return err
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tx = tx.Begin()
+	if err := (&JwtKey{}).RetireSignKeys(c, tx, now.Add(overlapDuration()).Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	newKey := &JwtKey{
+		Kid:        fmt.Sprintf("%s-%d", now.In(lib.TimeLocation).Format("20060102150405"), now.UnixNano()%1000000),
+		Algorithm:  algorithm,
+		PrivateKey: privatePEM,
+		PublicKey:  publicPEM,
+	}
+	if err := newKey.Save(c, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	log.Printf(" [INFO] jwt_key_rotate new kid:%v\n", newKey.Kid)
+	return nil
+}
+
+// reload 从数据库加载可用密钥并同步到密钥环
+func (s *JwtKeyManager) reload() error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	list, err := (&JwtKey{}).ValidList(c, tx, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	var signKey *public.JwtKeyItem
+	verifyList := []*public.JwtKeyItem{}
+	for _, listItem := range list {
+		privatePEM := ""
+		// 只有最新的签名密钥才需要解析私钥
+		if listItem.RetireAt == 0 && signKey == nil {
+			privatePEM = listItem.PrivateKey
+		}
+		keyItem, err := public.ParseJwtKeyPair(listItem.Kid, listItem.Algorithm, privatePEM, listItem.PublicKey)
+		if err != nil {
+			log.Printf(" [ERROR] jwt_key_parse kid:%v err:%v\n", listItem.Kid, err)
+			continue
+		}
+		keyItem.RetireAt = listItem.RetireAt
+		if keyItem.PrivateKey != nil {
+			signKey = keyItem
+		}
+		verifyList = append(verifyList, keyItem)
+	}
+	public.JwtKeyRingHandler.Reset(signKey, verifyList)
+	return nil
+}
+
+// rotateInterval 签名密钥轮换周期
+func rotateInterval() time.Duration {
+	interval := lib.GetIntConf("proxy.jwt.rotate_interval")
+	if interval <= 0 {
+		interval = public.JwtKeyRotateInterval
+	}
+	return time.Duration(interval) * time.Second
+}
+
+// overlapDuration 旧密钥的验签过渡期，不能短于租户中最长的token有效期
+func overlapDuration() time.Duration {
+	overlap := int64(lib.GetIntConf("proxy.jwt.overlap"))
+	if overlap < public.JwtExpires {
+		overlap = public.JwtExpires
+	}
+	for _, appInfo := range AppManagerHandler.GetAppList() {
+		if expires := appInfo.GetTokenExpires(); overlap < expires {
+			overlap = expires
+		}
+	}
+	return time.Duration(overlap) * time.Second
+}
@@ -14,12 +14,18 @@ import (
 
 // ServiceDetail 服务详情信息结构体
 type ServiceDetail struct {
-	Info          *ServiceInfo   `json:"info" description:"基本信息"`
-	HTTPRule      *HttpRule      `json:"http_rule" description:"http_rule"`
-	TCPRule       *TcpRule       `json:"tcp_rule" description:"tcp_rule"`
-	GRPCRule      *GrpcRule      `json:"grpc_rule" description:"grpc_rule"`
-	LoadBalance   *LoadBalance   `json:"load_balance" description:"load_balance"`
-	AccessControl *AccessControl `json:"access_control" description:"access_control"`
+	Info           *ServiceInfo       `json:"info" description:"基本信息"`
+	HTTPRule       *HttpRule          `json:"http_rule" description:"http_rule"`
+	TCPRule        *TcpRule           `json:"tcp_rule" description:"tcp_rule"`
+	GRPCRule       *GrpcRule          `json:"grpc_rule" description:"grpc_rule"`
+	UDPRule        *UdpRule           `json:"udp_rule" description:"udp_rule"`
+	LoadBalance    *LoadBalance       `json:"load_balance" description:"load_balance"`
+	AccessControl  *AccessControl     `json:"access_control" description:"access_control"`
+	JwtIssuerList  []*JwtIssuer       `json:"jwt_issuer_list" description:"外部JWT签发方"`
+	AppGrantList   []*AppGrant        `json:"app_grant_list" description:"租户授权"`
+	ScopeRuleList  []*ScopeRule       `json:"scope_rule_list" description:"接口权限范围"`
+	MethodRuleList []*MethodRule      `json:"method_rule_list" description:"grpc方法规则"`
+	Descriptor     *ServiceDescriptor `json:"descriptor" description:"gRPC转码描述文件，未上传时为空"`
 }
 
 // ServiceManager 对应服务信息管理的结构体
@@ -78,6 +84,18 @@ func (s *ServiceManager) GetGrpcServiceList() []*ServiceDetail {
 	return list
 }
 
+// GetUdpServiceList 获取UDP服务列表
+func (s *ServiceManager) GetUdpServiceList() []*ServiceDetail {
+	var list []*ServiceDetail
+	for _, serverItem := range s.ServiceSlice {
+		tmpItem := serverItem
+		if tmpItem.Info.LoadType == public.LoadTypeUDP {
+			list = append(list, tmpItem)
+		}
+	}
+	return list
+}
+
 // HTTPAccessMode 匹配HTTP服务
 func (s *ServiceManager) HTTPAccessMode(c *gin.Context) (*ServiceDetail, error) {
 	//1、前缀匹配 /abc ==> serviceSlice.rule
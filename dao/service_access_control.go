@@ -1,7 +1,11 @@
 package dao
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
 	"gorm.io/gorm"
 )
 
@@ -10,11 +14,16 @@ type AccessControl struct {
 	ID                int64  `json:"id" gorm:"primary_key"`
 	ServiceID         int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
 	OpenAuth          int    `json:"open_auth" gorm:"column:open_auth" description:"是否开启权限 1=开启"`
+	AuthType          int    `json:"auth_type" gorm:"column:auth_type" description:"租户认证方式 0=JWT 1=API Key 2=两者皆可 3=HMAC签名"`
 	BlackList         string `json:"black_list" gorm:"column:black_list" description:"黑名单ip"`
 	WhiteList         string `json:"white_list" gorm:"column:white_list" description:"白名单ip"`
 	WhiteHostName     string `json:"white_host_name" gorm:"column:white_host_name" description:"白名单主机"`
+	BlackHostName     string `json:"black_host_name" gorm:"column:black_host_name" description:"黑名单主机"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" gorm:"column:clientip_flow_limit" description:"客户端ip限流"`
 	ServiceFlowLimit  int    `json:"service_flow_limit" gorm:"column:service_flow_limit" description:"服务端限流"`
+	ClientCA          string `json:"client_ca" gorm:"column:client_ca" description:"校验客户端证书的CA证书包，PEM格式"`
+	ClientVerify      int    `json:"client_verify" gorm:"column:client_verify" description:"客户端证书校验 0=关闭 1=可选 2=必须"`
+	WhiteCertList     string `json:"white_cert_list" gorm:"column:white_cert_list" description:"客户端证书白名单，证书subject CN或SAN"`
 }
 
 // TableName 对应数据库中的表名
@@ -22,6 +31,99 @@ func (t *AccessControl) TableName() string {
 	return "gateway_service_access_control"
 }
 
+// AllowJwt 是否允许通过JWT认证租户
+func (t *AccessControl) AllowJwt() bool {
+	return t.AuthType == public.AuthTypeJwt || t.AuthType == public.AuthTypeJwtOrApiKey
+}
+
+// AllowApiKey 是否允许通过API Key认证租户
+func (t *AccessControl) AllowApiKey() bool {
+	return t.AuthType == public.AuthTypeApiKey || t.AuthType == public.AuthTypeJwtOrApiKey
+}
+
+// AllowHmac 是否要求HMAC请求签名认证租户
+func (t *AccessControl) AllowHmac() bool {
+	return t.AuthType == public.AuthTypeHmac
+}
+
+// OpenMtls 是否开启客户端证书校验，CA证书包为空时校验必然失败
+func (t *AccessControl) OpenMtls() bool {
+	return t.ClientVerify != public.ClientVerifyOff
+}
+
+// clientCAPool 服务的CA证书池，按照权限控制配置的id缓存
+func (t *AccessControl) clientCAPool() (*x509.CertPool, error) {
+	return public.CachedCertPool(fmt.Sprintf("access_control_%d", t.ID), t.ClientCA)
+}
+
+// VerifyClientCert 使用服务的CA证书包校验客户端证书链
+func (t *AccessControl) VerifyClientCert(certs []*x509.Certificate) (*x509.Certificate, error) {
+	pool, err := t.clientCAPool()
+	if err != nil {
+		return nil, err
+	}
+	return public.VerifyClientCert(certs, pool)
+}
+
+// ServerTLSConfig 独立端口的TCP、gRPC服务在握手时校验客户端证书
+func (t *AccessControl) ServerTLSConfig() (*tls.Config, error) {
+	cert, err := public.LoadServerCert()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if err := t.SetClientAuth(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SetClientAuth 在TLS配置中设置客户端证书校验方式和CA证书包
+func (t *AccessControl) SetClientAuth(config *tls.Config) error {
+	pool, err := t.clientCAPool()
+	if err != nil {
+		return err
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if t.ClientVerify == public.ClientVerifyRequired {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
+
+// MatchWhiteCert 客户端证书身份是否在证书白名单中，未配置证书白名单时返回true
+func (t *AccessControl) MatchWhiteCert(identities []string) bool {
+	if t.WhiteCertList == "" {
+		return true
+	}
+	return public.CertIdentityMatch(identities, t.WhiteCertList)
+}
+
+// MatchWhiteHost SNI是否在主机白名单中，未配置主机白名单时返回true
+func (t *AccessControl) MatchWhiteHost(serverName string) bool {
+	if t.WhiteHostName == "" {
+		return true
+	}
+	return matchHostList(t.WhiteHostName, serverName)
+}
+
+// MatchBlackHost SNI是否在主机黑名单中
+func (t *AccessControl) MatchBlackHost(serverName string) bool {
+	return matchHostList(t.BlackHostName, serverName)
+}
+
+func matchHostList(list, serverName string) bool {
+	for _, host := range public.SplitList(list) {
+		if public.MatchServerName(host, serverName) {
+			return true
+		}
+	}
+	return false
+}
+
 // Find  方法获得数据库中权限控制的信息
 func (t *AccessControl) Find(c *gin.Context, tx *gorm.DB, search *AccessControl) (*AccessControl, error) {
 	model := &AccessControl{}
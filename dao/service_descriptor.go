@@ -0,0 +1,126 @@
+package dao
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/reverse_proxy/grpc_transcode"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceDescriptor protobuf描述文件集合，HTTP服务用于gRPC转码，gRPC服务用于应答反射请求
+type ServiceDescriptor struct {
+	ID            int64     `json:"id" gorm:"primary_key"`
+	ServiceID     int64     `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	DescriptorSet []byte    `json:"-" gorm:"column:descriptor_set" description:"protoc --descriptor_set_out生成的描述文件"`
+	CreatedAt     time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt     time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete      int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// TableName 对应数据库中的表名
+func (t *ServiceDescriptor) TableName() string {
+	return "gateway_service_descriptor"
+}
+
+// FindByServiceID 获取服务未删除的描述文件，若不存在则返回ErrRecordNotFound
+func (t *ServiceDescriptor) FindByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) (*ServiceDescriptor, error) {
+	model := &ServiceDescriptor{}
+	err := tx.WithContext(c).Where("service_id=? and is_delete=0", serviceID).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *ServiceDescriptor) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// TranscoderHandler 暴露出去的Handler
+var TranscoderHandler *TranscoderManager
+
+// init 初始化TranscoderHandler
+func init() {
+	TranscoderHandler = NewTranscoderManager()
+}
+
+// TranscoderManager 按服务缓存gRPC转码器以及到下游的gRPC连接
+type TranscoderManager struct {
+	TranscoderMap map[string]*TranscoderItem
+	Locker        sync.RWMutex
+}
+
+// TranscoderItem 服务的转码器和按下游地址复用的gRPC连接
+type TranscoderItem struct {
+	Transcoder  *grpc_transcode.Transcoder
+	ServiceName string
+	conns       map[string]*grpc.ClientConn
+	locker      sync.Mutex
+}
+
+func NewTranscoderManager() *TranscoderManager {
+	return &TranscoderManager{
+		TranscoderMap: map[string]*TranscoderItem{},
+		Locker:        sync.RWMutex{},
+	}
+}
+
+// GetTranscoder 获取服务的转码器，服务没有上传描述文件时返回错误
+func (t *TranscoderManager) GetTranscoder(service *ServiceDetail) (*TranscoderItem, error) {
+	t.Locker.RLock()
+	item, ok := t.TranscoderMap[service.Info.ServiceName]
+	t.Locker.RUnlock()
+	if ok {
+		return item, nil
+	}
+	if service.Descriptor == nil || len(service.Descriptor.DescriptorSet) == 0 {
+		return nil, errors.Errorf("service %v has no descriptor set", service.Info.ServiceName)
+	}
+	transcoder, err := grpc_transcode.NewTranscoder(service.Descriptor.DescriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	item = &TranscoderItem{
+		Transcoder:  transcoder,
+		ServiceName: service.Info.ServiceName,
+		conns:       map[string]*grpc.ClientConn{},
+	}
+	t.Locker.Lock()
+	defer t.Locker.Unlock()
+	if exist, ok := t.TranscoderMap[service.Info.ServiceName]; ok {
+		return exist, nil
+	}
+	t.TranscoderMap[service.Info.ServiceName] = item
+	return item, nil
+}
+
+// GetConn 获取到下游地址的gRPC连接，地址可以带有http://或https://前缀
+func (t *TranscoderItem) GetConn(lb *LoadBalance, addr string) (*grpc.ClientConn, error) {
+	addr = strings.TrimPrefix(strings.TrimPrefix(addr, "http://"), "https://")
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	if conn, ok := t.conns[addr]; ok {
+		return conn, nil
+	}
+	tlsConfig, err := lb.UpstreamTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transportOpt := grpc.WithInsecure()
+	if tlsConfig != nil {
+		transportOpt = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+	// grpc.Dial不会阻塞等待连接建立，连接失败时在调用时返回Unavailable
+	conn, err := grpc.Dial(addr, transportOpt)
+	if err != nil {
+		return nil, err
+	}
+	t.conns[addr] = conn
+	return conn, nil
+}
@@ -11,6 +11,9 @@ type GrpcRule struct {
 	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
 	Port           int    `json:"port" gorm:"column:port" description:"端口"`
 	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue"`
+	WebPort        int    `json:"web_port" gorm:"column:web_port" description:"gRPC-Web端口，为0时不开启"`
+	WebCorsOrigin  string `json:"web_cors_origin" gorm:"column:web_cors_origin" description:"gRPC-Web允许跨域的来源，以逗号间隔，*表示所有来源，为空时不处理跨域预检"`
+	ReflectionMode int    `json:"reflection_mode" gorm:"column:reflection_mode" description:"服务反射请求处理方式 0=转发给下游 1=使用上传的描述文件应答 2=关闭"`
 }
 
 // TableName 对应数据库中的表名
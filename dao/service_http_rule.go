@@ -16,6 +16,7 @@ type HttpRule struct {
 	NeedStripUri   int    `json:"need_strip_uri" gorm:"column:need_strip_uri" description:"启用strip_uri 1=启用"`
 	UrlRewrite     string `json:"url_rewrite" gorm:"column:url_rewrite" description:"url重写功能，每行一个"`
 	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue"`
+	GrpcTranscode  int    `json:"grpc_transcode" gorm:"column:grpc_transcode" description:"REST/JSON转码为gRPC调用下游 1=启用"`
 }
 
 // TableName 对应数据库中的表名
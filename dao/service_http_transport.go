@@ -0,0 +1,121 @@
+package dao
+
+import (
+	"crypto/tls"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/public"
+	"golang.org/x/net/http2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// newUpstreamTransport 按照服务配置的下游协议创建连接池
+func newUpstreamTransport(lb *LoadBalance) (http.RoundTripper, error) {
+	tlsConfig, err := lb.UpstreamTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout: time.Duration(lb.UpstreamConnectTimeout) * time.Second,
+	}
+	switch lb.UpstreamProtocol {
+	case public.UpstreamProtocolH2C:
+		// h2c不经过TLS协商，直接使用HTTP/2连接下游(prior knowledge)
+		if tlsConfig != nil {
+			return nil, errors.New("h2c upstream can not use tls")
+		}
+		limiter := newConnLimiter(lb.UpstreamMaxConns, dialer.Timeout)
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return limiter.dial(dialer, network, addr)
+			},
+			StrictMaxConcurrentStreams: lb.UpstreamStrictStreams == 1,
+		}, nil
+	case public.UpstreamProtocolHTTP1, public.UpstreamProtocolH2:
+	default:
+		return nil, errors.Errorf("unsupported upstream protocol %d", lb.UpstreamProtocol)
+	}
+	trans := &http.Transport{
+		TLSClientConfig:       tlsConfig,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          lb.UpstreamMaxIdle,
+		MaxConnsPerHost:       lb.UpstreamMaxConns,
+		IdleConnTimeout:       time.Duration(lb.UpstreamIdleTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(lb.UpstreamHeaderTimeout) * time.Second,
+	}
+	if lb.UpstreamProtocol == public.UpstreamProtocolH2 {
+		// 通过ALPN协商HTTP/2，下游不支持时回退到HTTP/1.1
+		h2Trans, err := http2.ConfigureTransports(trans)
+		if err != nil {
+			return nil, err
+		}
+		h2Trans.StrictMaxConcurrentStreams = lb.UpstreamStrictStreams == 1
+	}
+	return trans, nil
+}
+
+// connLimiter 限制每个下游地址同时打开的连接数，连接关闭后释放名额
+type connLimiter struct {
+	max     int
+	timeout time.Duration
+	locker  sync.Mutex
+	slots   map[string]chan struct{}
+}
+
+func newConnLimiter(max int, timeout time.Duration) *connLimiter {
+	return &connLimiter{
+		max:     max,
+		timeout: timeout,
+		slots:   map[string]chan struct{}{},
+	}
+}
+
+// dial 建立连接，名额已满时在连接超时时间内等待其他连接关闭
+func (l *connLimiter) dial(dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	if l.max <= 0 {
+		return dialer.Dial(network, addr)
+	}
+	l.locker.Lock()
+	slot, ok := l.slots[addr]
+	if !ok {
+		slot = make(chan struct{}, l.max)
+		l.slots[addr] = slot
+	}
+	l.locker.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+	default:
+		if l.timeout <= 0 {
+			return nil, errors.Errorf("upstream %v max conns %d reached", addr, l.max)
+		}
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		select {
+		case slot <- struct{}{}:
+		case <-timer.C:
+			return nil, errors.Errorf("upstream %v max conns %d reached", addr, l.max)
+		}
+	}
+	conn, err := dialer.Dial(network, addr)
+	if err != nil {
+		<-slot
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, release: func() { <-slot }}, nil
+}
+
+// limitedConn 关闭时释放连接名额
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"encoding/pem"
+	"github.com/zhj/go_gateway/public"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Proto))
+})
+
+func getProto(t *testing.T, lb *LoadBalance, url string) string {
+	trans, err := newUpstreamTransport(lb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: trans}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestUpstreamTransportH2C(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(protoHandler, &http2.Server{}))
+	defer server.Close()
+	if proto := getProto(t, &LoadBalance{}, server.URL); proto != "HTTP/1.1" {
+		t.Fatalf("expect HTTP/1.1, got %v", proto)
+	}
+	if proto := getProto(t, &LoadBalance{UpstreamProtocol: public.UpstreamProtocolH2C}, server.URL); proto != "HTTP/2.0" {
+		t.Fatalf("expect HTTP/2.0, got %v", proto)
+	}
+	// h2c是明文协议，不能与下游TLS同时开启
+	if _, err := newUpstreamTransport(&LoadBalance{UpstreamProtocol: public.UpstreamProtocolH2C, UpstreamTls: public.UpstreamTlsOn}); err == nil {
+		t.Fatal("h2c with upstream tls should fail")
+	}
+}
+
+func TestUpstreamTransportH2(t *testing.T) {
+	server := httptest.NewUnstartedServer(protoHandler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	lb := &LoadBalance{UpstreamTls: 1, UpstreamCA: string(caPem), UpstreamServerName: "example.com"}
+	if proto := getProto(t, lb, server.URL); proto != "HTTP/1.1" {
+		t.Fatalf("expect HTTP/1.1, got %v", proto)
+	}
+	lb.UpstreamProtocol = public.UpstreamProtocolH2
+	if proto := getProto(t, lb, server.URL); proto != "HTTP/2.0" {
+		t.Fatalf("expect HTTP/2.0, got %v", proto)
+	}
+}
+
+func TestConnLimiter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	limiter := newConnLimiter(1, 0)
+	dialer := &net.Dialer{}
+	conn, err := limiter.dial(dialer, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.dial(dialer, "tcp", ln.Addr().String()); err == nil {
+		t.Fatal("second conn should exceed the limit")
+	}
+	// 连接关闭后释放名额
+	conn.Close()
+	conn.Close()
+	conn, err = limiter.dial(dialer, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
@@ -3,6 +3,7 @@ package dao
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/zhj/go_gateway/dto"
+	"github.com/zhj/go_gateway/public"
 	"gorm.io/gorm"
 	"time"
 )
@@ -10,7 +11,7 @@ import (
 // ServiceInfo 服务信息
 type ServiceInfo struct {
 	ID          int64     `json:"id" gorm:"primary_key"`
-	LoadType    int       `json:"load_type" gorm:"column:load_type" description:"负载类型 0=http 1=tcp 2=grpc"`
+	LoadType    int       `json:"load_type" gorm:"column:load_type" description:"负载类型 0=http 1=tcp 2=grpc 3=udp"`
 	ServiceName string    `json:"service_name" gorm:"column:service_name" description:"服务名称"`
 	ServiceDesc string    `json:"service_desc" gorm:"column:service_desc" description:"服务描述"`
 	UpdatedAt   time.Time `json:"create_at" gorm:"column:create_at" description:"更新时间"`
@@ -47,6 +48,11 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
+	udpRule := &UdpRule{ServiceID: search.ID}
+	udpRule, err = udpRule.Find(c, tx, udpRule)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
 	accessControl := &AccessControl{ServiceID: search.ID}
 	accessControl, err = accessControl.Find(c, tx, accessControl)
 	if err != nil && err != gorm.ErrRecordNotFound {
@@ -57,13 +63,69 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
+	jwtIssuer := &JwtIssuer{}
+	jwtIssuers, _, err := jwtIssuer.ListBYServiceID(c, tx, search.ID)
+	if err != nil {
+		return nil, err
+	}
+	jwtIssuerList := []*JwtIssuer{}
+	for _, item := range jwtIssuers {
+		tmpItem := item
+		jwtIssuerList = append(jwtIssuerList, &tmpItem)
+	}
+	appGrant := &AppGrant{}
+	appGrants, _, err := appGrant.GrantList(c, tx, "", search.ID)
+	if err != nil {
+		return nil, err
+	}
+	appGrantList := []*AppGrant{}
+	for _, item := range appGrants {
+		tmpItem := item
+		appGrantList = append(appGrantList, &tmpItem)
+	}
+	scopeRule := &ScopeRule{}
+	scopeRules, _, err := scopeRule.ListBYServiceID(c, tx, search.ID)
+	if err != nil {
+		return nil, err
+	}
+	scopeRuleList := []*ScopeRule{}
+	for _, item := range scopeRules {
+		tmpItem := item
+		scopeRuleList = append(scopeRuleList, &tmpItem)
+	}
+	methodRule := &MethodRule{}
+	methodRules, _, err := methodRule.ListBYServiceID(c, tx, search.ID)
+	if err != nil {
+		return nil, err
+	}
+	methodRuleList := []*MethodRule{}
+	for _, item := range methodRules {
+		tmpItem := item
+		methodRuleList = append(methodRuleList, &tmpItem)
+	}
+	var descriptor *ServiceDescriptor
+	if httpRule.GrpcTranscode == 1 || grpcRule.ReflectionMode == public.GrpcReflectionDescriptor {
+		descriptor, err = (&ServiceDescriptor{}).FindByServiceID(c, tx, search.ID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		if err == gorm.ErrRecordNotFound {
+			descriptor = nil
+		}
+	}
 	detail := &ServiceDetail{
-		Info:          search,
-		HTTPRule:      httpRule,
-		TCPRule:       tcpRule,
-		GRPCRule:      grpcRule,
-		LoadBalance:   loadBalance,
-		AccessControl: accessControl,
+		Info:           search,
+		HTTPRule:       httpRule,
+		TCPRule:        tcpRule,
+		GRPCRule:       grpcRule,
+		UDPRule:        udpRule,
+		LoadBalance:    loadBalance,
+		AccessControl:  accessControl,
+		JwtIssuerList:  jwtIssuerList,
+		AppGrantList:   appGrantList,
+		ScopeRuleList:  scopeRuleList,
+		MethodRuleList: methodRuleList,
+		Descriptor:     descriptor,
 	}
 	return detail, nil
 }
@@ -0,0 +1,247 @@
+package dao
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/public"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JwtIssuer 服务信任的外部JWT签发方结构体
+type JwtIssuer struct {
+	ID             int64  `json:"id" gorm:"primary_key"`
+	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Issuer         string `json:"issuer" gorm:"column:issuer" description:"签发方，与token中的iss匹配"`
+	JwksURI        string `json:"jwks_uri" gorm:"column:jwks_uri" description:"JWKS地址，支持http(s)://或本地文件路径"`
+	Audience       string `json:"audience" gorm:"column:audience" description:"受众，与token中的aud匹配，为空不校验"`
+	RequiredClaims string `json:"required_claims" gorm:"column:required_claims" description:"必须的claim，以逗号间隔，格式: key 或 key=value"`
+	ClockSkew      int    `json:"clock_skew" gorm:"column:clock_skew" description:"允许的时钟偏差, 单位s"`
+	ClaimHeader    string `json:"claim_header" gorm:"column:claim_header" description:"claim转发到header，以逗号间隔，格式: claim headname"`
+	IsDelete       int8   `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// DefaultJwtClaimHeader 未配置claim转发时默认转发subject和roles
+const DefaultJwtClaimHeader = "sub X-Auth-Subject,roles X-Auth-Roles"
+
+// TableName 对应数据库中的表名
+func (t *JwtIssuer) TableName() string {
+	return "gateway_service_jwt_issuer"
+}
+
+// Find  方法获得数据库中签发方的信息
+func (t *JwtIssuer) Find(c *gin.Context, tx *gorm.DB, search *JwtIssuer) (*JwtIssuer, error) {
+	model := &JwtIssuer{}
+	err := tx.WithContext(c).Where(search).Find(model).Error
+	return model, err
+}
+
+// FindFirst 方法获得数据库中第一个匹配的签发方，若不存在则返回ErrRecordNotFound
+func (t *JwtIssuer) FindFirst(c *gin.Context, tx *gorm.DB, search *JwtIssuer) (*JwtIssuer, error) {
+	model := &JwtIssuer{}
+	err := tx.WithContext(c).Where(search).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *JwtIssuer) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListBYServiceID 方法获取服务下未删除的签发方列表
+func (t *JwtIssuer) ListBYServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]JwtIssuer, int64, error) {
+	var list []JwtIssuer
+	var count int64
+	query := tx.WithContext(c)
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("service_id=? and is_delete=0", serviceID)
+	err := query.Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	errCount := query.Count(&count).Error
+	if errCount != nil {
+		return nil, 0, errCount
+	}
+	return list, count, nil
+}
+
+// Verify 使用签发方的JWKS验证token，并校验iss、aud、时间以及必须的claim
+func (t *JwtIssuer) Verify(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	// 时间相关校验需要考虑时钟偏差，所以跳过jwt-go自带的校验
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := public.JwksCacheHandler.GetKey(t.JwksURI, kid)
+		if err != nil {
+			return nil, err
+		}
+		// 签名算法必须与公钥类型一致，防止算法替换攻击
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errors.New(fmt.Sprintf("unexpected jwt alg %v", token.Method.Alg()))
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, errors.New(fmt.Sprintf("unexpected jwt alg %v", token.Method.Alg()))
+			}
+		default:
+			return nil, errors.New("unsupported jwks key type")
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != t.Issuer {
+		return nil, errors.New("issuer mismatch")
+	}
+	now := time.Now().Unix()
+	skew := int64(t.ClockSkew)
+	exp, ok := claimInt64(claims, "exp")
+	if !ok {
+		return nil, errors.New("token missing exp")
+	}
+	if now > exp+skew {
+		return nil, errors.New("token is expired")
+	}
+	if nbf, ok := claimInt64(claims, "nbf"); ok && now+skew < nbf {
+		return nil, errors.New("token is not valid yet")
+	}
+	if iat, ok := claimInt64(claims, "iat"); ok && now+skew < iat {
+		return nil, errors.New("token used before issued")
+	}
+	if t.Audience != "" && !claimContains(claims["aud"], t.Audience) {
+		return nil, errors.New("audience mismatch")
+	}
+	for _, item := range strings.Split(t.RequiredClaims, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		value, ok := ClaimLookup(claims, parts[0])
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("token missing claim %v", parts[0]))
+		}
+		if len(parts) == 2 && !claimContains(value, parts[1]) {
+			return nil, errors.New(fmt.Sprintf("claim %v mismatch", parts[0]))
+		}
+	}
+	return claims, nil
+}
+
+// ClaimHeaders 按照claim转发配置生成需要转发到后端的header
+func (t *JwtIssuer) ClaimHeaders(claims jwt.MapClaims) map[string]string {
+	claimHeader := t.ClaimHeader
+	if claimHeader == "" {
+		claimHeader = DefaultJwtClaimHeader
+	}
+	headers := map[string]string{}
+	for _, item := range strings.Split(claimHeader, ",") {
+		items := strings.Split(strings.TrimSpace(item), " ")
+		if len(items) != 2 {
+			continue
+		}
+		// 即使token中没有该claim也输出空值，以便覆盖客户端伪造的同名header
+		headers[items[1]] = ""
+		if value, ok := ClaimLookup(claims, items[0]); ok {
+			headers[items[1]] = claimString(value)
+		}
+	}
+	return headers
+}
+
+// MatchJwtIssuer 通过iss匹配服务配置的外部签发方
+func (s *ServiceDetail) MatchJwtIssuer(iss string) *JwtIssuer {
+	if iss == "" {
+		return nil
+	}
+	for _, item := range s.JwtIssuerList {
+		if item.Issuer == iss {
+			return item
+		}
+	}
+	return nil
+}
+
+// ClaimLookup 按照点号分隔的路径查找claim，如 realm_access.roles
+func ClaimLookup(claims jwt.MapClaims, path string) (interface{}, bool) {
+	var current interface{} = map[string]interface{}(claims)
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// ClaimScopes 读取外部token中的权限范围，兼容 scope 字符串和 scp 数组两种写法
+func ClaimScopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return public.ScopeList(scope)
+	}
+	if scp, ok := claims["scp"]; ok {
+		return public.ScopeList(claimString(scp))
+	}
+	return []string{}
+}
+
+// claimContains claim为数组时判断是否包含，为字符串、布尔值或数字时转换为字符串后判断是否相等
+func claimContains(value interface{}, target string) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if claimContains(item, target) {
+				return true
+			}
+		}
+		return false
+	case map[string]interface{}, nil:
+		return false
+	}
+	return claimString(value) == target
+}
+
+// claimString 将claim转换为header值，数组以逗号拼接
+func claimString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func claimInt64(claims jwt.MapClaims, key string) (int64, bool) {
+	switch v := claims[key].(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
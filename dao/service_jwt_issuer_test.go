@@ -0,0 +1,131 @@
+package dao
+
+import (
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/zhj/go_gateway/public"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestIssuer(t *testing.T) (*JwtIssuer, *public.JwtKeyItem, func()) {
+	privatePEM, publicPEM, err := public.GenJwtKeyPair(public.JwtAlgorithmRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := public.ParseJwtKeyPair("idp_k1", public.JwtAlgorithmRS256, privatePEM, publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := public.NewJSONWebKey(key.Kid, key.Algorithm, key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 本地JWKS替身
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(public.JSONWebKeySet{Keys: []public.JSONWebKey{*jwk}})
+	}))
+	issuer := &JwtIssuer{
+		Issuer:         "https://idp.example.com/",
+		JwksURI:        server.URL,
+		Audience:       "gateway",
+		RequiredClaims: "email_verified=true,mfa_level=2,realm_access.roles",
+		ClockSkew:      30,
+		ClaimHeader:    "sub X-Auth-Subject,realm_access.roles X-Auth-Roles,email X-Auth-Email",
+	}
+	return issuer, key, server.Close
+}
+
+func signTestToken(t *testing.T, key *public.JwtKeyItem, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.Kid
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validTestClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            "https://idp.example.com/",
+		"sub":            "user_1",
+		"aud":            []interface{}{"gateway", "other"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"email_verified": true,
+		"mfa_level":      2,
+		"realm_access":   map[string]interface{}{"roles": []interface{}{"admin", "dev"}},
+	}
+}
+
+func TestJwtIssuerVerify(t *testing.T) {
+	issuer, key, closeFunc := newTestIssuer(t)
+	defer closeFunc()
+
+	claims, err := issuer.Verify(signTestToken(t, key, validTestClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := issuer.ClaimHeaders(claims)
+	if headers["X-Auth-Subject"] != "user_1" || headers["X-Auth-Roles"] != "admin,dev" {
+		t.Fatalf("unexpected claim headers %v", headers)
+	}
+	// 缺失的claim输出空值，用于覆盖客户端伪造的header
+	if value, ok := headers["X-Auth-Email"]; !ok || value != "" {
+		t.Fatalf("missing claim should map to empty header, got %v", headers)
+	}
+
+	cases := map[string]func(claims jwt.MapClaims){
+		"audience": func(claims jwt.MapClaims) { claims["aud"] = "other" },
+		"issuer":   func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com/" },
+		"expired":  func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no_exp":   func(claims jwt.MapClaims) { delete(claims, "exp") },
+		"nbf":      func(claims jwt.MapClaims) { claims["nbf"] = time.Now().Add(time.Minute).Unix() },
+		"claim":    func(claims jwt.MapClaims) { claims["email_verified"] = false },
+		"number":   func(claims jwt.MapClaims) { claims["mfa_level"] = 1.5 },
+		"no_roles": func(claims jwt.MapClaims) { delete(claims, "realm_access") },
+	}
+	for name, mutate := range cases {
+		claims := validTestClaims()
+		mutate(claims)
+		if _, err := issuer.Verify(signTestToken(t, key, claims)); err == nil {
+			t.Fatalf("%v: token should be rejected", name)
+		}
+	}
+}
+
+func TestJwtIssuerClockSkew(t *testing.T) {
+	issuer, key, closeFunc := newTestIssuer(t)
+	defer closeFunc()
+
+	// 在允许的偏差范围内
+	claims := validTestClaims()
+	claims["exp"] = time.Now().Add(-10 * time.Second).Unix()
+	claims["nbf"] = time.Now().Add(10 * time.Second).Unix()
+	if _, err := issuer.Verify(signTestToken(t, key, claims)); err != nil {
+		t.Fatal(err)
+	}
+	issuer.ClockSkew = 0
+	if _, err := issuer.Verify(signTestToken(t, key, claims)); err == nil {
+		t.Fatal("token should be rejected without clock skew")
+	}
+}
+
+func TestJwtIssuerUnknownKid(t *testing.T) {
+	issuer, _, closeFunc := newTestIssuer(t)
+	defer closeFunc()
+
+	privatePEM, publicPEM, err := public.GenJwtKeyPair(public.JwtAlgorithmRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := public.ParseJwtKeyPair("idp_k2", public.JwtAlgorithmRS256, privatePEM, publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Verify(signTestToken(t, other, validTestClaims())); err == nil {
+		t.Fatal("token signed by unknown key should be rejected")
+	}
+}
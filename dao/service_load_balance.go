@@ -1,16 +1,15 @@
 package dao
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/zhj/go_gateway/public"
 	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
 	"gorm.io/gorm"
-	"net"
 	"net/http"
 	"strings"
 	"sync"
-	"time"
 )
 
 // LoadBalance 负载均衡信息结构体
@@ -29,6 +28,17 @@ type LoadBalance struct {
 	UpstreamHeaderTimeout  int `json:"upstream_header_timeout" gorm:"column:upstream_header_timeout" description:"下游获取header超时, 单位s	"`
 	UpstreamIdleTimeout    int `json:"upstream_idle_timeout" gorm:"column:upstream_idle_timeout" description:"下游链接最大空闲时间, 单位s	"`
 	UpstreamMaxIdle        int `json:"upstream_max_idle" gorm:"column:upstream_max_idle" description:"下游最大空闲链接数"`
+
+	UpstreamTls        int    `json:"upstream_tls" gorm:"column:upstream_tls" description:"是否使用TLS连接下游 1=开启"`
+	UpstreamCA         string `json:"upstream_ca" gorm:"column:upstream_ca" description:"校验下游证书的CA证书包，PEM格式，为空时使用系统CA"`
+	UpstreamSkipVerify int    `json:"upstream_skip_verify" gorm:"column:upstream_skip_verify" description:"是否跳过下游证书校验，仅用于开发环境 1=跳过"`
+	UpstreamCert       string `json:"upstream_cert" gorm:"column:upstream_cert" description:"连接下游的客户端证书，PEM格式，用于双向TLS"`
+	UpstreamKey        string `json:"upstream_key" gorm:"column:upstream_key" description:"连接下游的客户端私钥，PEM格式"`
+	UpstreamServerName string `json:"upstream_server_name" gorm:"column:upstream_server_name" description:"连接下游时的SNI及校验的证书域名，为空时使用下游地址"`
+
+	UpstreamProtocol      int `json:"upstream_protocol" gorm:"column:upstream_protocol" description:"HTTP服务连接下游的协议 0=HTTP/1.1 1=HTTP/2(TLS) 2=h2c"`
+	UpstreamMaxConns      int `json:"upstream_max_conns" gorm:"column:upstream_max_conns" description:"每个下游地址的最大连接数，0为不限制"`
+	UpstreamStrictStreams int `json:"upstream_strict_streams" gorm:"column:upstream_strict_streams" description:"HTTP/2是否将下游的最大并发流作为全局限制，超出时排队而不新建连接 1=开启"`
 }
 
 // TableName 对应数据库中的表名
@@ -53,6 +63,48 @@ func (t *LoadBalance) Save(c *gin.Context, tx *gorm.DB) error {
 	return nil
 }
 
+// UpstreamTLSConfig 连接下游的TLS配置，HTTP、TCP、GRPC服务共用，未开启时返回nil
+func (t *LoadBalance) UpstreamTLSConfig() (*tls.Config, error) {
+	if t.UpstreamTls != public.UpstreamTlsOn {
+		return nil, nil
+	}
+	config := &tls.Config{
+		ServerName:         t.UpstreamServerName,
+		InsecureSkipVerify: t.UpstreamSkipVerify == 1,
+	}
+	if t.UpstreamCA != "" {
+		pool, err := public.CertPoolFromPEM(t.UpstreamCA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if t.UpstreamCert != "" || t.UpstreamKey != "" {
+		cert, err := public.ParseCertKeyPair(t.UpstreamCert, t.UpstreamKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	return config, nil
+}
+
+// HTTPUpstreamTls HTTP服务是否使用TLS连接下游，未设置下游TLS时沿用needHttps
+func HTTPUpstreamTls(upstreamTls, needHttps int) bool {
+	if upstreamTls == public.UpstreamTlsUnset {
+		return needHttps == 1
+	}
+	return upstreamTls == public.UpstreamTlsOn
+}
+
+// UpstreamTlsEnabled 服务是否使用TLS连接下游
+func (s *ServiceDetail) UpstreamTlsEnabled() bool {
+	if s.Info.LoadType == public.LoadTypeHTTP && s.HTTPRule != nil {
+		return HTTPUpstreamTls(s.LoadBalance.UpstreamTls, s.HTTPRule.NeedHttps)
+	}
+	return s.LoadBalance.UpstreamTls == public.UpstreamTlsOn
+}
+
 // GetIPListByModel 获取IP列表
 func (t *LoadBalance) GetIPListByModel() []string {
 	return strings.Split(t.IpList, ",")
@@ -75,6 +127,8 @@ type LoadBalancer struct {
 
 type LoadBalancerItem struct {
 	LoadBalance load_balance.LoadBalance
+	// Conf 负载均衡器观察的配置，其中只包含健康检查通过的下游地址
+	Conf        load_balance.LoadBalanceConf
 	ServiceName string
 }
 
@@ -93,18 +147,26 @@ func init() {
 // GetLoadBalancer 通过服务信息获取负载均衡器
 func (l *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.LoadBalance, error) {
 	// 匹配服务对应的负载均衡器，找到则直接返回
-	for _, lbrItem := range l.LoadBalanceSlice {
-		if lbrItem.ServiceName == service.Info.ServiceName {
-			return lbrItem.LoadBalance, nil
-		}
+	l.Locker.RLock()
+	lbItem, ok := l.LoadBalanceMap[service.Info.ServiceName]
+	l.Locker.RUnlock()
+	if ok {
+		return lbItem.LoadBalance, nil
+	}
+	// 创建过程中持有写锁，避免并发请求重复创建负载均衡器和健康检查
+	l.Locker.Lock()
+	defer l.Locker.Unlock()
+	if lbItem, ok := l.LoadBalanceMap[service.Info.ServiceName]; ok {
+		return lbItem.LoadBalance, nil
 	}
 	// 找不到服务匹配的负载均衡器则创建一个
-	// 确定是http还是https
+	// 确定是http还是https，由下游TLS配置决定，未设置时沿用是否监听https
 	schema := "http://"
-	if service.HTTPRule.NeedHttps == 1 {
+	if service.UpstreamTlsEnabled() {
 		schema = "https://"
 	}
-	if service.Info.LoadType==public.LoadTypeTCP || service.Info.LoadType==public.LoadTypeGRPC{
+	if service.Info.LoadType==public.LoadTypeTCP || service.Info.LoadType==public.LoadTypeGRPC ||
+		service.Info.LoadType == public.LoadTypeUDP {
 		schema = ""
 	}
 	// 获取IP列表和对应的权重的列表
@@ -115,23 +177,110 @@ func (l *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.Loa
 	for ipIndex, ipItem := range ipList {
 		ipConf[ipItem] = weightList[ipIndex]
 	}
-	mConf, err := load_balance.NewLoadBalanceCheckConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf)
+	newConf := load_balance.NewLoadBalanceCheckConf
+	// UDP下游无法通过TCP拨号检查健康状态
+	if service.Info.LoadType == public.LoadTypeUDP {
+		newConf = load_balance.NewLoadBalanceStaticConf
+	}
+	mConf, err := newConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf)
 	if err != nil {
 		return nil, err
 	}
 	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbType(service.LoadBalance.RoundType), mConf)
 
 	//将新建的负载均衡器保存到Map和Slice中
-	lbItem := &LoadBalancerItem{
+	lbItem = &LoadBalancerItem{
 		LoadBalance: lb,
+		Conf:        mConf,
 		ServiceName: service.Info.ServiceName,
 	}
 	l.LoadBalanceSlice = append(l.LoadBalanceSlice, lbItem)
+	l.LoadBalanceMap[service.Info.ServiceName] = lbItem
+	return lb, nil
+}
 
-	// 对Map操作最好使用锁
+// GetLoadBalanceConf 获取服务负载均衡器观察的配置，可以通过Attach监听下游健康状态的变化
+func (l *LoadBalancer) GetLoadBalanceConf(service *ServiceDetail) (load_balance.LoadBalanceConf, error) {
+	if _, err := l.GetLoadBalancer(service); err != nil {
+		return nil, err
+	}
+	l.Locker.RLock()
+	defer l.Locker.RUnlock()
+	return l.LoadBalanceMap[service.Info.ServiceName].Conf, nil
+}
+
+// ServicesWithoutHealthyBackend 下游全部未通过健康检查的服务名，只读取已经创建的负载均衡器
+// HTTP服务在第一次请求时才创建负载均衡器，尚未创建的服务不计入
+func (l *LoadBalancer) ServicesWithoutHealthyBackend(services []*ServiceDetail) []string {
+	l.Locker.RLock()
+	defer l.Locker.RUnlock()
+	names := []string{}
+	for _, service := range services {
+		lbItem, ok := l.LoadBalanceMap[service.Info.ServiceName]
+		if ok && len(lbItem.Conf.GetConf()) == 0 {
+			names = append(names, service.Info.ServiceName)
+		}
+	}
+	return names
+}
+
+// GetRedisReplicaLoadBalancer 获取redis从节点的负载均衡器，从节点轮询选择并同样进行健康检查，未配置从节点时返回nil
+func (l *LoadBalancer) GetRedisReplicaLoadBalancer(service *ServiceDetail) (load_balance.LoadBalance, error) {
+	replicaList := service.TCPRule.GetRedisReplicaList()
+	if len(replicaList) == 0 {
+		return nil, nil
+	}
+	// 服务名只包含字母数字和下划线，使用"#"区分从节点的负载均衡器
+	name := service.Info.ServiceName + "#replica"
 	l.Locker.Lock()
 	defer l.Locker.Unlock()
-	l.LoadBalanceMap[service.Info.ServiceName] = lbItem
+	if lbItem, ok := l.LoadBalanceMap[name]; ok {
+		return lbItem.LoadBalance, nil
+	}
+	ipConf := map[string]string{}
+	for _, ipItem := range replicaList {
+		ipConf[ipItem] = "1"
+	}
+	mConf, err := load_balance.NewLoadBalanceCheckConf("%s", ipConf)
+	if err != nil {
+		return nil, err
+	}
+	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbRoundRobin, mConf)
+	lbItem := &LoadBalancerItem{
+		LoadBalance: lb,
+		Conf:        mConf,
+		ServiceName: name,
+	}
+	l.LoadBalanceSlice = append(l.LoadBalanceSlice, lbItem)
+	l.LoadBalanceMap[name] = lbItem
+	return lb, nil
+}
+
+// GetTcpRouteLoadBalancer 获取TCP服务第index条分流规则的负载均衡器，下游轮询选择并同样进行健康检查
+func (l *LoadBalancer) GetTcpRouteLoadBalancer(service *ServiceDetail, index int, route *TcpRoute) (load_balance.LoadBalance, error) {
+	// 服务名只包含字母数字和下划线，使用"#"区分分流规则的负载均衡器
+	name := fmt.Sprintf("%s#route%d", service.Info.ServiceName, index)
+	l.Locker.Lock()
+	defer l.Locker.Unlock()
+	if lbItem, ok := l.LoadBalanceMap[name]; ok {
+		return lbItem.LoadBalance, nil
+	}
+	ipConf := map[string]string{}
+	for _, ipItem := range route.IpList {
+		ipConf[ipItem] = "1"
+	}
+	mConf, err := load_balance.NewLoadBalanceCheckConf("%s", ipConf)
+	if err != nil {
+		return nil, err
+	}
+	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbRoundRobin, mConf)
+	lbItem := &LoadBalancerItem{
+		LoadBalance: lb,
+		Conf:        mConf,
+		ServiceName: name,
+	}
+	l.LoadBalanceSlice = append(l.LoadBalanceSlice, lbItem)
+	l.LoadBalanceMap[name] = lbItem
 	return lb, nil
 }
 
@@ -146,7 +295,7 @@ type Transporter struct {
 }
 
 type TransportItem struct {
-	Trans       *http.Transport
+	Trans       http.RoundTripper
 	ServiceName string
 }
 
@@ -162,8 +311,8 @@ func init() {
 	TransporterHandler = NewTransporter()
 }
 
-// GetTrans 获取定制化连接池
-func (t *Transporter) GetTrans(service *ServiceDetail) (*http.Transport, error) {
+// GetTrans 获取定制化连接池，按照服务配置的下游协议使用HTTP/1.1、HTTP/2或h2c
+func (t *Transporter) GetTrans(service *ServiceDetail) (http.RoundTripper, error) {
 	// 匹配服务对应的连接池，找得到就直接返回连接池
 	for _, transItem := range t.TransportSlice {
 		if transItem.ServiceName == service.Info.ServiceName {
@@ -171,13 +320,9 @@ func (t *Transporter) GetTrans(service *ServiceDetail) (*http.Transport, error)
 		}
 	}
 	// 没找到连接池就新建一个定制的连接池并保存
-	trans := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout: time.Duration(service.LoadBalance.UpstreamConnectTimeout) * time.Second,
-		}).DialContext,
-		MaxIdleConns:          service.LoadBalance.UpstreamMaxIdle,
-		IdleConnTimeout:       time.Duration(service.LoadBalance.UpstreamIdleTimeout) * time.Second,
-		ResponseHeaderTimeout: time.Duration(service.LoadBalance.UpstreamHeaderTimeout) * time.Second,
+	trans, err := newUpstreamTransport(service.LoadBalance)
+	if err != nil {
+		return nil, err
 	}
 
 	//将新建的连接池保存到Map和Slice中
@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"encoding/pem"
+	"github.com/zhj/go_gateway/public"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpstreamTLSConfig(t *testing.T) {
+	if config, err := (&LoadBalance{}).UpstreamTLSConfig(); err != nil || config != nil {
+		t.Fatalf("upstream tls should be off, got %v %v", config, err)
+	}
+	if _, err := (&LoadBalance{UpstreamTls: 1, UpstreamCA: "not a pem"}).UpstreamTLSConfig(); err == nil {
+		t.Fatal("invalid ca should fail")
+	}
+	if _, err := (&LoadBalance{UpstreamTls: 1, UpstreamCert: "not a pem"}).UpstreamTLSConfig(); err == nil {
+		t.Fatal("invalid client cert should fail")
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	// 使用下游证书作为CA，并通过SNI覆盖校验证书中的域名
+	lb := &LoadBalance{UpstreamTls: 1, UpstreamCA: string(caPem), UpstreamServerName: "example.com"}
+	config, err := lb.UpstreamTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// 域名不匹配时校验失败
+	lb.UpstreamServerName = "other.example.org"
+	config, _ = lb.UpstreamTLSConfig()
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("server name mismatch should fail")
+	}
+}
+
+func TestUpstreamTlsEnabled(t *testing.T) {
+	cases := []struct {
+		loadType    int
+		needHttps   int
+		upstreamTls int
+		want        bool
+	}{
+		// HTTP服务未设置下游TLS时沿用need_https
+		{public.LoadTypeHTTP, 1, public.UpstreamTlsUnset, true},
+		{public.LoadTypeHTTP, 0, public.UpstreamTlsUnset, false},
+		{public.LoadTypeHTTP, 1, public.UpstreamTlsOff, false},
+		{public.LoadTypeHTTP, 0, public.UpstreamTlsOn, true},
+		{public.LoadTypeTCP, 0, public.UpstreamTlsUnset, false},
+		{public.LoadTypeTCP, 0, public.UpstreamTlsOn, true},
+	}
+	for _, item := range cases {
+		service := &ServiceDetail{
+			Info:        &ServiceInfo{LoadType: item.loadType},
+			HTTPRule:    &HttpRule{NeedHttps: item.needHttps},
+			LoadBalance: &LoadBalance{UpstreamTls: item.upstreamTls},
+		}
+		if got := service.UpstreamTlsEnabled(); got != item.want {
+			t.Fatalf("%+v upstream tls %v", item, got)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package dao
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+	"gorm.io/gorm"
+	"path"
+	"strings"
+	"time"
+)
+
+// MethodRule grpc服务按方法生效的访问策略结构体
+type MethodRule struct {
+	ID          int64  `json:"id" gorm:"primary_key"`
+	ServiceID   int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Method      string `json:"method" gorm:"column:method" description:"完整方法名或通配，如 /pkg.Service/Method、/pkg.Service/*、/pkg.*/*"`
+	AllowApp    string `json:"allow_app" gorm:"column:allow_app" description:"允许访问的租户id，以逗号间隔，为空不限制"`
+	DenyApp     string `json:"deny_app" gorm:"column:deny_app" description:"禁止访问的租户id，以逗号间隔"`
+	Qps         int    `json:"qps" gorm:"column:qps" description:"规则匹配的方法共用的每秒请求量限制，为0不限制"`
+	Timeout     int    `json:"timeout" gorm:"column:timeout" description:"调用超时时间，单位ms，为0不修改客户端的deadline"`
+	TimeoutMode int    `json:"timeout_mode" gorm:"column:timeout_mode" description:"0=作为deadline上限 1=仅在客户端未设置deadline时使用"`
+	AddMetadata string `json:"add_metadata" gorm:"column:add_metadata" description:"转发时添加的metadata，以逗号间隔，格式: key value"`
+	IsDelete    int8   `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// TableName 对应数据库中的表名
+func (t *MethodRule) TableName() string {
+	return "gateway_service_method_rule"
+}
+
+// FindFirst 方法获得数据库中第一个匹配的规则，若不存在则返回ErrRecordNotFound
+func (t *MethodRule) FindFirst(c *gin.Context, tx *gorm.DB, search *MethodRule) (*MethodRule, error) {
+	model := &MethodRule{}
+	err := tx.WithContext(c).Where(search).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *MethodRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListBYServiceID 方法获取服务下未删除的规则列表
+func (t *MethodRule) ListBYServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]MethodRule, int64, error) {
+	var list []MethodRule
+	var count int64
+	query := tx.WithContext(c)
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("service_id=? and is_delete=0", serviceID)
+	err := query.Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	errCount := query.Count(&count).Error
+	if errCount != nil {
+		return nil, 0, errCount
+	}
+	return list, count, nil
+}
+
+// Match 完整方法名是否匹配该规则，*匹配不含/的任意字符
+func (t *MethodRule) Match(fullMethod string) bool {
+	matched, err := path.Match(t.Method, fullMethod)
+	return err == nil && matched
+}
+
+// specificity 规则的具体程度，完整方法名最高，通配规则按照第一个通配符前的长度比较
+func (t *MethodRule) specificity() int {
+	idx := strings.IndexAny(t.Method, "*?[")
+	if idx < 0 {
+		return len(t.Method) + 1<<16
+	}
+	return idx
+}
+
+// AllowAppID 租户是否允许访问，appID为空表示未识别租户
+func (t *MethodRule) AllowAppID(appID string) bool {
+	if appID != "" && public.InStringSlice(strings.Split(t.DenyApp, ","), appID) {
+		return false
+	}
+	if t.AllowApp == "" {
+		return true
+	}
+	return appID != "" && public.InStringSlice(strings.Split(t.AllowApp, ","), appID)
+}
+
+// Deadline 根据规则计算调用的deadline，ok为false时沿用客户端的deadline
+func (t *MethodRule) Deadline(now time.Time, clientDeadline time.Time, hasDeadline bool) (time.Time, bool) {
+	if t.Timeout <= 0 {
+		return time.Time{}, false
+	}
+	if t.TimeoutMode == public.MethodTimeoutDefault && hasDeadline {
+		return time.Time{}, false
+	}
+	deadline := now.Add(time.Duration(t.Timeout) * time.Millisecond)
+	if hasDeadline && clientDeadline.Before(deadline) {
+		return time.Time{}, false
+	}
+	return deadline, true
+}
+
+// Metadata 转发时添加的metadata
+func (t *MethodRule) Metadata() map[string]string {
+	md := map[string]string{}
+	for _, item := range strings.Split(t.AddMetadata, ",") {
+		items := strings.SplitN(strings.TrimSpace(item), " ", 2)
+		if len(items) != 2 {
+			continue
+		}
+		md[strings.ToLower(items[0])] = strings.TrimSpace(items[1])
+	}
+	return md
+}
+
+// MatchMethodRule 获取与方法匹配的最具体的规则，没有匹配的规则时返回nil
+func (s *ServiceDetail) MatchMethodRule(fullMethod string) *MethodRule {
+	var matched *MethodRule
+	for _, item := range s.MethodRuleList {
+		if !item.Match(fullMethod) {
+			continue
+		}
+		if matched == nil || item.specificity() > matched.specificity() {
+			matched = item
+		}
+	}
+	return matched
+}
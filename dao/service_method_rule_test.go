@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"github.com/zhj/go_gateway/public"
+	"testing"
+	"time"
+)
+
+func TestMatchMethodRule(t *testing.T) {
+	detail := &ServiceDetail{MethodRuleList: []*MethodRule{
+		{ID: 1, Method: "/echo.*/*"},
+		{ID: 2, Method: "/echo.Echo/*"},
+		{ID: 3, Method: "/echo.Echo/UnaryEcho"},
+	}}
+	cases := map[string]int64{
+		"/echo.Echo/UnaryEcho":           3,
+		"/echo.Echo/ServerStreamingEcho": 2,
+		"/echo.Other/UnaryEcho":          1,
+		"/other.Echo/UnaryEcho":          0,
+	}
+	for method, id := range cases {
+		rule := detail.MatchMethodRule(method)
+		if id == 0 && rule != nil || id != 0 && (rule == nil || rule.ID != id) {
+			t.Fatalf("%v expect rule %v, got %+v", method, id, rule)
+		}
+	}
+}
+
+func TestMethodRuleAllowApp(t *testing.T) {
+	rule := &MethodRule{AllowApp: "app_a,app_b", DenyApp: "app_b"}
+	for appID, allow := range map[string]bool{"app_a": true, "app_b": false, "app_c": false, "": false} {
+		if rule.AllowAppID(appID) != allow {
+			t.Fatalf("app %q expect allow %v", appID, allow)
+		}
+	}
+	rule = &MethodRule{DenyApp: "app_b"}
+	if !rule.AllowAppID("") || !rule.AllowAppID("app_a") || rule.AllowAppID("app_b") {
+		t.Fatal("deny list only should allow other apps")
+	}
+}
+
+func TestMethodRuleDeadline(t *testing.T) {
+	now := time.Now()
+	rule := &MethodRule{Timeout: 1000, TimeoutMode: public.MethodTimeoutCap}
+	if deadline, ok := rule.Deadline(now, time.Time{}, false); !ok || !deadline.Equal(now.Add(time.Second)) {
+		t.Fatalf("cap without client deadline expect 1s, got %v %v", deadline, ok)
+	}
+	if _, ok := rule.Deadline(now, now.Add(500*time.Millisecond), true); ok {
+		t.Fatal("earlier client deadline should be kept")
+	}
+	if deadline, ok := rule.Deadline(now, now.Add(5*time.Second), true); !ok || !deadline.Equal(now.Add(time.Second)) {
+		t.Fatalf("later client deadline should be capped, got %v %v", deadline, ok)
+	}
+	rule.TimeoutMode = public.MethodTimeoutDefault
+	if _, ok := rule.Deadline(now, now.Add(5*time.Second), true); ok {
+		t.Fatal("default timeout should not change client deadline")
+	}
+	if deadline, ok := rule.Deadline(now, time.Time{}, false); !ok || !deadline.Equal(now.Add(time.Second)) {
+		t.Fatalf("default timeout expect 1s, got %v %v", deadline, ok)
+	}
+}
@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// ScopeRule 服务接口需要的权限范围结构体
+type ScopeRule struct {
+	ID        int64  `json:"id" gorm:"primary_key"`
+	ServiceID int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Method    string `json:"method" gorm:"column:method" description:"HTTP请求方法，为空匹配所有方法"`
+	Path      string `json:"path" gorm:"column:path" description:"路径前缀，grpc服务为完整方法名前缀，如 /pkg.Service/"`
+	Scope     string `json:"scope" gorm:"column:scope" description:"需要的权限范围"`
+	IsDelete  int8   `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+// TableName 对应数据库中的表名
+func (t *ScopeRule) TableName() string {
+	return "gateway_service_scope_rule"
+}
+
+// FindFirst 方法获得数据库中第一个匹配的规则，若不存在则返回ErrRecordNotFound
+func (t *ScopeRule) FindFirst(c *gin.Context, tx *gorm.DB, search *ScopeRule) (*ScopeRule, error) {
+	model := &ScopeRule{}
+	err := tx.WithContext(c).Where(search).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *ScopeRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListBYServiceID 方法获取服务下未删除的规则列表
+func (t *ScopeRule) ListBYServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]ScopeRule, int64, error) {
+	var list []ScopeRule
+	var count int64
+	query := tx.WithContext(c)
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("service_id=? and is_delete=0", serviceID)
+	err := query.Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	errCount := query.Count(&count).Error
+	if errCount != nil {
+		return nil, 0, errCount
+	}
+	return list, count, nil
+}
+
+// Match 请求方法和路径是否匹配该规则，method为空时只匹配未限定方法的规则
+func (t *ScopeRule) Match(method, path string) bool {
+	if t.Method != "" && !strings.EqualFold(t.Method, method) {
+		return false
+	}
+	return strings.HasPrefix(path, t.Path)
+}
+
+// RequiredScopes 获取请求需要的全部权限范围
+func (s *ServiceDetail) RequiredScopes(method, path string) []string {
+	scopes := []string{}
+	for _, item := range s.ScopeRuleList {
+		if item.Match(method, path) {
+			scopes = append(scopes, item.Scope)
+		}
+	}
+	return scopes
+}
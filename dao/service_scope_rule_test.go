@@ -0,0 +1,37 @@
+package dao
+
+import (
+	"testing"
+)
+
+func TestRequiredScopes(t *testing.T) {
+	serviceDetail := &ServiceDetail{
+		AppGrantList: []*AppGrant{{AppID: "app_id_a", Scope: "read,write"}},
+		ScopeRuleList: []*ScopeRule{
+			{Path: "/test_http_service/", Scope: "read"},
+			{Method: "POST", Path: "/test_http_service/order", Scope: "write"},
+			{Path: "/helloworld.Greeter/", Scope: "grpc"},
+		},
+	}
+	if scopes := serviceDetail.RequiredScopes("GET", "/test_http_service/order"); len(scopes) != 1 || scopes[0] != "read" {
+		t.Fatalf("unexpected scopes %v", scopes)
+	}
+	if scopes := serviceDetail.RequiredScopes("post", "/test_http_service/order/1"); len(scopes) != 2 {
+		t.Fatalf("unexpected scopes %v", scopes)
+	}
+	// grpc请求不区分方法，只匹配未限定方法的规则
+	if scopes := serviceDetail.RequiredScopes("", "/helloworld.Greeter/SayHello"); len(scopes) != 1 || scopes[0] != "grpc" {
+		t.Fatalf("unexpected scopes %v", scopes)
+	}
+	grant := serviceDetail.MatchAppGrant("app_id_a")
+	if grant == nil || !grant.HasScope("write") || grant.HasScope("grpc") {
+		t.Fatalf("unexpected grant %+v", grant)
+	}
+	if serviceDetail.MatchAppGrant("app_id_b") != nil {
+		t.Fatal("app_id_b should not be granted")
+	}
+	// 没有配置授权的服务不限制租户
+	if !serviceDetail.GrantRequired() || (&ServiceDetail{}).GrantRequired() {
+		t.Fatal("unexpected grant required")
+	}
+}
@@ -1,15 +1,50 @@
 package dao
 
 import (
+	"crypto/tls"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
 	"gorm.io/gorm"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // TcpRule tpc规则信息结构体
 type TcpRule struct {
-	ID        int64 `json:"id" gorm:"primary_key"`
-	ServiceID int64 `json:"service_id" gorm:"column:service_id" description:"服务id"`
-	Port      int   `json:"port" gorm:"column:port" description:"端口"`
+	ID            int64  `json:"id" gorm:"primary_key"`
+	ServiceID     int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Port          int    `json:"port" gorm:"column:port" description:"端口"`
+	NeedTls       int    `json:"need_tls" gorm:"column:need_tls" description:"是否终止TLS 1=开启"`
+	TlsCert       string `json:"tls_cert" gorm:"column:tls_cert" description:"服务证书，PEM格式，为空时使用网关证书"`
+	TlsKey        string `json:"tls_key" gorm:"column:tls_key" description:"服务私钥，PEM格式"`
+	TlsMinVersion string `json:"tls_min_version" gorm:"column:tls_min_version" description:"TLS最低版本 1.0/1.1/1.2/1.3"`
+	TlsCiphers    string `json:"tls_ciphers" gorm:"column:tls_ciphers" description:"加密套件，以逗号间隔"`
+	TlsAlpn       string `json:"tls_alpn" gorm:"column:tls_alpn" description:"ALPN协议，以逗号间隔"`
+	SniHost       string `json:"sni_host" gorm:"column:sni_host" description:"共享端口按照SNI路由的域名，以逗号间隔，支持*.example.com"`
+	SniDefault    int    `json:"sni_default" gorm:"column:sni_default" description:"是否为共享端口的默认服务 1=是"`
+
+	Protocol           int    `json:"protocol" gorm:"column:protocol" description:"解析的应用层协议 0=原始TCP 1=redis"`
+	RedisAllowCommands string `json:"redis_allow_commands" gorm:"column:redis_allow_commands" description:"允许的redis命令，以逗号间隔，为空时不限制，EVAL、FCALL等脚本命令需要显式列出"`
+	RedisDenyCommands  string `json:"redis_deny_commands" gorm:"column:redis_deny_commands" description:"禁止的redis命令，以逗号间隔，优先级高于允许列表"`
+	RedisReplicaList   string `json:"redis_replica_list" gorm:"column:redis_replica_list" description:"redis从节点 ip:port，以逗号间隔，只读命令发往从节点，为空时全部发往主节点"`
+
+	IdleTimeout int `json:"idle_timeout" gorm:"column:idle_timeout" description:"连接空闲超时，两个方向都没有数据时关闭连接，单位s，0为不限制"`
+	MaxLifetime int `json:"max_lifetime" gorm:"column:max_lifetime" description:"连接最大生命周期，单位s，0为不限制"`
+	MaxConns    int `json:"max_conns" gorm:"column:max_conns" description:"服务最大并发连接数，0为不限制"`
+
+	RouteRule string `json:"route_rule" gorm:"column:route_rule" description:"按连接特征分流的规则，每行一条：条件 下游列表，未匹配的连接转发到服务的下游"`
+}
+
+// TcpRoute 一条分流规则，连接满足全部条件时转发到规则的下游，没有设置的条件不限制
+type TcpRoute struct {
+	Protocols  []string // 客户端协议 tls/http/ssh/unknown
+	ClientCIDR []string // 客户端IP网段或IP
+	SniHosts   []string // SNI域名，支持*.example.com
+	LocalPorts []int    // 连接的本地端口
+	IpList     []string // 下游 ip:port
 }
 
 // TableName 对应数据库中的表名
@@ -17,6 +52,165 @@ func (t *TcpRule) TableName() string {
 	return "gateway_service_tcp_rule"
 }
 
+// OpenTls 是否开启TLS终止
+func (t *TcpRule) OpenTls() bool {
+	return t.NeedTls == 1
+}
+
+// OpenSni 是否接入共享端口的SNI路由
+func (t *TcpRule) OpenSni() bool {
+	return t.SniHost != "" || t.SniDefault == 1
+}
+
+// MatchSni SNI是否匹配服务的域名，exact表示是否为精确匹配
+func (t *TcpRule) MatchSni(serverName string) (matched bool, exact bool) {
+	for _, host := range public.SplitList(t.SniHost) {
+		if public.MatchServerName(host, serverName) {
+			if !strings.HasPrefix(host, "*.") {
+				return true, true
+			}
+			matched = true
+		}
+	}
+	return matched, false
+}
+
+// OpenRedis 是否按照redis协议解析命令
+func (t *TcpRule) OpenRedis() bool {
+	return t.Protocol == public.TcpProtocolRedis
+}
+
+// RedisCommandAllowed 命令是否被允许，命令名不区分大小写，脚本命令需要在允许列表中显式列出
+func (t *TcpRule) RedisCommandAllowed(name string) bool {
+	for _, item := range public.SplitList(t.RedisDenyCommands) {
+		if strings.EqualFold(item, name) {
+			return false
+		}
+	}
+	allowList := public.SplitList(t.RedisAllowCommands)
+	if len(allowList) == 0 && !public.InStringSlice(public.RedisScriptCommands, strings.ToUpper(name)) {
+		return true
+	}
+	for _, item := range allowList {
+		if strings.EqualFold(item, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRedisReplicaList 获取redis从节点列表
+func (t *TcpRule) GetRedisReplicaList() []string {
+	return public.SplitList(t.RedisReplicaList)
+}
+
+// GetTcpRoutes 解析分流规则，每行一条，条件与下游列表以空格间隔
+// 条件以分号间隔，支持protocol、cidr、sni、port，同一条件的多个值以逗号间隔，下游 ip:port 以逗号间隔
+// 例如：protocol=ssh;cidr=10.0.0.0/8 10.0.0.5:22,10.0.0.6:22
+func (t *TcpRule) GetTcpRoutes() ([]*TcpRoute, error) {
+	routes := []*TcpRoute{}
+	for _, line := range strings.Split(t.RouteRule, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("分流规则 %q 需要设置为：条件 下游列表", line)
+		}
+		route := &TcpRoute{IpList: public.SplitList(fields[1])}
+		for _, ipItem := range route.IpList {
+			if _, _, err := net.SplitHostPort(ipItem); err != nil {
+				return nil, fmt.Errorf("分流规则 %q 的下游需要设置为ip:port格式", line)
+			}
+		}
+		for _, cond := range strings.Split(fields[0], ";") {
+			kv := strings.SplitN(cond, "=", 2)
+			if len(kv) != 2 || len(public.SplitList(kv[1])) == 0 {
+				return nil, fmt.Errorf("分流规则 %q 的条件 %q 需要设置为 名称=值", line, cond)
+			}
+			values := public.SplitList(kv[1])
+			switch kv[0] {
+			case "protocol":
+				route.Protocols = append(route.Protocols, values...)
+			case "cidr":
+				route.ClientCIDR = append(route.ClientCIDR, values...)
+			case "sni":
+				route.SniHosts = append(route.SniHosts, values...)
+			case "port":
+				for _, value := range values {
+					port, err := strconv.Atoi(value)
+					if err != nil {
+						return nil, fmt.Errorf("分流规则 %q 的端口 %q 不是数字", line, value)
+					}
+					route.LocalPorts = append(route.LocalPorts, port)
+				}
+			default:
+				return nil, fmt.Errorf("分流规则 %q 不支持条件 %q", line, kv[0])
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// ConnIdleTimeout 连接空闲超时
+func (t *TcpRule) ConnIdleTimeout() time.Duration {
+	return time.Duration(t.IdleTimeout) * time.Second
+}
+
+// ConnMaxLifetime 连接最大生命周期
+func (t *TcpRule) ConnMaxLifetime() time.Duration {
+	return time.Duration(t.MaxLifetime) * time.Second
+}
+
+// ServerTLSConfig 服务监听器的TLS配置，包括证书、最低版本、加密套件和ALPN
+func (t *TcpRule) ServerTLSConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if t.TlsCert != "" {
+		cert, err = tls.X509KeyPair([]byte(t.TlsCert), []byte(t.TlsKey))
+	} else {
+		cert, err = public.LoadServerCert()
+	}
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := public.ParseTLSVersion(t.TlsMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := public.ParseCipherSuites(t.TlsCiphers)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		NextProtos:   public.SplitList(t.TlsAlpn),
+	}, nil
+}
+
+// TCPServerTLSConfig TCP服务监听器的TLS配置，未开启TLS终止和客户端证书校验时返回nil
+func (s *ServiceDetail) TCPServerTLSConfig() (*tls.Config, error) {
+	if !s.TCPRule.OpenTls() && !s.AccessControl.OpenMtls() {
+		return nil, nil
+	}
+	if !s.TCPRule.OpenTls() {
+		return s.AccessControl.ServerTLSConfig()
+	}
+	config, err := s.TCPRule.ServerTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if s.AccessControl.OpenMtls() {
+		if err := s.AccessControl.SetClientAuth(config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
 // Find  方法获得数据库中tcp规则的信息
 func (t *TcpRule) Find(c *gin.Context, tx *gorm.DB, search *TcpRule) (*TcpRule, error) {
 	model := &TcpRule{}
@@ -0,0 +1,42 @@
+package dao
+
+import "testing"
+
+func TestRedisCommandAllowed(t *testing.T) {
+	rule := &TcpRule{}
+	// 没有配置允许列表时只拒绝脚本命令
+	if !rule.RedisCommandAllowed("get") || rule.RedisCommandAllowed("eval") || rule.RedisCommandAllowed("FCALL") {
+		t.Fatal("scripts should be denied by default")
+	}
+	rule = &TcpRule{RedisDenyCommands: "flushall"}
+	if rule.RedisCommandAllowed("FLUSHALL") || rule.RedisCommandAllowed("EVALSHA") || !rule.RedisCommandAllowed("SET") {
+		t.Fatal("deny list not applied")
+	}
+	// 脚本命令显式列出后允许
+	rule = &TcpRule{RedisAllowCommands: "get,eval"}
+	if !rule.RedisCommandAllowed("EVAL") || rule.RedisCommandAllowed("EVALSHA") || rule.RedisCommandAllowed("SET") {
+		t.Fatal("allow list not applied")
+	}
+}
+
+func TestGetTcpRoutes(t *testing.T) {
+	rule := &TcpRule{RouteRule: "protocol=ssh;cidr=10.0.0.0/8,192.168.1.1 10.0.0.5:22,10.0.0.6:22\n\nsni=*.example.com;port=8001 10.0.0.7:443\n"}
+	routes, err := rule.GetTcpRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("routes = %d, want 2", len(routes))
+	}
+	if routes[0].Protocols[0] != "ssh" || len(routes[0].ClientCIDR) != 2 || len(routes[0].IpList) != 2 {
+		t.Fatalf("route 0 = %+v", routes[0])
+	}
+	if routes[1].SniHosts[0] != "*.example.com" || routes[1].LocalPorts[0] != 8001 || routes[1].IpList[0] != "10.0.0.7:443" {
+		t.Fatalf("route 1 = %+v", routes[1])
+	}
+	for _, bad := range []string{"protocol=ssh", "host=a 10.0.0.5:22", "port=abc 10.0.0.5:22", "protocol=ssh 10.0.0.5", "cidr= 10.0.0.5:22"} {
+		if _, err := (&TcpRule{RouteRule: bad}).GetTcpRoutes(); err == nil {
+			t.Fatalf("route rule %q should be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"time"
+)
+
+// UdpRule udp规则信息结构体
+type UdpRule struct {
+	ID          int64 `json:"id" gorm:"primary_key"`
+	ServiceID   int64 `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Port        int   `json:"port" gorm:"column:port" description:"端口"`
+	IdleTimeout int   `json:"idle_timeout" gorm:"column:idle_timeout" description:"客户端会话空闲超时时间，单位s，为0时使用默认值"`
+}
+
+// TableName 对应数据库中的表名
+func (t *UdpRule) TableName() string {
+	return "gateway_service_udp_rule"
+}
+
+// SessionIdleTimeout 客户端会话空闲超时时间，为0时由UDP服务器使用默认值
+func (t *UdpRule) SessionIdleTimeout() time.Duration {
+	return time.Duration(t.IdleTimeout) * time.Second
+}
+
+// Find 方法获得数据库中udp规则的信息
+func (t *UdpRule) Find(c *gin.Context, tx *gorm.DB, search *UdpRule) (*UdpRule, error) {
+	model := &UdpRule{}
+	err := tx.WithContext(c).Where(search).Find(model).Error
+	return model, err
+}
+
+// FindFirst 方法获得数据库中第一个匹配的udp规则，若不存在则返回ErrRecordNotFound
+func (t *UdpRule) FindFirst(c *gin.Context, tx *gorm.DB, search *UdpRule) (*UdpRule, error) {
+	model := &UdpRule{}
+	err := tx.WithContext(c).Where(search).First(model).Error
+	return model, err
+}
+
+// Save 方法将数据保存的数据库中
+func (t *UdpRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.WithContext(c).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
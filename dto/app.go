@@ -68,12 +68,15 @@ type StatisticsOutput struct {
 
 // AppAddInput 添加租户输入信息结构体
 type AppAddInput struct {
-	AppID    string `json:"app_id" form:"app_id" comment:"租户id" validate:"required"`
-	Name     string `json:"name" form:"name" comment:"租户名称" validate:"required"`
-	Secret   string `json:"secret" form:"secret" comment:"密钥" validate:""`
-	WhiteIPS string `json:"white_ips" form:"white_ips" comment:"ip白名单，支持前缀匹配"`
-	Qpd      int64  `json:"qpd" form:"qpd" comment:"日请求量限制" validate:""`
-	Qps      int64  `json:"qps" form:"qps" comment:"每秒请求量限制" validate:""`
+	AppID          string `json:"app_id" form:"app_id" comment:"租户id" validate:"required"`
+	Name           string `json:"name" form:"name" comment:"租户名称" validate:"required"`
+	Secret         string `json:"secret" form:"secret" comment:"密钥" validate:""`
+	WhiteIPS       string `json:"white_ips" form:"white_ips" comment:"ip白名单，支持前缀匹配"`
+	Qpd            int64  `json:"qpd" form:"qpd" comment:"日请求量限制" validate:""`
+	Qps            int64  `json:"qps" form:"qps" comment:"每秒请求量限制" validate:""`
+	TokenExpires   int64  `json:"token_expires" form:"token_expires" comment:"access_token有效期" validate:"min=0"`
+	RefreshExpires int64  `json:"refresh_expires" form:"refresh_expires" comment:"refresh_token有效期" validate:"min=0"`
+	CertIdentity   string `json:"cert_identity" form:"cert_identity" comment:"客户端证书身份，证书subject CN或SAN，以逗号间隔" validate:""`
 }
 
 // BindValidParam 验证参数有效性
@@ -83,16 +86,57 @@ func (param *AppAddInput) BindValidParam(c *gin.Context) error {
 
 // AppUpdateInput 修改租户输入信息结构体
 type AppUpdateInput struct {
-	ID       int64  `json:"id" form:"id" gorm:"column:id" comment:"主键ID" validate:"required"`
-	AppID    string `json:"app_id" form:"app_id" gorm:"column:app_id" comment:"租户id" validate:""`
-	Name     string `json:"name" form:"name" gorm:"column:name" comment:"租户名称" validate:"required"`
-	Secret   string `json:"secret" form:"secret" gorm:"column:secret" comment:"密钥" validate:"required"`
-	WhiteIPS string `json:"white_ips" form:"white_ips" gorm:"column:white_ips" comment:"ip白名单，支持前缀匹配"`
-	Qpd      int64  `json:"qpd" form:"qpd" gorm:"column:qpd" comment:"日请求量限制"`
-	Qps      int64  `json:"qps" form:"qps" gorm:"column:qps" comment:"每秒请求量限制"`
+	ID             int64  `json:"id" form:"id" gorm:"column:id" comment:"主键ID" validate:"required"`
+	AppID          string `json:"app_id" form:"app_id" gorm:"column:app_id" comment:"租户id" validate:""`
+	Name           string `json:"name" form:"name" gorm:"column:name" comment:"租户名称" validate:"required"`
+	Secret         string `json:"secret" form:"secret" gorm:"column:secret" comment:"密钥" validate:"required"`
+	WhiteIPS       string `json:"white_ips" form:"white_ips" gorm:"column:white_ips" comment:"ip白名单，支持前缀匹配"`
+	Qpd            int64  `json:"qpd" form:"qpd" gorm:"column:qpd" comment:"日请求量限制"`
+	Qps            int64  `json:"qps" form:"qps" gorm:"column:qps" comment:"每秒请求量限制"`
+	TokenExpires   int64  `json:"token_expires" form:"token_expires" gorm:"column:token_expires" comment:"access_token有效期" validate:"min=0"`
+	RefreshExpires int64  `json:"refresh_expires" form:"refresh_expires" gorm:"column:refresh_expires" comment:"refresh_token有效期" validate:"min=0"`
+	CertIdentity   string `json:"cert_identity" form:"cert_identity" gorm:"column:cert_identity" comment:"客户端证书身份，证书subject CN或SAN，以逗号间隔" validate:""`
 }
 
 // BindValidParam 验证参数有效性
 func (param *AppUpdateInput) BindValidParam(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, param)
 }
+
+// ApiKeyListInput API Key列表输入信息结构体
+type ApiKeyListInput struct {
+	AppID string `json:"app_id" form:"app_id" comment:"租户id" validate:"required"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *ApiKeyListInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ApiKeyAddInput 添加API Key输入信息结构体
+type ApiKeyAddInput struct {
+	AppID    string `json:"app_id" form:"app_id" comment:"租户id" validate:"required"`
+	Label    string `json:"label" form:"label" comment:"标签" validate:"required,max=64"`
+	ExpireAt int64  `json:"expire_at" form:"expire_at" comment:"过期时间戳，0表示不过期" validate:"min=0"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *ApiKeyAddInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ApiKeyAddOutput 添加API Key输出信息结构体，明文key只在创建时返回一次
+type ApiKeyAddOutput struct {
+	ID     int64  `json:"id" form:"id" comment:"API Key ID"`
+	ApiKey string `json:"api_key" form:"api_key" comment:"API Key明文"`
+}
+
+// ApiKeyDeleteInput 吊销API Key输入信息结构体
+type ApiKeyDeleteInput struct {
+	ID int64 `json:"id" form:"id" comment:"API Key ID" validate:"required"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *ApiKeyDeleteInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
@@ -0,0 +1,79 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+)
+
+// AppGrantListInput 租户授权列表输入信息结构体
+type AppGrantListInput struct {
+	AppID     string `json:"app_id" form:"app_id" comment:"租户ID" example:"app_id_a" validate:""`   //租户ID
+	ServiceID int64  `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:""` //服务ID
+}
+
+// BindValidParam 验证参数有效性
+func (param *AppGrantListInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// AppGrantListOutput 租户授权列表输出信息结构体
+type AppGrantListOutput struct {
+	Total int64       `json:"total" form:"total" comment:"总数" example:"" validate:""` //总数
+	List  interface{} `json:"list" form:"list" comment:"列表" example:"" validate:""`   //列表
+}
+
+// AppGrantAddInput 添加租户授权输入信息结构体
+type AppGrantAddInput struct {
+	AppID     string `json:"app_id" form:"app_id" comment:"租户ID" example:"app_id_a" validate:"required"`       //租户ID
+	ServiceID int64  `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"`     //服务ID
+	Scope     string `json:"scope" form:"scope" comment:"授予的权限范围" example:"read,write" validate:"valid_scope"` //授予的权限范围
+}
+
+// BindValidParam 验证参数有效性
+func (param *AppGrantAddInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// AppGrantUpdateInput 修改租户授权输入信息结构体
+type AppGrantUpdateInput struct {
+	ID    int64  `json:"id" form:"id" comment:"授权ID" example:"1" validate:"required"`                      //授权ID
+	Scope string `json:"scope" form:"scope" comment:"授予的权限范围" example:"read,write" validate:"valid_scope"` //授予的权限范围
+}
+
+// BindValidParam 验证参数有效性
+func (param *AppGrantUpdateInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// AppGrantDeleteInput 删除租户授权或者权限规则输入信息结构体
+type AppGrantDeleteInput struct {
+	ID int64 `json:"id" form:"id" comment:"ID" example:"1" validate:"required"` //ID
+}
+
+// BindValidParam 验证参数有效性
+func (param *AppGrantDeleteInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ScopeRuleListInput 接口权限规则列表输入信息结构体
+type ScopeRuleListInput struct {
+	ServiceID int64 `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"` //服务ID
+}
+
+// BindValidParam 验证参数有效性
+func (param *ScopeRuleListInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ScopeRuleAddInput 添加接口权限规则输入信息结构体
+type ScopeRuleAddInput struct {
+	ServiceID int64  `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"`                                //服务ID
+	Method    string `json:"method" form:"method" comment:"HTTP请求方法" example:"POST" validate:"omitempty,oneof=GET POST PUT PATCH DELETE"` //HTTP请求方法
+	Path      string `json:"path" form:"path" comment:"路径前缀" example:"/test_http_service/write" validate:"required"`                      //路径前缀
+	Scope     string `json:"scope" form:"scope" comment:"需要的权限范围" example:"write" validate:"required,valid_rule"`                         //需要的权限范围
+}
+
+// BindValidParam 验证参数有效性
+func (param *ScopeRuleAddInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+	"time"
+)
+
+// CertListInput 证书列表输入信息结构体
+type CertListInput struct {
+	Info     string `json:"info" form:"info" comment:"查找信息" validate:""`
+	ExpireIn int    `json:"expire_in" form:"expire_in" comment:"多少天内过期，为0不过滤" validate:"min=0"`
+	PageSize int    `json:"page_size" form:"page_size" comment:"页数" validate:"required,min=1,max=999"`
+	PageNo   int    `json:"page_no" form:"page_no" comment:"页码" validate:"required,min=1,max=999"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *CertListInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// CertListOutput 证书列表输出信息结构体
+type CertListOutput struct {
+	List  []CertListItemOutput `json:"list" form:"list" comment:"证书列表"`
+	Total int64                `json:"total" form:"total" comment:"证书总数"`
+}
+
+// CertListItemOutput 证书列表输出具体信息结构体
+type CertListItemOutput struct {
+	ID         int64     `json:"id" form:"id"`
+	Name       string    `json:"name" form:"name" comment:"证书名称"`
+	Hosts      string    `json:"hosts" form:"hosts" comment:"证书域名"`
+	NotBefore  time.Time `json:"not_before" form:"not_before" comment:"生效时间"`
+	NotAfter   time.Time `json:"not_after" form:"not_after" comment:"过期时间"`
+	ExpireDays int       `json:"expire_days" form:"expire_days" comment:"剩余天数，已过期为负数"`
+	Expiring   bool      `json:"expiring" form:"expiring" comment:"是否即将过期"`
+	UpdatedAt  time.Time `json:"update_at" form:"update_at" comment:"更新时间"`
+}
+
+// CertDetailInput 证书详情输入信息结构体
+type CertDetailInput struct {
+	ID int64 `json:"id" form:"id" comment:"证书ID" validate:"required"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *CertDetailInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// CertDeleteInput 证书删除输入信息结构体
+type CertDeleteInput struct {
+	ID int64 `json:"id" form:"id" comment:"证书ID" validate:"required"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *CertDeleteInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// CertAddInput 添加证书输入信息结构体
+type CertAddInput struct {
+	Name    string `json:"name" form:"name" comment:"证书名称" validate:"required"`
+	CertPem string `json:"cert_pem" form:"cert_pem" comment:"PEM格式证书链" validate:"required"`
+	KeyPem  string `json:"key_pem" form:"key_pem" comment:"PEM格式私钥" validate:"required"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *CertAddInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// CertUpdateInput 更新证书输入信息结构体
+type CertUpdateInput struct {
+	ID      int64  `json:"id" form:"id" comment:"证书ID" validate:"required"`
+	Name    string `json:"name" form:"name" comment:"证书名称" validate:"required"`
+	CertPem string `json:"cert_pem" form:"cert_pem" comment:"PEM格式证书链" validate:"required"`
+	KeyPem  string `json:"key_pem" form:"key_pem" comment:"PEM格式私钥，为空时沿用原私钥" validate:""`
+}
+
+// BindValidParam 验证参数有效性
+func (param *CertUpdateInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
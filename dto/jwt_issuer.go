@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+)
+
+// JwtIssuerListInput 外部签发方列表输入信息结构体
+type JwtIssuerListInput struct {
+	ServiceID int64 `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"` //服务ID
+}
+
+// BindValidParam 验证参数有效性
+func (param *JwtIssuerListInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// JwtIssuerListOutput 外部签发方列表输出信息结构体
+type JwtIssuerListOutput struct {
+	Total int64       `json:"total" form:"total" comment:"总数" example:"" validate:""` //总数
+	List  interface{} `json:"list" form:"list" comment:"列表" example:"" validate:""`   //列表
+}
+
+// JwtIssuerAddInput 添加外部签发方输入信息结构体
+type JwtIssuerAddInput struct {
+	ServiceID      int64  `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"`                                             //服务ID
+	Issuer         string `json:"issuer" form:"issuer" comment:"签发方" example:"https://idp.example.com/" validate:"required"`                                //签发方
+	JwksURI        string `json:"jwks_uri" form:"jwks_uri" comment:"JWKS地址" example:"https://idp.example.com/.well-known/jwks.json" validate:"required"`    //JWKS地址
+	Audience       string `json:"audience" form:"audience" comment:"受众" example:"" validate:""`                                                             //受众
+	RequiredClaims string `json:"required_claims" form:"required_claims" comment:"必须的claim" example:"email_verified=true" validate:"valid_required_claims"` //必须的claim
+	ClockSkew      int    `json:"clock_skew" form:"clock_skew" comment:"允许的时钟偏差, 单位s" example:"30" validate:"min=0,max=600"`                                //允许的时钟偏差
+	ClaimHeader    string `json:"claim_header" form:"claim_header" comment:"claim转发header" example:"sub X-Auth-Subject" validate:"valid_claim_header"`      //claim转发header
+}
+
+// BindValidParam 验证参数有效性
+func (param *JwtIssuerAddInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// JwtIssuerUpdateInput 修改外部签发方输入信息结构体
+type JwtIssuerUpdateInput struct {
+	ID             int64  `json:"id" form:"id" comment:"签发方ID" example:"1" validate:"required"`                                                             //签发方ID
+	Issuer         string `json:"issuer" form:"issuer" comment:"签发方" example:"https://idp.example.com/" validate:"required"`                                //签发方
+	JwksURI        string `json:"jwks_uri" form:"jwks_uri" comment:"JWKS地址" example:"https://idp.example.com/.well-known/jwks.json" validate:"required"`    //JWKS地址
+	Audience       string `json:"audience" form:"audience" comment:"受众" example:"" validate:""`                                                             //受众
+	RequiredClaims string `json:"required_claims" form:"required_claims" comment:"必须的claim" example:"email_verified=true" validate:"valid_required_claims"` //必须的claim
+	ClockSkew      int    `json:"clock_skew" form:"clock_skew" comment:"允许的时钟偏差, 单位s" example:"30" validate:"min=0,max=600"`                                //允许的时钟偏差
+	ClaimHeader    string `json:"claim_header" form:"claim_header" comment:"claim转发header" example:"sub X-Auth-Subject" validate:"valid_claim_header"`      //claim转发header
+}
+
+// BindValidParam 验证参数有效性
+func (param *JwtIssuerUpdateInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// JwtIssuerDeleteInput 删除外部签发方输入信息结构体
+type JwtIssuerDeleteInput struct {
+	ID int64 `json:"id" form:"id" comment:"签发方ID" example:"1" validate:"required"` //签发方ID
+}
+
+// BindValidParam 验证参数有效性
+func (param *JwtIssuerDeleteInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
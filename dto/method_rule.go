@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+)
+
+// MethodRuleListInput grpc方法规则列表输入信息结构体
+type MethodRuleListInput struct {
+	ServiceID int64 `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"` //服务ID
+}
+
+// BindValidParam 验证参数有效性
+func (param *MethodRuleListInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// MethodRuleListOutput grpc方法规则列表输出信息结构体
+type MethodRuleListOutput struct {
+	Total int64       `json:"total" form:"total" comment:"总数" example:"" validate:""` //总数
+	List  interface{} `json:"list" form:"list" comment:"列表" example:"" validate:""`   //列表
+}
+
+// MethodRuleAddInput 添加grpc方法规则输入信息结构体
+type MethodRuleAddInput struct {
+	ServiceID   int64  `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"`                                             //服务ID
+	Method      string `json:"method" form:"method" comment:"完整方法名或通配" example:"/echo.Echo/*" validate:"required,valid_grpc_method"`                     //完整方法名或通配
+	AllowApp    string `json:"allow_app" form:"allow_app" comment:"允许访问的租户id，以逗号间隔" example:"" validate:""`                                              //允许访问的租户
+	DenyApp     string `json:"deny_app" form:"deny_app" comment:"禁止访问的租户id，以逗号间隔" example:"" validate:""`                                                //禁止访问的租户
+	Qps         int    `json:"qps" form:"qps" comment:"每秒请求量限制，为0不限制" example:"0" validate:"min=0"`                                                      //每秒请求量限制
+	Timeout     int    `json:"timeout" form:"timeout" comment:"调用超时时间，单位ms，为0不修改deadline" example:"0" validate:"min=0"`                                  //调用超时时间
+	TimeoutMode int    `json:"timeout_mode" form:"timeout_mode" comment:"0=作为deadline上限 1=仅在客户端未设置deadline时使用" example:"0" validate:"max=1,min=0"`       //超时方式
+	AddMetadata string `json:"add_metadata" form:"add_metadata" comment:"添加的metadata，格式: key value" example:"x-tier gold" validate:"valid_claim_header"` //添加的metadata
+}
+
+// BindValidParam 验证参数有效性
+func (param *MethodRuleAddInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// MethodRuleUpdateInput 修改grpc方法规则输入信息结构体
+type MethodRuleUpdateInput struct {
+	ID          int64  `json:"id" form:"id" comment:"规则ID" example:"1" validate:"required"`                                                              //规则ID
+	Method      string `json:"method" form:"method" comment:"完整方法名或通配" example:"/echo.Echo/*" validate:"required,valid_grpc_method"`                     //完整方法名或通配
+	AllowApp    string `json:"allow_app" form:"allow_app" comment:"允许访问的租户id，以逗号间隔" example:"" validate:""`                                              //允许访问的租户
+	DenyApp     string `json:"deny_app" form:"deny_app" comment:"禁止访问的租户id，以逗号间隔" example:"" validate:""`                                                //禁止访问的租户
+	Qps         int    `json:"qps" form:"qps" comment:"每秒请求量限制，为0不限制" example:"0" validate:"min=0"`                                                      //每秒请求量限制
+	Timeout     int    `json:"timeout" form:"timeout" comment:"调用超时时间，单位ms，为0不修改deadline" example:"0" validate:"min=0"`                                  //调用超时时间
+	TimeoutMode int    `json:"timeout_mode" form:"timeout_mode" comment:"0=作为deadline上限 1=仅在客户端未设置deadline时使用" example:"0" validate:"max=1,min=0"`       //超时方式
+	AddMetadata string `json:"add_metadata" form:"add_metadata" comment:"添加的metadata，格式: key value" example:"x-tier gold" validate:"valid_claim_header"` //添加的metadata
+}
+
+// BindValidParam 验证参数有效性
+func (param *MethodRuleUpdateInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// MethodRuleDeleteInput 删除grpc方法规则输入信息结构体
+type MethodRuleDeleteInput struct {
+	ID int64 `json:"id" form:"id" comment:"规则ID" example:"1" validate:"required"` //规则ID
+}
+
+// BindValidParam 验证参数有效性
+func (param *MethodRuleDeleteInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
@@ -7,8 +7,9 @@ import (
 
 // TokensInput Tokens输入信息结构体
 type TokensInput struct {
-	GrantType string `json:"grant_type" form:"grant_type" comment:"授权类型" example:"client_credentials" validate:"required"` //授权类型
-	Scope     string `json:"scope" form:"scope" comment:"权限范围" example:"read_write" validate:"required"`                   //权限范围
+	GrantType    string `json:"grant_type" form:"grant_type" comment:"授权类型" example:"client_credentials" validate:"required,oneof=client_credentials refresh_token"` //授权类型
+	Scope        string `json:"scope" form:"scope" comment:"权限范围，以空格间隔" example:"read write" validate:""`                                                            //权限范围
+	RefreshToken string `json:"refresh_token" form:"refresh_token" comment:"刷新token" example:"" validate:""`                                                         //刷新token
 }
 
 // BindValidParam 验证参数有效性
@@ -18,8 +19,20 @@ func (param *TokensInput) BindValidParam(c *gin.Context) error {
 
 // TokensOutput Tokens输出信息结构体
 type TokensOutput struct {
-	AccessToken string `json:"access_token" form:"access_token"` //access_token
-	ExpiresIn   int    `json:"expires_in" form:"expires_in"`     //expires_in
-	TokenType   string `json:"token_type" form:"token_type"`     //token_type
-	Scope       string `json:"scope" form:"scope"`               //scope
+	AccessToken  string `json:"access_token" form:"access_token"`   //access_token
+	ExpiresIn    int    `json:"expires_in" form:"expires_in"`       //expires_in
+	TokenType    string `json:"token_type" form:"token_type"`       //token_type
+	Scope        string `json:"scope" form:"scope"`                 //scope
+	RefreshToken string `json:"refresh_token" form:"refresh_token"` //refresh_token
+}
+
+// RevokeInput 吊销token输入信息结构体
+type RevokeInput struct {
+	Token         string `json:"token" form:"token" comment:"需要吊销的token" example:"" validate:"required"`                                                                   //需要吊销的token
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint" comment:"token类型提示" example:"refresh_token" validate:"omitempty,oneof=access_token refresh_token"` //token类型提示
+}
+
+// BindValidParam 验证参数有效性
+func (param *RevokeInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
 }
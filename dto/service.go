@@ -17,10 +17,15 @@ type ServiceAddHTTPInput struct {
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket" example:"" validate:"max=1,min=0"`        //是否支持websocket
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`              //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"` //header转换
+	GrpcTranscode  int    `json:"grpc_transcode" form:"grpc_transcode" comment:"gRPC转码" example:"" validate:"max=1,min=0"`              //REST/JSON转码为gRPC
 
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                //关键词
+	AuthType          int    `json:"auth_type" form:"auth_type" comment:"租户认证方式" example:"" validate:"max=3,min=0"`                //0=JWT 1=API Key 2=两者皆可 3=HMAC签名
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                          //黑名单ip
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                          //白名单ip
+	ClientCA          string `json:"client_ca" form:"client_ca" comment:"客户端CA证书包" example:"" validate:"valid_client_ca"`          //客户端CA证书包，PEM格式
+	ClientVerify      int    `json:"client_verify" form:"client_verify" comment:"客户端证书校验" example:"" validate:"max=2,min=0"`       //0=关闭 1=可选 2=必须
+	WhiteCertList     string `json:"white_cert_list" form:"white_cert_list" comment:"客户端证书白名单" example:"" validate:""`             //证书subject CN或SAN
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`     //服务端限流
 
@@ -31,6 +36,15 @@ type ServiceAddHTTPInput struct {
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+	UpstreamProtocol       int    `json:"upstream_protocol" form:"upstream_protocol" comment:"下游协议 0=HTTP/1.1 1=HTTP/2(TLS) 2=h2c" example:"" validate:"max=2,min=0"`
+	UpstreamMaxConns       int    `json:"upstream_max_conns" form:"upstream_max_conns" comment:"每个下游地址的最大连接数，0为不限制" example:"" validate:"min=0"`
+	UpstreamStrictStreams  int    `json:"upstream_strict_streams" form:"upstream_strict_streams" comment:"HTTP/2是否将下游的最大并发流作为全局限制" example:"" validate:"max=1,min=0"`
+	UpstreamTls            int    `json:"upstream_tls" form:"upstream_tls" comment:"是否使用TLS连接下游 0=沿用need_https 1=开启 2=关闭" validate:"max=2,min=0"`
+	UpstreamCA             string `json:"upstream_ca" form:"upstream_ca" comment:"校验下游证书的CA证书包，PEM格式，为空时使用系统CA" validate:"valid_client_ca"`
+	UpstreamSkipVerify     int    `json:"upstream_skip_verify" form:"upstream_skip_verify" comment:"是否跳过下游证书校验，仅用于开发环境" validate:"max=1,min=0"`
+	UpstreamCert           string `json:"upstream_cert" form:"upstream_cert" comment:"连接下游的客户端证书，PEM格式" validate:""`
+	UpstreamKey            string `json:"upstream_key" form:"upstream_key" comment:"连接下游的客户端私钥，PEM格式" validate:""`
+	UpstreamServerName     string `json:"upstream_server_name" form:"upstream_server_name" comment:"连接下游时的SNI及校验的证书域名" validate:""`
 }
 
 // BindValidParam 验证参数有效性
@@ -111,10 +125,15 @@ type ServiceUpdateHTTPInput struct {
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket" example:"" validate:"max=1,min=0"`          //是否支持websocket
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`                //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"`   //header转换
+	GrpcTranscode  int    `json:"grpc_transcode" form:"grpc_transcode" comment:"gRPC转码" example:"" validate:"max=1,min=0"`                //REST/JSON转码为gRPC
 
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                  //关键词
+	AuthType          int    `json:"auth_type" form:"auth_type" comment:"租户认证方式" example:"" validate:"max=3,min=0"`                  //0=JWT 1=API Key 2=两者皆可 3=HMAC签名
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                            //黑名单ip
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                            //白名单ip
+	ClientCA          string `json:"client_ca" form:"client_ca" comment:"客户端CA证书包" example:"" validate:"valid_client_ca"`            //客户端CA证书包，PEM格式
+	ClientVerify      int    `json:"client_verify" form:"client_verify" comment:"客户端证书校验" example:"" validate:"max=2,min=0"`         //0=关闭 1=可选 2=必须
+	WhiteCertList     string `json:"white_cert_list" form:"white_cert_list" comment:"客户端证书白名单" example:"" validate:""`               //证书subject CN或SAN
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流	" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`       //服务端限流
 
@@ -125,6 +144,15 @@ type ServiceUpdateHTTPInput struct {
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+	UpstreamProtocol       int    `json:"upstream_protocol" form:"upstream_protocol" comment:"下游协议 0=HTTP/1.1 1=HTTP/2(TLS) 2=h2c" example:"" validate:"max=2,min=0"`
+	UpstreamMaxConns       int    `json:"upstream_max_conns" form:"upstream_max_conns" comment:"每个下游地址的最大连接数，0为不限制" example:"" validate:"min=0"`
+	UpstreamStrictStreams  int    `json:"upstream_strict_streams" form:"upstream_strict_streams" comment:"HTTP/2是否将下游的最大并发流作为全局限制" example:"" validate:"max=1,min=0"`
+	UpstreamTls            int    `json:"upstream_tls" form:"upstream_tls" comment:"是否使用TLS连接下游 0=沿用need_https 1=开启 2=关闭" validate:"max=2,min=0"`
+	UpstreamCA             string `json:"upstream_ca" form:"upstream_ca" comment:"校验下游证书的CA证书包，PEM格式，为空时使用系统CA" validate:"valid_client_ca"`
+	UpstreamSkipVerify     int    `json:"upstream_skip_verify" form:"upstream_skip_verify" comment:"是否跳过下游证书校验，仅用于开发环境" validate:"max=1,min=0"`
+	UpstreamCert           string `json:"upstream_cert" form:"upstream_cert" comment:"连接下游的客户端证书，PEM格式" validate:""`
+	UpstreamKey            string `json:"upstream_key" form:"upstream_key" comment:"连接下游的客户端私钥，PEM格式" validate:""`
+	UpstreamServerName     string `json:"upstream_server_name" form:"upstream_server_name" comment:"连接下游时的SNI及校验的证书域名" validate:""`
 }
 
 // BindValidParam 验证参数有效性
@@ -136,25 +164,67 @@ func (param *ServiceUpdateHTTPInput) BindValidParam(c *gin.Context) error {
 type ServiceStatOutput struct {
 	Today     []int64 `json:"today" form:"today" comment:"今日流量" example:"" validate:""`         //列表
 	Yesterday []int64 `json:"yesterday" form:"yesterday" comment:"昨日流量" example:"" validate:""` //列表
+
+	TLSErrorToday     []int64 `json:"tls_error_today" form:"tls_error_today" comment:"今日TLS握手失败次数" example:"" validate:""`              //TCP服务开启TLS时返回
+	TLSHandshakeToday []int64 `json:"tls_handshake_today" form:"tls_handshake_today" comment:"今日TLS平均握手耗时，单位ms" example:"" validate:""` //TCP服务开启TLS时返回
+
+	GrpcErrorToday map[string]int64 `json:"grpc_error_today" form:"grpc_error_today" comment:"今日按状态码统计的错误数" example:"" validate:""` //gRPC服务返回
+
+	TcpActiveConns int64                     `json:"tcp_active_conns" form:"tcp_active_conns" comment:"当前并发连接数" example:"" validate:""` //TCP服务返回
+	TcpConnToday   []*public.TcpConnStatData `json:"tcp_conn_today" form:"tcp_conn_today" comment:"今日每小时连接统计" example:"" validate:""`   //TCP服务返回
+	TcpBackends    []*TcpBackendStatOutput   `json:"tcp_backends" form:"tcp_backends" comment:"今日各下游的连接统计" example:"" validate:""`    //TCP服务返回
+}
+
+// TcpBackendStatOutput TCP服务下游的连接统计
+type TcpBackendStatOutput struct {
+	Addr        string                  `json:"addr" form:"addr" comment:"下游地址" example:"" validate:""`
+	ActiveConns int64                   `json:"active_conns" form:"active_conns" comment:"当前并发连接数" example:"" validate:""`
+	Today       *public.TcpConnStatData `json:"today" form:"today" comment:"今日连接统计" example:"" validate:""`
 }
 
 // ServiceAddTcpInput 添加TCP服务输入信息结构体
 type ServiceAddTcpInput struct {
 	ServiceName    string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc    string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
-	Port           int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	Port           int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内，只接入SNI路由时为0" validate:"min=0,max=8999"`
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header头转换" validate:"
 "`
-	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
-	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
-	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
-	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
-	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
-	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	OpenAuth           int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
+	BlackList          string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteList          string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteHostName      string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
+	ClientCA           string `json:"client_ca" form:"client_ca" comment:"校验客户端证书的CA证书包，PEM格式" validate:"valid_client_ca"`
+	ClientVerify       int    `json:"client_verify" form:"client_verify" comment:"客户端证书校验 0=关闭 1=可选 2=必须" validate:"max=2,min=0"`
+	WhiteCertList      string `json:"white_cert_list" form:"white_cert_list" comment:"客户端证书白名单，证书subject CN或SAN，以逗号间隔" validate:""`
+	ClientIPFlowLimit  int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
+	ServiceFlowLimit   int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	RoundType          int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	IpList             string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
+	WeightList         string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
+	ForbidList         string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	NeedTls            int    `json:"need_tls" form:"need_tls" comment:"是否终止TLS" validate:"max=1,min=0"`
+	TlsCert            string `json:"tls_cert" form:"tls_cert" comment:"服务证书，PEM格式，为空时使用网关证书" validate:""`
+	TlsKey             string `json:"tls_key" form:"tls_key" comment:"服务私钥，PEM格式" validate:""`
+	TlsMinVersion      string `json:"tls_min_version" form:"tls_min_version" comment:"TLS最低版本 1.0/1.1/1.2/1.3" validate:"valid_tls_version"`
+	TlsCiphers         string `json:"tls_ciphers" form:"tls_ciphers" comment:"加密套件，以逗号间隔" validate:"valid_cipher_suites"`
+	TlsAlpn            string `json:"tls_alpn" form:"tls_alpn" comment:"ALPN协议，以逗号间隔" validate:""`
+	UpstreamTls        int    `json:"upstream_tls" form:"upstream_tls" comment:"是否使用TLS连接下游" validate:"max=1,min=0"`
+	UpstreamCA         string `json:"upstream_ca" form:"upstream_ca" comment:"校验下游证书的CA证书包，PEM格式，为空时使用系统CA" validate:"valid_client_ca"`
+	UpstreamSkipVerify int    `json:"upstream_skip_verify" form:"upstream_skip_verify" comment:"是否跳过下游证书校验，仅用于开发环境" validate:"max=1,min=0"`
+	UpstreamCert       string `json:"upstream_cert" form:"upstream_cert" comment:"连接下游的客户端证书，PEM格式" validate:""`
+	UpstreamKey        string `json:"upstream_key" form:"upstream_key" comment:"连接下游的客户端私钥，PEM格式" validate:""`
+	UpstreamServerName string `json:"upstream_server_name" form:"upstream_server_name" comment:"连接下游时的SNI及校验的证书域名" validate:""`
+	SniHost            string `json:"sni_host" form:"sni_host" comment:"共享端口按照SNI路由的域名，以逗号间隔，支持*.example.com" validate:"valid_iplist"`
+	SniDefault         int    `json:"sni_default" form:"sni_default" comment:"是否为共享端口的默认服务" validate:"max=1,min=0"`
+	BlackHostName      string `json:"black_host_name" form:"black_host_name" comment:"黑名单主机，以逗号间隔" validate:"valid_iplist"`
+	Protocol           int    `json:"protocol" form:"protocol" comment:"解析的应用层协议 0=原始TCP 1=redis" validate:"max=1,min=0"`
+	RedisAllowCommands string `json:"redis_allow_commands" form:"redis_allow_commands" comment:"允许的redis命令，以逗号间隔，为空时不限制，EVAL、FCALL等脚本命令需要显式列出" validate:""`
+	RedisDenyCommands  string `json:"redis_deny_commands" form:"redis_deny_commands" comment:"禁止的redis命令，以逗号间隔，优先级高于允许列表" validate:""`
+	RedisReplicaList   string `json:"redis_replica_list" form:"redis_replica_list" comment:"redis从节点 ip:port，以逗号间隔，只读命令发往从节点" validate:""`
+	IdleTimeout        int    `json:"idle_timeout" form:"idle_timeout" comment:"连接空闲超时，单位s，0为不限制" validate:"min=0"`
+	MaxLifetime        int    `json:"max_lifetime" form:"max_lifetime" comment:"连接最大生命周期，单位s，0为不限制" validate:"min=0"`
+	MaxConns           int    `json:"max_conns" form:"max_conns" comment:"服务最大并发连接数，0为不限制" validate:"min=0"`
+	RouteRule          string `json:"route_rule" form:"route_rule" comment:"分流规则，每行一条：条件 下游列表，例如 protocol=ssh;cidr=10.0.0.0/8 10.0.0.5:22" validate:""`
 }
 
 // BindValidParam 验证参数有效性
@@ -164,20 +234,46 @@ func (param *ServiceAddTcpInput) BindValidParam(c *gin.Context) error {
 
 // ServiceUpdateTcpInput 修改TCP服务输入信息结构体
 type ServiceUpdateTcpInput struct {
-	ID                int64  `json:"id" form:"id" comment:"服务ID" validate:"required"`
-	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
-	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
-	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
-	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
-	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
-	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
-	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
-	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
-	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
-	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	ID                 int64  `json:"id" form:"id" comment:"服务ID" validate:"required"`
+	ServiceName        string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
+	ServiceDesc        string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
+	Port               int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内，只接入SNI路由时为0" validate:"min=0,max=8999"`
+	OpenAuth           int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
+	BlackList          string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteList          string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteHostName      string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
+	ClientCA           string `json:"client_ca" form:"client_ca" comment:"校验客户端证书的CA证书包，PEM格式" validate:"valid_client_ca"`
+	ClientVerify       int    `json:"client_verify" form:"client_verify" comment:"客户端证书校验 0=关闭 1=可选 2=必须" validate:"max=2,min=0"`
+	WhiteCertList      string `json:"white_cert_list" form:"white_cert_list" comment:"客户端证书白名单，证书subject CN或SAN，以逗号间隔" validate:""`
+	ClientIPFlowLimit  int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
+	ServiceFlowLimit   int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	RoundType          int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	IpList             string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
+	WeightList         string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
+	ForbidList         string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	NeedTls            int    `json:"need_tls" form:"need_tls" comment:"是否终止TLS" validate:"max=1,min=0"`
+	TlsCert            string `json:"tls_cert" form:"tls_cert" comment:"服务证书，PEM格式，为空时使用网关证书" validate:""`
+	TlsKey             string `json:"tls_key" form:"tls_key" comment:"服务私钥，PEM格式" validate:""`
+	TlsMinVersion      string `json:"tls_min_version" form:"tls_min_version" comment:"TLS最低版本 1.0/1.1/1.2/1.3" validate:"valid_tls_version"`
+	TlsCiphers         string `json:"tls_ciphers" form:"tls_ciphers" comment:"加密套件，以逗号间隔" validate:"valid_cipher_suites"`
+	TlsAlpn            string `json:"tls_alpn" form:"tls_alpn" comment:"ALPN协议，以逗号间隔" validate:""`
+	UpstreamTls        int    `json:"upstream_tls" form:"upstream_tls" comment:"是否使用TLS连接下游" validate:"max=1,min=0"`
+	UpstreamCA         string `json:"upstream_ca" form:"upstream_ca" comment:"校验下游证书的CA证书包，PEM格式，为空时使用系统CA" validate:"valid_client_ca"`
+	UpstreamSkipVerify int    `json:"upstream_skip_verify" form:"upstream_skip_verify" comment:"是否跳过下游证书校验，仅用于开发环境" validate:"max=1,min=0"`
+	UpstreamCert       string `json:"upstream_cert" form:"upstream_cert" comment:"连接下游的客户端证书，PEM格式" validate:""`
+	UpstreamKey        string `json:"upstream_key" form:"upstream_key" comment:"连接下游的客户端私钥，PEM格式" validate:""`
+	UpstreamServerName string `json:"upstream_server_name" form:"upstream_server_name" comment:"连接下游时的SNI及校验的证书域名" validate:""`
+	SniHost            string `json:"sni_host" form:"sni_host" comment:"共享端口按照SNI路由的域名，以逗号间隔，支持*.example.com" validate:"valid_iplist"`
+	SniDefault         int    `json:"sni_default" form:"sni_default" comment:"是否为共享端口的默认服务" validate:"max=1,min=0"`
+	BlackHostName      string `json:"black_host_name" form:"black_host_name" comment:"黑名单主机，以逗号间隔" validate:"valid_iplist"`
+	Protocol           int    `json:"protocol" form:"protocol" comment:"解析的应用层协议 0=原始TCP 1=redis" validate:"max=1,min=0"`
+	RedisAllowCommands string `json:"redis_allow_commands" form:"redis_allow_commands" comment:"允许的redis命令，以逗号间隔，为空时不限制，EVAL、FCALL等脚本命令需要显式列出" validate:""`
+	RedisDenyCommands  string `json:"redis_deny_commands" form:"redis_deny_commands" comment:"禁止的redis命令，以逗号间隔，优先级高于允许列表" validate:""`
+	RedisReplicaList   string `json:"redis_replica_list" form:"redis_replica_list" comment:"redis从节点 ip:port，以逗号间隔，只读命令发往从节点" validate:""`
+	IdleTimeout        int    `json:"idle_timeout" form:"idle_timeout" comment:"连接空闲超时，单位s，0为不限制" validate:"min=0"`
+	MaxLifetime        int    `json:"max_lifetime" form:"max_lifetime" comment:"连接最大生命周期，单位s，0为不限制" validate:"min=0"`
+	MaxConns           int    `json:"max_conns" form:"max_conns" comment:"服务最大并发连接数，0为不限制" validate:"min=0"`
+	RouteRule          string `json:"route_rule" form:"route_rule" comment:"分流规则，每行一条：条件 下游列表，例如 protocol=ssh;cidr=10.0.0.0/8 10.0.0.5:22" validate:""`
 }
 
 // BindValidParam 验证参数有效性
@@ -187,47 +283,118 @@ func (param *ServiceUpdateTcpInput) BindValidParam(c *gin.Context) error {
 
 // ServiceAddGrpcInput 添加grpc服务输入信息结构体
 type ServiceAddGrpcInput struct {
+	ServiceName        string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
+	ServiceDesc        string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
+	Port               int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	HeaderTransfor     string `json:"header_transfor" form:"header_transfor" comment:"metadata转换" validate:"valid_header_transfor"`
+	WebPort            int    `json:"web_port" form:"web_port" comment:"gRPC-Web端口，为0时不开启，需要设置8001-8999范围内" validate:"min=0,max=8999"`
+	WebCorsOrigin      string `json:"web_cors_origin" form:"web_cors_origin" comment:"gRPC-Web允许跨域的来源，以逗号间隔，*表示所有来源，为空时不处理跨域预检" validate:""`
+	ReflectionMode     int    `json:"reflection_mode" form:"reflection_mode" comment:"服务反射请求处理方式 0=转发给下游 1=使用上传的描述文件应答 2=关闭" validate:"max=2,min=0"`
+	OpenAuth           int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
+	AuthType           int    `json:"auth_type" form:"auth_type" comment:"租户认证方式 0=JWT 1=API Key 2=两者皆可" validate:"max=2,min=0"`
+	BlackList          string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteList          string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteHostName      string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
+	ClientCA           string `json:"client_ca" form:"client_ca" comment:"校验客户端证书的CA证书包，PEM格式" validate:"valid_client_ca"`
+	ClientVerify       int    `json:"client_verify" form:"client_verify" comment:"客户端证书校验 0=关闭 1=可选 2=必须" validate:"max=2,min=0"`
+	WhiteCertList      string `json:"white_cert_list" form:"white_cert_list" comment:"客户端证书白名单，证书subject CN或SAN，以逗号间隔" validate:""`
+	ClientIPFlowLimit  int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
+	ServiceFlowLimit   int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	RoundType          int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	IpList             string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
+	WeightList         string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
+	ForbidList         string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	UpstreamTls        int    `json:"upstream_tls" form:"upstream_tls" comment:"是否使用TLS连接下游" validate:"max=1,min=0"`
+	UpstreamCA         string `json:"upstream_ca" form:"upstream_ca" comment:"校验下游证书的CA证书包，PEM格式，为空时使用系统CA" validate:"valid_client_ca"`
+	UpstreamSkipVerify int    `json:"upstream_skip_verify" form:"upstream_skip_verify" comment:"是否跳过下游证书校验，仅用于开发环境" validate:"max=1,min=0"`
+	UpstreamCert       string `json:"upstream_cert" form:"upstream_cert" comment:"连接下游的客户端证书，PEM格式" validate:""`
+	UpstreamKey        string `json:"upstream_key" form:"upstream_key" comment:"连接下游的客户端私钥，PEM格式" validate:""`
+	UpstreamServerName string `json:"upstream_server_name" form:"upstream_server_name" comment:"连接下游时的SNI及校验的证书域名" validate:""`
+}
+
+// BindValidParam 验证参数有效性
+func (param *ServiceAddGrpcInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ServiceUpdateGrpcInput 修改Grpc服务输入信息结构体
+type ServiceUpdateGrpcInput struct {
+	ID                 int64  `json:"id" form:"id" comment:"服务ID" validate:"required"`
+	ServiceName        string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
+	ServiceDesc        string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
+	Port               int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	HeaderTransfor     string `json:"header_transfor" form:"header_transfor" comment:"metadata转换" validate:"valid_header_transfor"`
+	WebPort            int    `json:"web_port" form:"web_port" comment:"gRPC-Web端口，为0时不开启，需要设置8001-8999范围内" validate:"min=0,max=8999"`
+	WebCorsOrigin      string `json:"web_cors_origin" form:"web_cors_origin" comment:"gRPC-Web允许跨域的来源，以逗号间隔，*表示所有来源，为空时不处理跨域预检" validate:""`
+	ReflectionMode     int    `json:"reflection_mode" form:"reflection_mode" comment:"服务反射请求处理方式 0=转发给下游 1=使用上传的描述文件应答 2=关闭" validate:"max=2,min=0"`
+	OpenAuth           int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
+	AuthType           int    `json:"auth_type" form:"auth_type" comment:"租户认证方式 0=JWT 1=API Key 2=两者皆可" validate:"max=2,min=0"`
+	BlackList          string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteList          string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteHostName      string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
+	ClientCA           string `json:"client_ca" form:"client_ca" comment:"校验客户端证书的CA证书包，PEM格式" validate:"valid_client_ca"`
+	ClientVerify       int    `json:"client_verify" form:"client_verify" comment:"客户端证书校验 0=关闭 1=可选 2=必须" validate:"max=2,min=0"`
+	WhiteCertList      string `json:"white_cert_list" form:"white_cert_list" comment:"客户端证书白名单，证书subject CN或SAN，以逗号间隔" validate:""`
+	ClientIPFlowLimit  int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
+	ServiceFlowLimit   int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
+	RoundType          int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	IpList             string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
+	WeightList         string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
+	ForbidList         string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	UpstreamTls        int    `json:"upstream_tls" form:"upstream_tls" comment:"是否使用TLS连接下游" validate:"max=1,min=0"`
+	UpstreamCA         string `json:"upstream_ca" form:"upstream_ca" comment:"校验下游证书的CA证书包，PEM格式，为空时使用系统CA" validate:"valid_client_ca"`
+	UpstreamSkipVerify int    `json:"upstream_skip_verify" form:"upstream_skip_verify" comment:"是否跳过下游证书校验，仅用于开发环境" validate:"max=1,min=0"`
+	UpstreamCert       string `json:"upstream_cert" form:"upstream_cert" comment:"连接下游的客户端证书，PEM格式" validate:""`
+	UpstreamKey        string `json:"upstream_key" form:"upstream_key" comment:"连接下游的客户端私钥，PEM格式" validate:""`
+	UpstreamServerName string `json:"upstream_server_name" form:"upstream_server_name" comment:"连接下游时的SNI及校验的证书域名" validate:""`
+}
+
+// BindValidParam 验证参数有效性
+func (param *ServiceUpdateGrpcInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ServiceAddUdpInput 添加UDP服务输入信息结构体
+type ServiceAddUdpInput struct {
 	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
-	HeaderTransfor    string `json:"header_transfor" form:"header_transfor" comment:"metadata转换" validate:"valid_header_transfor"`
+	IdleTimeout       int    `json:"idle_timeout" form:"idle_timeout" comment:"客户端会话空闲超时时间，单位s，为0时使用默认值60s" validate:"min=0"`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
-	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
-	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP每秒数据包数限制" validate:""`
+	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端每秒数据包数限制" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略，一致性hash时按客户端IP选择下游" validate:"max=3,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
 }
 
 // BindValidParam 验证参数有效性
-func (param *ServiceAddGrpcInput) BindValidParam(c *gin.Context) error {
+func (param *ServiceAddUdpInput) BindValidParam(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, param)
 }
 
-// ServiceUpdateGrpcInput 修改Grpc服务输入信息结构体
-type ServiceUpdateGrpcInput struct {
+// ServiceUpdateUdpInput 修改UDP服务输入信息结构体
+type ServiceUpdateUdpInput struct {
 	ID                int64  `json:"id" form:"id" comment:"服务ID" validate:"required"`
 	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
-	HeaderTransfor    string `json:"header_transfor" form:"header_transfor" comment:"metadata转换" validate:"valid_header_transfor"`
+	IdleTimeout       int    `json:"idle_timeout" form:"idle_timeout" comment:"客户端会话空闲超时时间，单位s，为0时使用默认值60s" validate:"min=0"`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
-	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
-	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
-	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP每秒数据包数限制" validate:""`
+	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端每秒数据包数限制" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略，一致性hash时按客户端IP选择下游" validate:"max=3,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
 }
 
 // BindValidParam 验证参数有效性
-func (param *ServiceUpdateGrpcInput) BindValidParam(c *gin.Context) error {
+func (param *ServiceUpdateUdpInput) BindValidParam(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, param)
 }
@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/public"
+	"time"
+)
+
+// ServiceDescriptorUploadInput 上传protobuf描述文件输入信息结构体
+type ServiceDescriptorUploadInput struct {
+	ServiceID     int64  `json:"service_id" form:"service_id" comment:"服务ID" validate:"required"`
+	DescriptorSet string `json:"descriptor_set" form:"descriptor_set" comment:"base64编码的描述文件，由protoc --include_imports --descriptor_set_out生成" validate:"required"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *ServiceDescriptorUploadInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ServiceDescriptorInput 查询、删除描述文件输入信息结构体
+type ServiceDescriptorInput struct {
+	ServiceID int64 `json:"service_id" form:"service_id" comment:"服务ID" validate:"required"`
+}
+
+// BindValidParam 验证参数有效性
+func (param *ServiceDescriptorInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+// ServiceDescriptorOutput 描述文件详情输出信息结构体
+type ServiceDescriptorOutput struct {
+	ServiceID int64                          `json:"service_id" form:"service_id" comment:"服务ID"`
+	UpdatedAt time.Time                      `json:"update_at" form:"update_at" comment:"更新时间"`
+	Routes    []ServiceDescriptorRouteOutput `json:"routes" form:"routes" comment:"转码路由"`
+	Services  []string                       `json:"services" form:"services" comment:"描述文件中定义的gRPC服务"`
+}
+
+// ServiceDescriptorRouteOutput 转码路由输出信息结构体
+type ServiceDescriptorRouteOutput struct {
+	HttpMethod string `json:"http_method" form:"http_method" comment:"HTTP方法"`
+	Path       string `json:"path" form:"path" comment:"路径模板"`
+	GrpcMethod string `json:"grpc_method" form:"grpc_method" comment:"gRPC方法"`
+}
@@ -0,0 +1,40 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// GrpcAccessLogMiddleware grpc访问日志和错误计数中间件，需要作为第一个拦截器
+// 网关拒绝的请求和下游返回的错误都按照状态码分别计数
+func GrpcAccessLogMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		startTime := time.Now()
+		err := handler(srv, ss)
+		code := status.Code(err)
+		clientAddr := ""
+		if peerCtx, ok := peer.FromContext(ss.Context()); ok {
+			clientAddr = peerCtx.Addr.String()
+		}
+		public.ContextNotice(ss.Context(), "_com_grpc_access", map[string]interface{}{
+			"service":   serviceDetail.Info.ServiceName,
+			"method":    info.FullMethod,
+			"client":    clientAddr,
+			"code":      code.String(),
+			"reason":    errorReason(err),
+			"proc_time": time.Since(startTime).Seconds(),
+		})
+		if code != codes.OK {
+			if counter, counterErr := public.FlowCounterHandler.GetCounter(
+				public.FlowGrpcErrorPrefix + serviceDetail.Info.ServiceName + "_" + code.String()); counterErr == nil {
+				counter.Increase()
+			}
+		}
+		return err
+	}
+}
@@ -2,10 +2,10 @@ package grpc_proxy_middleware
 
 import (
 	"fmt"
-	"github.com/pkg/errors"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
 	"log"
 	"strings"
@@ -20,7 +20,7 @@ func GrpcBlackListMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 		}
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
-			return errors.New("peer not found with context")
+			return internalError("peer not found with context")
 		}
 		peerAddr := peerCtx.Addr.String()
 		addrPos := strings.LastIndex(peerAddr, ":")
@@ -31,7 +31,7 @@ func GrpcBlackListMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 		}
 		if serviceDetail.AccessControl.OpenAuth == 1 && len(whileIpList) == 0 && len(blackIpList) > 0 {
 			if public.InStringSlice(blackIpList, clientIP) {
-				return errors.New(fmt.Sprintf("%s in black ip list", clientIP))
+				return gatewayError(codes.PermissionDenied, ReasonIPBlocked, fmt.Sprintf("%s in black ip list", clientIP), errorMetadata(serviceDetail, info))
 			}
 		}
 		if err := handler(srv, ss); err != nil {
@@ -0,0 +1,122 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/e421083458/golang_common/lib"
+	"github.com/zhj/go_gateway/dao"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"time"
+)
+
+// ErrorDomain 网关拒绝请求时ErrorInfo中的domain
+const ErrorDomain = "go_gateway"
+
+// 网关拒绝请求的原因，写在ErrorInfo的reason中，客户端可以据此区分同一状态码下的不同原因
+const (
+	ReasonInternal           = "GATEWAY_INTERNAL"
+	ReasonCertRequired       = "CLIENT_CERT_REQUIRED"
+	ReasonApiKeyInvalid      = "API_KEY_INVALID"
+	ReasonTokenInvalid       = "TOKEN_INVALID"
+	ReasonTokenRevoked       = "TOKEN_REVOKED"
+	ReasonAppNotFound        = "APP_NOT_FOUND"
+	ReasonAppNotGranted      = "APP_NOT_GRANTED"
+	ReasonScopeMissing       = "SCOPE_MISSING"
+	ReasonIPBlocked          = "IP_BLOCKED"
+	ReasonIPNotAllowed       = "IP_NOT_ALLOWED"
+	ReasonCertNotAllowed     = "CERT_NOT_ALLOWED"
+	ReasonMethodDenied       = "METHOD_DENIED"
+	ReasonServiceRateLimited = "SERVICE_RATE_LIMITED"
+	ReasonClientRateLimited  = "CLIENT_RATE_LIMITED"
+	ReasonAppRateLimited     = "APP_RATE_LIMITED"
+	ReasonMethodRateLimited  = "METHOD_RATE_LIMITED"
+	ReasonAppQuotaExceeded   = "APP_QUOTA_EXCEEDED"
+)
+
+// gatewayError 返回带有ErrorInfo的状态错误，metadata中的键值会原样返回给客户端
+func gatewayError(code codes.Code, reason string, message string, metadata map[string]string) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// internalError 网关内部处理失败
+func internalError(message string) error {
+	return gatewayError(codes.Internal, ReasonInternal, message, nil)
+}
+
+// limitError 返回ResourceExhausted状态错误，除ErrorInfo外附带QuotaFailure和建议的重试间隔RetryInfo
+// subject为被限制的对象，如 service:name、client:ip、app:id
+func limitError(reason string, subject string, message string, retryDelay time.Duration, metadata map[string]string) error {
+	st := status.New(codes.ResourceExhausted, message)
+	if detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason:   reason,
+			Domain:   ErrorDomain,
+			Metadata: metadata,
+		},
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{
+				Subject:     subject,
+				Description: message,
+			}},
+		},
+		&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(retryDelay),
+		},
+	); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// qpsRetryDelay 每秒限流时建议的重试间隔，即产生一个令牌所需的时间
+func qpsRetryDelay(qps float64) time.Duration {
+	if qps <= 0 {
+		return time.Second
+	}
+	delay := time.Duration(float64(time.Second) / qps)
+	if delay < time.Millisecond {
+		delay = time.Millisecond
+	}
+	return delay
+}
+
+// qpdRetryDelay 日请求量超限时建议的重试间隔，即距离第二天零点的时间
+func qpdRetryDelay(now time.Time) time.Duration {
+	if lib.TimeLocation != nil {
+		now = now.In(lib.TimeLocation)
+	}
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	return tomorrow.Sub(now)
+}
+
+// errorReason 获取状态错误中ErrorInfo的reason，没有时返回空
+func errorReason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+// errorMetadata ErrorInfo中返回的服务名和方法名
+func errorMetadata(serviceDetail *dao.ServiceDetail, info *grpc.StreamServerInfo) map[string]string {
+	return map[string]string{
+		"service": serviceDetail.Info.ServiceName,
+		"method":  info.FullMethod,
+	}
+}
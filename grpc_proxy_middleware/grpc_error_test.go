@@ -0,0 +1,63 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/zhj/go_gateway/dao"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestGrpcMethodRuleQpsErrorDetails(t *testing.T) {
+	rule := &dao.MethodRule{ID: 4, Method: "/echo.Echo/UnaryEcho", Qps: 2}
+	var err error
+	for i := 0; i < 7 && err == nil; i++ {
+		err = methodRuleCall(rule, appContext(""), okHandler)
+	}
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expect ResourceExhausted, got %v", err)
+	}
+	var info *errdetails.ErrorInfo
+	var quota *errdetails.QuotaFailure
+	var retry *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = detail
+		case *errdetails.QuotaFailure:
+			quota = detail
+		case *errdetails.RetryInfo:
+			retry = detail
+		}
+	}
+	if info == nil || info.Reason != ReasonMethodRateLimited || info.Domain != ErrorDomain ||
+		info.Metadata["method"] != "/echo.Echo/UnaryEcho" {
+		t.Fatalf("unexpected ErrorInfo: %v", info)
+	}
+	if quota == nil || len(quota.Violations) != 1 || quota.Violations[0].Subject != "method:/echo.Echo/UnaryEcho" {
+		t.Fatalf("unexpected QuotaFailure: %v", quota)
+	}
+	if retry == nil || retry.RetryDelay.AsDuration() != 500*time.Millisecond {
+		t.Fatalf("unexpected RetryInfo: %v", retry)
+	}
+	if errorReason(err) != ReasonMethodRateLimited {
+		t.Fatalf("unexpected reason: %v", errorReason(err))
+	}
+}
+
+func TestGrpcMethodRuleDenyErrorReason(t *testing.T) {
+	rule := &dao.MethodRule{ID: 5, Method: "/echo.Echo/*", DenyApp: "app_b"}
+	err := methodRuleCall(rule, appContext("app_b"), okHandler)
+	if status.Code(err) != codes.PermissionDenied || errorReason(err) != ReasonMethodDenied {
+		t.Fatalf("expect PermissionDenied with %v, got %v", ReasonMethodDenied, err)
+	}
+}
+
+func TestQpdRetryDelay(t *testing.T) {
+	now := time.Date(2021, 4, 1, 23, 30, 0, 0, time.Local)
+	if delay := qpdRetryDelay(now); delay != 30*time.Minute {
+		t.Fatalf("expect 30m, got %v", delay)
+	}
+}
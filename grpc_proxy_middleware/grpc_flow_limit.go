@@ -2,7 +2,6 @@ package grpc_proxy_middleware
 
 import (
 	"fmt"
-	"github.com/pkg/errors"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
 	"google.golang.org/grpc"
@@ -19,15 +18,17 @@ func GrpcFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName,
 				float64(serviceDetail.AccessControl.ServiceFlowLimit))
 			if err != nil {
-				return err
+				return internalError(err.Error())
 			}
 			if !serviceLimiter.Allow() {
-				return errors.New(fmt.Sprintf("service flow limit %v", serviceDetail.AccessControl.ServiceFlowLimit))
+				return limitError(ReasonServiceRateLimited, "service:"+serviceDetail.Info.ServiceName,
+					fmt.Sprintf("service flow limit %v", serviceDetail.AccessControl.ServiceFlowLimit),
+					qpsRetryDelay(float64(serviceDetail.AccessControl.ServiceFlowLimit)), errorMetadata(serviceDetail, info))
 			}
 		}
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
-			return errors.New("peer not found with context")
+			return internalError("peer not found with context")
 		}
 		peerAddr := peerCtx.Addr.String()
 		addrPos := strings.LastIndex(peerAddr, ":")
@@ -37,10 +38,12 @@ func GrpcFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+clientIP,
 				float64(serviceDetail.AccessControl.ClientIPFlowLimit))
 			if err != nil {
-				return err
+				return internalError(err.Error())
 			}
 			if !clientLimiter.Allow() {
-				return errors.New(fmt.Sprintf("%v flow limit %v", clientIP, serviceDetail.AccessControl.ClientIPFlowLimit))
+				return limitError(ReasonClientRateLimited, "client:"+clientIP,
+					fmt.Sprintf("%v flow limit %v", clientIP, serviceDetail.AccessControl.ClientIPFlowLimit),
+					qpsRetryDelay(float64(serviceDetail.AccessControl.ClientIPFlowLimit)), errorMetadata(serviceDetail, info))
 			}
 		}
 		if err := handler(srv, ss); err != nil {
@@ -1,7 +1,7 @@
 package grpc_proxy_middleware
 
 import (
-	"github.com/pkg/errors"
+	"fmt"
 	"github.com/zhj/go_gateway/dao"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -14,7 +14,7 @@ func GrpcHeaderTransferMiddleware(serviceDetail *dao.ServiceDetail) func(srv int
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
-			return errors.New("miss metadata from context")
+			return internalError("miss metadata from context")
 		}
 		for _, item := range strings.Split(serviceDetail.GRPCRule.HeaderTransfor, ",") {
 			items := strings.Split(item, " ")
@@ -29,7 +29,7 @@ func GrpcHeaderTransferMiddleware(serviceDetail *dao.ServiceDetail) func(srv int
 			}
 		}
 		if err:=ss.SetHeader(md);err!=nil{
-			return errors.WithMessage(err,"SetHeader")
+			return internalError(fmt.Sprintf("SetHeader: %v", err))
 		}
 		if err := handler(srv, ss); err != nil {
 			log.Printf("RPC failed with error %v\n", err)
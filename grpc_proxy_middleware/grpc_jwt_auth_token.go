@@ -1,10 +1,12 @@
 package grpc_proxy_middleware
 
 import (
-	"github.com/pkg/errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"log"
 	"strings"
@@ -15,7 +17,13 @@ func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error{
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
-			return errors.New("miss metadata from context")
+			return internalError("miss metadata from context")
+		}
+		// 清除客户端伪造的claim转发metadata
+		for _, issuer := range serviceDetail.JwtIssuerList {
+			for name := range issuer.ClaimHeaders(jwt.MapClaims{}) {
+				delete(md, strings.ToLower(name))
+			}
 		}
 		authToken:=""
 		auths:=md.Get("Authorization")
@@ -23,23 +31,91 @@ func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 			authToken = auths[0]
 		}
 		token:=strings.ReplaceAll(authToken,"Bearer ","")
+		if !serviceDetail.AccessControl.AllowJwt() {
+			token = ""
+		}
+		// 取出API Key，并删除以免转发给后端
+		apiKey := ""
+		if serviceDetail.AccessControl.AllowApiKey() {
+			apiKeyName := strings.ToLower(dao.ApiKeyHeaderName())
+			if apiKeys := md.Get(apiKeyName); len(apiKeys) > 0 {
+				apiKey = apiKeys[0]
+			}
+			delete(md, apiKeyName)
+		}
 		appMatched:=false
-		if token!=""{
+		// token中携带的权限范围以及租户在该服务上的授权
+		tokenScopes := []string{}
+		var appGrant *dao.AppGrant
+		externalIssuer := false
+		if appInfo := grpcCertApp(ss.Context(), serviceDetail); appInfo != nil {
+			// 客户端证书已经确定了租户，使用租户在服务上被授予的权限范围
+			appMatched = true
+			appGrant = serviceDetail.MatchAppGrant(appInfo.AppID)
+			if appGrant != nil {
+				tokenScopes = public.ScopeList(appGrant.Scope)
+			}
+		} else if apiKey != "" {
+			appInfo, err := dao.ApiKeyManagerHandler.Verify(apiKey)
+			if err != nil {
+				return gatewayError(codes.Unauthenticated, ReasonApiKeyInvalid, fmt.Sprintf("ApiKeyVerify: %v", err), errorMetadata(serviceDetail, info))
+			}
+			md.Set("app", public.Obj2Json(appInfo))
+			appMatched = true
+			appGrant = serviceDetail.MatchAppGrant(appInfo.AppID)
+			// API Key没有单独的权限范围，使用租户在服务上被授予的权限范围
+			if appGrant != nil {
+				tokenScopes = public.ScopeList(appGrant.Scope)
+			}
+		} else if issuer := serviceDetail.MatchJwtIssuer(public.JwtUnverifiedIssuer(token)); token != "" && issuer != nil {
+			claims, err := issuer.Verify(token)
+			if err != nil {
+				return gatewayError(codes.Unauthenticated, ReasonTokenInvalid, fmt.Sprintf("JwtIssuerVerify: %v", err), errorMetadata(serviceDetail, info))
+			}
+			for name, value := range issuer.ClaimHeaders(claims) {
+				md.Set(name, value)
+			}
+			tokenScopes = dao.ClaimScopes(claims)
+			externalIssuer = true
+			appMatched = true
+		} else if token!=""{
 			claims,err:=public.JwtDecode(token)
 			if err!=nil{
-				return errors.WithMessage(err,"JwtDecode")
+				return gatewayError(codes.Unauthenticated, ReasonTokenInvalid, fmt.Sprintf("JwtDecode: %v", err), errorMetadata(serviceDetail, info))
+			}
+			// 检查token是否已被吊销
+			revoked, err := public.JwtIsRevoked(claims)
+			if err != nil {
+				return internalError(fmt.Sprintf("JwtIsRevoked: %v", err))
+			}
+			if revoked {
+				return gatewayError(codes.Unauthenticated, ReasonTokenRevoked, "token revoked", errorMetadata(serviceDetail, info))
 			}
 			appList:=dao.AppManagerHandler.GetAppList()
 			for _,appInfo:=range appList{
 				if appInfo.AppID==claims.Issuer{
 					md.Set("app",public.Obj2Json(appInfo))
 					appMatched = true
+					appGrant = serviceDetail.MatchAppGrant(appInfo.AppID)
+					tokenScopes = public.ScopeList(claims.Scope)
 					break
 				}
 			}
 		}
 		if serviceDetail.AccessControl.OpenAuth==1 && !appMatched{
-			return errors.New("not match valid app")
+			return gatewayError(codes.Unauthenticated, ReasonAppNotFound, "not match valid app", errorMetadata(serviceDetail, info))
+		}
+		if serviceDetail.AccessControl.OpenAuth == 1 {
+			// 服务配置了授权时租户必须被授权访问该服务
+			if !externalIssuer && appGrant == nil && serviceDetail.GrantRequired() {
+				return gatewayError(codes.PermissionDenied, ReasonAppNotGranted, "app not granted to service", errorMetadata(serviceDetail, info))
+			}
+			// token和授权中都必须包含方法需要的权限范围
+			for _, scope := range serviceDetail.RequiredScopes("", info.FullMethod) {
+				if !public.InStringSlice(tokenScopes, scope) || (appGrant != nil && !appGrant.HasScope(scope)) {
+					return gatewayError(codes.PermissionDenied, ReasonScopeMissing, "missing scope "+scope, errorMetadata(serviceDetail, info))
+				}
+			}
 		}
 		if err := handler(srv, ss);err != nil {
 			log.Printf("GrpcJwtAuthTokenMiddleware failed with error %v\n", err)
@@ -3,12 +3,12 @@ package grpc_proxy_middleware
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/pkg/errors"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"log"
+	"time"
 )
 
 // GrpcJwtFlowCountMiddleware jwt流量计数器
@@ -16,7 +16,7 @@ func GrpcJwtFlowCountMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error{
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
-			return errors.New("miss metadata from context")
+			return internalError("miss metadata from context")
 		}
 		appInfos := md.Get("app")
 		if len(appInfos)==0 {
@@ -29,15 +29,17 @@ func GrpcJwtFlowCountMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 
 		appInfo := &dao.App{}
 		if err:=json.Unmarshal([]byte(appInfos[0]),appInfo);err!=nil{
-			return err
+			return internalError(err.Error())
 		}
 		appCounter, err := public.FlowCounterHandler.GetCounter(public.FlowAppPrefix + appInfo.AppID)
 		if err != nil {
-			return err
+			return internalError(err.Error())
 		}
 		appCounter.Increase()
 		if appInfo.Qpd>0 && appCounter.TotalCount>appInfo.Qpd{
-			return errors.New(fmt.Sprintf("租户日请求量限流 limit:%v current:%v",appInfo.Qpd,appCounter.TotalCount))
+			return limitError(ReasonAppQuotaExceeded, "app:"+appInfo.AppID,
+				fmt.Sprintf("租户日请求量限流 limit:%v current:%v", appInfo.Qpd, appCounter.TotalCount),
+				qpdRetryDelay(time.Now()), errorMetadata(serviceDetail, info))
 		}
 		if err := handler(srv, ss);err != nil {
 			log.Printf("RPC failed with error %v\n", err)
@@ -3,7 +3,6 @@ package grpc_proxy_middleware
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/pkg/errors"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
 	"google.golang.org/grpc"
@@ -18,7 +17,7 @@ func GrpcJwtFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
-			return errors.New("miss metadata from context")
+			return internalError("miss metadata from context")
 		}
 		appInfos := md.Get("app")
 		if len(appInfos) == 0 {
@@ -30,12 +29,12 @@ func GrpcJwtFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		}
 		appInfo := &dao.App{}
 		if err := json.Unmarshal([]byte(appInfos[0]), appInfo); err != nil {
-			return err
+			return internalError(err.Error())
 		}
 
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
-			return errors.New("peer not found with context")
+			return internalError("peer not found with context")
 		}
 		peerAddr := peerCtx.Addr.String()
 		addrPos := strings.LastIndex(peerAddr, ":")
@@ -45,10 +44,12 @@ func GrpcJwtFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 				public.FlowAppPrefix+appInfo.AppID+"_"+clientIP,
 				float64(appInfo.Qps))
 			if err != nil {
-				return err
+				return internalError(err.Error())
 			}
 			if !clientLimiter.Allow() {
-				return errors.New(fmt.Sprintf("%v flow limit %v", clientIP, appInfo.Qps))
+				return limitError(ReasonAppRateLimited, "app:"+appInfo.AppID+" client:"+clientIP,
+					fmt.Sprintf("%v flow limit %v", clientIP, appInfo.Qps),
+					qpsRetryDelay(float64(appInfo.Qps)), errorMetadata(serviceDetail, info))
 			}
 		}
 		if err := handler(srv, ss); err != nil {
@@ -0,0 +1,80 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"log"
+	"time"
+)
+
+// GrpcMethodRuleMiddleware grpc方法规则中间件，按照info.FullMethod匹配最具体的规则
+// 规则可以按租户允许或禁止访问、单独限流、修改deadline以及添加转发的metadata
+// 需要在认证中间件之后执行，租户信息从metadata中的app取得
+func GrpcMethodRuleMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule := serviceDetail.MatchMethodRule(info.FullMethod)
+		if rule == nil {
+			return handler(srv, ss)
+		}
+		md, ok := metadata.FromIncomingContext(ss.Context())
+		if !ok {
+			return internalError("miss metadata from context")
+		}
+		appID := ""
+		if appInfos := md.Get("app"); len(appInfos) > 0 {
+			appInfo := &dao.App{}
+			if err := json.Unmarshal([]byte(appInfos[0]), appInfo); err != nil {
+				return internalError(err.Error())
+			}
+			appID = appInfo.AppID
+		}
+		if !rule.AllowAppID(appID) {
+			return gatewayError(codes.PermissionDenied, ReasonMethodDenied, fmt.Sprintf("method %v not allowed for app %q", info.FullMethod, appID), errorMetadata(serviceDetail, info))
+		}
+		if rule.Qps > 0 {
+			limiter, err := public.FlowLimiterHandler.GetLimiter(
+				fmt.Sprintf("%v%v_method_%d", public.FlowServicePrefix, serviceDetail.Info.ServiceName, rule.ID),
+				float64(rule.Qps))
+			if err != nil {
+				return internalError(err.Error())
+			}
+			if !limiter.Allow() {
+				return limitError(ReasonMethodRateLimited, "method:"+info.FullMethod,
+					fmt.Sprintf("method %v flow limit %v", info.FullMethod, rule.Qps),
+					qpsRetryDelay(float64(rule.Qps)), errorMetadata(serviceDetail, info))
+			}
+		}
+		for key, value := range rule.Metadata() {
+			md.Set(key, value)
+		}
+		ctx := ss.Context()
+		clientDeadline, hasDeadline := ctx.Deadline()
+		if deadline, ok := rule.Deadline(time.Now(), clientDeadline, hasDeadline); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+			ss = &deadlineServerStream{ServerStream: ss, ctx: ctx}
+		}
+		if err := handler(srv, ss); err != nil {
+			log.Printf("GrpcMethodRuleMiddleware failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
+
+// deadlineServerStream 使用规则修改后的上下文，透明代理从该上下文派生调用下游的上下文
+type deadlineServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *deadlineServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,84 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func methodRuleCall(rule *dao.MethodRule, ctx context.Context, handler grpc.StreamHandler) error {
+	detail := &dao.ServiceDetail{
+		Info:           &dao.ServiceInfo{ServiceName: "test_grpc_method_rule"},
+		MethodRuleList: []*dao.MethodRule{rule},
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/echo.Echo/UnaryEcho"}
+	return GrpcMethodRuleMiddleware(detail)(nil, &testServerStream{ctx: ctx}, info, handler)
+}
+
+func appContext(appID string) context.Context {
+	md := metadata.MD{}
+	if appID != "" {
+		md.Set("app", public.Obj2Json(&dao.App{AppID: appID}))
+	}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func okHandler(srv interface{}, ss grpc.ServerStream) error {
+	return nil
+}
+
+func TestGrpcMethodRuleAllowApp(t *testing.T) {
+	rule := &dao.MethodRule{ID: 1, Method: "/echo.Echo/*", AllowApp: "app_a"}
+	if err := methodRuleCall(rule, appContext("app_a"), okHandler); err != nil {
+		t.Fatal(err)
+	}
+	err := methodRuleCall(rule, appContext("app_b"), okHandler)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expect PermissionDenied, got %v", err)
+	}
+}
+
+func TestGrpcMethodRuleQps(t *testing.T) {
+	rule := &dao.MethodRule{ID: 2, Method: "/echo.Echo/UnaryEcho", Qps: 1}
+	var err error
+	// 令牌桶容量为qps的3倍
+	for i := 0; i < 4 && err == nil; i++ {
+		err = methodRuleCall(rule, appContext(""), okHandler)
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expect ResourceExhausted, got %v", err)
+	}
+}
+
+func TestGrpcMethodRuleDeadlineAndMetadata(t *testing.T) {
+	rule := &dao.MethodRule{ID: 3, Method: "/echo.*/*", Timeout: 100, AddMetadata: "x-tier gold"}
+	err := methodRuleCall(rule, appContext(""), func(srv interface{}, ss grpc.ServerStream) error {
+		deadline, ok := ss.Context().Deadline()
+		if !ok || time.Until(deadline) > 100*time.Millisecond {
+			t.Fatalf("deadline not capped: %v %v", deadline, ok)
+		}
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		if tiers := md.Get("x-tier"); len(tiers) != 1 || tiers[0] != "gold" {
+			t.Fatalf("metadata not added: %v", md)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"log"
+	"strings"
+)
+
+// GrpcMtlsAuthMiddleware grpc客户端证书校验
+// 证书链已经在握手时按照服务的CA证书包校验，这里将证书身份写入metadata转发给后端
+func GrpcMtlsAuthMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, ok := metadata.FromIncomingContext(ss.Context())
+		if !ok {
+			return internalError("miss metadata from context")
+		}
+		// 清除客户端伪造的证书身份metadata
+		delete(md, strings.ToLower(public.ClientCertSubjectHeader))
+		delete(md, strings.ToLower(public.ClientCertSanHeader))
+		if serviceDetail.AccessControl.OpenMtls() {
+			cert := grpcClientCert(ss.Context())
+			if cert == nil && serviceDetail.AccessControl.ClientVerify == public.ClientVerifyRequired {
+				return gatewayError(codes.Unauthenticated, ReasonCertRequired, "client certificate required", errorMetadata(serviceDetail, info))
+			}
+			if cert != nil {
+				md.Set(public.ClientCertSubjectHeader, cert.Subject.String())
+				md.Set(public.ClientCertSanHeader, strings.Join(public.CertSans(cert), ","))
+				if appInfo := dao.AppManagerHandler.MatchCertApp(public.CertIdentities(cert)); appInfo != nil {
+					md.Set("app", public.Obj2Json(appInfo))
+				}
+			}
+		}
+		if err := handler(srv, ss); err != nil {
+			log.Printf("GrpcMtlsAuthMiddleware failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
+
+// grpcClientCert 取出握手时校验通过的客户端证书
+func grpcClientCert(ctx context.Context) *x509.Certificate {
+	peerCtx, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := peerCtx.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return nil
+	}
+	return tlsInfo.State.VerifiedChains[0][0]
+}
+
+// grpcClientCertIdentities 取出客户端证书身份
+func grpcClientCertIdentities(ctx context.Context) []string {
+	if cert := grpcClientCert(ctx); cert != nil {
+		return public.CertIdentities(cert)
+	}
+	return []string{}
+}
+
+// grpcCertApp 通过客户端证书匹配租户
+func grpcCertApp(ctx context.Context, serviceDetail *dao.ServiceDetail) *dao.App {
+	if !serviceDetail.AccessControl.OpenMtls() {
+		return nil
+	}
+	return dao.AppManagerHandler.MatchCertApp(grpcClientCertIdentities(ctx))
+}
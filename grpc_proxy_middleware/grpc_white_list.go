@@ -2,10 +2,10 @@ package grpc_proxy_middleware
 
 import (
 	"fmt"
-	"github.com/pkg/errors"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
 	"log"
 	"strings"
@@ -21,14 +21,20 @@ func GrpcWhiteListMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 
 		peerCtx,ok:=peer.FromContext(ss.Context())
 		if !ok{
-			return errors.New("peer not found with context")
+			return internalError("peer not found with context")
 		}
 		peerAddr:=peerCtx.Addr.String()
 		addrPos:=strings.LastIndex(peerAddr,":")
 		clientIP:=peerAddr[0:addrPos]
 		if serviceDetail.AccessControl.OpenAuth == 1 && len(iplist) > 0 {
 			if !public.InStringSlice(iplist, clientIP) {
-				return errors.New(fmt.Sprintf("%s not in white ip list", clientIP))
+				return gatewayError(codes.PermissionDenied, ReasonIPNotAllowed, fmt.Sprintf("%s not in white ip list", clientIP), errorMetadata(serviceDetail, info))
+			}
+		}
+		// 开启客户端证书校验时，证书身份需要在证书白名单中
+		if serviceDetail.AccessControl.OpenAuth == 1 && serviceDetail.AccessControl.OpenMtls() {
+			if !serviceDetail.AccessControl.MatchWhiteCert(grpcClientCertIdentities(ss.Context())) {
+				return gatewayError(codes.PermissionDenied, ReasonCertNotAllowed, "client certificate not in white cert list", errorMetadata(serviceDetail, info))
 			}
 		}
 		if err := handler(srv, ss);err != nil {
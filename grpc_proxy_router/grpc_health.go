@@ -0,0 +1,63 @@
+package grpc_proxy_router
+
+import (
+	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"strings"
+)
+
+// grpcHealthMethodPrefix 网关自身应答的健康检查方法前缀
+const grpcHealthMethodPrefix = "/grpc.health.v1.Health/"
+
+// grpcHealthObserver 监听下游健康检查结果，健康的下游地址为空时报告NOT_SERVING
+type grpcHealthObserver struct {
+	conf     load_balance.LoadBalanceConf
+	server   *health.Server
+	services []string
+}
+
+// newGrpcHealthServer 创建健康检查服务，services为空字符串(整体状态)以外额外报告状态的服务名
+func newGrpcHealthServer(conf load_balance.LoadBalanceConf, services []string) *health.Server {
+	observer := &grpcHealthObserver{
+		conf:     conf,
+		server:   health.NewServer(),
+		services: append([]string{""}, services...),
+	}
+	conf.Attach(observer)
+	observer.Update()
+	return observer.server
+}
+
+func (o *grpcHealthObserver) Update() {
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if len(o.conf.GetConf()) == 0 {
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, service := range o.services {
+		o.server.SetServingStatus(service, servingStatus)
+	}
+}
+
+// isGrpcHealthMethod 健康检查请求不经过认证、限流等拦截器，以便探针直接访问
+func isGrpcHealthMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, grpcHealthMethodPrefix)
+}
+
+// chainStreamInterceptors 按顺序串联拦截器，skip返回true的方法直接交给handler处理
+func chainStreamInterceptors(skip func(fullMethod string) bool, interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skip(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, nextHandler := interceptors[i], next
+			next = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, nextHandler)
+			}
+		}
+		return next(srv, ss)
+	}
+}
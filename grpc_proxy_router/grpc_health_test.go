@@ -0,0 +1,87 @@
+package grpc_proxy_router
+
+import (
+	"context"
+	"github.com/e421083458/grpc-proxy/proxy"
+	pb "github.com/zhj/go_gateway/grpc_server_client/proto"
+	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+// testLoadBalanceConf 不做主动探测的负载均衡配置，测试中直接修改健康的下游地址
+type testLoadBalanceConf struct {
+	observers  []load_balance.Observer
+	activeList []string
+}
+
+func (s *testLoadBalanceConf) Attach(o load_balance.Observer) {
+	s.observers = append(s.observers, o)
+}
+
+func (s *testLoadBalanceConf) GetConf() []string {
+	return s.activeList
+}
+
+func (s *testLoadBalanceConf) WatchConf() {}
+
+func (s *testLoadBalanceConf) UpdateConf(conf []string) {
+	s.activeList = conf
+	for _, obs := range s.observers {
+		obs.Update()
+	}
+}
+
+func denyAllInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return status.Error(codes.PermissionDenied, "denied")
+}
+
+func TestGrpcHealthServer(t *testing.T) {
+	conf := &testLoadBalanceConf{activeList: []string{"127.0.0.1:50055,50"}}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(
+		grpc.StreamInterceptor(chainStreamInterceptors(isGrpcHealthMethod, denyAllInterceptor)),
+		grpc.CustomCodec(proxy.Codec()),
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		}),
+	)
+	healthpb.RegisterHealthServer(server, newGrpcHealthServer(conf, []string{"echo.Echo"}))
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client := healthpb.NewHealthClient(conn)
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "echo.Echo"})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expect SERVING, got %v %v", resp, err)
+	}
+	// Watch是流式方法，不经过拦截器
+	watch, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expect SERVING, got %v %v", resp, err)
+	}
+	conf.UpdateConf(nil)
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expect NOT_SERVING, got %v %v", resp, err)
+	}
+	// 转发的请求仍然经过拦截器
+	_, err = pb.NewEchoClient(conn).UnaryEcho(context.Background(), &pb.EchoRequest{Message: "hi"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expect PermissionDenied, got %v", err)
+	}
+}
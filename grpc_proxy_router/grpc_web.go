@@ -0,0 +1,236 @@
+package grpc_proxy_router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	// grpcWebTrailerFlag gRPC-Web响应中trailer帧的标志位
+	grpcWebTrailerFlag = 0x80
+	// grpcWebCorsMaxAge 跨域预检结果的缓存时间，单位s
+	grpcWebCorsMaxAge = "600"
+	// grpcWebTextMaxBodySize text格式请求体需要整体读出后解码，限制base64编码后的大小，略大于gRPC默认的4MB消息上限
+	grpcWebTextMaxBodySize = 6 << 20
+)
+
+// grpcWebHandler 将HTTP/1.1上的gRPC-Web请求转换为原生gRPC请求，交给同一个grpc.Server处理
+// 转换后的请求经过与原生gRPC相同的拦截器链和TransparentHandler
+type grpcWebHandler struct {
+	server      *grpc.Server
+	corsOrigins []string
+}
+
+// newGrpcWebHandler corsOrigin为允许跨域的来源，以逗号间隔，*表示允许所有来源，为空时不处理跨域预检
+func newGrpcWebHandler(server *grpc.Server, corsOrigin string) *grpcWebHandler {
+	handler := &grpcWebHandler{server: server}
+	for _, origin := range strings.Split(corsOrigin, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			handler.corsOrigins = append(handler.corsOrigins, origin)
+		}
+	}
+	return handler
+}
+
+func (h *grpcWebHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	allowOrigin := h.allowOrigin(req.Header.Get("Origin"))
+	if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+		h.servePreflight(w, req, allowOrigin)
+		return
+	}
+	contentType := req.Header.Get("Content-Type")
+	if req.Method != http.MethodPost || !strings.HasPrefix(contentType, grpcWebContentType) {
+		http.Error(w, "grpc-web request required", http.StatusUnsupportedMediaType)
+		return
+	}
+	if allowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		w.Header().Set("Access-Control-Expose-Headers", "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin")
+		w.Header().Add("Vary", "Origin")
+	}
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+	grpcReq, err := toGrpcRequest(w, req, text)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writer := newGrpcWebResponseWriter(w, text)
+	h.server.ServeHTTP(writer, grpcReq)
+	writer.finish()
+}
+
+// allowOrigin 返回允许跨域的来源，不允许时返回空
+func (h *grpcWebHandler) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, item := range h.corsOrigins {
+		if item == "*" || strings.EqualFold(item, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// servePreflight 处理跨域预检请求
+func (h *grpcWebHandler) servePreflight(w http.ResponseWriter, req *http.Request, allowOrigin string) {
+	if allowOrigin == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+	w.Header().Set("Access-Control-Allow-Headers", req.Header.Get("Access-Control-Request-Headers"))
+	w.Header().Set("Access-Control-Max-Age", grpcWebCorsMaxAge)
+	w.Header().Add("Vary", "Origin")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toGrpcRequest 将gRPC-Web请求转换为HTTP/2的gRPC请求，text格式的请求体需要base64解码
+func toGrpcRequest(w http.ResponseWriter, req *http.Request, text bool) (*http.Request, error) {
+	grpcReq := req.Clone(req.Context())
+	grpcReq.Proto = "HTTP/2.0"
+	grpcReq.ProtoMajor = 2
+	grpcReq.ProtoMinor = 0
+	contentType := req.Header.Get("Content-Type")
+	if text {
+		contentType = "application/grpc" + strings.TrimPrefix(contentType, grpcWebTextContentType)
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, grpcWebTextMaxBodySize))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := decodeGrpcWebText(body)
+		if err != nil {
+			return nil, err
+		}
+		grpcReq.Body = ioutil.NopCloser(bytes.NewReader(decoded))
+	} else {
+		contentType = "application/grpc" + strings.TrimPrefix(contentType, grpcWebContentType)
+	}
+	grpcReq.Header.Set("Content-Type", contentType)
+	grpcReq.Header.Del("Content-Length")
+	grpcReq.ContentLength = -1
+	return grpcReq, nil
+}
+
+// decodeGrpcWebText 解码base64请求体，客户端可能将多个带填充的base64分段拼接在一起发送
+func decodeGrpcWebText(body []byte) ([]byte, error) {
+	body = bytes.Join(bytes.Fields(body), nil)
+	decoded := make([]byte, 0, base64.StdEncoding.DecodedLen(len(body)))
+	buf := make([]byte, 3)
+	for len(body) > 0 {
+		if len(body) < 4 {
+			return nil, fmt.Errorf("invalid grpc-web-text body")
+		}
+		n, err := base64.StdEncoding.Decode(buf, body[:4])
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, buf[:n]...)
+		body = body[4:]
+	}
+	return decoded, nil
+}
+
+// grpcWebResponseWriter 将原生gRPC响应转换为gRPC-Web格式，trailer作为最后一个帧写在响应体中
+type grpcWebResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	text        bool
+	wroteHeader bool
+}
+
+func newGrpcWebResponseWriter(w http.ResponseWriter, text bool) *grpcWebResponseWriter {
+	return &grpcWebResponseWriter{w: w, header: http.Header{}, text: text}
+}
+
+func (w *grpcWebResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *grpcWebResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	trailers := w.trailerNames()
+	for key, values := range w.header {
+		if key == "Trailer" || trailers[key] || strings.HasPrefix(key, http2.TrailerPrefix) {
+			continue
+		}
+		w.w.Header()[key] = values
+	}
+	contentType := grpcWebContentType
+	if w.text {
+		contentType = grpcWebTextContentType
+	}
+	if subtype := strings.TrimPrefix(w.header.Get("Content-Type"), "application/grpc"); subtype != "" {
+		contentType += subtype
+	}
+	w.w.Header().Set("Content-Type", contentType)
+	w.w.WriteHeader(code)
+}
+
+func (w *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.text {
+		if _, err := w.w.Write([]byte(base64.StdEncoding.EncodeToString(b))); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return w.w.Write(b)
+}
+
+func (w *grpcWebResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// trailerNames 预先声明的trailer
+func (w *grpcWebResponseWriter) trailerNames() map[string]bool {
+	names := map[string]bool{}
+	for _, value := range w.header.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			names[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	return names
+}
+
+// finish 将gRPC状态和trailer metadata编码为trailer帧写入响应体
+func (w *grpcWebResponseWriter) finish() {
+	trailers := w.trailerNames()
+	lines := []string{}
+	for key, values := range w.header {
+		name := key
+		if strings.HasPrefix(key, http2.TrailerPrefix) {
+			name = strings.TrimPrefix(key, http2.TrailerPrefix)
+		} else if !trailers[key] {
+			continue
+		}
+		for _, value := range values {
+			lines = append(lines, strings.ToLower(name)+": "+value+"\r\n")
+		}
+	}
+	sort.Strings(lines)
+	payload := []byte(strings.Join(lines, ""))
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	frame = append(frame, payload...)
+	w.Write(frame)
+	w.Flush()
+}
@@ -0,0 +1,195 @@
+package grpc_proxy_router
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/e421083458/grpc-proxy/proxy"
+	"github.com/golang/protobuf/proto"
+	pb "github.com/zhj/go_gateway/grpc_server_client/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type echoServer struct {
+	pb.UnimplementedEchoServer
+}
+
+func (s *echoServer) UnaryEcho(ctx context.Context, in *pb.EchoRequest) (*pb.EchoResponse, error) {
+	return &pb.EchoResponse{Message: in.Message}, nil
+}
+
+// startGrpcWebProxy 启动echo后端，并返回经过透明代理和拦截器的gRPC-Web服务
+func startGrpcWebProxy(t *testing.T, interceptor grpc.StreamServerInterceptor) *httptest.Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := grpc.NewServer()
+	pb.RegisterEchoServer(backend, &echoServer{})
+	go backend.Serve(ln)
+	t.Cleanup(backend.Stop)
+
+	director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
+		conn, err := grpc.DialContext(ctx, ln.Addr().String(), grpc.WithCodec(proxy.Codec()), grpc.WithInsecure())
+		md, _ := metadata.FromIncomingContext(ctx)
+		return metadata.NewOutgoingContext(ctx, md.Copy()), conn, err
+	}
+	s := grpc.NewServer(
+		grpc.ChainStreamInterceptor(interceptor),
+		grpc.CustomCodec(proxy.Codec()),
+		grpc.UnknownServiceHandler(proxy.TransparentHandler(director)),
+	)
+	server := httptest.NewServer(newGrpcWebHandler(s, "https://example.com"))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func passInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, ss)
+}
+
+func grpcWebFrame(t *testing.T, msg proto.Message) []byte {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, 5)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+// readGrpcWebFrames 解析响应体，返回数据帧和trailer
+func readGrpcWebFrames(t *testing.T, body []byte) ([][]byte, string) {
+	messages := [][]byte{}
+	trailer := ""
+	for len(body) >= 5 {
+		length := binary.BigEndian.Uint32(body[1:5])
+		payload := body[5 : 5+length]
+		if body[0]&grpcWebTrailerFlag != 0 {
+			trailer = string(payload)
+		} else {
+			messages = append(messages, payload)
+		}
+		body = body[5+length:]
+	}
+	return messages, trailer
+}
+
+func postGrpcWeb(t *testing.T, url, contentType string, body []byte) *http.Response {
+	req, _ := http.NewRequest("POST", url+"/echo.Echo/UnaryEcho", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Origin", "https://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestGrpcWebBinary(t *testing.T) {
+	server := startGrpcWebProxy(t, passInterceptor)
+	resp := postGrpcWeb(t, server.URL, "application/grpc-web+proto", grpcWebFrame(t, &pb.EchoRequest{Message: "hello"}))
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 1 || resp.Header.Get("Content-Type") != "application/grpc-web+proto" {
+		t.Fatalf("unexpected response %v %v", resp.Proto, resp.Header)
+	}
+	if resp.Header.Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Fatalf("cors header missing: %v", resp.Header)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	messages, trailer := readGrpcWebFrames(t, body)
+	out := &pb.EchoResponse{}
+	if len(messages) != 1 || proto.Unmarshal(messages[0], out) != nil || out.Message != "hello" {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+	if !strings.Contains(trailer, "grpc-status: 0\r\n") {
+		t.Fatalf("unexpected trailer %q", trailer)
+	}
+}
+
+func TestGrpcWebText(t *testing.T) {
+	server := startGrpcWebProxy(t, passInterceptor)
+	body := base64.StdEncoding.EncodeToString(grpcWebFrame(t, &pb.EchoRequest{Message: "hello text"}))
+	resp := postGrpcWeb(t, server.URL, "application/grpc-web-text", []byte(body))
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/grpc-web-text" {
+		t.Fatalf("unexpected content type %v", resp.Header.Get("Content-Type"))
+	}
+	encoded, _ := ioutil.ReadAll(resp.Body)
+	decoded, err := decodeGrpcWebText(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, trailer := readGrpcWebFrames(t, decoded)
+	out := &pb.EchoResponse{}
+	if len(messages) != 1 || proto.Unmarshal(messages[0], out) != nil || out.Message != "hello text" {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+	if !strings.Contains(trailer, "grpc-status: 0\r\n") {
+		t.Fatalf("unexpected trailer %q", trailer)
+	}
+	// text格式的请求体超过上限时直接拒绝
+	oversized := postGrpcWeb(t, server.URL, "application/grpc-web-text", bytes.Repeat([]byte("A"), grpcWebTextMaxBodySize+4))
+	oversized.Body.Close()
+	if oversized.StatusCode != http.StatusBadRequest {
+		t.Fatalf("oversized body expect 400, got %v", oversized.StatusCode)
+	}
+}
+
+func TestGrpcWebInterceptor(t *testing.T) {
+	// gRPC-Web请求同样经过拦截器链，拦截器拒绝时返回对应的状态码
+	deny := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return status.Error(codes.ResourceExhausted, "flow limit")
+	}
+	server := startGrpcWebProxy(t, deny)
+	resp := postGrpcWeb(t, server.URL, "application/grpc-web+proto", grpcWebFrame(t, &pb.EchoRequest{Message: "hello"}))
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	messages, trailer := readGrpcWebFrames(t, body)
+	if len(messages) != 0 || !strings.Contains(trailer, "grpc-status: 8\r\n") ||
+		!strings.Contains(trailer, "grpc-message: flow limit\r\n") {
+		t.Fatalf("unexpected response %v %q", messages, trailer)
+	}
+}
+
+func TestGrpcWebPreflight(t *testing.T) {
+	server := startGrpcWebProxy(t, passInterceptor)
+	preflight := func(origin string) *http.Response {
+		req, _ := http.NewRequest("OPTIONS", server.URL+"/echo.Echo/UnaryEcho", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	resp := preflight("https://example.com")
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Headers") != "content-type,x-grpc-web" {
+		t.Fatalf("unexpected preflight response %v %v", resp.StatusCode, resp.Header)
+	}
+	if resp = preflight("https://evil.com"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("origin not allowed expect 403, got %v", resp.StatusCode)
+	}
+}
+
+func TestDecodeGrpcWebText(t *testing.T) {
+	// 客户端分段发送时每段都带有base64填充
+	body := base64.StdEncoding.EncodeToString([]byte("ab")) + base64.StdEncoding.EncodeToString([]byte("cde"))
+	decoded, err := decodeGrpcWebText([]byte(body))
+	if err != nil || string(decoded) != "abcde" {
+		t.Fatalf("unexpected decoded %q %v", decoded, err)
+	}
+}
@@ -1,22 +1,127 @@
 package grpc_proxy_router
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/e421083458/grpc-proxy/proxy"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/grpc_proxy_middleware"
+	"github.com/zhj/go_gateway/public"
 	"github.com/zhj/go_gateway/reverse_proxy"
+	"github.com/zhj/go_gateway/reverse_proxy/grpc_transcode"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 	"log"
-	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
 var grpcServerList = []*warpGrpcServer{}
 
+var grpcWebServerList = []*grpcWebServer{}
+
+// grpcServerLocker 保护服务器列表，开始关闭后不再登记和启动新的服务器
+var (
+	grpcServerLocker  sync.Mutex
+	grpcServerStopped bool
+)
+
+// addGrpcServer 登记gRPC服务器，已经开始关闭时返回false
+func addGrpcServer(server *warpGrpcServer) bool {
+	grpcServerLocker.Lock()
+	defer grpcServerLocker.Unlock()
+	if grpcServerStopped {
+		return false
+	}
+	grpcServerList = append(grpcServerList, server)
+	return true
+}
+
+// addGrpcWebServer 登记gRPC-Web服务器，已经开始关闭时返回false
+func addGrpcWebServer(server *grpcWebServer) bool {
+	grpcServerLocker.Lock()
+	defer grpcServerLocker.Unlock()
+	if grpcServerStopped {
+		return false
+	}
+	grpcWebServerList = append(grpcWebServerList, server)
+	return true
+}
+
 // warpGrpcServer 包装后的grpc服务器
 type warpGrpcServer struct {
 	Addr string
 	*grpc.Server
+	Health   *health.Server
+	inflight int64 //处理中的请求数，关闭超时时报告被中断的请求
+}
+
+// trackStreams 统计处理中的请求，包括健康检查的Watch请求
+func (w *warpGrpcServer) trackStreams(interceptor grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		atomic.AddInt64(&w.inflight, 1)
+		defer atomic.AddInt64(&w.inflight, -1)
+		return interceptor(srv, ss, info, handler)
+	}
+}
+
+// stop 等待处理中的请求结束，ctx到期后强制关闭，返回被中断的请求数
+func (w *warpGrpcServer) stop(ctx context.Context) int {
+	// 先将健康状态置为NOT_SERVING，Watch的客户端可以及时切换
+	w.Health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		w.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return 0
+	case <-ctx.Done():
+	}
+	cut := int(atomic.LoadInt64(&w.inflight))
+	w.Stop()
+	<-done
+	return cut
+}
+
+// grpcWebServer gRPC-Web服务器及其连接状态
+type grpcWebServer struct {
+	*http.Server
+	Conns *public.HttpConnTracker
+}
+
+// registerGrpcReflection 按照服务配置处理反射请求，返回描述文件中定义的服务名
+// 转发模式下不注册反射服务，反射请求与其他未知服务一样转发给健康的下游
+func registerGrpcReflection(s *grpc.Server, serviceDetail *dao.ServiceDetail) ([]string, error) {
+	var reflectionServer *grpc_transcode.ReflectionServer
+	if serviceDetail.Descriptor != nil && len(serviceDetail.Descriptor.DescriptorSet) > 0 {
+		var err error
+		reflectionServer, err = grpc_transcode.NewReflectionServer(serviceDetail.Descriptor.DescriptorSet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch serviceDetail.GRPCRule.ReflectionMode {
+	case public.GrpcReflectionDescriptor:
+		if reflectionServer == nil {
+			log.Printf(" [WARN] grpc_proxy_reflection %v has no descriptor set\n", serviceDetail.Info.ServiceName)
+			rpb.RegisterServerReflectionServer(s, &rpb.UnimplementedServerReflectionServer{})
+			return nil, nil
+		}
+		rpb.RegisterServerReflectionServer(s, reflectionServer)
+	case public.GrpcReflectionDisable:
+		rpb.RegisterServerReflectionServer(s, &rpb.UnimplementedServerReflectionServer{})
+	}
+	if reflectionServer == nil {
+		return nil, nil
+	}
+	return reflectionServer.ServiceNames(), nil
 }
 
 func GrpcServerRun() {
@@ -24,6 +129,10 @@ func GrpcServerRun() {
 	grpcServiceList := dao.ServiceManagerHandler.GetGrpcServiceList()
 	for _, serviceItem := range grpcServiceList {
 		tmpItem := serviceItem
+		public.HealthHandler.ExpectListener("tcp", fmt.Sprintf(":%d", tmpItem.GRPCRule.Port))
+		if tmpItem.GRPCRule.WebPort != 0 {
+			public.HealthHandler.ExpectListener("tcp", fmt.Sprintf(":%d", tmpItem.GRPCRule.WebPort))
+		}
 		go func(serviceDetail *dao.ServiceDetail) {
 			// 获取gRPC的地址（主要是端口号）
 			addr := fmt.Sprintf(":%d", serviceDetail.GRPCRule.Port)
@@ -32,42 +141,128 @@ func GrpcServerRun() {
 				log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
 				return
 			}
-			lis, err := net.Listen("tcp", addr)
+			lis, err := public.UpgraderHandler.Listen("tcp", addr)
 			if err != nil {
 				log.Fatalf(" [INFO] GrpcListen %v err:%v\n", addr, err)
 			}
-			grpcHandler := reverse_proxy.NewGrpcLoadBalanceHandler(lb)
-			s := grpc.NewServer(
-				grpc.ChainStreamInterceptor(
+			// 开启下游TLS的服务使用TLS证书连接下游，替代明文连接
+			upstreamTLSConfig, err := serviceDetail.LoadBalance.UpstreamTLSConfig()
+			if err != nil {
+				log.Fatalf(" [INFO] grpc_proxy_upstream_tls_config %v err:%v\n", addr, err)
+			}
+			grpcHandler := reverse_proxy.NewGrpcLoadBalanceHandler(lb, upstreamTLSConfig)
+			grpcServer := &warpGrpcServer{Addr: addr}
+			opts := []grpc.ServerOption{
+				grpc.StreamInterceptor(grpcServer.trackStreams(chainStreamInterceptors(isGrpcHealthMethod,
+					grpc_proxy_middleware.GrpcAccessLogMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcFlowCountMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcFlowLimitMiddleware(serviceDetail),
+					grpc_proxy_middleware.GrpcMtlsAuthMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcJwtAuthTokenMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcJwtFlowCountMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcJwtFlowLimitMiddleware(serviceDetail),
+					grpc_proxy_middleware.GrpcMethodRuleMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcWhiteListMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcBlackListMiddleware(serviceDetail),
 					grpc_proxy_middleware.GrpcHeaderTransferMiddleware(serviceDetail),
-				),
+				))),
 				grpc.CustomCodec(proxy.Codec()),
 				grpc.UnknownServiceHandler(grpcHandler),
-			)
-			grpcServerList = append(grpcServerList, &warpGrpcServer{
-				Addr:   addr,
-				Server: s,
-			})
+			}
+			// 开启客户端证书校验的服务使用TLS，握手时校验客户端证书
+			var tlsConfig *tls.Config
+			if serviceDetail.AccessControl.OpenMtls() {
+				tlsConfig, err = serviceDetail.AccessControl.ServerTLSConfig()
+				if err != nil {
+					log.Fatalf(" [INFO] grpc_proxy_tls_config %v err:%v\n", addr, err)
+					return
+				}
+				opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+			}
+			s := grpc.NewServer(opts...)
+			healthServices, err := registerGrpcReflection(s, serviceDetail)
+			if err != nil {
+				log.Fatalf(" [INFO] grpc_proxy_reflection %v err:%v\n", addr, err)
+			}
+			// 健康检查由网关应答，状态取决于下游是否有通过健康检查的地址
+			lbConf, err := dao.LoadBalancerHandler.GetLoadBalanceConf(serviceDetail)
+			if err != nil {
+				log.Fatalf(" [INFO] GetLoadBalanceConf %v err:%v\n", addr, err)
+			}
+			healthServer := newGrpcHealthServer(lbConf, healthServices)
+			healthpb.RegisterHealthServer(s, healthServer)
+			grpcServer.Server = s
+			grpcServer.Health = healthServer
+			if !addGrpcServer(grpcServer) {
+				lis.Close()
+				return
+			}
+			if serviceDetail.GRPCRule.WebPort != 0 {
+				go grpcWebServerRun(serviceDetail, s, tlsConfig)
+			}
 
 			log.Printf(" [INFO] grpc_proxy_run %v\n", addr)
-			if err := s.Serve(lis); err != nil {
+			if err := s.Serve(lis); err != nil && err != grpc.ErrServerStopped {
 				log.Fatalf(" [INFO] grpc_proxy_run %v err:%v\n", addr, err)
 			}
 		}(tmpItem)
 	}
 }
 
-// GrpcServerStop 遍历所有gRPC服务器并关闭
-func GrpcServerStop() {
-	for _, grpcServer := range grpcServerList {
-		grpcServer.GracefulStop()
-		log.Printf(" [INFO] grpc_proxy_stop %v stopped\n", grpcServer.Addr)
+// grpcWebServerRun 在独立端口上接收HTTP/1.1的gRPC-Web请求，转换后交给同一个gRPC服务器处理
+func grpcWebServerRun(serviceDetail *dao.ServiceDetail, s *grpc.Server, tlsConfig *tls.Config) {
+	addr := fmt.Sprintf(":%d", serviceDetail.GRPCRule.WebPort)
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   newGrpcWebHandler(s, serviceDetail.GRPCRule.WebCorsOrigin),
+		TLSConfig: tlsConfig,
+		// gRPC-Web只使用HTTP/1.1
+		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){},
+	}
+	conns := public.NewHttpConnTracker()
+	server.ConnState = conns.ConnState
+	if !addGrpcWebServer(&grpcWebServer{Server: server, Conns: conns}) {
+		return
+	}
+	log.Printf(" [INFO] grpc_web_proxy_run %v\n", addr)
+	ln, err := public.UpgraderHandler.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf(" [INFO] grpc_web_proxy_run %v err:%v\n", addr, err)
+	}
+	if tlsConfig != nil {
+		err = server.ServeTLS(ln, "", "")
+	} else {
+		err = server.Serve(ln)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatalf(" [INFO] grpc_web_proxy_run %v err:%v\n", addr, err)
+	}
+}
+
+// GrpcServerStop 遍历所有gRPC服务器，等待处理中的请求结束，ctx到期后强制关闭，返回被中断的请求数
+func GrpcServerStop(ctx context.Context) int {
+	grpcServerLocker.Lock()
+	grpcServerStopped = true
+	webServerList := grpcWebServerList
+	serverList := grpcServerList
+	grpcServerLocker.Unlock()
+
+	cut := 0
+	// gRPC-Web请求由grpc.Server.ServeHTTP处理，GracefulStop前需要先等待这些请求结束
+	for _, webServer := range webServerList {
+		cut += public.ShutdownHttpServer(ctx, webServer.Server, webServer.Conns)
+		log.Printf(" [INFO] grpc_web_proxy_stop %v stopped\n", webServer.Addr)
+	}
+	var total int64
+	wg := sync.WaitGroup{}
+	for _, grpcServer := range serverList {
+		wg.Add(1)
+		go func(grpcServer *warpGrpcServer) {
+			defer wg.Done()
+			atomic.AddInt64(&total, int64(grpcServer.stop(ctx)))
+			log.Printf(" [INFO] grpc_proxy_stop %v stopped\n", grpcServer.Addr)
+		}(grpcServer)
 	}
+	wg.Wait()
+	return cut + int(total)
 }
@@ -0,0 +1,51 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/middleware"
+)
+
+// HTTPGrpcTranscodeMiddleware 服务启用gRPC转码时，将REST/JSON请求转码为gRPC调用下游，不再经过反向代理
+func HTTPGrpcTranscodeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serviceInterface.(*dao.ServiceDetail)
+		if serviceDetail.HTTPRule.GrpcTranscode != 1 {
+			c.Next()
+			return
+		}
+		transcoder, err := dao.TranscoderHandler.GetTranscoder(serviceDetail)
+		if err != nil {
+			middleware.ResponseError(c, 2004, err)
+			c.Abort()
+			return
+		}
+		lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+		if err != nil {
+			middleware.ResponseError(c, 2002, err)
+			c.Abort()
+			return
+		}
+		nextAddr, err := lb.Get(c.Request.URL.String())
+		if err != nil || nextAddr == "" {
+			middleware.ResponseError(c, 2005, errors.New("get next addr fail"))
+			c.Abort()
+			return
+		}
+		conn, err := transcoder.GetConn(serviceDetail.LoadBalance, nextAddr)
+		if err != nil {
+			middleware.ResponseError(c, 2006, err)
+			c.Abort()
+			return
+		}
+		transcoder.Transcoder.ServeHTTP(c.Writer, c.Request, conn)
+		c.Abort()
+	}
+}
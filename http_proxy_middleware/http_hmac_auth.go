@@ -0,0 +1,75 @@
+package http_proxy_middleware
+
+import (
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+)
+
+// HTTPHmacAuthMiddleware HMAC请求签名认证中间件，服务的认证方式为HMAC签名时生效
+func HTTPHmacAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if !serviceDetail.AccessControl.AllowHmac() {
+			c.Next()
+			return
+		}
+		// 未开启验证时不带签名的请求直接放行
+		authorization := c.GetHeader("Authorization")
+		if authorization == "" && serviceDetail.AccessControl.OpenAuth == 0 {
+			c.Next()
+			return
+		}
+		auth, err := public.ParseHmacAuth(authorization)
+		if err != nil {
+			middleware.ResponseError(c, 2002, err)
+			c.Abort()
+			return
+		}
+		// 启动后新建的租户从数据库加载
+		appInfo, err := dao.AppManagerHandler.ResolveApp(auth.AppID)
+		if err != nil {
+			middleware.ResponseError(c, 2003, err)
+			c.Abort()
+			return
+		}
+		clockSkew := int64(lib.GetIntConf("proxy.hmac.clock_skew"))
+		if clockSkew <= 0 {
+			clockSkew = public.HmacClockSkew
+		}
+		maxBodySize := int64(lib.GetIntConf("proxy.hmac.max_body_size"))
+		if maxBodySize <= 0 {
+			maxBodySize = public.HmacMaxBodySize
+		}
+		if err := public.HmacVerify(c.Request, auth, appInfo.Secret, clockSkew, maxBodySize); err != nil {
+			middleware.ResponseError(c, 2004, err)
+			c.Abort()
+			return
+		}
+		// 签名通过后再记录nonce，时钟偏差窗口内同一个nonce只能使用一次
+		reply, err := public.RedisConfDo("SET", public.RedisHmacNoncePrefix+auth.AppID+"_"+auth.Nonce, 1, "NX", "EX", 2*clockSkew)
+		if err != nil {
+			middleware.ResponseError(c, 2005, err)
+			c.Abort()
+			return
+		}
+		if reply == nil {
+			middleware.ResponseError(c, 2005, errors.New("nonce already used"))
+			c.Abort()
+			return
+		}
+		// 签名已经校验，不再转发给后端
+		c.Request.Header.Del("Authorization")
+		c.Set("app", appInfo)
+		c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package http_proxy_middleware
+
+import (
+	"encoding/json"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHmacAuthAppAddedAfterLoad(t *testing.T) {
+	// 租户在代理启动加载之后才创建，只能从数据库中读取
+	manager := dao.NewAppManager()
+	manager.LoadApp = func(appID string) (*dao.App, error) {
+		if appID != "app_id_new" {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &dao.App{AppID: appID, Secret: "secret_new"}, nil
+	}
+	oldManager := dao.AppManagerHandler
+	dao.AppManagerHandler = manager
+	defer func() { dao.AppManagerHandler = oldManager }()
+
+	// 未加载配置文件时使用默认的时钟偏差和请求体上限
+	if lib.ViperConfMap == nil {
+		lib.ViperConfMap = map[string]*viper.Viper{}
+	}
+	if lib.ViperConfMap["proxy"] == nil {
+		lib.ViperConfMap["proxy"] = viper.New()
+		defer delete(lib.ViperConfMap, "proxy")
+	}
+
+	serviceDetail := &dao.ServiceDetail{AccessControl: &dao.AccessControl{OpenAuth: 1, AuthType: public.AuthTypeHmac}}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", serviceDetail)
+		c.Next()
+	}, HTTPHmacAuthMiddleware())
+	router.POST("/api/order", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	serve := func(appID, secret string) *middleware.Response {
+		req := httptest.NewRequest(http.MethodPost, "http://gateway.local/api/order", strings.NewReader(`{"id":1}`))
+		if err := public.HmacSignRequest(req, appID, secret, []string{"Host"}); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		resp := &middleware.Response{}
+		if w.Body.String() != "ok" {
+			if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp
+	}
+
+	// 签名校验通过，之后的nonce记录依赖redis
+	if resp := serve("app_id_new", "secret_new"); resp.ErrorCode == 2003 || resp.ErrorCode == 2004 {
+		t.Fatalf("new app should pass signature check: %d %s", resp.ErrorCode, resp.ErrorMsg)
+	}
+	if manager.GetApp("app_id_new") == nil {
+		t.Fatal("new app should be cached after first request")
+	}
+	if resp := serve("app_id_new", "wrong_secret"); resp.ErrorCode != 2004 {
+		t.Fatalf("wrong secret errno = %d, want 2004", resp.ErrorCode)
+	}
+	if resp := serve("app_id_unknown", "secret"); resp.ErrorCode != 2003 {
+		t.Fatalf("unknown app errno = %d, want 2003", resp.ErrorCode)
+	}
+}
@@ -1,11 +1,13 @@
 package http_proxy_middleware
 
 import (
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/middleware"
 	"github.com/zhj/go_gateway/public"
+	"net/http"
 	"strings"
 )
 
@@ -24,19 +26,88 @@ func HTTPJwtOAuthTokenMiddleware() gin.HandlerFunc {
 		// 1.decode Jwt Token
 		// 2.通过app_id从app_list中取出app_info
 		// 3.将app_info放到gin的context中
+		// 外部签发方的token则通过JWKS验签，并将claim转发到header
+
+		// 清除客户端伪造的claim转发header
+		for _, issuer := range serviceDetail.JwtIssuerList {
+			for name := range issuer.ClaimHeaders(jwt.MapClaims{}) {
+				c.Request.Header.Del(name)
+			}
+		}
 
 		// 取出token
 		token := strings.ReplaceAll(c.GetHeader("Authorization"), "Bearer ", "")
 		//fmt.Println("token", token)
+		if !serviceDetail.AccessControl.AllowJwt() {
+			token = ""
+		}
+		// 取出API Key
+		apiKey := ""
+		if serviceDetail.AccessControl.AllowApiKey() {
+			apiKey = httpApiKey(c)
+		}
 		appMatched := false
-		// decode Jwt Token
-		if token != "" {
+		// token中携带的权限范围以及租户在该服务上的授权
+		tokenScopes := []string{}
+		var appGrant *dao.AppGrant
+		externalIssuer := false
+		if appInterface, ok := c.Get("app"); ok {
+			// 前面的客户端证书或HMAC签名认证已经确定了租户，使用租户在服务上被授予的权限范围
+			appInfo := appInterface.(*dao.App)
+			appMatched = true
+			appGrant = serviceDetail.MatchAppGrant(appInfo.AppID)
+			if appGrant != nil {
+				tokenScopes = public.ScopeList(appGrant.Scope)
+			}
+		} else if apiKey != "" {
+			// API Key和JWT一样解析为租户信息，后续的租户流量统计和限流不需要区分
+			appInfo, err := dao.ApiKeyManagerHandler.Verify(apiKey)
+			if err != nil {
+				middleware.ResponseError(c, 2008, err)
+				c.Abort()
+				return
+			}
+			c.Set("app", appInfo)
+			appMatched = true
+			appGrant = serviceDetail.MatchAppGrant(appInfo.AppID)
+			// API Key没有单独的权限范围，使用租户在服务上被授予的权限范围
+			if appGrant != nil {
+				tokenScopes = public.ScopeList(appGrant.Scope)
+			}
+		} else if issuer := serviceDetail.MatchJwtIssuer(public.JwtUnverifiedIssuer(token)); token != "" && issuer != nil {
+			claims, err := issuer.Verify(token)
+			if err != nil {
+				middleware.ResponseError(c, 2004, err)
+				c.Abort()
+				return
+			}
+			// 将subject、roles等claim转发给后端
+			for name, value := range issuer.ClaimHeaders(claims) {
+				c.Request.Header.Set(name, value)
+			}
+			tokenScopes = dao.ClaimScopes(claims)
+			externalIssuer = true
+			appMatched = true
+		} else if token != "" {
+			// decode Jwt Token
 			claims, err := public.JwtDecode(token)
 			if err != nil {
 				middleware.ResponseError(c, 2002, err)
 				c.Abort()
 				return
 			}
+			// 检查token是否已被吊销
+			revoked, err := public.JwtIsRevoked(claims)
+			if err != nil {
+				middleware.ResponseError(c, 2007, err)
+				c.Abort()
+				return
+			}
+			if revoked {
+				middleware.ResponseError(c, 2007, errors.New("token revoked"))
+				c.Abort()
+				return
+			}
 			//fmt.Println("claims.Issuer", claims.Issuer)
 			appList := dao.AppManagerHandler.GetAppList()
 			// 通过app_id从app_list中取出app_info
@@ -45,6 +116,8 @@ func HTTPJwtOAuthTokenMiddleware() gin.HandlerFunc {
 					// 将app_info放到gin的context中
 					c.Set("app", appInfo)
 					appMatched = true
+					appGrant = serviceDetail.MatchAppGrant(appInfo.AppID)
+					tokenScopes = public.ScopeList(claims.Scope)
 					break
 				}
 			}
@@ -55,6 +128,40 @@ func HTTPJwtOAuthTokenMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if serviceDetail.AccessControl.OpenAuth == 1 {
+			// 服务配置了授权时租户必须被授权访问该服务
+			if !externalIssuer && appGrant == nil && serviceDetail.GrantRequired() {
+				middleware.ResponseErrorWithStatus(c, http.StatusForbidden, 2005, errors.New("app not granted to service"))
+				c.Abort()
+				return
+			}
+			// token和授权中都必须包含接口需要的权限范围
+			for _, scope := range serviceDetail.RequiredScopes(c.Request.Method, c.Request.URL.Path) {
+				if !public.InStringSlice(tokenScopes, scope) || (appGrant != nil && !appGrant.HasScope(scope)) {
+					middleware.ResponseErrorWithStatus(c, http.StatusForbidden, 2006, errors.New("missing scope "+scope))
+					c.Abort()
+					return
+				}
+			}
+		}
 		c.Next()
 	}
 }
+
+// httpApiKey 从header或者query中取出API Key，并删除以免转发给后端
+func httpApiKey(c *gin.Context) string {
+	headerName := dao.ApiKeyHeaderName()
+	apiKey := c.GetHeader(headerName)
+	c.Request.Header.Del(headerName)
+	if queryName := dao.ApiKeyQueryName(); queryName != "" {
+		query := c.Request.URL.Query()
+		if apiKey == "" {
+			apiKey = query.Get(queryName)
+		}
+		if _, ok := query[queryName]; ok {
+			query.Del(queryName)
+			c.Request.URL.RawQuery = query.Encode()
+		}
+	}
+	return apiKey
+}
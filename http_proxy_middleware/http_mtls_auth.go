@@ -0,0 +1,61 @@
+package http_proxy_middleware
+
+import (
+	"crypto/x509"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+	"net/http"
+	"strings"
+)
+
+// HTTPMtlsAuthMiddleware 客户端证书校验中间件
+// https监听器只请求客户端证书，由于多个服务共用端口，证书链按照服务各自的CA证书包在这里校验
+func HTTPMtlsAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		// 清除客户端伪造的证书身份header
+		c.Request.Header.Del(public.ClientCertSubjectHeader)
+		c.Request.Header.Del(public.ClientCertSanHeader)
+		if !serviceDetail.AccessControl.OpenMtls() {
+			c.Next()
+			return
+		}
+		var peerCerts []*x509.Certificate
+		if c.Request.TLS != nil {
+			peerCerts = c.Request.TLS.PeerCertificates
+		}
+		if len(peerCerts) == 0 {
+			if serviceDetail.AccessControl.ClientVerify == public.ClientVerifyRequired {
+				middleware.ResponseErrorWithStatus(c, http.StatusUnauthorized, 2002, errors.New("client certificate required"))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		cert, err := serviceDetail.AccessControl.VerifyClientCert(peerCerts)
+		if err != nil {
+			middleware.ResponseErrorWithStatus(c, http.StatusUnauthorized, 2003, err)
+			c.Abort()
+			return
+		}
+		// 将证书身份转发给后端，并保存到上下文中供白名单校验
+		identities := public.CertIdentities(cert)
+		c.Request.Header.Set(public.ClientCertSubjectHeader, cert.Subject.String())
+		c.Request.Header.Set(public.ClientCertSanHeader, strings.Join(public.CertSans(cert), ","))
+		c.Set(public.ClientCertIdentityKey, identities)
+		if appInfo := dao.AppManagerHandler.MatchCertApp(identities); appInfo != nil {
+			c.Set("app", appInfo)
+		}
+		c.Next()
+	}
+}
@@ -33,6 +33,18 @@ func HTTPWhiteListMiddleware() gin.HandlerFunc {
 				return
 			}
 		}
+		// 开启客户端证书校验时，证书身份需要在证书白名单中
+		if serviceDetail.AccessControl.OpenAuth == 1 && serviceDetail.AccessControl.OpenMtls() {
+			identities := []string{}
+			if identityInterface, ok := c.Get(public.ClientCertIdentityKey); ok {
+				identities = identityInterface.([]string)
+			}
+			if !serviceDetail.AccessControl.MatchWhiteCert(identities) {
+				middleware.ResponseError(c, 3002, errors.New("client certificate not in white cert list"))
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,104 @@
+package http_proxy_router
+
+import (
+	"context"
+	"fmt"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+	"log"
+	"net/http"
+	"time"
+)
+
+var AdminSrvHandler *http.Server
+
+// adminStartTime 进程启动时间，存活检查中返回运行时长
+var adminStartTime = time.Now()
+
+// AdminServerRun 管理端口，提供存活检查/healthz和就绪检查/readyz，未配置地址时不启动
+// 管理端口在代理服务器排空期间继续服务，以便探针及时看到未就绪
+func AdminServerRun() {
+	addr := lib.GetStringConf("proxy.admin.addr")
+	if addr == "" {
+		return
+	}
+	public.HealthHandler.ExpectListener("tcp", addr)
+	router := gin.New()
+	router.Use(middleware.RecoveryMiddleware())
+	router.GET("/healthz", healthzHandler)
+	router.GET("/readyz", readyzHandler)
+	AdminSrvHandler = &http.Server{
+		Addr:         addr,
+		Handler:      router,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	log.Printf(" [INFO] admin_run %s\n", addr)
+	ln, err := public.UpgraderHandler.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf(" [ERROR] admin_run %s err:%v\n", addr, err)
+	}
+	if err := AdminSrvHandler.Serve(ln); err != nil && err != http.ErrServerClosed {
+		log.Fatalf(" [ERROR] admin_run %s err:%v\n", addr, err)
+	}
+}
+
+// AdminServerStop 管理端口退出，在代理服务器排空之后调用
+func AdminServerStop() {
+	if AdminSrvHandler == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := AdminSrvHandler.Shutdown(ctx); err != nil {
+		AdminSrvHandler.Close()
+	}
+	log.Printf(" [INFO] admin_stop %v stopped\n", AdminSrvHandler.Addr)
+}
+
+// healthzHandler 进程存活即返回成功，不检查依赖
+func healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": public.HealthStatusOk,
+		"uptime": time.Since(adminStartTime).String(),
+	})
+}
+
+// readyzHandler 检查启动状态、配置加载、监听器、redis和服务下游，任意检查失败时返回503
+func readyzHandler(c *gin.Context) {
+	report := public.NewHealthReport(map[string]*public.HealthCheck{
+		"serving":   public.CheckServing(),
+		"config":    public.HealthHandler.CheckConfig(),
+		"listeners": public.HealthHandler.CheckListeners(),
+		"redis":     public.CheckRedis(),
+		"backends":  checkBackends(),
+	})
+	status := http.StatusOK
+	if report.Status == public.HealthStatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// checkBackends 统计下游全部不健康的服务，只影响单个服务，不作为网关未就绪的条件
+func checkBackends() *public.HealthCheck {
+	dao.ServiceManagerHandler.Locker.RLock()
+	services := append([]*dao.ServiceDetail{}, dao.ServiceManagerHandler.ServiceSlice...)
+	dao.ServiceManagerHandler.Locker.RUnlock()
+	unhealthy := dao.LoadBalancerHandler.ServicesWithoutHealthyBackend(services)
+	check := &public.HealthCheck{
+		Status: public.HealthStatusOk,
+		Detail: gin.H{
+			"services":                 len(services),
+			"without_healthy_backends": unhealthy,
+		},
+	}
+	if len(unhealthy) > 0 {
+		check.Status = public.HealthStatusWarn
+		check.Message = fmt.Sprintf("%d services have no healthy backend", len(unhealthy))
+	}
+	return check
+}
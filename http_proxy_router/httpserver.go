@@ -2,9 +2,14 @@ package http_proxy_router
 
 import (
 	"context"
+	"crypto/tls"
 	"github.com/e421083458/golang_common/lib"
 	"github.com/gin-gonic/gin"
+	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/middleware"
+	"github.com/zhj/go_gateway/public"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"log"
 	"net/http"
 	"time"
@@ -13,6 +18,10 @@ import (
 var (
 	HttpSrvHandler  *http.Server
 	HttpsSrvHandler *http.Server
+
+	// httpConns、httpsConns 记录处理中的连接，关闭超时时报告被中断的请求
+	httpConns  = public.NewHttpConnTracker()
+	httpsConns = public.NewHttpConnTracker()
 )
 
 // HttpServerRun http代理服务器运行
@@ -21,27 +30,43 @@ func HttpServerRun() {
 	r := InitRouter(
 		middleware.RecoveryMiddleware(),
 		middleware.RequestLog())
+	// 开启h2c时同一端口同时接受HTTP/1.1和明文HTTP/2
+	var handler http.Handler = r
+	if lib.GetBoolConf("proxy.http.h2c") {
+		handler = h2c.NewHandler(r, newHTTP2Server("proxy.http"))
+	}
 	HttpSrvHandler = &http.Server{
 		Addr:           lib.GetStringConf("proxy.http.addr"),
-		Handler:        r,
+		Handler:        handler,
 		ReadTimeout:    time.Duration(lib.GetIntConf("proxy.http.read_timeout")) * time.Second,
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.http.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.http.max_header_bytes")),
+		ConnState:      httpConns.ConnState,
 	}
 	log.Printf(" [INFO] http_proxy_run %s\n", lib.GetStringConf("proxy.http.addr"))
-	if err := HttpSrvHandler.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	ln, err := public.UpgraderHandler.Listen("tcp", HttpSrvHandler.Addr)
+	if err != nil {
 		log.Fatalf(" [ERROR] http_proxy_run %s err:%v\n", lib.GetStringConf("proxy.http.addr"), err)
 	}
+	if err := HttpSrvHandler.Serve(ln); err != nil && err != http.ErrServerClosed {
+		log.Fatalf(" [ERROR] http_proxy_run %s err:%v\n", lib.GetStringConf("proxy.http.addr"), err)
+	}
+}
+
+// ExpectListeners 登记http和https代理需要监听的地址，在启动代理服务器之前调用
+func ExpectListeners() {
+	public.HealthHandler.ExpectListener("tcp", lib.GetStringConf("proxy.http.addr"))
+	public.HealthHandler.ExpectListener("tcp", lib.GetStringConf("proxy.https.addr"))
 }
 
-// HttpServerStop http代理服务器退出
-func HttpServerStop() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := HttpSrvHandler.Shutdown(ctx); err != nil {
-		log.Printf(" [ERROR] http_proxy_stop err:%v\n", err)
+// HttpServerStop http代理服务器退出，等待处理中的请求结束，ctx到期后强制关闭，返回被中断的请求数
+func HttpServerStop(ctx context.Context) int {
+	if HttpSrvHandler == nil {
+		return 0
 	}
+	cut := public.ShutdownHttpServer(ctx, HttpSrvHandler, httpConns)
 	log.Printf(" [INFO] http_proxy_stop %v stopped\n", lib.GetStringConf("proxy.http.addr"))
+	return cut
 }
 
 // HttpsServerRun https代理服务器运行
@@ -55,21 +80,47 @@ func HttpsServerRun() {
 		ReadTimeout:    time.Duration(lib.GetIntConf("proxy.https.read_timeout")) * time.Second,
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.https.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.https.max_header_bytes")),
+		ConnState:      httpsConns.ConnState,
+		// 多个服务共用https端口，握手时只请求客户端证书，由服务各自的CA证书包校验
+		// 服务端证书按照SNI从证书库中选择，未命中时使用配置文件中的默认证书
+		TLSConfig: &tls.Config{
+			ClientAuth:     tls.RequestClientCert,
+			GetCertificate: dao.CertManagerHandler.GetCertificate,
+		},
+	}
+	// 通过ALPN协商HTTP/2，关闭时只支持HTTP/1.1
+	if lib.GetBoolConf("proxy.https.http2") {
+		if err := http2.ConfigureServer(HttpsSrvHandler, newHTTP2Server("proxy.https")); err != nil {
+			log.Fatalf(" [ERROR] https_proxy_http2 %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
+		}
+	} else {
+		HttpsSrvHandler.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
 	}
 	log.Printf(" [INFO] https_proxy_run %s\n", lib.GetStringConf("proxy.https.addr"))
 	//todo 以下命令只在编译机有效，如果是交叉编译情况下需要单独设置路径
 	//if err := HttpsSrvHandler.ListenAndServeTLS(cert_file.Path("server.crt"), cert_file.Path("server.key")); err != nil && err!=http.ErrServerClosed {
-	if err := HttpsSrvHandler.ListenAndServeTLS("./cert_file/server.crt", "./cert_file/server.key"); err != nil && err != http.ErrServerClosed {
+	ln, err := public.UpgraderHandler.Listen("tcp", HttpsSrvHandler.Addr)
+	if err != nil {
+		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
+	}
+	if err := HttpsSrvHandler.ServeTLS(ln, "", ""); err != nil && err != http.ErrServerClosed {
 		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
 	}
 }
 
-// HttpsServerStop http代理服务器退出
-func HttpsServerStop() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := HttpsSrvHandler.Shutdown(ctx); err != nil {
-		log.Fatalf(" [ERROR] https_proxy_stop err:%v\n", err)
+// newHTTP2Server 按照监听配置创建HTTP/2服务端参数，单个连接上的最大并发流为0时使用默认值
+func newHTTP2Server(confPrefix string) *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams: uint32(lib.GetIntConf(confPrefix + ".max_concurrent_streams")),
+	}
+}
+
+// HttpsServerStop https代理服务器退出，等待处理中的请求结束，ctx到期后强制关闭，返回被中断的请求数
+func HttpsServerStop(ctx context.Context) int {
+	if HttpsSrvHandler == nil {
+		return 0
 	}
+	cut := public.ShutdownHttpServer(ctx, HttpsSrvHandler, httpsConns)
 	log.Printf(" [INFO] https_proxy_stop %v stopped\n", lib.GetStringConf("proxy.https.addr"))
+	return cut
 }
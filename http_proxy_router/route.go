@@ -26,6 +26,8 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		http_proxy_middleware.HTTPAccessModeMiddleware(),
 		http_proxy_middleware.HTTPFlowCountMiddleware(),
 		http_proxy_middleware.HTTPFlowLimitMiddleware(),
+		http_proxy_middleware.HTTPMtlsAuthMiddleware(),
+		http_proxy_middleware.HTTPHmacAuthMiddleware(),
 		http_proxy_middleware.HTTPJwtOAuthTokenMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowCountMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowLimitMiddleware(),
@@ -34,6 +36,7 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		http_proxy_middleware.HTTPHeaderTransMiddleware(),
 		http_proxy_middleware.HTTPStripUriMiddleware(),
 		http_proxy_middleware.HTTPUrlRewriteMiddleware(),
+		http_proxy_middleware.HTTPGrpcTranscodeMiddleware(),
 		http_proxy_middleware.HTTPReverseProxyMiddleware(),
 	)
 	return router
@@ -7,11 +7,15 @@ import (
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/grpc_proxy_router"
 	"github.com/zhj/go_gateway/http_proxy_router"
+	"github.com/zhj/go_gateway/public"
 	"github.com/zhj/go_gateway/router"
 	"github.com/zhj/go_gateway/tcp_proxy_router"
+	"github.com/zhj/go_gateway/udp_proxy_router"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 // 使用同一个main文件来实现后台管理功能和代理服务器功能
@@ -47,30 +51,77 @@ func main() {
 		lib.InitModule(config, []string{"base", "mysql", "redis"})
 		defer lib.Destroy()
 		// 调用ServiceManagerHandler.LoadOnce()方法将服务加载到内存（只加载一次）
-		dao.ServiceManagerHandler.LoadOnce()
+		public.HealthHandler.ConfigLoaded("service", dao.ServiceManagerHandler.LoadOnce())
 		// 调用AppManagerHandler.LoadOnce()方法将租户加载到内存（只加载一次）
-		dao.AppManagerHandler.LoadOnce()
+		public.HealthHandler.ConfigLoaded("app", dao.AppManagerHandler.LoadOnce())
+		// 调用JwtKeyManagerHandler.LoadOnce()方法加载JWT签名密钥并启动定时轮换
+		public.HealthHandler.ConfigLoaded("jwt_key", dao.JwtKeyManagerHandler.LoadOnce())
+		// 调用ApiKeyManagerHandler.LoadOnce()方法加载租户API Key并定时同步
+		public.HealthHandler.ConfigLoaded("api_key", dao.ApiKeyManagerHandler.LoadOnce())
+		// 调用CertManagerHandler.LoadOnce()方法加载HTTPS证书库并定时重新加载
+		public.HealthHandler.ConfigLoaded("cert", dao.CertManagerHandler.LoadOnce())
+
+		// 加载失败的配置通过管理端口的/readyz报告
+		go func() {
+			http_proxy_router.AdminServerRun()
+		}()
 
 		// 因为可能需要同时启动多个代理服务器，所以需要使用goroutine来启动
+		// 需要监听的地址在启动之前登记，全部监听成功后才就绪
+		http_proxy_router.ExpectListeners()
 		go func() {
 			http_proxy_router.HttpServerRun()
 		}()
 		go func() {
 			http_proxy_router.HttpsServerRun()
 		}()
+		// TCP、gRPC和UDP服务登记监听地址后在各自的goroutine中启动，不会阻塞
+		tcp_proxy_router.TCPServerRun()
+		grpc_proxy_router.GrpcServerRun()
+		udp_proxy_router.UDPServerRun()
 		go func() {
-			tcp_proxy_router.TCPServerRun()
-		}()
-		go func() {
-			grpc_proxy_router.GrpcServerRun()
+			// 平滑升级启动时等待继承的监听器全部启动，再通知父进程退出
+			public.UpgraderHandler.WaitInherited()
+			// 配置加载或监听失败时新进程不通知父进程并退出，父进程继续服务
+			if err := public.HealthHandler.WaitStarted(public.UpgraderHandler.InheritTimeout); err != nil {
+				if public.UpgraderHandler.IsUpgradeChild() {
+					log.Fatalf(" [ERROR] upgrade child not ready err:%v\n", err)
+				}
+				log.Printf(" [ERROR] server not ready err:%v\n", err)
+				return
+			}
+			// 收到退出信号后不再置为就绪
+			if !public.MarkServerReady() {
+				return
+			}
+			if err := public.UpgraderHandler.Ready(); err != nil {
+				log.Printf(" [ERROR] upgrade notify ready err:%v\n", err)
+			}
 		}()
 		fmt.Println("start server")
-		quit := make(chan os.Signal)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
-		tcp_proxy_router.TCPServerStop()
-		grpc_proxy_router.GrpcServerStop()
-		http_proxy_router.HttpServerStop()
-		http_proxy_router.HttpsServerStop()
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM}, public.UpgradeSignals...)...)
+		for {
+			sig := <-quit
+			if !public.IsUpgradeSignal(sig) {
+				break
+			}
+			// 新进程就绪后当前进程排空连接并退出，升级失败时继续服务
+			if err := public.UpgraderHandler.Upgrade(); err != nil {
+				log.Printf(" [ERROR] upgrade err:%v\n", err)
+				continue
+			}
+			break
+		}
+		// 所有代理服务器同时停止接受新连接，在排空时间内等待处理中的请求和连接结束
+		coordinator := public.NewShutdownCoordinator(
+			time.Duration(lib.GetIntConf("proxy.shutdown.drain_timeout")) * time.Second)
+		coordinator.Register("http_proxy", http_proxy_router.HttpServerStop)
+		coordinator.Register("https_proxy", http_proxy_router.HttpsServerStop)
+		coordinator.Register("tcp_proxy", tcp_proxy_router.TCPServerStop)
+		coordinator.Register("grpc_proxy", grpc_proxy_router.GrpcServerStop)
+		coordinator.Register("udp_proxy", udp_proxy_router.UDPServerStop)
+		coordinator.Shutdown()
+		http_proxy_router.AdminServerStop()
 	}
 }
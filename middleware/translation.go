@@ -10,6 +10,7 @@ import (
 	"gopkg.in/go-playground/validator.v9"
 	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
 	zh_translations "gopkg.in/go-playground/validator.v9/translations/zh"
+	"path"
 	"reflect"
 	"regexp"
 	"strings"
@@ -110,6 +111,59 @@ func TranslationMiddleware() gin.HandlerFunc {
 				}
 				return true
 			})
+			val.RegisterValidation("valid_required_claims", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				for _, ms := range strings.Split(fl.Field().String(), ",") {
+					if matched, _ := regexp.Match(`^[^\s=]+(=\S+)?$`, []byte(strings.TrimSpace(ms))); !matched {
+						return false
+					}
+				}
+				return true
+			})
+			val.RegisterValidation("valid_claim_header", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				for _, ms := range strings.Split(fl.Field().String(), ",") {
+					if len(strings.Split(strings.TrimSpace(ms), " ")) != 2 {
+						return false
+					}
+				}
+				return true
+			})
+			val.RegisterValidation("valid_scope", func(fl validator.FieldLevel) bool {
+				for _, ms := range public.ScopeList(fl.Field().String()) {
+					if matched, _ := regexp.Match(`^[\w:.\-/]+$`, []byte(ms)); !matched {
+						return false
+					}
+				}
+				return true
+			})
+			val.RegisterValidation("valid_grpc_method", func(fl validator.FieldLevel) bool {
+				// 完整方法名或通配，如 /pkg.Service/Method、/pkg.Service/*
+				if !strings.HasPrefix(fl.Field().String(), "/") {
+					return false
+				}
+				_, err := path.Match(fl.Field().String(), "")
+				return err == nil
+			})
+			val.RegisterValidation("valid_client_ca", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				_, err := public.CertPoolFromPEM(fl.Field().String())
+				return err == nil
+			})
+			val.RegisterValidation("valid_tls_version", func(fl validator.FieldLevel) bool {
+				_, err := public.ParseTLSVersion(fl.Field().String())
+				return err == nil
+			})
+			val.RegisterValidation("valid_cipher_suites", func(fl validator.FieldLevel) bool {
+				_, err := public.ParseCipherSuites(fl.Field().String())
+				return err == nil
+			})
 
 			//自定义翻译器
 			//https://github.com/go-playground/validator/blob/v9/_examples/translations/main.go
@@ -161,6 +215,48 @@ func TranslationMiddleware() gin.HandlerFunc {
 				t, _ := ut.T("valid_weightlist", fe.Field())
 				return t
 			})
+			val.RegisterTranslation("valid_required_claims", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_required_claims", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_required_claims", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_claim_header", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_claim_header", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_claim_header", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_scope", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_scope", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_scope", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_grpc_method", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_grpc_method", "{0} 需要是以/开头的完整方法名或通配", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_grpc_method", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_client_ca", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_client_ca", "{0} 不是有效的PEM格式证书", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_client_ca", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_tls_version", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_tls_version", "{0} 只支持1.0、1.1、1.2、1.3", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_tls_version", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_cipher_suites", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_cipher_suites", "{0} 包含不支持的加密套件", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_cipher_suites", fe.Field())
+				return t
+			})
 			break
 		}
 		c.Set(public.TranslatorKey, trans)
@@ -0,0 +1,82 @@
+package public
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ParseCertKeyPair 解析PEM格式的证书链和私钥，并校验两者是否匹配
+func ParseCertKeyPair(certPem, keyPem string) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair([]byte(certPem), []byte(keyPem))
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf = leaf
+	return &cert, nil
+}
+
+// CertHosts 获取证书可用于SNI匹配的域名，没有DNS SAN时使用subject的CN
+func CertHosts(cert *x509.Certificate) []string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames
+	}
+	if cert.Subject.CommonName != "" {
+		return []string{cert.Subject.CommonName}
+	}
+	return []string{}
+}
+
+// CertStore 按照SNI选择服务端证书，精确匹配优先，其次*.通配符，都未命中时使用默认证书
+type CertStore struct {
+	exact    map[string]*tls.Certificate
+	wildcard map[string]*tls.Certificate
+	fallback *tls.Certificate
+}
+
+// NewCertStore 创建证书库，fallback为客户端未携带SNI或未命中时使用的证书，可以为空
+func NewCertStore(fallback *tls.Certificate) *CertStore {
+	return &CertStore{
+		exact:    map[string]*tls.Certificate{},
+		wildcard: map[string]*tls.Certificate{},
+		fallback: fallback,
+	}
+}
+
+// Add 将证书按照域名加入证书库，同一域名已存在证书时保留先加入的证书
+func (s *CertStore) Add(cert *tls.Certificate, hosts []string) {
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if strings.HasPrefix(host, "*.") {
+			if _, ok := s.wildcard[host[1:]]; !ok {
+				s.wildcard[host[1:]] = cert
+			}
+			continue
+		}
+		if _, ok := s.exact[host]; host != "" && !ok {
+			s.exact[host] = cert
+		}
+	}
+}
+
+// Get 按照SNI选择证书
+func (s *CertStore) Get(serverName string) (*tls.Certificate, error) {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if cert, ok := s.exact[serverName]; ok {
+		return cert, nil
+	}
+	if dot := strings.Index(serverName, "."); dot > 0 {
+		if cert, ok := s.wildcard[serverName[dot:]]; ok {
+			return cert, nil
+		}
+	}
+	if s.fallback != nil {
+		return s.fallback, nil
+	}
+	return nil, errors.New("no certificate for server name " + serverName)
+}
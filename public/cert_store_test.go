@@ -0,0 +1,56 @@
+package public
+
+import (
+	"crypto/tls"
+	"github.com/zhj/go_gateway/public/test_cert"
+	"testing"
+)
+
+// newTestServerPem 生成自签名的服务端证书和私钥
+func newTestServerPem(t *testing.T, hosts ...string) (string, string) {
+	cert := test_cert.NewServer(t, nil, hosts...)
+	return cert.CertPem(), cert.KeyPem()
+}
+
+func TestParseCertKeyPair(t *testing.T) {
+	certPem, keyPem := newTestServerPem(t, "api.example.com", "*.example.com")
+	cert, err := ParseCertKeyPair(certPem, keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts := CertHosts(cert.Leaf); len(hosts) != 2 || hosts[1] != "*.example.com" {
+		t.Fatalf("unexpected hosts %v", hosts)
+	}
+	// 证书与私钥不匹配
+	_, otherKey := newTestServerPem(t, "other.example.com")
+	if _, err := ParseCertKeyPair(certPem, otherKey); err == nil {
+		t.Fatal("mismatched key should fail")
+	}
+}
+
+func TestCertStore(t *testing.T) {
+	fallback := &tls.Certificate{}
+	exact := &tls.Certificate{}
+	wildcard := &tls.Certificate{}
+	store := NewCertStore(fallback)
+	store.Add(exact, []string{"API.example.com"})
+	store.Add(wildcard, []string{"*.example.com", "api.example.com"})
+
+	cases := []struct {
+		serverName string
+		cert       *tls.Certificate
+	}{
+		{"api.example.com", exact},
+		{"www.example.com", wildcard},
+		{"a.b.example.com", fallback},
+		{"", fallback},
+	}
+	for _, item := range cases {
+		if cert, err := store.Get(item.serverName); err != nil || cert != item.cert {
+			t.Fatalf("unexpected certificate for %q", item.serverName)
+		}
+	}
+	if _, err := NewCertStore(nil).Get("api.example.com"); err == nil {
+		t.Fatal("empty store without fallback should fail")
+	}
+}
@@ -9,6 +9,13 @@ const (
 	LoadTypeHTTP = 0
 	LoadTypeTCP  = 1
 	LoadTypeGRPC = 2
+	LoadTypeUDP  = 3
+
+	// 租户认证方式常量
+	AuthTypeJwt         = 0
+	AuthTypeApiKey      = 1
+	AuthTypeJwtOrApiKey = 2
+	AuthTypeHmac        = 3
 
 	// http域名接入类型常量
 	HTTPRuleTypePrefixURL = 0
@@ -23,8 +30,88 @@ const (
 	FlowServicePrefix = "flow_service_"
 	FlowAppPrefix     = "flow_app_"
 
-	JwtSignKey = "my_sign_key"
+	// TCP服务TLS握手失败计数和握手耗时统计
+	FlowTlsErrorPrefix   = "flow_tls_error_"
+	RedisTLSHandshakeKey = "tls_handshake"
+
+	// TCP连接统计，按小时和天汇总连接数、流量、连接时长和拨号情况，并发连接数按网关实例上报
+	RedisTcpConnStatKey   = "tcp_conn_stat"
+	RedisTcpConnActiveKey = "tcp_conn_active"
+
+	// gRPC服务按状态码统计的错误计数，完整key为 前缀+服务名+"_"+状态码名称
+	FlowGrpcErrorPrefix = "flow_grpc_error_"
+
+	// redis协议TCP服务按命令统计的计数，完整key为 前缀+服务名+"_"+命令名
+	FlowRedisCommandPrefix = "flow_redis_command_"
+
 	JwtExpires = 60 * 60
+
+	// JWT密钥轮换默认值，单位s
+	JwtKeyRotateInterval = 7 * 24 * 60 * 60
+	JwtKeyCheckInterval  = 60
+	JwtKeyLoadWait       = 5 //启动时等待其他节点写入签名密钥的最长时间
+	RedisJwtKeyRotateKey = "jwt_key_rotate_lock"
+
+	// 刷新token默认有效期，单位s
+	JwtRefreshExpires = 30 * 24 * 60 * 60
+
+	// token吊销相关的redis key前缀
+	RedisJwtDenyPrefix      = "jwt_deny_"
+	RedisJwtAppRevokePrefix = "jwt_app_revoke_"
+	RedisJwtRefreshPrefix   = "jwt_refresh_"
+	// 租户签发的全部刷新token，删除租户时一起删除
+	RedisJwtRefreshAppPrefix = "jwt_refresh_app_"
+
+	// API Key默认的header和query参数名
+	ApiKeyHeader     = "X-Api-Key"
+	ApiKeyQuery      = "api_key"
+	ApiKeyPrefix     = "gk_"
+	ApiKeyReloadTime = 60
+
+	// HMAC签名认证默认允许的时钟偏差，单位s，以及签名校验时读取的body上限，单位byte
+	HmacClockSkew        = 300
+	HmacMaxBodySize      = 10 << 20
+	RedisHmacNoncePrefix = "hmac_nonce_"
+
+	// 客户端证书校验方式常量
+	ClientVerifyOff      = 0
+	ClientVerifyOptional = 1
+	ClientVerifyRequired = 2
+
+	// 客户端证书身份转发给后端的header，以及在上下文中保存证书身份的key
+	ClientCertSubjectHeader = "X-Client-Cert-Subject"
+	ClientCertSanHeader     = "X-Client-Cert-San"
+	ClientCertIdentityKey   = "client_cert_identity"
+
+	// TCP共享端口按照SNI路由时，在上下文中保存SNI的key
+	SniContextKey = "sni"
+
+	// 证书库定时重新加载的间隔，以及即将过期告警的天数
+	CertReloadTime     = 60
+	CertExpireWarnDays = 30
+
+	// HTTP服务连接下游使用的协议常量
+	UpstreamProtocolHTTP1 = 0
+	UpstreamProtocolH2    = 1
+	UpstreamProtocolH2C   = 2
+
+	// 下游TLS开关，HTTP服务未设置时沿用need_https，兼容拆分下游TLS配置之前的服务
+	UpstreamTlsUnset = 0
+	UpstreamTlsOn    = 1
+	UpstreamTlsOff   = 2
+
+	// gRPC方法规则的超时方式，作为deadline上限或者在客户端未设置deadline时使用
+	MethodTimeoutCap     = 0
+	MethodTimeoutDefault = 1
+
+	// gRPC服务反射请求处理方式
+	GrpcReflectionForward    = 0
+	GrpcReflectionDescriptor = 1
+	GrpcReflectionDisable    = 2
+
+	// TCP服务解析的应用层协议，0为原始字节转发
+	TcpProtocolRaw   = 0
+	TcpProtocolRedis = 1
 )
 
 var (
@@ -32,5 +119,12 @@ var (
 		LoadTypeHTTP: "HTTP",
 		LoadTypeTCP:  "TCP",
 		LoadTypeGRPC: "GRPC",
+		LoadTypeUDP:  "UDP",
 	}
+
+	// redis脚本命令，脚本中可以执行被禁止的命令，只有在允许列表中显式列出时才允许
+	RedisScriptCommands = []string{"EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO"}
+
+	// 证书即将过期的告警阈值，单位天，从大到小排列，每个证书在每个阈值只告警一次，0表示已经过期
+	CertExpireWarnThresholds = []int{CertExpireWarnDays, 7, 1, 0}
 )
@@ -0,0 +1,159 @@
+package public
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 就绪检查项的状态，warn不影响整体就绪
+const (
+	HealthStatusOk   = "ok"
+	HealthStatusWarn = "warn"
+	HealthStatusFail = "fail"
+)
+
+var errListenerPending = errors.New("not bound yet")
+
+// HealthHandler 暴露出去的Handler
+var HealthHandler *HealthManager
+
+func init() {
+	HealthHandler = NewHealthManager()
+}
+
+// HealthCheck 单个检查项的结果
+type HealthCheck struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+// HealthReport 就绪检查的结果，任意检查项为fail时整体为fail
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks"`
+}
+
+// NewHealthReport 按照各检查项计算整体状态
+func NewHealthReport(checks map[string]*HealthCheck) *HealthReport {
+	report := &HealthReport{Status: HealthStatusOk, Checks: checks}
+	for _, check := range checks {
+		if check.Status == HealthStatusFail {
+			report.Status = HealthStatusFail
+		}
+	}
+	return report
+}
+
+// HealthManager 记录启动过程中配置加载和监听器的结果
+type HealthManager struct {
+	mu        sync.Mutex
+	configs   map[string]error
+	listeners map[string]error //尚未监听时为errListenerPending
+}
+
+// NewHealthManager 暴露出去的New方法
+func NewHealthManager() *HealthManager {
+	return &HealthManager{
+		configs:   map[string]error{},
+		listeners: map[string]error{},
+	}
+}
+
+// ConfigLoaded 记录配置的加载结果
+func (h *HealthManager) ConfigLoaded(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.configs[name] = err
+}
+
+// ExpectListener 记录需要监听的地址，监听成功前就绪检查失败
+func (h *HealthManager) ExpectListener(network, addr string) {
+	name := socketName(network, addr)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.listeners[name]; !ok {
+		h.listeners[name] = errListenerPending
+	}
+}
+
+// listenerResult 记录监听结果，由Upgrader在监听时调用
+func (h *HealthManager) listenerResult(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners[name] = err
+}
+
+// CheckConfig 所有配置是否加载成功
+func (h *HealthManager) CheckConfig() *HealthCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return checkErrors(h.configs, "loaded", "config load failed")
+}
+
+// CheckListeners 所有需要监听的地址是否监听成功
+func (h *HealthManager) CheckListeners() *HealthCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return checkErrors(h.listeners, "bound", "listener not bound")
+}
+
+// WaitStarted 等待所有需要监听的地址完成监听，超过timeout后不再等待，配置加载或监听失败时返回错误
+func (h *HealthManager) WaitStarted(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for h.listenerPending() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	for name, check := range map[string]*HealthCheck{"config": h.CheckConfig(), "listeners": h.CheckListeners()} {
+		if check.Status == HealthStatusFail {
+			return fmt.Errorf("%s: %s %v", name, check.Message, check.Detail)
+		}
+	}
+	return nil
+}
+
+// listenerPending 是否还有尚未监听的地址
+func (h *HealthManager) listenerPending() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, err := range h.listeners {
+		if err == errListenerPending {
+			return true
+		}
+	}
+	return false
+}
+
+func checkErrors(items map[string]error, okText, failMessage string) *HealthCheck {
+	check := &HealthCheck{Status: HealthStatusOk}
+	detail := map[string]string{}
+	for name, err := range items {
+		if err != nil {
+			check.Status = HealthStatusFail
+			check.Message = failMessage
+			detail[name] = err.Error()
+			continue
+		}
+		detail[name] = okText
+	}
+	check.Detail = detail
+	return check
+}
+
+// CheckServing 服务是否启动完成且没有在关闭过程中
+func CheckServing() *HealthCheck {
+	if !ServerReady() {
+		return &HealthCheck{Status: HealthStatusFail, Message: "starting or draining"}
+	}
+	return &HealthCheck{Status: HealthStatusOk}
+}
+
+// CheckRedis redis是否可以访问
+func CheckRedis() *HealthCheck {
+	if _, err := RedisConfDo("PING"); err != nil {
+		return &HealthCheck{Status: HealthStatusFail, Message: err.Error()}
+	}
+	return &HealthCheck{Status: HealthStatusOk}
+}
@@ -0,0 +1,83 @@
+package public
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthManagerChecks(t *testing.T) {
+	h := NewHealthManager()
+	h.ConfigLoaded("service", nil)
+	if check := h.CheckConfig(); check.Status != HealthStatusOk {
+		t.Fatalf("config check = %+v", check)
+	}
+	h.ConfigLoaded("app", errors.New("mysql down"))
+	if check := h.CheckConfig(); check.Status != HealthStatusFail || check.Detail.(map[string]string)["app"] != "mysql down" {
+		t.Fatalf("config check = %+v", check)
+	}
+
+	h.ExpectListener("tcp", ":8001")
+	if check := h.CheckListeners(); check.Status != HealthStatusFail {
+		t.Fatalf("pending listener check = %+v", check)
+	}
+	h.listenerResult(socketName("tcp", ":8001"), nil)
+	// 已经监听的地址再次登记时保持监听成功的状态
+	h.ExpectListener("tcp", ":8001")
+	if check := h.CheckListeners(); check.Status != HealthStatusOk {
+		t.Fatalf("bound listener check = %+v", check)
+	}
+}
+
+func TestUpgraderListenReportsHealth(t *testing.T) {
+	HealthHandler.ExpectListener("tcp", "127.0.0.1:0")
+	ln, err := NewUpgrader().Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if check := HealthHandler.CheckListeners(); check.Status != HealthStatusOk {
+		t.Fatalf("listener check = %+v", check)
+	}
+}
+
+func TestHealthReportStatus(t *testing.T) {
+	report := NewHealthReport(map[string]*HealthCheck{
+		"a": {Status: HealthStatusOk},
+		"b": {Status: HealthStatusWarn},
+	})
+	if report.Status != HealthStatusOk {
+		t.Fatalf("report status = %s, warn should not fail readiness", report.Status)
+	}
+	report.Checks["c"] = &HealthCheck{Status: HealthStatusFail}
+	if report = NewHealthReport(report.Checks); report.Status != HealthStatusFail {
+		t.Fatalf("report status = %s, want fail", report.Status)
+	}
+}
+
+func TestHealthManagerWaitStarted(t *testing.T) {
+	h := NewHealthManager()
+	h.ConfigLoaded("service", nil)
+	h.ExpectListener("tcp", ":8001")
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		h.listenerResult(socketName("tcp", ":8001"), nil)
+	}()
+	if err := h.WaitStarted(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// 监听失败或超时仍未监听时返回错误
+	h.ExpectListener("tcp", ":8002")
+	if err := h.WaitStarted(200 * time.Millisecond); err == nil {
+		t.Fatal("pending listener should fail startup")
+	}
+	h.listenerResult(socketName("tcp", ":8002"), errors.New("address already in use"))
+	if err := h.WaitStarted(time.Second); err == nil {
+		t.Fatal("failed listener should fail startup")
+	}
+	h = NewHealthManager()
+	h.ConfigLoaded("app", errors.New("mysql down"))
+	if err := h.WaitStarted(time.Second); err == nil {
+		t.Fatal("failed config should fail startup")
+	}
+}
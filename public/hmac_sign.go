@@ -0,0 +1,190 @@
+package public
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HmacAlgorithm HMAC签名认证的算法标识，同时作为Authorization头的前缀
+const HmacAlgorithm = "HMAC-SHA256"
+
+// HmacAuth 从Authorization头中解析出的签名信息
+// 格式: HMAC-SHA256 AppId=xx,Timestamp=xx,Nonce=xx,SignedHeaders=content-type;host,Signature=xx
+type HmacAuth struct {
+	AppID         string
+	Timestamp     int64
+	Nonce         string
+	SignedHeaders []string
+	Signature     string
+}
+
+// ParseHmacAuth 解析Authorization头
+func ParseHmacAuth(authorization string) (*HmacAuth, error) {
+	if !strings.HasPrefix(authorization, HmacAlgorithm+" ") {
+		return nil, errors.New("authorization is not " + HmacAlgorithm)
+	}
+	auth := &HmacAuth{}
+	for _, item := range strings.Split(strings.TrimPrefix(authorization, HmacAlgorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("invalid authorization item " + item)
+		}
+		switch kv[0] {
+		case "AppId":
+			auth.AppID = kv[1]
+		case "Timestamp":
+			timestamp, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return nil, errors.WithMessage(err, "invalid timestamp")
+			}
+			auth.Timestamp = timestamp
+		case "Nonce":
+			auth.Nonce = kv[1]
+		case "SignedHeaders":
+			if kv[1] != "" {
+				auth.SignedHeaders = strings.Split(kv[1], ";")
+			}
+		case "Signature":
+			auth.Signature = kv[1]
+		}
+	}
+	if auth.AppID == "" || auth.Timestamp == 0 || auth.Nonce == "" || auth.Signature == "" {
+		return nil, errors.New("authorization missing AppId, Timestamp, Nonce or Signature")
+	}
+	return auth, nil
+}
+
+// String 生成Authorization头
+func (a *HmacAuth) String() string {
+	return fmt.Sprintf("%s AppId=%s,Timestamp=%d,Nonce=%s,SignedHeaders=%s,Signature=%s",
+		HmacAlgorithm, a.AppID, a.Timestamp, a.Nonce, strings.Join(a.SignedHeaders, ";"), a.Signature)
+}
+
+// HmacStringToSign 生成待签名字符串，包含请求方法、路径、规范化的query、指定的header、body摘要、时间戳和nonce
+func HmacStringToSign(req *http.Request, auth *HmacAuth, bodyDigest string) string {
+	lines := []string{
+		HmacAlgorithm,
+		strings.ToUpper(req.Method),
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+	}
+	for _, name := range auth.SignedHeaders {
+		lines = append(lines, name+":"+canonicalHeader(req, name))
+	}
+	lines = append(lines,
+		strings.Join(auth.SignedHeaders, ";"),
+		bodyDigest,
+		strconv.FormatInt(auth.Timestamp, 10),
+		auth.Nonce,
+	)
+	return strings.Join(lines, "\n")
+}
+
+// HmacSignature 使用密钥计算签名
+func HmacSignature(secret, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// HmacBodyDigest 读取body计算sha256摘要，并将body还原以便后续转发，maxBodySize大于0时限制读取的body大小
+func HmacBodyDigest(req *http.Request, maxBodySize int64) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		var reader io.Reader = req.Body
+		if maxBodySize > 0 {
+			reader = io.LimitReader(req.Body, maxBodySize+1)
+		}
+		var err error
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			return "", err
+		}
+		if maxBodySize > 0 && int64(len(body)) > maxBodySize {
+			return "", errors.New(fmt.Sprintf("request body exceeds %d bytes", maxBodySize))
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HmacVerify 校验请求签名，时间戳需要在允许的偏差内，body超过maxBodySize时校验失败
+func HmacVerify(req *http.Request, auth *HmacAuth, secret string, clockSkew, maxBodySize int64) error {
+	now := time.Now().Unix()
+	if auth.Timestamp < now-clockSkew || auth.Timestamp > now+clockSkew {
+		return errors.New("timestamp out of allowed clock skew")
+	}
+	bodyDigest, err := HmacBodyDigest(req, maxBodySize)
+	if err != nil {
+		return err
+	}
+	expected := HmacSignature(secret, HmacStringToSign(req, auth, bodyDigest))
+	if !hmac.Equal([]byte(expected), []byte(auth.Signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// HmacSignRequest 参考客户端签名实现，对请求签名并写入Authorization头
+func HmacSignRequest(req *http.Request, appID, secret string, signedHeaders []string) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	headers := []string{}
+	for _, name := range signedHeaders {
+		headers = append(headers, strings.ToLower(name))
+	}
+	sort.Strings(headers)
+	auth := &HmacAuth{
+		AppID:         appID,
+		Timestamp:     time.Now().Unix(),
+		Nonce:         hex.EncodeToString(nonce),
+		SignedHeaders: headers,
+	}
+	bodyDigest, err := HmacBodyDigest(req, 0)
+	if err != nil {
+		return err
+	}
+	auth.Signature = HmacSignature(secret, HmacStringToSign(req, auth, bodyDigest))
+	req.Header.Set("Authorization", auth.String())
+	return nil
+}
+
+// canonicalQuery 按照key和value排序后编码query
+func canonicalQuery(query url.Values) string {
+	for key := range query {
+		sort.Strings(query[key])
+	}
+	return query.Encode()
+}
+
+// canonicalHeader 获取header规范化后的值，host需要从请求中单独读取
+func canonicalHeader(req *http.Request, name string) string {
+	if name == "host" {
+		if req.Host != "" {
+			return req.Host
+		}
+		return req.URL.Host
+	}
+	values := []string{}
+	for _, value := range req.Header.Values(name) {
+		values = append(values, strings.TrimSpace(value))
+	}
+	return strings.Join(values, ",")
+}
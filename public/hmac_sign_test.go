@@ -0,0 +1,83 @@
+package public
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newSignedRequest(t *testing.T, secret string) *http.Request {
+	req := httptest.NewRequest("POST", "http://gateway.local/api/order?b=2&a=1&a=0", strings.NewReader(`{"id":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := HmacSignRequest(req, "app_id_a", secret, []string{"Host", "Content-Type"}); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func verifySignedRequest(req *http.Request, secret string) error {
+	auth, err := ParseHmacAuth(req.Header.Get("Authorization"))
+	if err != nil {
+		return err
+	}
+	return HmacVerify(req, auth, secret, HmacClockSkew, HmacMaxBodySize)
+}
+
+func TestHmacSignVerify(t *testing.T) {
+	req := newSignedRequest(t, "secret")
+	if err := verifySignedRequest(req, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	// 校验后body需要还原以便转发
+	body, _ := ioutil.ReadAll(req.Body)
+	if string(body) != `{"id":1}` {
+		t.Fatalf("body not restored: %s", body)
+	}
+	if err := verifySignedRequest(newSignedRequest(t, "secret"), "other"); err == nil {
+		t.Fatal("wrong secret should fail")
+	}
+	// body超过上限时不再继续读取
+	req = newSignedRequest(t, "secret")
+	auth, _ := ParseHmacAuth(req.Header.Get("Authorization"))
+	if err := HmacVerify(req, auth, "secret", HmacClockSkew, 4); err == nil {
+		t.Fatal("oversized body should fail")
+	}
+}
+
+func TestHmacTampered(t *testing.T) {
+	tampers := map[string]func(req *http.Request){
+		"path": func(req *http.Request) {
+			req.URL.Path = "/api/admin"
+		},
+		"query": func(req *http.Request) {
+			req.URL.RawQuery = "a=1&b=3"
+		},
+		"body": func(req *http.Request) {
+			req.Body = ioutil.NopCloser(strings.NewReader(`{"id":2}`))
+		},
+		"header": func(req *http.Request) {
+			req.Header.Set("Content-Type", "text/plain")
+		},
+		"host": func(req *http.Request) {
+			req.Host = "evil.local"
+		},
+		"method": func(req *http.Request) {
+			req.Method = "PUT"
+		},
+	}
+	for name, tamper := range tampers {
+		req := newSignedRequest(t, "secret")
+		tamper(req)
+		if err := verifySignedRequest(req, "secret"); err == nil {
+			t.Fatalf("tampered %s should fail", name)
+		}
+	}
+	// query参数顺序不影响签名
+	req := newSignedRequest(t, "secret")
+	req.URL.RawQuery = "a=0&b=2&a=1"
+	if err := verifySignedRequest(req, "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
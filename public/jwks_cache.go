@@ -0,0 +1,142 @@
+package public
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWKS缓存默认值
+const (
+	JwksCacheTTL          = 5 * time.Minute  // 缓存有效期，到期后重新拉取
+	JwksMinRefresh        = 10 * time.Second // 遇到未知kid时两次拉取的最小间隔
+	JwksFetchTimeout      = 5 * time.Second
+	JwksFileSchemePrefix  = "file://"
+	JwksHTTPSchemePrefix  = "http://"
+	JwksHTTPSSchemePrefix = "https://"
+)
+
+// 实现JWKS缓存单例模式
+
+// JwksCacheHandler 暴露出去的Handler
+var JwksCacheHandler *JwksCache
+
+// JwksCache 外部签发方JWKS公钥缓存，key为JWKS地址
+type JwksCache struct {
+	JwksMap map[string]*JwksCacheItem
+	Locker  sync.RWMutex
+	Client  *http.Client
+}
+
+// JwksCacheItem 单个JWKS地址的缓存
+type JwksCacheItem struct {
+	URI       string
+	Keys      map[string]crypto.PublicKey
+	FetchedAt time.Time
+	Locker    sync.Mutex
+}
+
+func NewJwksCache() *JwksCache {
+	return &JwksCache{
+		JwksMap: map[string]*JwksCacheItem{},
+		Locker:  sync.RWMutex{},
+		Client:  &http.Client{Timeout: JwksFetchTimeout},
+	}
+}
+
+func init() {
+	JwksCacheHandler = NewJwksCache()
+}
+
+// GetKey 通过JWKS地址和kid获取公钥，缓存过期或kid未知时重新拉取
+func (j *JwksCache) GetKey(uri, kid string) (crypto.PublicKey, error) {
+	item := j.getItem(uri)
+	item.Locker.Lock()
+	defer item.Locker.Unlock()
+
+	key, ok := item.Keys[kid]
+	expired := time.Since(item.FetchedAt) > JwksCacheTTL
+	// kid未知时也刷新，以便及时获取签发方轮换出的新密钥，但需要限制频率
+	unknown := !ok && time.Since(item.FetchedAt) > JwksMinRefresh
+	if expired || unknown {
+		keys, err := j.fetch(uri)
+		if err != nil {
+			// 拉取失败时继续使用旧缓存
+			if ok {
+				return key, nil
+			}
+			return nil, errors.WithMessage(err, "fetch jwks")
+		}
+		item.Keys = keys
+		item.FetchedAt = time.Now()
+		key, ok = item.Keys[kid]
+	}
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("kid %q not found in jwks %v", kid, uri))
+	}
+	return key, nil
+}
+
+func (j *JwksCache) getItem(uri string) *JwksCacheItem {
+	j.Locker.RLock()
+	item, ok := j.JwksMap[uri]
+	j.Locker.RUnlock()
+	if ok {
+		return item
+	}
+	// 对Map操作最好用锁
+	j.Locker.Lock()
+	defer j.Locker.Unlock()
+	if item, ok := j.JwksMap[uri]; ok {
+		return item
+	}
+	item = &JwksCacheItem{URI: uri, Keys: map[string]crypto.PublicKey{}}
+	j.JwksMap[uri] = item
+	return item
+}
+
+// fetch 从URL或者本地文件读取JWKS
+func (j *JwksCache) fetch(uri string) (map[string]crypto.PublicKey, error) {
+	var body []byte
+	if strings.HasPrefix(uri, JwksHTTPSchemePrefix) || strings.HasPrefix(uri, JwksHTTPSSchemePrefix) {
+		resp, err := j.Client.Get(uri)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New(fmt.Sprintf("jwks status code %v", resp.StatusCode))
+		}
+		if body, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if body, err = ioutil.ReadFile(strings.TrimPrefix(uri, JwksFileSchemePrefix)); err != nil {
+			return nil, err
+		}
+	}
+	keySet := &JSONWebKeySet{}
+	if err := json.Unmarshal(body, keySet); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range keySet.Keys {
+		// 只保留用于签名的公钥
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		publicKey, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = publicKey
+	}
+	return keys, nil
+}
@@ -1,26 +1,246 @@
 package public
 
 import (
-	"errors"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+	"time"
 )
 
-func JwtDecode(tokenString string) (*jwt.StandardClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(JwtSignKey), nil
+// 签名算法常量
+const (
+	JwtAlgorithmRS256 = "RS256"
+	JwtAlgorithmES256 = "ES256"
+)
+
+// JwtKeyItem 内存中的签名密钥信息
+type JwtKeyItem struct {
+	Kid        string
+	Algorithm  string
+	PrivateKey crypto.Signer    // 签名私钥，只有当前签名密钥需要
+	PublicKey  crypto.PublicKey // 验签公钥
+	RetireAt   int64            // 停止验签的时间戳，0表示仍在使用
+}
+
+// Usable 密钥当前是否仍可用于验签
+func (k *JwtKeyItem) Usable(now time.Time) bool {
+	return k.RetireAt == 0 || now.Unix() < k.RetireAt
+}
+
+// 实现JWT密钥环单例模式
+
+// JwtKeyRingHandler 暴露出去的Handler
+var JwtKeyRingHandler *JwtKeyRing
+
+// JwtKeyRing JWT密钥环，保存当前签名密钥以及过渡期内仍然可以验签的旧密钥
+type JwtKeyRing struct {
+	signKey    *JwtKeyItem
+	verifyMap  map[string]*JwtKeyItem
+	verifyList []*JwtKeyItem
+	Locker     sync.RWMutex
+}
+
+func NewJwtKeyRing() *JwtKeyRing {
+	return &JwtKeyRing{
+		verifyMap:  map[string]*JwtKeyItem{},
+		verifyList: []*JwtKeyItem{},
+		Locker:     sync.RWMutex{},
+	}
+}
+
+func init() {
+	JwtKeyRingHandler = NewJwtKeyRing()
+}
+
+// Reset 整体替换密钥环中的密钥
+func (r *JwtKeyRing) Reset(signKey *JwtKeyItem, verifyList []*JwtKeyItem) {
+	verifyMap := map[string]*JwtKeyItem{}
+	for _, item := range verifyList {
+		verifyMap[item.Kid] = item
+	}
+	r.Locker.Lock()
+	defer r.Locker.Unlock()
+	r.signKey = signKey
+	r.verifyMap = verifyMap
+	r.verifyList = verifyList
+}
+
+// GetSignKey 获取当前签名密钥
+func (r *JwtKeyRing) GetSignKey() (*JwtKeyItem, error) {
+	r.Locker.RLock()
+	defer r.Locker.RUnlock()
+	if r.signKey == nil || r.signKey.PrivateKey == nil {
+		return nil, errors.New("jwt sign key not loaded")
+	}
+	return r.signKey, nil
+}
+
+// GetVerifyKey 通过kid获取验签密钥
+func (r *JwtKeyRing) GetVerifyKey(kid string) (*JwtKeyItem, error) {
+	r.Locker.RLock()
+	defer r.Locker.RUnlock()
+	item, ok := r.verifyMap[kid]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown jwt kid %q", kid))
+	}
+	if !item.Usable(time.Now()) {
+		return nil, errors.New(fmt.Sprintf("jwt kid %q retired", kid))
+	}
+	return item, nil
+}
+
+// GetVerifyKeyList 获取所有仍然可以验签的密钥
+func (r *JwtKeyRing) GetVerifyKeyList() []*JwtKeyItem {
+	r.Locker.RLock()
+	defer r.Locker.RUnlock()
+	now := time.Now()
+	list := []*JwtKeyItem{}
+	for _, item := range r.verifyList {
+		if item.Usable(now) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// JwtClaims 网关签发的token中的claims，在标准claims基础上增加scope
+type JwtClaims struct {
+	jwt.StandardClaims
+	Scope string `json:"scope,omitempty"` // 以空格间隔的权限范围
+}
+
+// HasScope token是否拥有指定权限范围
+func (c *JwtClaims) HasScope(scope string) bool {
+	return InStringSlice(ScopeList(c.Scope), scope)
+}
+
+// ScopeList 将以空格或者逗号间隔的权限范围拆分为列表
+func ScopeList(scope string) []string {
+	return strings.FieldsFunc(scope, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+// JwtDecode 通过token头中的kid找到对应公钥并验签
+func JwtDecode(tokenString string) (*JwtClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		keyItem, err := JwtKeyRingHandler.GetVerifyKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		// 防止算法替换攻击，算法必须与密钥登记的算法一致
+		if token.Method.Alg() != keyItem.Algorithm {
+			return nil, errors.New(fmt.Sprintf("unexpected jwt alg %v", token.Method.Alg()))
+		}
+		return keyItem.PublicKey, nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*jwt.StandardClaims); ok {
+	if claims, ok := token.Claims.(*JwtClaims); ok {
 		return claims, nil
 	} else {
-		return nil, errors.New("token is not jwt.StandardClaims")
+		return nil, errors.New("token is not JwtClaims")
+	}
+}
+
+// JwtEncode 使用当前签名密钥生成token，并在头部写入kid
+func JwtEncode(claims jwt.Claims) (string, error) {
+	keyItem, err := JwtKeyRingHandler.GetSignKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(keyItem.Algorithm), claims)
+	token.Header["kid"] = keyItem.Kid
+	return token.SignedString(keyItem.PrivateKey)
+}
+
+// GenJwtKeyPair 按照算法生成PEM格式的密钥对
+func GenJwtKeyPair(algorithm string) (privatePEM string, publicPEM string, err error) {
+	var privateBlock *pem.Block
+	var publicKey crypto.PublicKey
+	switch algorithm {
+	case JwtAlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", "", err
+		}
+		privateBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		publicKey = &key.PublicKey
+	case JwtAlgorithmES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return "", "", err
+		}
+		privateBlock = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+		publicKey = &key.PublicKey
+	default:
+		return "", "", errors.New(fmt.Sprintf("unsupported jwt algorithm %v", algorithm))
 	}
+	publicDer, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", "", err
+	}
+	publicBlock := &pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}
+	return string(pem.EncodeToMemory(privateBlock)), string(pem.EncodeToMemory(publicBlock)), nil
+}
+
+// ParseJwtKeyPair 将PEM格式的密钥对解析为JwtKeyItem，privatePEM为空时只解析公钥
+func ParseJwtKeyPair(kid, algorithm, privatePEM, publicPEM string) (*JwtKeyItem, error) {
+	item := &JwtKeyItem{Kid: kid, Algorithm: algorithm}
+	switch algorithm {
+	case JwtAlgorithmRS256:
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicPEM))
+		if err != nil {
+			return nil, err
+		}
+		item.PublicKey = publicKey
+		if privatePEM != "" {
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privatePEM))
+			if err != nil {
+				return nil, err
+			}
+			item.PrivateKey = privateKey
+		}
+	case JwtAlgorithmES256:
+		publicKey, err := jwt.ParseECPublicKeyFromPEM([]byte(publicPEM))
+		if err != nil {
+			return nil, err
+		}
+		item.PublicKey = publicKey
+		if privatePEM != "" {
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(privatePEM))
+			if err != nil {
+				return nil, err
+			}
+			item.PrivateKey = privateKey
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported jwt algorithm %v", algorithm))
+	}
+	return item, nil
 }
 
-func JwtEncode(claims jwt.StandardClaims) (string, error) {
-	mySigningKey := []byte(JwtSignKey)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(mySigningKey)
+// JwtUnverifiedIssuer 不验签读取token中的iss，仅用于选择验签方式
+func JwtUnverifiedIssuer(tokenString string) string {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenString, claims); err != nil {
+		return ""
+	}
+	iss, _ := claims["iss"].(string)
+	return iss
 }
@@ -0,0 +1,202 @@
+package public
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/garyburd/redigo/redis"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// RefreshTokenItem 刷新token在redis中保存的信息
+type RefreshTokenItem struct {
+	AppID    string `json:"app_id"`
+	Scope    string `json:"scope"`
+	IssuedAt int64  `json:"issued_at"`
+}
+
+// GenJwtID 生成随机的jti
+func GenJwtID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// JwtDeny 将jti加入吊销列表，保留到token过期为止
+func JwtDeny(jti string, expiresAt int64) error {
+	if jti == "" {
+		return errors.New("token missing jti")
+	}
+	ttl := expiresAt - time.Now().Unix()
+	if ttl <= 0 {
+		// token已经过期，无需加入吊销列表
+		return nil
+	}
+	_, err := RedisConfDo("SET", RedisJwtDenyPrefix+jti, 1, "EX", ttl)
+	return err
+}
+
+// JwtRevokeApp 吊销租户在此之前签发的全部token，ttl为租户token的最长有效期
+func JwtRevokeApp(appID string, ttl int64) error {
+	_, err := RedisConfDo("SET", RedisJwtAppRevokePrefix+appID, time.Now().Unix(), "EX", ttl)
+	return err
+}
+
+// JwtAppRevokedAt 获取租户token的吊销时间，未吊销返回0
+func JwtAppRevokedAt(appID string) (int64, error) {
+	revokedAt, err := redis.Int64(RedisConfDo("GET", RedisJwtAppRevokePrefix+appID))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return revokedAt, err
+}
+
+// JwtIsRevoked 判断token是否已被吊销，jti在吊销列表中或者签发时间早于租户吊销时间
+func JwtIsRevoked(claims *JwtClaims) (bool, error) {
+	keys := []interface{}{RedisJwtAppRevokePrefix + claims.Issuer}
+	if claims.Id != "" {
+		keys = append(keys, RedisJwtDenyPrefix+claims.Id)
+	}
+	values, err := redis.Values(RedisConfDo("MGET", keys...))
+	if err != nil {
+		return false, err
+	}
+	if len(values) > 1 && values[1] != nil {
+		return true, nil
+	}
+	if values[0] != nil {
+		revokedAt, err := redis.Int64(values[0], nil)
+		if err != nil {
+			return false, err
+		}
+		if claims.IssuedAt <= revokedAt {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RefreshTokenSave 生成刷新token并保存到redis，redis中只保存token的哈希值
+func RefreshTokenSave(item *RefreshTokenItem, ttl int64) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	value, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	// 同时记录到租户的刷新token列表中，列表保留到最后签发的token过期为止
+	key := refreshTokenKey(token)
+	c.Send("MULTI")
+	c.Send("SET", key, value, "EX", ttl)
+	c.Send("SADD", RedisJwtRefreshAppPrefix+item.AppID, key)
+	c.Send("EXPIRE", RedisJwtRefreshAppPrefix+item.AppID, ttl)
+	if _, err := c.Do("EXEC"); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RefreshTokenRevokeApp 删除租户签发的全部刷新token
+func RefreshTokenRevokeApp(appID string) error {
+	keys, err := redis.Strings(RedisConfDo("SMEMBERS", RedisJwtRefreshAppPrefix+appID))
+	if err != nil {
+		return err
+	}
+	args := []interface{}{RedisJwtRefreshAppPrefix + appID}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	_, err = RedisConfDo("DEL", args...)
+	return err
+}
+
+// RefreshTokenGet 读取刷新token的信息，不存在返回nil
+func RefreshTokenGet(token string) (*RefreshTokenItem, error) {
+	value, err := redis.Bytes(RedisConfDo("GET", refreshTokenKey(token)))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	item := &RefreshTokenItem{}
+	if err := json.Unmarshal(value, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// RefreshTokenTake 校验刷新token属于appID后删除，保证每个刷新token只能被所属租户使用一次
+// token不存在、不属于该租户或者被并发使用时返回nil，不属于该租户时token不会被删除
+func RefreshTokenTake(token, appID string) (*RefreshTokenItem, error) {
+	c, err := lib.RedisConnFactory("default")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return refreshTokenTake(c, token, appID)
+}
+
+// refreshTokenTake 通过WATCH保证校验租户和删除之间token没有被其他请求使用
+func refreshTokenTake(c redis.Conn, token, appID string) (*RefreshTokenItem, error) {
+	key := refreshTokenKey(token)
+	if _, err := c.Do("WATCH", key); err != nil {
+		return nil, err
+	}
+	value, err := redis.Bytes(c.Do("GET", key))
+	if err != nil {
+		c.Do("UNWATCH")
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	item := &RefreshTokenItem{}
+	if err := json.Unmarshal(value, item); err != nil {
+		c.Do("UNWATCH")
+		return nil, err
+	}
+	if item.AppID != appID {
+		c.Do("UNWATCH")
+		return nil, nil
+	}
+	c.Send("MULTI")
+	c.Send("DEL", key)
+	values, err := redis.Values(c.Do("EXEC"))
+	if err == redis.ErrNil {
+		// WATCH的key被修改，token已经被其他请求使用
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deleted, err := redis.Int(values[0], nil); err != nil || deleted == 0 {
+		return nil, err
+	}
+	return item, nil
+}
+
+// RefreshTokenRevoke 删除刷新token
+func RefreshTokenRevoke(token string) error {
+	_, err := RedisConfDo("DEL", refreshTokenKey(token))
+	return err
+}
+
+func refreshTokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return RedisJwtRefreshPrefix + hex.EncodeToString(sum[:])
+}
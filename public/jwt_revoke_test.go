@@ -0,0 +1,93 @@
+package public
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// fakeRedisConn 只实现刷新token用到的命令，WATCH的key在MULTI前被修改时EXEC返回nil
+type fakeRedisConn struct {
+	data       map[string][]byte
+	dirty      bool
+	watched    bool
+	queued     [][]interface{}
+	touchOnGet bool //GET之后模拟其他连接修改了key
+}
+
+func (c *fakeRedisConn) Close() error { return nil }
+func (c *fakeRedisConn) Err() error   { return nil }
+func (c *fakeRedisConn) Flush() error { return nil }
+
+func (c *fakeRedisConn) Receive() (interface{}, error) { return nil, nil }
+
+func (c *fakeRedisConn) Send(commandName string, args ...interface{}) error {
+	if commandName != "MULTI" {
+		c.queued = append(c.queued, append([]interface{}{commandName}, args...))
+	}
+	return nil
+}
+
+func (c *fakeRedisConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	switch commandName {
+	case "WATCH":
+		c.watched, c.dirty = true, false
+	case "UNWATCH":
+		c.watched = false
+	case "GET":
+		c.dirty = c.touchOnGet
+		if value, ok := c.data[args[0].(string)]; ok {
+			return value, nil
+		}
+	case "EXEC":
+		queued := c.queued
+		c.queued = nil
+		if c.watched && c.dirty {
+			return nil, nil
+		}
+		replies := []interface{}{}
+		for _, cmd := range queued {
+			key := cmd[1].(string)
+			_, ok := c.data[key]
+			delete(c.data, key)
+			if ok {
+				replies = append(replies, int64(1))
+			} else {
+				replies = append(replies, int64(0))
+			}
+		}
+		return replies, nil
+	}
+	return nil, nil
+}
+
+func TestRefreshTokenTakeOwner(t *testing.T) {
+	value, _ := json.Marshal(&RefreshTokenItem{AppID: "app_id_a", Scope: "read"})
+	conn := &fakeRedisConn{data: map[string][]byte{refreshTokenKey("token_a"): value}}
+
+	// 其他租户使用时不删除token
+	item, err := refreshTokenTake(conn, "token_a", "app_id_b")
+	if err != nil || item != nil {
+		t.Fatalf("foreign app take item:%v err:%v", item, err)
+	}
+	if _, ok := conn.data[refreshTokenKey("token_a")]; !ok {
+		t.Fatal("token consumed by foreign app")
+	}
+
+	item, err = refreshTokenTake(conn, "token_a", "app_id_a")
+	if err != nil || item == nil || item.Scope != "read" {
+		t.Fatalf("owner take item:%v err:%v", item, err)
+	}
+	// 只能使用一次
+	if item, err = refreshTokenTake(conn, "token_a", "app_id_a"); err != nil || item != nil {
+		t.Fatalf("second take item:%v err:%v", item, err)
+	}
+}
+
+func TestRefreshTokenTakeConcurrent(t *testing.T) {
+	value, _ := json.Marshal(&RefreshTokenItem{AppID: "app_id_a"})
+	conn := &fakeRedisConn{data: map[string][]byte{refreshTokenKey("token_a"): value}, touchOnGet: true}
+	item, err := refreshTokenTake(conn, "token_a", "app_id_a")
+	if err != nil || item != nil {
+		t.Fatalf("concurrent take item:%v err:%v", item, err)
+	}
+}
@@ -0,0 +1,108 @@
+package public
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"testing"
+	"time"
+)
+
+func newTestKeyItem(t *testing.T, kid, algorithm string) *JwtKeyItem {
+	privatePEM, publicPEM, err := GenJwtKeyPair(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := ParseJwtKeyPair(kid, algorithm, privatePEM, publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestJwtRotateOverlap(t *testing.T) {
+	oldKey := newTestKeyItem(t, "old", JwtAlgorithmRS256)
+	JwtKeyRingHandler.Reset(oldKey, []*JwtKeyItem{oldKey})
+	claims := jwt.StandardClaims{Issuer: "app_id_a", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	oldToken, err := JwtEncode(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后旧token在过渡期内仍然可以验签
+	newKey := newTestKeyItem(t, "new", JwtAlgorithmES256)
+	oldVerify := &JwtKeyItem{Kid: oldKey.Kid, Algorithm: oldKey.Algorithm, PublicKey: oldKey.PublicKey, RetireAt: time.Now().Add(time.Hour).Unix()}
+	JwtKeyRingHandler.Reset(newKey, []*JwtKeyItem{newKey, oldVerify})
+	if decoded, err := JwtDecode(oldToken); err != nil || decoded.Issuer != "app_id_a" {
+		t.Fatalf("old token should verify during overlap: %v", err)
+	}
+	newToken, err := JwtEncode(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JwtDecode(newToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// 过渡期结束后旧token失效
+	oldVerify.RetireAt = time.Now().Add(-time.Second).Unix()
+	if _, err := JwtDecode(oldToken); err == nil {
+		t.Fatal("old token should be rejected after overlap")
+	}
+	if len(JwtKeyRingHandler.GetVerifyKeyList()) != 1 {
+		t.Fatal("retired key should not be published")
+	}
+}
+
+func TestJwtRejectHS256(t *testing.T) {
+	key := newTestKeyItem(t, "k1", JwtAlgorithmRS256)
+	JwtKeyRingHandler.Reset(key, []*JwtKeyItem{key})
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Issuer: "app_id_a"})
+	token.Header["kid"] = "k1"
+	forged, err := token.SignedString([]byte("my_sign_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JwtDecode(forged); err == nil {
+		t.Fatal("HS256 token should be rejected")
+	}
+}
+
+func TestJSONWebKeyRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{JwtAlgorithmRS256, JwtAlgorithmES256} {
+		key := newTestKeyItem(t, "k_"+algorithm, algorithm)
+		jwk, err := NewJSONWebKey(key.Kid, key.Algorithm, key.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(algorithm), jwt.StandardClaims{Issuer: "app_id_a"})
+		signed, err := token.SignedString(key.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) { return publicKey, nil }); err != nil {
+			t.Fatalf("%v: %v", algorithm, err)
+		}
+	}
+}
+
+func TestJwtScopeClaims(t *testing.T) {
+	key := newTestKeyItem(t, "k1", JwtAlgorithmES256)
+	JwtKeyRingHandler.Reset(key, []*JwtKeyItem{key})
+	token, err := JwtEncode(JwtClaims{
+		StandardClaims: jwt.StandardClaims{Issuer: "app_id_a", ExpiresAt: time.Now().Add(time.Hour).Unix()},
+		Scope:          "read write",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := JwtDecode(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != "app_id_a" || !claims.HasScope("write") || claims.HasScope("admin") {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
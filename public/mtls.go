@@ -0,0 +1,124 @@
+package public
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+// certPoolCache 按照CA所属配置的id缓存解析后的证书池，避免每个请求都重新解析
+// 每个id只保留一个证书池，CA内容变化时替换旧的证书池
+var certPoolCache = struct {
+	sync.Mutex
+	m map[string]*cachedCertPool
+}{m: map[string]*cachedCertPool{}}
+
+type cachedCertPool struct {
+	sum  [sha256.Size]byte
+	pool *x509.CertPool
+}
+
+// CertPoolFromPEM 解析PEM格式的CA证书包
+func CertPoolFromPEM(pemData string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(pemData)) {
+		return nil, errors.New("no valid certificate in client ca")
+	}
+	return pool, nil
+}
+
+// CachedCertPool 获取id对应的CA证书池，缓存的CA内容与pemData不一致时重新解析并替换
+func CachedCertPool(id, pemData string) (*x509.CertPool, error) {
+	sum := sha256.Sum256([]byte(pemData))
+	certPoolCache.Lock()
+	item, ok := certPoolCache.m[id]
+	certPoolCache.Unlock()
+	if ok && item.sum == sum {
+		return item.pool, nil
+	}
+	pool, err := CertPoolFromPEM(pemData)
+	if err != nil {
+		return nil, err
+	}
+	certPoolCache.Lock()
+	certPoolCache.m[id] = &cachedCertPool{sum: sum, pool: pool}
+	certPoolCache.Unlock()
+	return pool, nil
+}
+
+// VerifyClientCert 使用CA证书池校验客户端证书链，返回客户端证书
+func VerifyClientCert(certs []*x509.Certificate, roots *x509.CertPool) (*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("client certificate not found")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "verify client certificate")
+	}
+	return certs[0], nil
+}
+
+// CertSans 获取证书的SAN列表，包括DNS、邮箱、URI和IP
+func CertSans(cert *x509.Certificate) []string {
+	sans := []string{}
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+// CertIdentities 获取证书可用于识别身份的值，subject的CN以及全部SAN
+func CertIdentities(cert *x509.Certificate) []string {
+	identities := []string{}
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	return append(identities, CertSans(cert)...)
+}
+
+// CertIdentityMatch 判断证书身份是否命中以逗号分隔的身份列表
+func CertIdentityMatch(identities []string, list string) bool {
+	for _, item := range SplitList(list) {
+		if InStringSlice(identities, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerCertFile 网关服务端证书路径
+func ServerCertFile() string {
+	if certFile := lib.GetStringConf("proxy.https.cert_file"); certFile != "" {
+		return certFile
+	}
+	return "./cert_file/server.crt"
+}
+
+// ServerKeyFile 网关服务端私钥路径
+func ServerKeyFile() string {
+	if keyFile := lib.GetStringConf("proxy.https.key_file"); keyFile != "" {
+		return keyFile
+	}
+	return "./cert_file/server.key"
+}
+
+// LoadServerCert 加载网关服务端证书
+func LoadServerCert() (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(ServerCertFile(), ServerKeyFile())
+}
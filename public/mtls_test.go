@@ -0,0 +1,81 @@
+package public
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/zhj/go_gateway/public/test_cert"
+	"net/url"
+	"testing"
+)
+
+// newTestClientCert 由ca签发带有CN、DNS和URI身份的客户端证书
+func newTestClientCert(t *testing.T, ca *test_cert.Cert) *x509.Certificate {
+	uri, _ := url.Parse("spiffe://gateway/app_id_a")
+	return test_cert.New(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client_a"},
+		DNSNames:    []string{"client-a.local"},
+		URIs:        []*url.URL{uri},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}).Cert
+}
+
+func TestVerifyClientCert(t *testing.T) {
+	ca := test_cert.NewCA(t)
+	clientCert := newTestClientCert(t, ca)
+	pool, err := CertPoolFromPEM(ca.CertPem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyClientCert([]*x509.Certificate{clientCert}, pool); err != nil {
+		t.Fatal(err)
+	}
+	// 其他CA签发的证书校验失败
+	otherPool, err := CertPoolFromPEM(test_cert.NewCA(t).CertPem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyClientCert([]*x509.Certificate{clientCert}, otherPool); err == nil {
+		t.Fatal("cert signed by other ca should fail")
+	}
+	if _, err := CertPoolFromPEM("not a pem"); err == nil {
+		t.Fatal("invalid pem should fail")
+	}
+}
+
+func TestCachedCertPool(t *testing.T) {
+	caPEM := test_cert.NewCA(t).CertPem()
+	otherPEM := test_cert.NewCA(t).CertPem()
+	pool, err := CachedCertPool("access_control_1", caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := CachedCertPool("access_control_1", caPEM); cached != pool {
+		t.Fatal("pool should be cached by id")
+	}
+	// CA变化后替换旧的证书池，同一个id只保留一个
+	replaced, err := CachedCertPool("access_control_1", otherPEM)
+	if err != nil || replaced == pool {
+		t.Fatalf("pool should be replaced err:%v", err)
+	}
+	certPoolCache.Lock()
+	size := len(certPoolCache.m)
+	certPoolCache.Unlock()
+	if size != 1 {
+		t.Fatalf("unexpected cache size %d", size)
+	}
+}
+
+func TestCertIdentities(t *testing.T) {
+	identities := CertIdentities(newTestClientCert(t, test_cert.NewCA(t)))
+	for _, identity := range []string{"client_a", "client-a.local", "spiffe://gateway/app_id_a"} {
+		if !InStringSlice(identities, identity) {
+			t.Fatalf("identity %s not found in %v", identity, identities)
+		}
+	}
+	if !CertIdentityMatch(identities, "other, spiffe://gateway/app_id_a") {
+		t.Fatal("identity should match")
+	}
+	if CertIdentityMatch(identities, "other,") {
+		t.Fatal("identity should not match")
+	}
+}
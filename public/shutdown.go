@@ -0,0 +1,140 @@
+package public
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	serverStarting int32 = iota
+	serverReady
+	serverDraining
+)
+
+// serverState 代理服务器的状态，只能从启动中变为就绪，关闭时置为排空且不再恢复
+var serverState int32
+
+// MarkServerReady 启动中的代理服务器置为就绪，已经开始关闭时返回false
+func MarkServerReady() bool {
+	return atomic.CompareAndSwapInt32(&serverState, serverStarting, serverReady)
+}
+
+// MarkServerDraining 开始关闭，之后代理服务器不会再变为就绪
+func MarkServerDraining() {
+	atomic.StoreInt32(&serverState, serverDraining)
+}
+
+// ServerReady 代理服务器是否就绪
+func ServerReady() bool {
+	return atomic.LoadInt32(&serverState) == serverReady
+}
+
+// DefaultDrainTimeout 未配置时等待请求和连接结束的最长时间
+const DefaultDrainTimeout = 30 * time.Second
+
+// ShutdownFunc 停止接受新连接并等待处理中的请求和连接结束，ctx到期后强制关闭，返回被中断的数量
+type ShutdownFunc func(ctx context.Context) int
+
+// ShutdownCoordinator 统一关闭各个代理服务器，所有服务器同时排空，共用一个截止时间
+type ShutdownCoordinator struct {
+	DrainTimeout time.Duration
+	names        []string
+	funcs        []ShutdownFunc
+}
+
+// NewShutdownCoordinator drainTimeout不大于0时使用DefaultDrainTimeout
+func NewShutdownCoordinator(drainTimeout time.Duration) *ShutdownCoordinator {
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultDrainTimeout
+	}
+	return &ShutdownCoordinator{DrainTimeout: drainTimeout}
+}
+
+// Register 注册需要关闭的服务器
+func (s *ShutdownCoordinator) Register(name string, fn ShutdownFunc) {
+	s.names = append(s.names, name)
+	s.funcs = append(s.funcs, fn)
+}
+
+// Shutdown 将服务置为未就绪后关闭所有服务器，返回各服务器被中断的请求和连接数
+func (s *ShutdownCoordinator) Shutdown() map[string]int {
+	MarkServerDraining()
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), s.DrainTimeout)
+	defer cancel()
+	result := map[string]int{}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := range s.funcs {
+		name, fn := s.names[i], s.funcs[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cut := fn(ctx)
+			mu.Lock()
+			result[name] = cut
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	total := 0
+	for _, name := range s.names {
+		if cut := result[name]; cut > 0 {
+			log.Printf(" [WARN] shutdown %s drain timeout %v exceeded, %d cut\n", name, s.DrainTimeout, cut)
+			total += cut
+		}
+	}
+	log.Printf(" [INFO] shutdown finished in %v, %d cut\n", time.Since(start), total)
+	return result
+}
+
+// HttpConnTracker 通过http.Server.ConnState记录连接状态，关闭时统计被中断的请求
+type HttpConnTracker struct {
+	mu     sync.Mutex
+	states map[net.Conn]http.ConnState
+}
+
+// NewHttpConnTracker 暴露出去的New方法
+func NewHttpConnTracker() *HttpConnTracker {
+	return &HttpConnTracker{states: map[net.Conn]http.ConnState{}}
+}
+
+// ConnState 设置为http.Server.ConnState
+func (t *HttpConnTracker) ConnState(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		delete(t.states, conn)
+	default:
+		t.states[conn] = state
+	}
+}
+
+// Active 正在处理请求的连接数
+func (t *HttpConnTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := 0
+	for _, state := range t.states {
+		if state == http.StateActive {
+			active++
+		}
+	}
+	return active
+}
+
+// ShutdownHttpServer 优雅关闭http.Server，ctx到期后关闭剩余连接，返回被中断的请求数
+func ShutdownHttpServer(ctx context.Context, srv *http.Server, tracker *HttpConnTracker) int {
+	if err := srv.Shutdown(ctx); err == nil {
+		return 0
+	}
+	cut := tracker.Active()
+	srv.Close()
+	return cut
+}
@@ -0,0 +1,88 @@
+package public
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownCoordinator(t *testing.T) {
+	atomic.StoreInt32(&serverState, serverStarting)
+	if !MarkServerReady() || !ServerReady() {
+		t.Fatal("starting server should become ready")
+	}
+	coordinator := NewShutdownCoordinator(200 * time.Millisecond)
+	coordinator.Register("drained", func(ctx context.Context) int {
+		if ServerReady() {
+			t.Error("server still ready during shutdown")
+		}
+		return 0
+	})
+	coordinator.Register("timeout", func(ctx context.Context) int {
+		<-ctx.Done()
+		return 3
+	})
+	start := time.Now()
+	result := coordinator.Shutdown()
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("shutdown took %v, want drain timeout", d)
+	}
+	if result["drained"] != 0 || result["timeout"] != 3 {
+		t.Fatalf("result = %v", result)
+	}
+}
+
+func TestMarkServerReadyAfterShutdown(t *testing.T) {
+	// 启动检查还没完成时收到退出信号，之后不能再置为就绪
+	atomic.StoreInt32(&serverState, serverStarting)
+	NewShutdownCoordinator(time.Second).Shutdown()
+	if MarkServerReady() || ServerReady() {
+		t.Fatal("server became ready after shutdown started")
+	}
+}
+
+func TestShutdownHttpServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	tracker := NewHttpConnTracker()
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-release
+			}
+			w.Write([]byte("ok"))
+		}),
+		ConnState: tracker.ConnState,
+	}
+	go srv.Serve(l)
+	defer close(release)
+
+	// 空闲的keep-alive连接不计入被中断的请求
+	resp, err := http.Get("http://" + l.Addr().String() + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	go http.Get("http://" + l.Addr().String() + "/slow")
+	deadline := time.Now().Add(2 * time.Second)
+	for tracker.Active() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("active = %d, want 1", tracker.Active())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if cut := ShutdownHttpServer(ctx, srv, tracker); cut != 1 {
+		t.Fatalf("cut = %d, want 1", cut)
+	}
+}
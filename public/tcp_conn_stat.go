@@ -0,0 +1,327 @@
+package public
+
+import (
+	"fmt"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/garyburd/redigo/redis"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TcpConnStatHandler 暴露出去的Handler
+var TcpConnStatHandler *TcpConnStatManager
+
+// tcpConnInstanceID 上报并发连接数时区分网关实例
+var tcpConnInstanceID string
+
+// tcpConnActiveStale 超过该时间没有上报的实例不再计入并发连接数，单位s
+const tcpConnActiveStale = 10
+
+func init() {
+	TcpConnStatHandler = NewTcpConnStatManager()
+	hostname, _ := os.Hostname()
+	tcpConnInstanceID = fmt.Sprintf("%s_%d", hostname, os.Getpid())
+}
+
+// TcpConnStatManager TCP连接统计管理，每个服务和每个服务的下游各一个统计项
+type TcpConnStatManager struct {
+	StatMap map[string]*TcpConnStat
+	Locker  sync.Mutex
+}
+
+// NewTcpConnStatManager 暴露出去的New方法
+func NewTcpConnStatManager() *TcpConnStatManager {
+	return &TcpConnStatManager{
+		StatMap: map[string]*TcpConnStat{},
+		Locker:  sync.Mutex{},
+	}
+}
+
+// GetStat 获取统计项，不存在则新建
+func (m *TcpConnStatManager) GetStat(name string) *TcpConnStat {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if stat, ok := m.StatMap[name]; ok {
+		return stat
+	}
+	stat := NewTcpConnStat(name, 1*time.Second)
+	m.StatMap[name] = stat
+	return stat
+}
+
+// TcpConnStatName 下游统计项的名称，服务整体的统计项使用服务名
+func TcpConnStatName(serviceName, backend string) string {
+	return serviceName + "_" + backend
+}
+
+// TcpConnStatData 一段时间内的TCP连接统计
+type TcpConnStatData struct {
+	Conns         int64 `json:"conns"`           //关闭的连接数
+	BytesIn       int64 `json:"bytes_in"`        //客户端发往下游的字节数
+	BytesOut      int64 `json:"bytes_out"`       //下游返回客户端的字节数
+	DurationMs    int64 `json:"duration_ms"`     //连接总时长，包括仍在进行中的连接在这段时间内的时长
+	AvgDurationMs int64 `json:"avg_duration_ms"` //平均连接时长
+	Dials         int64 `json:"dials"`           //拨号次数，包括失败的拨号
+	DialErrors    int64 `json:"dial_errors"`     //拨号失败次数
+	DialMs        int64 `json:"dial_ms"`         //拨号总耗时
+	AvgDialMs     int64 `json:"avg_dial_ms"`     //平均拨号耗时
+}
+
+// TcpConnStat TCP连接统计，内存中累计后定时写入redis按小时和天汇总
+type TcpConnStat struct {
+	Name     string
+	Interval time.Duration
+
+	active     int64
+	conns      int64
+	bytesIn    int64
+	bytesOut   int64
+	dials      int64
+	dialErrors int64
+	dialMs     int64
+
+	// durationAcc 加上当前连接数乘以当前时间即为上次写入后的连接总时长，单位ms
+	// 连接建立时减去建立时间，关闭时加上关闭时间，仍在进行中的连接按周期计入时长
+	durationLock sync.Mutex
+	durationAcc  int64
+}
+
+// NewTcpConnStat 新建统计项并启动定时写入
+func NewTcpConnStat(name string, interval time.Duration) *TcpConnStat {
+	stat := &TcpConnStat{
+		Name:     name,
+		Interval: interval,
+	}
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+		ticker := time.NewTicker(interval)
+		lastActive := int64(0)
+		for {
+			<-ticker.C
+			now := time.Now()
+			fields := map[string]int64{
+				"conns":       atomic.SwapInt64(&stat.conns, 0),
+				"bytes_in":    atomic.SwapInt64(&stat.bytesIn, 0),
+				"bytes_out":   atomic.SwapInt64(&stat.bytesOut, 0),
+				"duration_ms": stat.takeDuration(now),
+				"dials":       atomic.SwapInt64(&stat.dials, 0),
+				"dial_errors": atomic.SwapInt64(&stat.dialErrors, 0),
+				"dial_ms":     atomic.SwapInt64(&stat.dialMs, 0),
+			}
+			hasData := false
+			for _, value := range fields {
+				hasData = hasData || value != 0
+			}
+			active := atomic.LoadInt64(&stat.active)
+			// 有连接时持续刷新上报时间，连接数归零时上报一次
+			reportActive := active > 0 || active != lastActive
+			if !hasData && !reportActive {
+				continue
+			}
+			hourKey := stat.GetHourKey(now)
+			dayKey := stat.GetDayKey(now)
+			activeKey := stat.GetActiveKey()
+			if err := RedisConfPipeline(func(c redis.Conn) {
+				if hasData {
+					for field, value := range fields {
+						if value != 0 {
+							c.Send("HINCRBY", hourKey, field, value)
+							c.Send("HINCRBY", dayKey, field, value)
+						}
+					}
+					c.Send("EXPIRE", hourKey, 86400*2)
+					c.Send("EXPIRE", dayKey, 86400*2)
+				}
+				if reportActive {
+					c.Send("HSET", activeKey, tcpConnInstanceID, fmt.Sprintf("%d %d", active, now.Unix()))
+					c.Send("EXPIRE", activeKey, tcpConnActiveStale*6)
+				}
+			}); err != nil {
+				fmt.Println("RedisConfPipeline err", err)
+				continue
+			}
+			lastActive = active
+		}
+	}()
+	return stat
+}
+
+// ConnOpen 与下游建立连接后调用
+func (s *TcpConnStat) ConnOpen() {
+	s.connOpenAt(time.Now())
+}
+
+// ConnClose 连接关闭后调用，流量在转发过程中通过AddBytesIn和AddBytesOut记录
+func (s *TcpConnStat) ConnClose() {
+	s.connCloseAt(time.Now())
+}
+
+func (s *TcpConnStat) connOpenAt(now time.Time) {
+	s.durationLock.Lock()
+	defer s.durationLock.Unlock()
+	atomic.AddInt64(&s.active, 1)
+	s.durationAcc -= now.UnixNano() / int64(time.Millisecond)
+}
+
+func (s *TcpConnStat) connCloseAt(now time.Time) {
+	s.durationLock.Lock()
+	defer s.durationLock.Unlock()
+	atomic.AddInt64(&s.active, -1)
+	atomic.AddInt64(&s.conns, 1)
+	s.durationAcc += now.UnixNano() / int64(time.Millisecond)
+}
+
+// takeDuration 取出上次调用后的连接总时长，包括仍在进行中的连接
+func (s *TcpConnStat) takeDuration(now time.Time) int64 {
+	s.durationLock.Lock()
+	defer s.durationLock.Unlock()
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	active := atomic.LoadInt64(&s.active)
+	duration := s.durationAcc + active*nowMs
+	s.durationAcc = -active * nowMs
+	return duration
+}
+
+// AddBytesIn 记录客户端发往下游的字节数，转发过程中实时调用
+func (s *TcpConnStat) AddBytesIn(n int64) {
+	atomic.AddInt64(&s.bytesIn, n)
+}
+
+// AddBytesOut 记录下游返回客户端的字节数，转发过程中实时调用
+func (s *TcpConnStat) AddBytesOut(n int64) {
+	atomic.AddInt64(&s.bytesOut, n)
+}
+
+// ObserveDial 记录一次拨号的耗时和结果
+func (s *TcpConnStat) ObserveDial(d time.Duration, err error) {
+	atomic.AddInt64(&s.dials, 1)
+	atomic.AddInt64(&s.dialMs, d.Milliseconds())
+	if err != nil {
+		atomic.AddInt64(&s.dialErrors, 1)
+	}
+}
+
+// Active 当前实例的并发连接数
+func (s *TcpConnStat) Active() int64 {
+	return atomic.LoadInt64(&s.active)
+}
+
+// TcpConnStatGroup 一个连接同时计入的多个统计项，通常为服务和下游各一个，为空时不统计
+type TcpConnStatGroup []*TcpConnStat
+
+func (g TcpConnStatGroup) ObserveDial(d time.Duration, err error) {
+	for _, stat := range g {
+		stat.ObserveDial(d, err)
+	}
+}
+
+func (g TcpConnStatGroup) ConnOpen() {
+	for _, stat := range g {
+		stat.ConnOpen()
+	}
+}
+
+func (g TcpConnStatGroup) ConnClose() {
+	for _, stat := range g {
+		stat.ConnClose()
+	}
+}
+
+func (g TcpConnStatGroup) AddBytesIn(n int64) {
+	for _, stat := range g {
+		stat.AddBytesIn(n)
+	}
+}
+
+func (g TcpConnStatGroup) AddBytesOut(n int64) {
+	for _, stat := range g {
+		stat.AddBytesOut(n)
+	}
+}
+
+// GetHourKey 获取以小时为单位的key
+func (s *TcpConnStat) GetHourKey(t time.Time) string {
+	hourStr := t.In(lib.TimeLocation).Format("2006010215")
+	return fmt.Sprintf("%s_%s_%s", RedisTcpConnStatKey, hourStr, s.Name)
+}
+
+// GetDayKey 获取以天为单位的key
+func (s *TcpConnStat) GetDayKey(t time.Time) string {
+	dayStr := t.In(lib.TimeLocation).Format("20060102")
+	return fmt.Sprintf("%s_%s_%s", RedisTcpConnStatKey, dayStr, s.Name)
+}
+
+// GetActiveKey 各网关实例上报并发连接数的key
+func (s *TcpConnStat) GetActiveKey() string {
+	return fmt.Sprintf("%s_%s", RedisTcpConnActiveKey, s.Name)
+}
+
+// GetHourData 获取一个小时的统计
+func (s *TcpConnStat) GetHourData(t time.Time) (*TcpConnStatData, error) {
+	return getTcpConnStatData(s.GetHourKey(t))
+}
+
+// GetDayData 获取一天的统计
+func (s *TcpConnStat) GetDayData(t time.Time) (*TcpConnStatData, error) {
+	return getTcpConnStatData(s.GetDayKey(t))
+}
+
+// GetActiveData 获取所有网关实例的并发连接数之和，长时间没有上报的实例不计入
+func (s *TcpConnStat) GetActiveData(t time.Time) (int64, error) {
+	values, err := redis.StringMap(RedisConfDo("HGETALL", s.GetActiveKey()))
+	if err != nil {
+		return 0, err
+	}
+	return sumTcpConnActive(values, t), nil
+}
+
+func sumTcpConnActive(values map[string]string, t time.Time) int64 {
+	total := int64(0)
+	for _, value := range values {
+		items := strings.Fields(value)
+		if len(items) != 2 {
+			continue
+		}
+		active, err := strconv.ParseInt(items[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		unix, err := strconv.ParseInt(items[1], 10, 64)
+		if err != nil || t.Unix()-unix > tcpConnActiveStale {
+			continue
+		}
+		total += active
+	}
+	return total
+}
+
+func getTcpConnStatData(key string) (*TcpConnStatData, error) {
+	values, err := redis.Int64Map(RedisConfDo("HGETALL", key))
+	if err != nil {
+		return nil, err
+	}
+	data := &TcpConnStatData{
+		Conns:      values["conns"],
+		BytesIn:    values["bytes_in"],
+		BytesOut:   values["bytes_out"],
+		DurationMs: values["duration_ms"],
+		Dials:      values["dials"],
+		DialErrors: values["dial_errors"],
+		DialMs:     values["dial_ms"],
+	}
+	if data.Conns > 0 {
+		data.AvgDurationMs = data.DurationMs / data.Conns
+	}
+	if data.Dials > 0 {
+		data.AvgDialMs = data.DialMs / data.Dials
+	}
+	return data, nil
+}
@@ -0,0 +1,52 @@
+package public
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTcpConnStatObserve(t *testing.T) {
+	stat := &TcpConnStat{Name: "test_service"}
+	stat.ObserveDial(20*time.Millisecond, nil)
+	stat.ObserveDial(40*time.Millisecond, errors.New("connection refused"))
+	start := time.Unix(1700000000, 0)
+	stat.connOpenAt(start)
+	stat.connOpenAt(start.Add(time.Second))
+	if stat.Active() != 2 {
+		t.Fatalf("active = %d, want 2", stat.Active())
+	}
+	// 流量在转发过程中累计，不等待连接关闭
+	stat.AddBytesIn(100)
+	stat.AddBytesOut(2000)
+	if stat.bytesIn != 100 || stat.bytesOut != 2000 {
+		t.Fatalf("bytes in = %d, bytes out = %d", stat.bytesIn, stat.bytesOut)
+	}
+	stat.connCloseAt(start.Add(3 * time.Second))
+	if stat.Active() != 1 {
+		t.Fatalf("active = %d, want 1", stat.Active())
+	}
+	if stat.dials != 2 || stat.dialErrors != 1 || stat.dialMs != 60 {
+		t.Fatalf("dials = %d, dial errors = %d, dial ms = %d", stat.dials, stat.dialErrors, stat.dialMs)
+	}
+	// 进行中的连接按周期计入时长：关闭的连接3s，进行中的连接到第一次写入时3s
+	if duration := stat.takeDuration(start.Add(4 * time.Second)); stat.conns != 1 || duration != 6000 {
+		t.Fatalf("conns = %d, duration = %d", stat.conns, duration)
+	}
+	if duration := stat.takeDuration(start.Add(6 * time.Second)); duration != 2000 {
+		t.Fatalf("duration = %d, want 2000", duration)
+	}
+}
+
+func TestSumTcpConnActive(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	values := map[string]string{
+		"gateway-a_100": "3 1699999998",
+		"gateway-b_200": "5 1700000000",
+		"gateway-c_300": "7 1699999900", // 实例已经停止上报
+		"gateway-d_400": "invalid",
+	}
+	if total := sumTcpConnActive(values, now); total != 8 {
+		t.Fatalf("active = %d, want 8", total)
+	}
+}
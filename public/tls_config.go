@@ -0,0 +1,71 @@
+package public
+
+import (
+	"crypto/tls"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// tlsVersionMap 支持配置的TLS最低版本
+var tlsVersionMap = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion 解析TLS版本，为空时默认TLS1.2
+func ParseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return tls.VersionTLS12, nil
+	}
+	if v, ok := tlsVersionMap[version]; ok {
+		return v, nil
+	}
+	return 0, errors.New("unsupported tls version " + version)
+}
+
+// ParseCipherSuites 解析以逗号间隔的加密套件名称，为空时使用go默认的加密套件
+func ParseCipherSuites(names string) ([]uint16, error) {
+	suites := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	ids := []uint16{}
+	for _, name := range SplitList(names) {
+		id, ok := suites[name]
+		if !ok {
+			return nil, errors.New("unsupported cipher suite " + name)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return ids, nil
+}
+
+// SplitList 按逗号拆分配置项，忽略空白项
+func SplitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// MatchServerName 判断SNI是否匹配域名规则，支持*.example.com匹配一级子域名
+func MatchServerName(pattern, serverName string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if pattern == "" || serverName == "" {
+		return false
+	}
+	if !strings.HasPrefix(pattern, "*.") {
+		return pattern == serverName
+	}
+	dot := strings.Index(serverName, ".")
+	return dot > 0 && serverName[dot:] == pattern[1:]
+}
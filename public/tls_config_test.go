@@ -0,0 +1,55 @@
+package public
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	if v, err := ParseTLSVersion(""); err != nil || v != tls.VersionTLS12 {
+		t.Fatalf("default version should be tls1.2, got %v %v", v, err)
+	}
+	if v, err := ParseTLSVersion("1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Fatalf("expect tls1.3, got %v %v", v, err)
+	}
+	if _, err := ParseTLSVersion("3.0"); err == nil {
+		t.Fatal("unsupported version should fail")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("unexpected cipher suites %v", ids)
+	}
+	if ids, err := ParseCipherSuites(""); err != nil || ids != nil {
+		t.Fatalf("empty cipher suites should use default, got %v %v", ids, err)
+	}
+	// 不安全的加密套件不允许配置
+	if _, err := ParseCipherSuites("TLS_RSA_WITH_RC4_128_SHA"); err == nil {
+		t.Fatal("insecure cipher suite should fail")
+	}
+}
+
+func TestMatchServerName(t *testing.T) {
+	cases := []struct {
+		pattern    string
+		serverName string
+		matched    bool
+	}{
+		{"api.example.com", "API.example.com", true},
+		{"api.example.com", "api.example.com.", true},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", false},
+		{"api.example.com", "", false},
+	}
+	for _, item := range cases {
+		if MatchServerName(item.pattern, item.serverName) != item.matched {
+			t.Fatalf("MatchServerName(%q, %q) should be %v", item.pattern, item.serverName, item.matched)
+		}
+	}
+}
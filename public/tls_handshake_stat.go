@@ -0,0 +1,108 @@
+package public
+
+import (
+	"fmt"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/garyburd/redigo/redis"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TLSHandshakeStatHandler 暴露出去的Handler
+var TLSHandshakeStatHandler *TLSHandshakeStatManager
+
+func init() {
+	TLSHandshakeStatHandler = NewTLSHandshakeStatManager()
+}
+
+// TLSHandshakeStatManager TLS握手耗时统计管理，每个服务一个统计项
+type TLSHandshakeStatManager struct {
+	StatMap map[string]*TLSHandshakeStat
+	Locker  sync.Mutex
+}
+
+// NewTLSHandshakeStatManager 暴露出去的New方法
+func NewTLSHandshakeStatManager() *TLSHandshakeStatManager {
+	return &TLSHandshakeStatManager{
+		StatMap: map[string]*TLSHandshakeStat{},
+		Locker:  sync.Mutex{},
+	}
+}
+
+// GetStat 获取服务的握手统计项，不存在则新建
+func (m *TLSHandshakeStatManager) GetStat(serviceName string) *TLSHandshakeStat {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if stat, ok := m.StatMap[serviceName]; ok {
+		return stat
+	}
+	stat := NewTLSHandshakeStat(serviceName, 1*time.Second)
+	m.StatMap[serviceName] = stat
+	return stat
+}
+
+// TLSHandshakeStat TLS握手耗时统计，内存中累计握手次数和耗时，定时写入redis按小时汇总
+type TLSHandshakeStat struct {
+	ServiceName string
+	Interval    time.Duration
+	tickerCount int64
+	tickerMs    int64
+}
+
+// NewTLSHandshakeStat 新建握手统计项并启动定时写入
+func NewTLSHandshakeStat(serviceName string, interval time.Duration) *TLSHandshakeStat {
+	stat := &TLSHandshakeStat{
+		ServiceName: serviceName,
+		Interval:    interval,
+	}
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+		ticker := time.NewTicker(interval)
+		for {
+			<-ticker.C
+			tickerCount := atomic.SwapInt64(&stat.tickerCount, 0)
+			tickerMs := atomic.SwapInt64(&stat.tickerMs, 0)
+			if tickerCount == 0 {
+				continue
+			}
+			hourKey := stat.GetHourKey(time.Now())
+			if err := RedisConfPipeline(func(c redis.Conn) {
+				c.Send("HINCRBY", hourKey, "count", tickerCount)
+				c.Send("HINCRBY", hourKey, "total_ms", tickerMs)
+				c.Send("EXPIRE", hourKey, 86400*2)
+			}); err != nil {
+				fmt.Println("RedisConfPipeline err", err)
+			}
+		}
+	}()
+	return stat
+}
+
+// Observe 记录一次成功握手的耗时
+func (s *TLSHandshakeStat) Observe(d time.Duration) {
+	atomic.AddInt64(&s.tickerCount, 1)
+	atomic.AddInt64(&s.tickerMs, d.Milliseconds())
+}
+
+// GetHourKey 获取以小时为单位的key
+func (s *TLSHandshakeStat) GetHourKey(t time.Time) string {
+	hourStr := t.In(lib.TimeLocation).Format("2006010215")
+	return fmt.Sprintf("%s_%s_%s", RedisTLSHandshakeKey, hourStr, s.ServiceName)
+}
+
+// GetHourAvgMs 获取一个小时内握手的平均耗时，单位ms
+func (s *TLSHandshakeStat) GetHourAvgMs(t time.Time) (int64, error) {
+	values, err := redis.Int64Map(RedisConfDo("HGETALL", s.GetHourKey(t)))
+	if err != nil {
+		return 0, err
+	}
+	if values["count"] == 0 {
+		return 0, nil
+	}
+	return values["total_ms"] / values["count"], nil
+}
@@ -0,0 +1,287 @@
+package public
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 平滑升级：收到升级信号后启动新的二进制，通过ExtraFiles将监听的socket传给新进程，
+// 新进程就绪后当前进程停止接受新连接，排空处理中的请求后退出
+const (
+	upgradeFdsEnv   = "GATEWAY_UPGRADE_FDS"      //继承的socket名称，以逗号间隔，依次对应文件描述符3、4...
+	upgradeReadyEnv = "GATEWAY_UPGRADE_READY_FD" //新进程就绪后写入的管道
+)
+
+var (
+	ErrUpgradeInProgress = errors.New("upgrade: already in progress")
+	ErrUpgradeTimeout    = errors.New("upgrade: timeout waiting for child ready")
+)
+
+// UpgraderHandler 暴露出去的Handler
+var UpgraderHandler *Upgrader
+
+func init() {
+	UpgraderHandler = NewUpgrader()
+}
+
+// socketFiler *net.TCPListener和*net.UDPConn都可以复制出文件描述符
+type socketFiler interface {
+	File() (*os.File, error)
+}
+
+// Upgrader 管理监听的socket，平滑升级时传给新进程
+type Upgrader struct {
+	ReadyTimeout   time.Duration //等待新进程就绪的最长时间
+	InheritTimeout time.Duration //新进程等待继承的socket被使用的最长时间，超时后关闭未使用的socket
+
+	mu        sync.Mutex
+	execPath  string
+	inherited map[string]*os.File //从父进程继承、尚未被使用的socket
+	sockets   map[string]socketFiler
+	readyFile *os.File
+	upgrading int32
+}
+
+// NewUpgrader 从环境变量中读取父进程传递的socket
+func NewUpgrader() *Upgrader {
+	u := &Upgrader{
+		ReadyTimeout:   time.Minute,
+		InheritTimeout: 10 * time.Second,
+		inherited:      map[string]*os.File{},
+		sockets:        map[string]socketFiler{},
+	}
+	if path, err := exec.LookPath(os.Args[0]); err == nil {
+		u.execPath, _ = filepath.Abs(path)
+	}
+	if names := os.Getenv(upgradeFdsEnv); names != "" {
+		for i, name := range strings.Split(names, ",") {
+			u.inherited[name] = os.NewFile(uintptr(3+i), name)
+		}
+	}
+	if fd, err := strconv.Atoi(os.Getenv(upgradeReadyEnv)); err == nil {
+		u.readyFile = os.NewFile(uintptr(fd), "upgrade_ready")
+	}
+	// 不再传递给之后启动的进程
+	os.Unsetenv(upgradeFdsEnv)
+	os.Unsetenv(upgradeReadyEnv)
+	return u
+}
+
+func socketName(network, addr string) string {
+	return network + "://" + addr
+}
+
+// takeInherited 取出继承的socket，不存在时返回nil
+func (u *Upgrader) takeInherited(name string) *os.File {
+	file, ok := u.inherited[name]
+	if !ok {
+		return nil
+	}
+	delete(u.inherited, name)
+	return file
+}
+
+// Listen 监听TCP地址，优先使用从父进程继承的socket，监听结果记录到HealthHandler
+func (u *Upgrader) Listen(network, addr string) (net.Listener, error) {
+	name := socketName(network, addr)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var ln net.Listener
+	var err error
+	if file := u.takeInherited(name); file != nil {
+		ln, err = net.FileListener(file)
+		file.Close()
+	} else {
+		ln, err = net.Listen(network, addr)
+	}
+	HealthHandler.listenerResult(name, err)
+	if err != nil {
+		return nil, err
+	}
+	if filer, ok := ln.(socketFiler); ok {
+		u.sockets[name] = filer
+	}
+	return ln, nil
+}
+
+// ListenUDP 监听UDP地址，优先使用从父进程继承的socket
+func (u *Upgrader) ListenUDP(network, addr string) (*net.UDPConn, error) {
+	name := socketName(network, addr)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var conn net.PacketConn
+	var err error
+	if file := u.takeInherited(name); file != nil {
+		conn, err = net.FilePacketConn(file)
+		file.Close()
+	} else {
+		conn, err = net.ListenPacket(network, addr)
+	}
+	HealthHandler.listenerResult(name, err)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("upgrade: %s is not a udp socket", name)
+	}
+	u.sockets[name] = udpConn
+	return udpConn, nil
+}
+
+// WaitInherited 等待继承的socket全部被使用，超过InheritTimeout后关闭未使用的socket，例如已经删除的服务的端口
+func (u *Upgrader) WaitInherited() {
+	deadline := time.Now().Add(u.InheritTimeout)
+	for time.Now().Before(deadline) {
+		u.mu.Lock()
+		remain := len(u.inherited)
+		u.mu.Unlock()
+		if remain == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for name, file := range u.inherited {
+		log.Printf(" [WARN] upgrade inherited socket %s not used, closed\n", name)
+		file.Close()
+	}
+	u.inherited = map[string]*os.File{}
+}
+
+// IsUpgradeChild 是否为平滑升级启动的新进程，通知父进程就绪之前返回true
+func (u *Upgrader) IsUpgradeChild() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.readyFile != nil
+}
+
+// Ready 通知父进程新进程已经就绪，不是由平滑升级启动时直接返回
+func (u *Upgrader) Ready() error {
+	u.mu.Lock()
+	readyFile := u.readyFile
+	u.readyFile = nil
+	u.mu.Unlock()
+	if readyFile == nil {
+		return nil
+	}
+	defer readyFile.Close()
+	_, err := readyFile.Write([]byte{1})
+	return err
+}
+
+// Upgrade 使用相同的参数启动新进程并传递当前监听的socket，新进程就绪后返回
+// 返回nil后当前进程需要排空连接并退出，新进程启动失败或超时未就绪时返回错误，当前进程继续服务
+func (u *Upgrader) Upgrade() error {
+	if !atomic.CompareAndSwapInt32(&u.upgrading, 0, 1) {
+		return ErrUpgradeInProgress
+	}
+	err := u.upgrade()
+	if err != nil {
+		atomic.StoreInt32(&u.upgrading, 0)
+	}
+	return err
+}
+
+func (u *Upgrader) upgrade() error {
+	if u.execPath == "" {
+		return fmt.Errorf("upgrade: executable %s not found", os.Args[0])
+	}
+	names, files := u.socketFiles()
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	cmd := exec.Command(u.execPath, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyWriter)
+	cmd.Env = append(os.Environ(),
+		upgradeFdsEnv+"="+strings.Join(names, ","),
+		upgradeReadyEnv+"="+strconv.Itoa(3+len(files)))
+	err = cmd.Start()
+	readyWriter.Close()
+	if err != nil {
+		return err
+	}
+	log.Printf(" [INFO] upgrade started pid %d with %d sockets\n", cmd.Process.Pid, len(files))
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	ready := make(chan error, 1)
+	go func() {
+		// 新进程退出时管道关闭，读取返回EOF
+		_, err := readyReader.Read(make([]byte, 1))
+		ready <- err
+	}()
+	timer := time.NewTimer(u.ReadyTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-ready:
+		if err != nil {
+			return fmt.Errorf("upgrade: child exited before ready: %v", err)
+		}
+		log.Printf(" [INFO] upgrade child pid %d ready\n", cmd.Process.Pid)
+		return nil
+	case err := <-exited:
+		return fmt.Errorf("upgrade: child exited before ready: %v", err)
+	case <-timer.C:
+		cmd.Process.Kill()
+		return ErrUpgradeTimeout
+	}
+}
+
+// socketFiles 复制当前监听的socket，已经关闭的socket不再传递
+func (u *Upgrader) socketFiles() ([]string, []*os.File) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	names := make([]string, 0, len(u.sockets))
+	for name := range u.sockets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	validNames := []string{}
+	files := []*os.File{}
+	for _, name := range names {
+		file, err := u.sockets[name].File()
+		if err != nil {
+			log.Printf(" [WARN] upgrade socket %s skipped err:%v\n", name, err)
+			continue
+		}
+		validNames = append(validNames, name)
+		files = append(files, file)
+	}
+	return validNames, files
+}
+
+// IsUpgradeSignal 是否为触发平滑升级的信号
+func IsUpgradeSignal(sig os.Signal) bool {
+	for _, item := range UpgradeSignals {
+		if item == sig {
+			return true
+		}
+	}
+	return false
+}
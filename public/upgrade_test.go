@@ -0,0 +1,76 @@
+package public
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+const upgradeHelperEnv = "GATEWAY_UPGRADE_TEST_ADDR"
+
+// TestUpgradeHelperProcess 平滑升级启动的新进程，使用继承的socket应答一个连接后退出
+func TestUpgradeHelperProcess(t *testing.T) {
+	addr := os.Getenv(upgradeHelperEnv)
+	if addr == "" {
+		t.Skip("helper process")
+	}
+	ln, err := UpgraderHandler.Listen("tcp", addr)
+	if err != nil {
+		os.Exit(1)
+	}
+	served := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Write([]byte("child"))
+			conn.Close()
+		}
+		close(served)
+	}()
+	UpgraderHandler.WaitInherited()
+	if err := UpgraderHandler.Ready(); err != nil {
+		os.Exit(1)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+	}
+	os.Exit(0)
+}
+
+func TestUpgraderHandoff(t *testing.T) {
+	upgrader := NewUpgrader()
+	upgrader.ReadyTimeout = 10 * time.Second
+	ln, err := upgrader.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	oldArgs := os.Args
+	os.Args = []string{os.Args[0], "-test.run=TestUpgradeHelperProcess"}
+	defer func() { os.Args = oldArgs }()
+	os.Setenv(upgradeHelperEnv, "127.0.0.1:0")
+	defer os.Unsetenv(upgradeHelperEnv)
+
+	if err := upgrader.Upgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if err := upgrader.Upgrade(); err != ErrUpgradeInProgress {
+		t.Fatalf("second upgrade err = %v, want in progress", err)
+	}
+	// 当前进程关闭监听器后，新进程继续在同一个socket上接受连接
+	ln.Close()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reply, err := ioutil.ReadAll(conn)
+	if err != nil || string(reply) != "child" {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package public
+
+import (
+	"os"
+	"syscall"
+)
+
+// UpgradeSignals 触发平滑升级的信号
+var UpgradeSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package public
+
+import "os"
+
+// UpgradeSignals windows不支持传递socket，不进行平滑升级
+var UpgradeSignals = []os.Signal{}
@@ -2,21 +2,28 @@ package reverse_proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"github.com/e421083458/grpc-proxy/proxy"
 	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"log"
 )
 
-func NewGrpcLoadBalanceHandler(lb load_balance.LoadBalance) grpc.StreamHandler {
+// NewGrpcLoadBalanceHandler 创建透明转发的handler，tlsConfig不为空时使用TLS连接下游
+func NewGrpcLoadBalanceHandler(lb load_balance.LoadBalance, tlsConfig *tls.Config) grpc.StreamHandler {
+	transportOpt := grpc.WithInsecure()
+	if tlsConfig != nil {
+		transportOpt = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
 	return func() grpc.StreamHandler {
 		nextAddr, err := lb.Get("")
 		if err != nil {
 			log.Fatal("get next addr fail")
 		}
 		director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
-			c, err := grpc.DialContext(ctx, nextAddr, grpc.WithCodec(proxy.Codec()), grpc.WithInsecure())
+			c, err := grpc.DialContext(ctx, nextAddr, grpc.WithCodec(proxy.Codec()), transportOpt)
 			md, _ := metadata.FromIncomingContext(ctx)
 			outCtx, _ := context.WithCancel(ctx)
 			outCtx = metadata.NewOutgoingContext(outCtx, md.Copy())
@@ -0,0 +1,137 @@
+package grpc_transcode
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"strings"
+)
+
+// parseDescriptorSet 解析protoc --descriptor_set_out生成的描述文件集合
+// 未使用--include_imports时，缺少的依赖(如google/api/annotations.proto)从已注册的全局描述中查找
+func parseDescriptorSet(data []byte) (*protoregistry.Files, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, errors.WithMessage(err, "invalid descriptor set")
+	}
+	files := &protoregistry.Files{}
+	resolver := &fileResolver{local: files}
+	pending := set.GetFile()
+	// 描述文件不保证按照依赖顺序排列，每轮注册依赖已经满足的文件
+	for len(pending) > 0 {
+		next := []*descriptorpb.FileDescriptorProto{}
+		var lastErr error
+		for _, fdProto := range pending {
+			fd, err := protodesc.NewFile(fdProto, resolver)
+			if err != nil {
+				lastErr = err
+				next = append(next, fdProto)
+				continue
+			}
+			if err := files.RegisterFile(fd); err != nil {
+				return nil, err
+			}
+		}
+		if len(next) == len(pending) {
+			return nil, errors.WithMessage(lastErr, "resolve descriptor set")
+		}
+		pending = next
+	}
+	return files, nil
+}
+
+// fileResolver 优先从上传的描述文件中查找，找不到时使用全局注册的描述
+type fileResolver struct {
+	local *protoregistry.Files
+}
+
+func (r *fileResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.local.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *fileResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.local.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// typeResolver 用于protojson解析Any类型，上传的消息类型使用动态消息
+type typeResolver struct {
+	files *fileResolver
+}
+
+func (r *typeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+	d, err := r.files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, protoregistry.NotFound
+	}
+	return dynamicpb.NewMessageType(md), nil
+}
+
+func (r *typeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	name := url
+	if idx := strings.LastIndex(url, "/"); idx >= 0 {
+		name = url[idx+1:]
+	}
+	return r.FindMessageByName(protoreflect.FullName(name))
+}
+
+func (r *typeResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (r *typeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// methodHttpRules 读取方法上的google.api.http注解，包括additional_bindings
+// 没有注解的方法默认绑定为 POST /包名.服务名/方法名，请求体为整个消息
+func methodHttpRules(method protoreflect.MethodDescriptor) []*annotations.HttpRule {
+	rule, ok := proto.GetExtension(method.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil || rule.GetPattern() == nil {
+		return []*annotations.HttpRule{{
+			Pattern: &annotations.HttpRule_Post{Post: "/" + string(method.Parent().FullName()) + "/" + string(method.Name())},
+			Body:    "*",
+		}}
+	}
+	rules := []*annotations.HttpRule{rule}
+	for _, item := range rule.GetAdditionalBindings() {
+		rules = append(rules, item)
+	}
+	return rules
+}
+
+// httpRulePattern 获取注解中的HTTP方法和路径模板
+func httpRulePattern(rule *annotations.HttpRule) (string, string, error) {
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return "GET", pattern.Get, nil
+	case *annotations.HttpRule_Put:
+		return "PUT", pattern.Put, nil
+	case *annotations.HttpRule_Post:
+		return "POST", pattern.Post, nil
+	case *annotations.HttpRule_Delete:
+		return "DELETE", pattern.Delete, nil
+	case *annotations.HttpRule_Patch:
+		return "PATCH", pattern.Patch, nil
+	case *annotations.HttpRule_Custom:
+		return strings.ToUpper(pattern.Custom.GetKind()), pattern.Custom.GetPath(), nil
+	}
+	return "", "", errors.New("empty http rule pattern")
+}
@@ -0,0 +1,130 @@
+package grpc_transcode
+
+import (
+	"github.com/pkg/errors"
+	"net/url"
+	"strings"
+)
+
+// pathVariable 路径模板中的变量，对应模板分段[start, end)，end为-1时表示匹配到末尾
+type pathVariable struct {
+	fieldPath string
+	start     int
+	end       int
+}
+
+// pathTemplate google.api.http的路径模板，格式: /v1/{name=messages/*}:verb
+type pathTemplate struct {
+	segments  []string // 字面量分段，*表示单段通配，**表示多段通配且只能出现在末尾
+	variables []pathVariable
+	verb      string
+	literals  int
+}
+
+// parsePathTemplate 解析路径模板
+func parsePathTemplate(template string) (*pathTemplate, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, errors.Errorf("path template %v must start with /", template)
+	}
+	t := &pathTemplate{}
+	rest := template[1:]
+	// 变量中可能出现冒号，只截取最后一个右括号之后的冒号作为verb
+	if idx := strings.LastIndex(rest, ":"); idx >= 0 && idx > strings.LastIndex(rest, "}") {
+		t.verb = rest[idx+1:]
+		rest = rest[:idx]
+	}
+	for rest != "" {
+		if strings.HasPrefix(rest, "{") {
+			end := strings.Index(rest, "}")
+			if end < 0 {
+				return nil, errors.Errorf("path template %v has unclosed variable", template)
+			}
+			field, pattern := rest[1:end], "*"
+			if eq := strings.Index(field, "="); eq >= 0 {
+				field, pattern = field[:eq], field[eq+1:]
+			}
+			variable := pathVariable{fieldPath: field, start: len(t.segments)}
+			for _, segment := range strings.Split(pattern, "/") {
+				t.appendSegment(segment)
+			}
+			variable.end = len(t.segments)
+			if t.segments[len(t.segments)-1] == "**" {
+				variable.end = -1
+			}
+			t.variables = append(t.variables, variable)
+			rest = strings.TrimPrefix(rest[end+1:], "/")
+			continue
+		}
+		segment := rest
+		if slash := strings.Index(rest, "/"); slash >= 0 {
+			segment, rest = rest[:slash], rest[slash+1:]
+		} else {
+			rest = ""
+		}
+		t.appendSegment(segment)
+	}
+	for i, segment := range t.segments {
+		if segment == "" {
+			return nil, errors.Errorf("path template %v has empty segment", template)
+		}
+		if segment == "**" && i != len(t.segments)-1 {
+			return nil, errors.Errorf("path template %v: ** must be the last segment", template)
+		}
+	}
+	return t, nil
+}
+
+func (t *pathTemplate) appendSegment(segment string) {
+	if segment != "*" && segment != "**" {
+		t.literals++
+	}
+	t.segments = append(t.segments, segment)
+}
+
+// match 匹配请求路径，返回变量字段路径与取值
+func (t *pathTemplate) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if t.verb != "" {
+		if !strings.HasSuffix(path, ":"+t.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+t.verb)
+	}
+	parts := []string{}
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	deep := len(t.segments) > 0 && t.segments[len(t.segments)-1] == "**"
+	if deep && len(parts) < len(t.segments)-1 || !deep && len(parts) != len(t.segments) {
+		return nil, false
+	}
+	for i, segment := range t.segments {
+		if segment == "**" {
+			break
+		}
+		if segment != "*" && segment != parts[i] {
+			return nil, false
+		}
+	}
+	values := map[string]string{}
+	for _, variable := range t.variables {
+		end := variable.end
+		if end < 0 {
+			end = len(parts)
+		}
+		if end-variable.start == 1 {
+			value, err := url.PathUnescape(parts[variable.start])
+			if err != nil {
+				return nil, false
+			}
+			values[variable.fieldPath] = value
+			continue
+		}
+		// 多段变量保留原始的分隔符
+		values[variable.fieldPath] = strings.Join(parts[variable.start:end], "/")
+	}
+	return values, true
+}
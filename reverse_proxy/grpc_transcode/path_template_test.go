@@ -0,0 +1,42 @@
+package grpc_transcode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathTemplate(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		values   map[string]string
+		ok       bool
+	}{
+		{"/v1/example/echo", "/v1/example/echo", map[string]string{}, true},
+		{"/v1/example/echo", "/v1/example/echo/1", nil, false},
+		{"/v1/users/{id}", "/v1/users/a%2Fb", map[string]string{"id": "a/b"}, true},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books/2", map[string]string{"name": "shelves/1/books/2"}, true},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/authors/2", nil, false},
+		{"/v1/files/{path=**}", "/v1/files/a/b/c", map[string]string{"path": "a/b/c"}, true},
+		{"/v1/users/{user.id}:cancel", "/v1/users/7:cancel", map[string]string{"user.id": "7"}, true},
+		{"/v1/users/{user.id}:cancel", "/v1/users/7", nil, false},
+	}
+	for _, item := range cases {
+		template, err := parsePathTemplate(item.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, ok := template.match(item.path)
+		if ok != item.ok || ok && !reflect.DeepEqual(values, item.values) {
+			t.Fatalf("%v match %v: got %v %v", item.template, item.path, values, ok)
+		}
+	}
+}
+
+func TestPathTemplateInvalid(t *testing.T) {
+	for _, template := range []string{"v1/echo", "/v1/{id", "/v1//echo", "/v1/{path=**}/info"} {
+		if _, err := parsePathTemplate(template); err == nil {
+			t.Fatalf("%v should be invalid", template)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package grpc_transcode
+
+import (
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"io"
+	"sort"
+)
+
+// ReflectionServer 使用上传的描述文件应答gRPC服务反射请求，下游服务不需要开启反射
+type ReflectionServer struct {
+	rpb.UnimplementedServerReflectionServer
+	files    *fileResolver
+	services []string
+	// extensions 按被扩展的消息类型索引扩展字段所在的文件
+	extensions map[protoreflect.FullName]map[protoreflect.FieldNumber]protoreflect.FileDescriptor
+}
+
+// NewReflectionServer 解析描述文件集合，生成反射服务
+func NewReflectionServer(descriptorSet []byte) (*ReflectionServer, error) {
+	local, err := parseDescriptorSet(descriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	s := &ReflectionServer{
+		files:      &fileResolver{local: local},
+		extensions: map[protoreflect.FullName]map[protoreflect.FieldNumber]protoreflect.FileDescriptor{},
+	}
+	local.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			s.services = append(s.services, string(services.Get(i).FullName()))
+		}
+		s.addExtensions(fd, fd.Extensions())
+		s.addMessageExtensions(fd, fd.Messages())
+		return true
+	})
+	sort.Strings(s.services)
+	return s, nil
+}
+
+// ServiceNames 描述文件中定义的服务全名
+func (s *ReflectionServer) ServiceNames() []string {
+	return s.services
+}
+
+func (s *ReflectionServer) addMessageExtensions(fd protoreflect.FileDescriptor, messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		s.addExtensions(fd, messages.Get(i).Extensions())
+		s.addMessageExtensions(fd, messages.Get(i).Messages())
+	}
+}
+
+func (s *ReflectionServer) addExtensions(fd protoreflect.FileDescriptor, extensions protoreflect.ExtensionDescriptors) {
+	for i := 0; i < extensions.Len(); i++ {
+		ext := extensions.Get(i)
+		name := ext.ContainingMessage().FullName()
+		if s.extensions[name] == nil {
+			s.extensions[name] = map[protoreflect.FieldNumber]protoreflect.FileDescriptor{}
+		}
+		s.extensions[name][ext.Number()] = fd
+	}
+}
+
+// ServerReflectionInfo 处理反射请求流，同一个流中已经发送过的依赖文件不再重复发送
+func (s *ReflectionServer) ServerReflectionInfo(stream rpb.ServerReflection_ServerReflectionInfoServer) error {
+	sent := map[string]bool{}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp := &rpb.ServerReflectionResponse{
+			ValidHost:       req.GetHost(),
+			OriginalRequest: req,
+		}
+		switch r := req.GetMessageRequest().(type) {
+		case *rpb.ServerReflectionRequest_FileByFilename:
+			fd, err := s.files.FindFileByPath(r.FileByFilename)
+			s.setFileResponse(resp, fd, err, sent)
+		case *rpb.ServerReflectionRequest_FileContainingSymbol:
+			var fd protoreflect.FileDescriptor
+			d, err := s.files.FindDescriptorByName(protoreflect.FullName(r.FileContainingSymbol))
+			if err == nil {
+				fd = d.ParentFile()
+			}
+			s.setFileResponse(resp, fd, err, sent)
+		case *rpb.ServerReflectionRequest_FileContainingExtension:
+			ext := r.FileContainingExtension
+			fd, ok := s.extensions[protoreflect.FullName(ext.GetContainingType())][protoreflect.FieldNumber(ext.GetExtensionNumber())]
+			var findErr error
+			if !ok {
+				findErr = protoregistry.NotFound
+			}
+			s.setFileResponse(resp, fd, findErr, sent)
+		case *rpb.ServerReflectionRequest_AllExtensionNumbersOfType:
+			s.setExtensionNumbersResponse(resp, protoreflect.FullName(r.AllExtensionNumbersOfType))
+		case *rpb.ServerReflectionRequest_ListServices:
+			list := &rpb.ListServiceResponse{}
+			for _, name := range s.services {
+				list.Service = append(list.Service, &rpb.ServiceResponse{Name: name})
+			}
+			resp.MessageResponse = &rpb.ServerReflectionResponse_ListServicesResponse{ListServicesResponse: list}
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid MessageRequest: %v", req.GetMessageRequest())
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// setFileResponse 返回请求的文件以及尚未发送过的依赖文件
+func (s *ReflectionServer) setFileResponse(resp *rpb.ServerReflectionResponse, fd protoreflect.FileDescriptor, err error, sent map[string]bool) {
+	if err != nil {
+		setErrorResponse(resp, codes.NotFound, err)
+		return
+	}
+	encoded := [][]byte{}
+	var walk func(fd protoreflect.FileDescriptor, requested bool) error
+	walk = func(fd protoreflect.FileDescriptor, requested bool) error {
+		if sent[fd.Path()] && !requested {
+			return nil
+		}
+		sent[fd.Path()] = true
+		b, err := proto.Marshal(protodesc.ToFileDescriptorProto(fd))
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, b)
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			if err := walk(imports.Get(i).FileDescriptor, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(fd, true); err != nil {
+		setErrorResponse(resp, codes.Internal, err)
+		return
+	}
+	resp.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{
+		FileDescriptorResponse: &rpb.FileDescriptorResponse{FileDescriptorProto: encoded},
+	}
+}
+
+// setExtensionNumbersResponse 返回消息类型所有扩展字段的编号
+func (s *ReflectionServer) setExtensionNumbersResponse(resp *rpb.ServerReflectionResponse, name protoreflect.FullName) {
+	d, err := s.files.FindDescriptorByName(name)
+	if err != nil {
+		setErrorResponse(resp, codes.NotFound, err)
+		return
+	}
+	if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+		setErrorResponse(resp, codes.NotFound, protoregistry.NotFound)
+		return
+	}
+	numbers := []int32{}
+	for number := range s.extensions[name] {
+		numbers = append(numbers, int32(number))
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	resp.MessageResponse = &rpb.ServerReflectionResponse_AllExtensionNumbersResponse{
+		AllExtensionNumbersResponse: &rpb.ExtensionNumberResponse{
+			BaseTypeName:    string(name),
+			ExtensionNumber: numbers,
+		},
+	}
+}
+
+func setErrorResponse(resp *rpb.ServerReflectionResponse, code codes.Code, err error) {
+	resp.MessageResponse = &rpb.ServerReflectionResponse_ErrorResponse{
+		ErrorResponse: &rpb.ErrorResponse{
+			ErrorCode:    int32(code),
+			ErrorMessage: err.Error(),
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package grpc_transcode
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"net"
+	"testing"
+)
+
+func startReflectionServer(t *testing.T) rpb.ServerReflection_ServerReflectionInfoClient {
+	reflectionServer, err := NewReflectionServer(echoDescriptorSet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	rpb.RegisterServerReflectionServer(server, reflectionServer)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+func reflectionCall(t *testing.T, stream rpb.ServerReflection_ServerReflectionInfoClient, req *rpb.ServerReflectionRequest) *rpb.ServerReflectionResponse {
+	if err := stream.Send(req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestReflectionListServices(t *testing.T) {
+	stream := startReflectionServer(t)
+	resp := reflectionCall(t, stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	})
+	services := resp.GetListServicesResponse().GetService()
+	if len(services) != 1 || services[0].Name != "echo.Echo" {
+		t.Fatalf("unexpected services %v", services)
+	}
+}
+
+func TestReflectionFileContainingSymbol(t *testing.T) {
+	stream := startReflectionServer(t)
+	resp := reflectionCall(t, stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "echo.Echo.UnaryEcho"},
+	})
+	files := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+	if len(files) < 2 {
+		t.Fatalf("expect file with dependencies, got %d files", len(files))
+	}
+	fdProto := &descriptorpb.FileDescriptorProto{}
+	if err := proto.Unmarshal(files[0], fdProto); err != nil {
+		t.Fatal(err)
+	}
+	if fdProto.GetName() != "echo-gateway.proto" {
+		t.Fatalf("unexpected file %v", fdProto.GetName())
+	}
+	// 同一个流中已经发送过的依赖不再重复发送
+	resp = reflectionCall(t, stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: "echo-gateway.proto"},
+	})
+	if files := resp.GetFileDescriptorResponse().GetFileDescriptorProto(); len(files) != 1 {
+		t.Fatalf("expect only requested file, got %d files", len(files))
+	}
+	resp = reflectionCall(t, stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "echo.Missing"},
+	})
+	if resp.GetErrorResponse().GetErrorCode() != int32(codes.NotFound) {
+		t.Fatalf("expect NotFound, got %v", resp)
+	}
+}
@@ -0,0 +1,430 @@
+package grpc_transcode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetadataHeaderPrefix 请求中以该前缀开头的header去掉前缀后作为metadata转发，响应中的header metadata同样加上该前缀
+const (
+	MetadataHeaderPrefix  = "Grpc-Metadata-"
+	MetadataTrailerPrefix = "Grpc-Trailer-"
+	// MaxBodySize 转码时读取的请求体上限，单位byte，与gRPC默认的最大接收消息大小一致
+	MaxBodySize = 4 << 20
+)
+
+// Route 转码路由，用于后台展示
+type Route struct {
+	HttpMethod string `json:"http_method"`
+	Path       string `json:"path"`
+	GrpcMethod string `json:"grpc_method"`
+}
+
+// route 一条HTTP到gRPC方法的绑定
+type route struct {
+	Route
+	template     *pathTemplate
+	method       protoreflect.MethodDescriptor
+	body         string
+	responseBody string
+}
+
+// Transcoder 按照描述文件和google.api.http注解，将REST/JSON请求转码为gRPC调用
+type Transcoder struct {
+	routes    []*route
+	resolver  *typeResolver
+	unmarshal protojson.UnmarshalOptions
+	marshal   protojson.MarshalOptions
+}
+
+// NewTranscoder 通过描述文件集合创建转码器，只绑定一元方法
+func NewTranscoder(descriptorSet []byte) (*Transcoder, error) {
+	files, err := parseDescriptorSet(descriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	resolver := &typeResolver{files: &fileResolver{local: files}}
+	t := &Transcoder{
+		resolver:  resolver,
+		unmarshal: protojson.UnmarshalOptions{Resolver: resolver},
+		marshal:   protojson.MarshalOptions{Resolver: resolver, UseProtoNames: true},
+	}
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len() && err == nil; i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len() && err == nil; j++ {
+				err = t.addMethod(methods.Get(j))
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.routes) == 0 {
+		return nil, errors.New("descriptor set has no unary method")
+	}
+	// 字面量分段越多的路由越具体，优先匹配
+	sort.SliceStable(t.routes, func(i, j int) bool {
+		return t.routes[i].template.literals > t.routes[j].template.literals
+	})
+	return t, nil
+}
+
+func (t *Transcoder) addMethod(method protoreflect.MethodDescriptor) error {
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil
+	}
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	for _, rule := range methodHttpRules(method) {
+		httpMethod, path, err := httpRulePattern(rule)
+		if err != nil {
+			return errors.WithMessage(err, fullMethod)
+		}
+		template, err := parsePathTemplate(path)
+		if err != nil {
+			return errors.WithMessage(err, fullMethod)
+		}
+		// google.api.http只允许body为顶层字段或者*
+		if strings.Contains(rule.GetBody(), ".") {
+			return errors.Errorf("%v: body %v must be a top-level field or *", fullMethod, rule.GetBody())
+		}
+		if err := checkFieldPath(method.Input(), rule.GetBody()); err != nil {
+			return errors.WithMessage(err, fullMethod)
+		}
+		for _, variable := range template.variables {
+			if err := checkFieldPath(method.Input(), variable.fieldPath); err != nil {
+				return errors.WithMessage(err, fullMethod)
+			}
+		}
+		if err := checkFieldPath(method.Output(), rule.GetResponseBody()); err != nil {
+			return errors.WithMessage(err, fullMethod)
+		}
+		t.routes = append(t.routes, &route{
+			Route:        Route{HttpMethod: httpMethod, Path: path, GrpcMethod: fullMethod},
+			template:     template,
+			method:       method,
+			body:         rule.GetBody(),
+			responseBody: rule.GetResponseBody(),
+		})
+	}
+	return nil
+}
+
+// Routes 转码路由列表
+func (t *Transcoder) Routes() []Route {
+	routes := []Route{}
+	for _, item := range t.routes {
+		routes = append(routes, item.Route)
+	}
+	return routes
+}
+
+// match 匹配HTTP方法和路径
+func (t *Transcoder) match(httpMethod, path string) (*route, map[string]string) {
+	for _, item := range t.routes {
+		if item.HttpMethod != httpMethod {
+			continue
+		}
+		if values, ok := item.template.match(path); ok {
+			return item, values
+		}
+	}
+	return nil, nil
+}
+
+// ServeHTTP 转码请求并通过conn调用下游gRPC服务，下游错误按照gRPC状态码映射为HTTP状态码
+func (t *Transcoder) ServeHTTP(w http.ResponseWriter, req *http.Request, conn grpc.ClientConnInterface) {
+	item, values := t.match(req.Method, req.URL.Path)
+	if item == nil {
+		t.writeError(w, status.Errorf(codes.NotFound, "no transcoding route for %v %v", req.Method, req.URL.Path))
+		return
+	}
+	in, err := t.buildRequest(req, item, values)
+	if err != nil {
+		t.writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	out := dynamicpb.NewMessage(item.method.Output())
+	var header, trailer metadata.MD
+	ctx := metadata.NewOutgoingContext(req.Context(), requestMetadata(req))
+	err = conn.Invoke(ctx, item.GrpcMethod, in, out,
+		grpc.ForceCodec(protoCodec{}), grpc.Header(&header), grpc.Trailer(&trailer))
+	writeMetadata(w, MetadataHeaderPrefix, header)
+	writeMetadata(w, MetadataTrailerPrefix, trailer)
+	if err != nil {
+		t.writeError(w, err)
+		return
+	}
+	body, err := t.marshalResponse(out, item.responseBody)
+	if err != nil {
+		t.writeError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// buildRequest 按照body、路径变量、query参数的顺序组装请求消息
+func (t *Transcoder) buildRequest(req *http.Request, item *route, values map[string]string) (*dynamicpb.Message, error) {
+	in := dynamicpb.NewMessage(item.method.Input())
+	if item.body != "" {
+		data, err := ioutil.ReadAll(io.LimitReader(req.Body, MaxBodySize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > MaxBodySize {
+			return nil, errors.Errorf("request body exceeds %d bytes", MaxBodySize)
+		}
+		if len(data) > 0 {
+			if item.body != "*" {
+				// 请求体只对应某个字段时，包装成对象后再解析
+				field := item.method.Input().Fields().ByName(protoreflect.Name(item.body))
+				data = []byte(`{"` + field.JSONName() + `":` + string(data) + `}`)
+			}
+			if err := t.unmarshal.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+		}
+	}
+	bound := map[string]bool{}
+	for fieldPath, value := range values {
+		if err := setField(in, fieldPath, []string{value}); err != nil {
+			return nil, err
+		}
+		bound[fieldPath] = true
+	}
+	// 请求体为整个消息时不再读取query参数
+	if item.body == "*" {
+		return in, nil
+	}
+	for key, queryValues := range req.URL.Query() {
+		if bound[key] || key == item.body || strings.HasPrefix(key, item.body+".") && item.body != "" {
+			continue
+		}
+		if err := setField(in, key, queryValues); err != nil && errors.Cause(err) != errUnknownField {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// marshalResponse 序列化响应，response_body不为空时只返回该字段
+func (t *Transcoder) marshalResponse(out *dynamicpb.Message, responseBody string) ([]byte, error) {
+	body, err := t.marshal.Marshal(out)
+	if err != nil || responseBody == "" {
+		return body, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	if value, ok := fields[responseBody]; ok {
+		return value, nil
+	}
+	return []byte("null"), nil
+}
+
+// writeError 按照gRPC状态码映射HTTP状态码，响应体为google.rpc.Status的JSON格式
+func (t *Transcoder) writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	body, marshalErr := t.marshal.Marshal(st.Proto())
+	if marshalErr != nil {
+		// details中存在无法解析的类型时只返回状态码和信息
+		body, _ = json.Marshal(map[string]interface{}{"code": st.Code(), "message": st.Message()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(HTTPStatusFromCode(st.Code()))
+	w.Write(body)
+}
+
+// HTTPStatusFromCode gRPC状态码映射为HTTP状态码，与grpc-gateway保持一致
+func HTTPStatusFromCode(code codes.Code) int {
+	return runtime.HTTPStatusFromCode(code)
+}
+
+var errUnknownField = errors.New("unknown field")
+
+// checkFieldPath 校验注解中的字段路径是否存在
+func checkFieldPath(md protoreflect.MessageDescriptor, fieldPath string) error {
+	if fieldPath == "" || fieldPath == "*" {
+		return nil
+	}
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return errors.Errorf("field %v not found in %v", fieldPath, md.FullName())
+		}
+		if i < len(names)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return errors.Errorf("field %v is not a message", fieldPath)
+			}
+			md = fd.Message()
+		}
+	}
+	return nil
+}
+
+// setField 按照点号分隔的字段路径设置字段值，支持proto字段名和json名
+func setField(msg protoreflect.Message, fieldPath string, values []string) error {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fields := msg.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return errors.WithMessage(errUnknownField, fieldPath)
+		}
+		if i < len(names)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return errors.Errorf("field %v is not a message", fieldPath)
+			}
+			msg = msg.Mutable(fd).Message()
+			continue
+		}
+		if fd.IsMap() {
+			return errors.Errorf("map field %v is not supported in path or query", fieldPath)
+		}
+		if fd.IsList() {
+			list := msg.Mutable(fd).List()
+			for _, value := range values {
+				v, err := parseValue(fd, list.NewElement, value)
+				if err != nil {
+					return errors.WithMessage(err, fieldPath)
+				}
+				list.Append(v)
+			}
+			return nil
+		}
+		v, err := parseValue(fd, func() protoreflect.Value { return msg.NewField(fd) }, values[len(values)-1])
+		if err != nil {
+			return errors.WithMessage(err, fieldPath)
+		}
+		msg.Set(fd, v)
+	}
+	return nil
+}
+
+// parseValue 将字符串转换为字段类型的值，消息类型按照JSON字符串解析，如Timestamp、Duration和包装类型
+func parseValue(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value, value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			data, err = base64.URLEncoding.DecodeString(value)
+		}
+		return protoreflect.ValueOfBytes(data), err
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v := newValue()
+		data, _ := json.Marshal(value)
+		err := protojson.Unmarshal(data, v.Message().Interface())
+		return v, err
+	}
+	return protoreflect.Value{}, errors.Errorf("unsupported field kind %v", fd.Kind())
+}
+
+// reservedHeaders 不作为metadata转发的header
+var reservedHeaders = map[string]bool{
+	"connection":        true,
+	"content-length":    true,
+	"content-type":      true,
+	"host":              true,
+	"keep-alive":        true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// requestMetadata 将请求header转换为metadata，Grpc-Metadata-前缀的header去掉前缀转发
+func requestMetadata(req *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+		if strings.HasPrefix(key, strings.ToLower(MetadataHeaderPrefix)) {
+			key = key[len(MetadataHeaderPrefix):]
+		} else if reservedHeaders[key] || strings.HasPrefix(key, "grpc-") || strings.HasPrefix(key, "proxy-") {
+			continue
+		}
+		md.Append(key, values...)
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		md.Append("x-forwarded-for", host)
+	}
+	return md
+}
+
+// writeMetadata 将下游返回的metadata写入响应header
+func writeMetadata(w http.ResponseWriter, prefix string, md metadata.MD) {
+	for key, values := range md {
+		for _, value := range values {
+			w.Header().Add(prefix+key, value)
+		}
+	}
+}
+
+// protoCodec 使用protobuf APIv2序列化动态消息
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (protoCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,144 @@
+package grpc_transcode
+
+import (
+	"context"
+	"encoding/json"
+	pb "github.com/zhj/go_gateway/grpc_server_client/proto"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type echoServer struct {
+	pb.UnimplementedEchoServer
+}
+
+func (s *echoServer) UnaryEcho(ctx context.Context, in *pb.EchoRequest) (*pb.EchoResponse, error) {
+	if in.Message == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty message")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs("x-user", strings.Join(md.Get("x-user"), ",")))
+	return &pb.EchoResponse{Message: in.Message}, nil
+}
+
+// echoDescriptorSet 使用已注册的echo描述生成描述文件集合，不包含依赖
+func echoDescriptorSet(t *testing.T) []byte {
+	fd, err := protoregistry.GlobalFiles.FindFileByPath("echo-gateway.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(fd)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func startEchoServer(t *testing.T) *grpc.ClientConn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterEchoServer(server, &echoServer{})
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestTranscoderRoutes(t *testing.T) {
+	transcoder, err := NewTranscoder(echoDescriptorSet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := transcoder.Routes()
+	// 流式方法不参与转码
+	if len(routes) != 1 || routes[0].HttpMethod != "POST" || routes[0].Path != "/v1/example/echo" ||
+		routes[0].GrpcMethod != "/echo.Echo/UnaryEcho" {
+		t.Fatalf("unexpected routes %v", routes)
+	}
+	if _, err := NewTranscoder([]byte("invalid")); err == nil {
+		t.Fatal("invalid descriptor set should fail")
+	}
+
+	// body只能是顶层字段或者*
+	fd, err := protoregistry.GlobalFiles.FindFileByPath("echo-gateway.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdProto := protodesc.ToFileDescriptorProto(fd)
+	for _, method := range fdProto.Service[0].Method {
+		if method.GetName() == "UnaryEcho" {
+			proto.SetExtension(method.Options, annotations.E_Http, &annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Post{Post: "/v1/example/echo"},
+				Body:    "message.text",
+			})
+		}
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fdProto}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTranscoder(data); err == nil || !strings.Contains(err.Error(), "top-level") {
+		t.Fatalf("nested body should fail, got %v", err)
+	}
+}
+
+func TestTranscoderServeHTTP(t *testing.T) {
+	transcoder, err := NewTranscoder(echoDescriptorSet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := startEchoServer(t)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", "tom")
+		w := httptest.NewRecorder()
+		transcoder.ServeHTTP(w, req, conn)
+		return w
+	}
+
+	w := serve("POST", "/v1/example/echo", `{"message":"hello"}`)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"message":"hello"}` {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	if user := w.Header().Get(MetadataHeaderPrefix + "x-user"); user != "tom" {
+		t.Fatalf("header metadata not forwarded: %v", w.Header())
+	}
+
+	w = serve("POST", "/v1/example/echo", `{}`)
+	result := map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusBadRequest || result["code"] != float64(codes.InvalidArgument) || result["message"] != "empty message" {
+		t.Fatalf("unexpected error response %v %v", w.Code, w.Body.String())
+	}
+
+	if w = serve("POST", "/v1/example/echo", `{"message":1}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid json expect 400, got %v", w.Code)
+	}
+	if w = serve("POST", "/v1/example/echo", `{"message":"`+strings.Repeat("a", MaxBodySize)+`"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("oversized body expect 400, got %v", w.Code)
+	}
+	if w = serve("GET", "/v1/example/echo", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown route expect 404, got %v", w.Code)
+	}
+}
@@ -43,7 +43,7 @@ func singleJoiningSlash(a, b string) string {
 	return a + b
 }
 
-func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport) *httputil.ReverseProxy {
+func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans http.RoundTripper) *httputil.ReverseProxy {
 	//请求协调者
 	director := func(req *http.Request) {
 		nextAddr, err := lb.Get(req.URL.String())
@@ -100,4 +100,13 @@ func NewLoadBalanceCheckConf(format string, conf map[string]string) (*LoadBalanc
 	mConf := &LoadBalanceCheckConf{format: format, activeList: aList, confIpWeight: conf}
 	mConf.WatchConf()
 	return mConf, nil
+}
+
+// NewLoadBalanceStaticConf 不做主动健康检查的配置，用于无法通过TCP拨号探测的UDP下游
+func NewLoadBalanceStaticConf(format string, conf map[string]string) (*LoadBalanceCheckConf, error) {
+	aList := []string{}
+	for item := range conf {
+		aList = append(aList, item)
+	}
+	return &LoadBalanceCheckConf{format: format, activeList: aList, confIpWeight: conf}, nil
 }
\ No newline at end of file
@@ -0,0 +1,458 @@
+package reverse_proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
+	"github.com/zhj/go_gateway/reverse_proxy/resp"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedisReverseProxy 按照RESP协议解析客户端命令的TCP反向代理
+// 每条命令经过命令控制后转发给下游，并读取一个完整的返回值再处理下一条命令
+// 配置从节点时只读命令发往从节点，事务和WATCH期间的命令固定发往主节点
+type RedisReverseProxy struct {
+	Primary        load_balance.LoadBalance
+	Replica        load_balance.LoadBalance //为空时不做读写分离
+	DialTimeout    time.Duration
+	TLSConfig      *tls.Config //不为空时使用TLS连接下游
+	CommandAllowed func(name string) bool
+	OnCommand      func(name string) //命令计数，name为resp.CounterName返回的名称
+	ServiceName    string            //不为空时按服务和下游统计连接、流量和拨号情况
+}
+
+func NewRedisLoadBalanceReverseProxy(primary, replica load_balance.LoadBalance) *RedisReverseProxy {
+	return &RedisReverseProxy{
+		Primary:     primary,
+		Replica:     replica,
+		DialTimeout: time.Second,
+	}
+}
+
+func (dp *RedisReverseProxy) dialTimeout() time.Duration {
+	if dp.DialTimeout > 0 {
+		return dp.DialTimeout
+	}
+	return 10 * time.Second
+}
+
+func (dp *RedisReverseProxy) commandAllowed(name string) bool {
+	if dp.CommandAllowed == nil {
+		return true
+	}
+	return dp.CommandAllowed(name)
+}
+
+func (dp *RedisReverseProxy) onCommand(name string) {
+	if dp.OnCommand != nil {
+		dp.OnCommand(resp.CounterName(name))
+	}
+}
+
+// deniedMessage 被拒绝的命令返回给客户端的错误
+func deniedMessage(name string) string {
+	return fmt.Sprintf("NOPERM command '%s' is not allowed by gateway", strings.ToLower(name))
+}
+
+// dial 从负载均衡器选择下游并建立连接
+func (dp *RedisReverseProxy) dial(lb load_balance.LoadBalance, clientIP string) (*redisBackend, error) {
+	addr, err := lb.Get(clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if addr == "" {
+		return nil, errors.New("no available upstream")
+	}
+	dialStart := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, dp.dialTimeout())
+	tcpConnStats(dp.ServiceName, addr).ObserveDial(time.Since(dialStart), err)
+	if err != nil {
+		return nil, err
+	}
+	if dp.TLSConfig != nil {
+		config := dp.TLSConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(dp.dialTimeout()))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+	stats := dp.backendStats(addr)
+	stats.ConnOpen()
+	conn = &countConn{Conn: conn, onRead: stats.AddBytesOut, onWrite: stats.AddBytesIn}
+	return &redisBackend{addr: addr, conn: conn, reader: resp.NewReader(conn), stats: stats}, nil
+}
+
+// serviceStats 服务的统计项，记录客户端连接
+func (dp *RedisReverseProxy) serviceStats() public.TcpConnStatGroup {
+	if dp.ServiceName == "" {
+		return nil
+	}
+	return public.TcpConnStatGroup{public.TcpConnStatHandler.GetStat(dp.ServiceName)}
+}
+
+// backendStats 下游的统计项，记录到下游的连接，主节点和从节点的连接分别计入各自的下游
+func (dp *RedisReverseProxy) backendStats(addr string) public.TcpConnStatGroup {
+	if dp.ServiceName == "" {
+		return nil
+	}
+	return public.TcpConnStatGroup{public.TcpConnStatHandler.GetStat(public.TcpConnStatName(dp.ServiceName, addr))}
+}
+
+// ServeTCP 读取客户端命令并转发给下游
+func (dp *RedisReverseProxy) ServeTCP(ctx context.Context, src net.Conn) {
+	clientIP, _, _ := net.SplitHostPort(src.RemoteAddr().String())
+	stats := dp.serviceStats()
+	stats.ConnOpen()
+	defer stats.ConnClose()
+	src = &countConn{Conn: src, onRead: stats.AddBytesIn, onWrite: stats.AddBytesOut}
+	s := &redisSession{
+		proxy:    dp,
+		src:      src,
+		clientIP: clientIP,
+		reader:   resp.NewReader(src),
+	}
+	defer s.close()
+	for {
+		cmd, err := s.reader.ReadCommand()
+		if err != nil {
+			if err == resp.ErrProtocol {
+				resp.WriteError(src, "ERR Protocol error")
+			}
+			return
+		}
+		name := cmd.Name()
+		dp.onCommand(name)
+		if !dp.commandAllowed(name) {
+			// 事务中被拒绝的命令使整个事务在EXEC时失败，与redis的行为一致
+			if s.inMulti {
+				s.multiAborted = true
+			}
+			if err := resp.WriteError(src, deniedMessage(name)); err != nil {
+				return
+			}
+			continue
+		}
+		if !s.handle(name, cmd) {
+			return
+		}
+	}
+}
+
+// redisBackend 到下游的一个连接
+type redisBackend struct {
+	addr   string
+	conn   net.Conn
+	reader *resp.Reader
+	stats  public.TcpConnStatGroup
+}
+
+func (b *redisBackend) close() {
+	b.conn.Close()
+	b.stats.ConnClose()
+}
+
+// do 发送命令并读取一个完整的返回值
+func (b *redisBackend) do(cmd *resp.Command) ([]byte, error) {
+	if _, err := b.conn.Write(cmd.Bytes()); err != nil {
+		return nil, err
+	}
+	return b.reader.ReadReply()
+}
+
+// redisSession 一个客户端连接的代理状态
+type redisSession struct {
+	proxy    *RedisReverseProxy
+	src      net.Conn
+	clientIP string
+	reader   *resp.Reader
+	primary  *redisBackend
+	replica  *redisBackend
+	// 从节点出错后不再使用，避免每条只读命令都重新建立连接
+	replicaDown bool
+
+	// 影响连接状态的命令，新建从节点连接时按顺序重放
+	sessionCmds  []*resp.Command
+	inMulti      bool
+	multiAborted bool
+	watching     bool
+}
+
+// handle 转发一条命令，返回false时关闭客户端连接
+func (s *redisSession) handle(name string, cmd *resp.Command) bool {
+	switch name {
+	case "QUIT":
+		resp.WriteStatus(s.src, "OK")
+		return false
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE", "MONITOR":
+		return s.passthrough(cmd)
+	case "EXEC":
+		if s.multiAborted {
+			return s.abortMulti()
+		}
+	}
+
+	if s.proxy.Replica != nil && !s.replicaDown && resp.IsReadCommand(name) && !s.inMulti && !s.watching {
+		reply, err := s.doReplica(cmd)
+		if err == nil {
+			_, err = s.src.Write(reply)
+			return err == nil
+		}
+		// 只读命令可以安全地在主节点重试，之后该连接的命令都发往主节点
+		log.Printf("redisproxy: for incoming conn %v, replica error: %v", s.src.RemoteAddr(), err)
+		s.closeReplica()
+		s.replicaDown = true
+	}
+
+	if err := s.dialPrimary(); err != nil {
+		log.Printf("redisproxy: for incoming conn %v, error dialing primary: %v", s.src.RemoteAddr(), err)
+		return resp.WriteError(s.src, "ERR gateway upstream unavailable") == nil
+	}
+	reply, err := s.primary.do(cmd)
+	if err != nil {
+		// 命令可能已经在下游执行，连接状态无法恢复，关闭客户端连接
+		log.Printf("redisproxy: for incoming conn %v, primary %v error: %v", s.src.RemoteAddr(), s.primary.addr, err)
+		resp.WriteError(s.src, "ERR gateway upstream error")
+		return false
+	}
+	if _, err := s.src.Write(reply); err != nil {
+		return false
+	}
+	if !resp.IsError(reply) {
+		s.afterCommand(name, cmd)
+	}
+	return true
+}
+
+// dialPrimary 首次向主节点发送命令时建立连接
+func (s *redisSession) dialPrimary() error {
+	if s.primary != nil {
+		return nil
+	}
+	primary, err := s.proxy.dial(s.proxy.Primary, s.clientIP)
+	if err != nil {
+		return err
+	}
+	s.primary = primary
+	return nil
+}
+
+// afterCommand 命令执行成功后更新连接状态
+func (s *redisSession) afterCommand(name string, cmd *resp.Command) {
+	switch name {
+	case "MULTI":
+		s.inMulti = true
+	case "EXEC", "DISCARD":
+		s.inMulti, s.multiAborted, s.watching = false, false, false
+	case "WATCH":
+		s.watching = true
+	case "UNWATCH":
+		s.watching = false
+	case "RESET":
+		s.inMulti, s.multiAborted, s.watching = false, false, false
+		s.sessionCmds = nil
+		s.closeReplica()
+		s.replicaDown = false
+	case "AUTH", "SELECT", "HELLO":
+		s.rememberSessionCmd(cmd)
+	case "CLIENT":
+		if cmd.SubName() == "SETNAME" {
+			s.rememberSessionCmd(cmd)
+		}
+	}
+}
+
+// rememberSessionCmd 记录影响连接状态的命令，已经建立的从节点连接同步执行
+func (s *redisSession) rememberSessionCmd(cmd *resp.Command) {
+	s.sessionCmds = append(s.sessionCmds, cmd)
+	if s.replica == nil {
+		return
+	}
+	if reply, err := s.replica.do(cmd); err != nil || resp.IsError(reply) {
+		s.closeReplica()
+		s.replicaDown = true
+	}
+}
+
+// doReplica 在从节点执行只读命令，首次使用时建立连接并重放连接状态
+func (s *redisSession) doReplica(cmd *resp.Command) ([]byte, error) {
+	if s.replica == nil {
+		replica, err := s.proxy.dial(s.proxy.Replica, s.clientIP)
+		if err != nil {
+			return nil, err
+		}
+		for _, sessionCmd := range s.sessionCmds {
+			reply, err := replica.do(sessionCmd)
+			if err == nil && resp.IsError(reply) {
+				err = fmt.Errorf("replay %s: %s", sessionCmd.Name(), strings.TrimSpace(string(reply)))
+			}
+			if err != nil {
+				replica.close()
+				return nil, err
+			}
+		}
+		s.replica = replica
+	}
+	return s.replica.do(cmd)
+}
+
+// abortMulti 事务中有被拒绝的命令，放弃下游的事务并返回EXECABORT
+func (s *redisSession) abortMulti() bool {
+	s.inMulti, s.multiAborted, s.watching = false, false, false
+	if s.primary != nil {
+		if _, err := s.primary.do(resp.NewCommand("DISCARD")); err != nil {
+			return false
+		}
+	}
+	return resp.WriteError(s.src, "EXECABORT Transaction discarded because of previous errors.") == nil
+}
+
+// passthrough 订阅和MONITOR之后下游会主动推送数据，命令和返回值不再一一对应，切换为推送模式直到连接关闭
+// 推送模式下客户端命令仍然逐条解析并经过命令控制，下游的返回值逐个转发给客户端
+func (s *redisSession) passthrough(cmd *resp.Command) bool {
+	if err := s.dialPrimary(); err != nil {
+		log.Printf("redisproxy: for incoming conn %v, error dialing primary: %v", s.src.RemoteAddr(), err)
+		return resp.WriteError(s.src, "ERR gateway upstream unavailable") == nil
+	}
+	if _, err := s.primary.conn.Write(cmd.Bytes()); err != nil {
+		return false
+	}
+	denied := &redisDeniedQueue{}
+	errc := make(chan error, 2)
+	go func() {
+		errc <- s.forwardCommands(denied)
+	}()
+	go func() {
+		errc <- s.forwardReplies(denied)
+	}()
+	<-errc
+	return false
+}
+
+// forwardCommands 推送模式下转发客户端命令，被拒绝的命令替换为PING占位
+// 本地直接返回错误会与下游尚未返回的结果顺序错乱，占位的返回值由forwardReplies替换为错误
+func (s *redisSession) forwardCommands(denied *redisDeniedQueue) error {
+	for {
+		cmd, err := s.reader.ReadCommand()
+		if err != nil {
+			return err
+		}
+		name := cmd.Name()
+		s.proxy.onCommand(name)
+		if !s.proxy.commandAllowed(name) {
+			cmd = denied.push(deniedMessage(name))
+		} else if name == "MULTI" {
+			// 推送模式下无法在EXEC时放弃包含被拒绝命令的事务，不支持事务
+			cmd = denied.push("NOPERM command 'multi' is not allowed by gateway after subscribe or monitor")
+		}
+		if _, err := s.primary.conn.Write(cmd.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// forwardReplies 推送模式下逐个转发下游的返回值和推送消息
+func (s *redisSession) forwardReplies(denied *redisDeniedQueue) error {
+	for {
+		reply, err := s.primary.reader.ReadReply()
+		if err != nil {
+			return err
+		}
+		if msg, ok := denied.match(reply); ok {
+			err = resp.WriteError(s.src, msg)
+		} else {
+			_, err = s.src.Write(reply)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// redisDeniedQueue 推送模式下被拒绝的命令，按发送顺序排列
+type redisDeniedQueue struct {
+	mu    sync.Mutex
+	seq   int
+	items []redisDenied
+}
+
+type redisDenied struct {
+	token string
+	msg   string
+}
+
+// push 记录被拒绝的命令，返回代替它发往下游的PING命令
+func (q *redisDeniedQueue) push(msg string) *resp.Command {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	token := fmt.Sprintf("gateway-denied-%d", q.seq)
+	q.items = append(q.items, redisDenied{token: token, msg: msg})
+	return resp.NewCommand("PING", token)
+}
+
+// match 返回值是否为最早一个占位PING的返回值，RESP2订阅状态下PING返回数组，其他情况返回bulk string
+func (q *redisDeniedQueue) match(reply []byte) (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return "", false
+	}
+	head := q.items[0]
+	bulk := fmt.Sprintf("$%d\r\n%s\r\n", len(head.token), head.token)
+	if string(reply) != bulk && string(reply) != "*2\r\n$4\r\npong\r\n"+bulk {
+		return "", false
+	}
+	q.items = q.items[1:]
+	return head.msg, true
+}
+
+// countConn 读写时实时累计流量
+type countConn struct {
+	net.Conn
+	onRead  func(n int64)
+	onWrite func(n int64)
+}
+
+func (c *countConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.onRead(int64(n))
+	}
+	return n, err
+}
+
+func (c *countConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.onWrite(int64(n))
+	}
+	return n, err
+}
+
+func (s *redisSession) closeReplica() {
+	if s.replica != nil {
+		s.replica.close()
+		s.replica = nil
+	}
+}
+
+func (s *redisSession) close() {
+	if s.primary != nil {
+		s.primary.close()
+	}
+	s.closeReplica()
+}
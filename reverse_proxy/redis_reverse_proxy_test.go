@@ -0,0 +1,266 @@
+package reverse_proxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
+	"github.com/zhj/go_gateway/reverse_proxy/resp"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRedisStore 进程内RESP服务器的数据，只实现测试用到的命令
+type testRedisStore struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (s *testRedisStore) exec(cmd *resp.Command) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch cmd.Name() {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		s.data[string(cmd.Args[1])] = string(cmd.Args[2])
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.data[string(cmd.Args[1])]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SELECT", "WATCH", "UNWATCH":
+		return "+OK\r\n"
+	case "FLUSHALL":
+		s.data = map[string]string{}
+		return "+OK\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd.Name())
+}
+
+func (s *testRedisStore) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := resp.NewReader(conn)
+	var queued []*resp.Command
+	inMulti, subscribed := false, false
+	for {
+		cmd, err := reader.ReadCommand()
+		if err != nil {
+			return
+		}
+		var reply string
+		switch cmd.Name() {
+		case "MULTI":
+			inMulti, queued = true, nil
+			reply = "+OK\r\n"
+		case "DISCARD":
+			inMulti, queued = false, nil
+			reply = "+OK\r\n"
+		case "EXEC":
+			reply = fmt.Sprintf("*%d\r\n", len(queued))
+			for _, item := range queued {
+				reply += s.exec(item)
+			}
+			inMulti, queued = false, nil
+		case "SUBSCRIBE":
+			channel := string(cmd.Args[1])
+			subscribed = true
+			reply = fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(channel), channel)
+			reply += fmt.Sprintf("*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$5\r\nhello\r\n", len(channel), channel)
+		case "UNSUBSCRIBE":
+			subscribed = false
+			reply = "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n"
+		case "PING":
+			// RESP2订阅状态下PING返回数组
+			if len(cmd.Args) > 1 && subscribed {
+				reply = fmt.Sprintf("*2\r\n$4\r\npong\r\n$%d\r\n%s\r\n", len(cmd.Args[1]), cmd.Args[1])
+			} else if len(cmd.Args) > 1 {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(cmd.Args[1]), cmd.Args[1])
+			} else {
+				reply = "+PONG\r\n"
+			}
+		default:
+			if inMulti {
+				queued = append(queued, cmd)
+				reply = "+QUEUED\r\n"
+			} else {
+				reply = s.exec(cmd)
+			}
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func startTestRedisServer(t *testing.T, data map[string]string) load_balance.LoadBalance {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	store := &testRedisStore{data: data}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go store.serve(conn)
+		}
+	}()
+	lb := &load_balance.RoundRobinBalance{}
+	lb.Add(l.Addr().String())
+	return lb
+}
+
+type testRedisClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *resp.Reader
+}
+
+func (c *testRedisClient) do(args ...string) string {
+	if _, err := c.conn.Write(resp.NewCommand(args...).Bytes()); err != nil {
+		c.t.Fatal(err)
+	}
+	reply, err := c.reader.ReadReply()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return string(reply)
+}
+
+func startTestRedisProxy(t *testing.T, proxy *RedisReverseProxy) *testRedisClient {
+	clientConn, proxyConn := net.Pipe()
+	go func() {
+		proxy.ServeTCP(context.Background(), proxyConn)
+		proxyConn.Close()
+	}()
+	t.Cleanup(func() { clientConn.Close() })
+	return &testRedisClient{t: t, conn: clientConn, reader: resp.NewReader(clientConn)}
+}
+
+func TestRedisReverseProxyCommandACL(t *testing.T) {
+	primary := startTestRedisServer(t, map[string]string{})
+	proxy := NewRedisLoadBalanceReverseProxy(primary, nil)
+	proxy.CommandAllowed = func(name string) bool { return name != "FLUSHALL" && name != "KEYS" }
+	var mu sync.Mutex
+	counts := map[string]int{}
+	proxy.OnCommand = func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[name]++
+	}
+	client := startTestRedisProxy(t, proxy)
+
+	if reply := client.do("SET", "k", "v"); reply != "+OK\r\n" {
+		t.Fatalf("SET reply = %q", reply)
+	}
+	if reply := client.do("flushall"); !strings.HasPrefix(reply, "-NOPERM") {
+		t.Fatalf("FLUSHALL reply = %q, want NOPERM", reply)
+	}
+	if reply := client.do("GET", "k"); reply != "$1\r\nv\r\n" {
+		t.Fatalf("GET reply = %q, FLUSHALL should not reach upstream", reply)
+	}
+	if reply := client.do("NOSUCHCMD"); !strings.HasPrefix(reply, "-ERR unknown command") {
+		t.Fatalf("unknown command reply = %q", reply)
+	}
+
+	// 事务中被拒绝的命令使EXEC失败
+	client.do("MULTI")
+	client.do("SET", "k", "v2")
+	client.do("FLUSHALL")
+	if reply := client.do("EXEC"); !strings.HasPrefix(reply, "-EXECABORT") {
+		t.Fatalf("EXEC reply = %q, want EXECABORT", reply)
+	}
+	if reply := client.do("GET", "k"); reply != "$1\r\nv\r\n" {
+		t.Fatalf("GET after aborted transaction = %q", reply)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"set": 2, "get": 2, "flushall": 2, "other": 1, "multi": 1, "exec": 1}
+	for name, count := range want {
+		if counts[name] != count {
+			t.Errorf("count[%s] = %d, want %d", name, counts[name], count)
+		}
+	}
+}
+
+func TestRedisReverseProxyReadWriteSplit(t *testing.T) {
+	primary := startTestRedisServer(t, map[string]string{"k": "primary"})
+	replica := startTestRedisServer(t, map[string]string{"k": "replica"})
+	client := startTestRedisProxy(t, NewRedisLoadBalanceReverseProxy(primary, replica))
+
+	if reply := client.do("SELECT", "1"); reply != "+OK\r\n" {
+		t.Fatalf("SELECT reply = %q", reply)
+	}
+	if reply := client.do("GET", "k"); reply != "$7\r\nreplica\r\n" {
+		t.Fatalf("GET reply = %q, want replica", reply)
+	}
+	if reply := client.do("SET", "k", "written"); reply != "+OK\r\n" {
+		t.Fatalf("SET reply = %q", reply)
+	}
+	// WATCH之后的读命令需要在主节点执行
+	client.do("WATCH", "k")
+	if reply := client.do("GET", "k"); reply != "$7\r\nwritten\r\n" {
+		t.Fatalf("GET after WATCH = %q, want primary", reply)
+	}
+	client.do("MULTI")
+	client.do("GET", "k")
+	if reply := client.do("EXEC"); reply != "*1\r\n$7\r\nwritten\r\n" {
+		t.Fatalf("EXEC reply = %q", reply)
+	}
+	if reply := client.do("GET", "k"); reply != "$7\r\nreplica\r\n" {
+		t.Fatalf("GET after EXEC = %q, want replica", reply)
+	}
+}
+
+func TestRedisReverseProxySubscribe(t *testing.T) {
+	primary := startTestRedisServer(t, map[string]string{})
+	client := startTestRedisProxy(t, NewRedisLoadBalanceReverseProxy(primary, nil))
+	if reply := client.do("SUBSCRIBE", "news"); !strings.Contains(reply, "subscribe") {
+		t.Fatalf("SUBSCRIBE reply = %q", reply)
+	}
+	message, err := client.reader.ReadReply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(message), "$5\r\nhello\r\n") {
+		t.Fatalf("pushed message = %q", message)
+	}
+}
+
+func TestRedisReverseProxySubscribeCommandACL(t *testing.T) {
+	primary := startTestRedisServer(t, map[string]string{"k": "v"})
+	proxy := NewRedisLoadBalanceReverseProxy(primary, nil)
+	proxy.CommandAllowed = func(name string) bool { return name != "FLUSHALL" }
+	client := startTestRedisProxy(t, proxy)
+	client.do("SUBSCRIBE", "news")
+	if _, err := client.reader.ReadReply(); err != nil {
+		t.Fatal(err)
+	}
+	// 订阅状态下被拒绝的命令按顺序返回错误
+	client.conn.Write(append(resp.NewCommand("PING", "a").Bytes(), resp.NewCommand("FLUSHALL").Bytes()...))
+	if reply, _ := client.reader.ReadReply(); string(reply) != "*2\r\n$4\r\npong\r\n$1\r\na\r\n" {
+		t.Fatalf("PING reply = %q", reply)
+	}
+	if reply, _ := client.reader.ReadReply(); !strings.HasPrefix(string(reply), "-NOPERM") {
+		t.Fatalf("FLUSHALL reply = %q, want NOPERM", reply)
+	}
+	// 退订后仍然经过命令控制
+	client.do("UNSUBSCRIBE")
+	if reply := client.do("FLUSHALL"); !strings.HasPrefix(reply, "-NOPERM") {
+		t.Fatalf("FLUSHALL after UNSUBSCRIBE = %q, want NOPERM", reply)
+	}
+	if reply := client.do("MULTI"); !strings.HasPrefix(reply, "-NOPERM") {
+		t.Fatalf("MULTI after UNSUBSCRIBE = %q, want NOPERM", reply)
+	}
+	if reply := client.do("GET", "k"); reply != "$1\r\nv\r\n" {
+		t.Fatalf("GET reply = %q, FLUSHALL should not reach upstream", reply)
+	}
+}
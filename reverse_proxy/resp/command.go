@@ -0,0 +1,70 @@
+package resp
+
+import "strings"
+
+// commandOther 不在命令表中的命令统一计数，避免客户端发送任意命令名产生大量计数器
+const commandOther = "other"
+
+// readCommands 不修改数据的命令，开启读写分离时可以发往从节点
+var readCommands = []string{
+	"GET", "MGET", "GETRANGE", "SUBSTR", "STRLEN", "GETBIT", "BITCOUNT", "BITPOS", "LCS",
+	"EXISTS", "TYPE", "TTL", "PTTL", "EXPIRETIME", "PEXPIRETIME", "DUMP", "OBJECT",
+	"KEYS", "SCAN", "RANDOMKEY", "DBSIZE", "TOUCH",
+	"HGET", "HMGET", "HGETALL", "HKEYS", "HVALS", "HLEN", "HEXISTS", "HSTRLEN", "HSCAN", "HRANDFIELD",
+	"LRANGE", "LLEN", "LINDEX", "LPOS",
+	"SMEMBERS", "SISMEMBER", "SMISMEMBER", "SCARD", "SRANDMEMBER", "SINTER", "SINTERCARD", "SUNION", "SDIFF", "SSCAN",
+	"ZRANGE", "ZREVRANGE", "ZRANGEBYSCORE", "ZREVRANGEBYSCORE", "ZRANGEBYLEX", "ZREVRANGEBYLEX",
+	"ZSCORE", "ZMSCORE", "ZCARD", "ZCOUNT", "ZLEXCOUNT", "ZRANK", "ZREVRANK", "ZSCAN", "ZRANDMEMBER",
+	"ZINTER", "ZUNION", "ZDIFF", "ZINTERCARD",
+	"PFCOUNT", "GEOPOS", "GEODIST", "GEOHASH", "GEORADIUS_RO", "GEORADIUSBYMEMBER_RO", "GEOSEARCH",
+	"XRANGE", "XREVRANGE", "XLEN", "XINFO", "XPENDING",
+}
+
+// writeCommands 修改数据或需要在主节点执行的命令
+var writeCommands = []string{
+	"SET", "SETNX", "SETEX", "PSETEX", "MSET", "MSETNX", "GETSET", "GETDEL", "GETEX", "SETRANGE", "APPEND",
+	"INCR", "INCRBY", "INCRBYFLOAT", "DECR", "DECRBY", "SETBIT", "BITOP", "BITFIELD",
+	"DEL", "UNLINK", "EXPIRE", "PEXPIRE", "EXPIREAT", "PEXPIREAT", "PERSIST", "RENAME", "RENAMENX",
+	"MOVE", "COPY", "RESTORE", "SORT",
+	"HSET", "HSETNX", "HMSET", "HDEL", "HINCRBY", "HINCRBYFLOAT",
+	"LPUSH", "RPUSH", "LPUSHX", "RPUSHX", "LPOP", "RPOP", "LSET", "LREM", "LTRIM", "LINSERT", "LMOVE", "RPOPLPUSH",
+	"BLPOP", "BRPOP", "BLMOVE", "BRPOPLPUSH", "LMPOP", "BLMPOP",
+	"SADD", "SREM", "SPOP", "SMOVE", "SINTERSTORE", "SUNIONSTORE", "SDIFFSTORE",
+	"ZADD", "ZREM", "ZINCRBY", "ZPOPMIN", "ZPOPMAX", "BZPOPMIN", "BZPOPMAX", "ZMPOP", "BZMPOP",
+	"ZREMRANGEBYRANK", "ZREMRANGEBYSCORE", "ZREMRANGEBYLEX", "ZRANGESTORE",
+	"ZINTERSTORE", "ZUNIONSTORE", "ZDIFFSTORE",
+	"PFADD", "PFMERGE", "GEOADD", "GEORADIUS", "GEORADIUSBYMEMBER", "GEOSEARCHSTORE",
+	"XADD", "XDEL", "XTRIM", "XREAD", "XREADGROUP", "XGROUP", "XACK", "XCLAIM", "XAUTOCLAIM",
+	"EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "SCRIPT", "FCALL", "FCALL_RO", "FUNCTION",
+	"PUBLISH", "SPUBLISH", "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE", "SUNSUBSCRIBE", "PUBSUB",
+	"MULTI", "EXEC", "DISCARD", "WATCH", "UNWATCH",
+	"AUTH", "SELECT", "HELLO", "PING", "ECHO", "QUIT", "RESET", "CLIENT", "READONLY", "READWRITE",
+	"INFO", "CONFIG", "FLUSHDB", "FLUSHALL", "SAVE", "BGSAVE", "BGREWRITEAOF", "LASTSAVE", "SHUTDOWN",
+	"DEBUG", "MONITOR", "SLOWLOG", "LATENCY", "MEMORY", "COMMAND", "TIME", "ROLE", "REPLICAOF", "SLAVEOF",
+	"SWAPDB", "WAIT", "ACL", "MODULE", "CLUSTER",
+}
+
+var commandTable = map[string]bool{}
+
+func init() {
+	for _, name := range writeCommands {
+		commandTable[name] = false
+	}
+	for _, name := range readCommands {
+		commandTable[name] = true
+	}
+}
+
+// IsReadCommand 命令是否为只读命令
+func IsReadCommand(name string) bool {
+	return commandTable[strings.ToUpper(name)]
+}
+
+// CounterName 命令计数使用的名称，命令表之外的命令统一计为other
+func CounterName(name string) string {
+	name = strings.ToUpper(name)
+	if _, ok := commandTable[name]; !ok {
+		return commandOther
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,250 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBulkLen 单个bulk string的最大长度，与redis的proto-max-bulk-len默认值一致
+	maxBulkLen = 512 * 1024 * 1024
+	// maxArrayLen 客户端命令的最大参数个数
+	maxArrayLen = 1024 * 1024
+	// maxInlineLen inline命令的最大长度
+	maxInlineLen = 64 * 1024
+	// maxDepth 下游返回的嵌套数组的最大深度
+	maxDepth = 32
+)
+
+// ErrProtocol 数据不符合RESP协议，连接无法继续使用
+var ErrProtocol = errors.New("resp: protocol error")
+
+// Command 客户端发送的一条命令
+type Command struct {
+	Args [][]byte
+}
+
+// Name 大写的命令名
+func (c *Command) Name() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return strings.ToUpper(string(c.Args[0]))
+}
+
+// SubName 大写的子命令名，例如 CLIENT SETNAME 中的 SETNAME
+func (c *Command) SubName() string {
+	if len(c.Args) < 2 {
+		return ""
+	}
+	return strings.ToUpper(string(c.Args[1]))
+}
+
+// Bytes 将命令编码为RESP数组，inline命令也以数组形式发往下游
+func (c *Command) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "*%d\r\n", len(c.Args))
+	for _, arg := range c.Args {
+		fmt.Fprintf(buf, "$%d\r\n", len(arg))
+		buf.Write(arg)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// NewCommand 通过参数构造命令
+func NewCommand(args ...string) *Command {
+	cmd := &Command{}
+	for _, arg := range args {
+		cmd.Args = append(cmd.Args, []byte(arg))
+	}
+	return cmd
+}
+
+// Reader 从连接中读取命令或返回值
+type Reader struct {
+	br *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReader(r)}
+}
+
+// ReadCommand 读取一条客户端命令，支持RESP数组和inline命令，空行会被跳过
+func (r *Reader) ReadCommand() (*Command, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '*' {
+			if len(line) > maxInlineLen {
+				return nil, ErrProtocol
+			}
+			fields := bytes.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			return &Command{Args: fields}, nil
+		}
+		n, err := parseLen(line[1:], maxArrayLen)
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			continue
+		}
+		cmd := &Command{Args: make([][]byte, 0, n)}
+		for i := 0; i < n; i++ {
+			line, err := r.readLine()
+			if err != nil {
+				return nil, err
+			}
+			if len(line) == 0 || line[0] != '$' {
+				return nil, ErrProtocol
+			}
+			size, err := parseLen(line[1:], maxBulkLen)
+			if err != nil || size < 0 {
+				return nil, ErrProtocol
+			}
+			arg, err := r.readBulk(size)
+			if err != nil {
+				return nil, err
+			}
+			cmd.Args = append(cmd.Args, arg)
+		}
+		return cmd, nil
+	}
+}
+
+// ReadReply 读取下游的一个完整返回值并返回原始字节，支持RESP2和RESP3的类型
+func (r *Reader) ReadReply() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := r.readReply(buf, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *Reader) readReply(buf *bytes.Buffer, depth int) error {
+	if depth > maxDepth {
+		return ErrProtocol
+	}
+	line, err := r.readLine()
+	if err != nil {
+		return err
+	}
+	if len(line) == 0 {
+		return ErrProtocol
+	}
+	buf.Write(line)
+	buf.WriteString("\r\n")
+	switch line[0] {
+	case '+', '-', ':', '_', ',', '#', '(':
+		return nil
+	case '$', '!', '=':
+		size, err := parseLen(line[1:], maxBulkLen)
+		if err != nil {
+			return err
+		}
+		if size < 0 {
+			return nil
+		}
+		data, err := r.readBulk(size)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteString("\r\n")
+		return nil
+	case '*', '~', '>', '%', '|':
+		n, err := parseLen(line[1:], maxArrayLen)
+		if err != nil {
+			return err
+		}
+		// map和attribute的每一项包括key和value
+		if line[0] == '%' || line[0] == '|' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			if err := r.readReply(buf, depth+1); err != nil {
+				return err
+			}
+		}
+		// attribute之后才是真正的返回值
+		if line[0] == '|' {
+			return r.readReply(buf, depth+1)
+		}
+		return nil
+	}
+	return ErrProtocol
+}
+
+// readLine 读取以\r\n结尾的一行，返回的内容不包括\r\n
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// 超出缓冲区长度的行只可能是inline命令，继续读取直到行尾
+		long := append([]byte{}, line...)
+		for err == bufio.ErrBufferFull && len(long) <= maxInlineLen {
+			line, err = r.br.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		if err == bufio.ErrBufferFull {
+			return nil, ErrProtocol
+		}
+		line = long
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, ErrProtocol
+	}
+	return append([]byte{}, line[:len(line)-2]...), nil
+}
+
+func (r *Reader) readBulk(size int) ([]byte, error) {
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r.br, data); err != nil {
+		return nil, err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return nil, ErrProtocol
+	}
+	return data[:size], nil
+}
+
+// parseLen 解析数组或bulk string的长度，-1表示空值
+func parseLen(b []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n < -1 || n > max {
+		return 0, ErrProtocol
+	}
+	return n, nil
+}
+
+// WriteError 向客户端返回错误，msg以错误类型开头，例如 "ERR ..."
+func WriteError(w io.Writer, msg string) error {
+	_, err := io.WriteString(w, "-"+strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)+"\r\n")
+	return err
+}
+
+// WriteStatus 向客户端返回状态，例如 "OK"
+func WriteStatus(w io.Writer, status string) error {
+	_, err := io.WriteString(w, "+"+status+"\r\n")
+	return err
+}
+
+// IsError 返回值是否为错误
+func IsError(reply []byte) bool {
+	return len(reply) > 0 && (reply[0] == '-' || reply[0] == '!')
+}
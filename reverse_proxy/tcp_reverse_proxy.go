@@ -2,8 +2,11 @@ package reverse_proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"github.com/zhj/go_gateway/public"
 	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
 	"github.com/zhj/go_gateway/tcp_proxy_middleware"
+	"github.com/zhj/go_gateway/tcp_server"
 	"io"
 	"log"
 	"net"
@@ -34,6 +37,9 @@ type TcpReverseProxy struct {
 	DialContext          func(ctx context.Context, network, address string) (net.Conn, error)
 	OnDialError          func(src net.Conn, dstDialErr error)
 	ProxyProtocolVersion int
+	TLSConfig            *tls.Config //不为空时使用TLS连接下游
+	ServiceName          string      //不为空时按服务和下游统计连接、流量和拨号情况
+	HalfCloseTimeout     time.Duration //一个方向结束后等待另一个方向的最长时间，客户端连接开启空闲超时时使用空闲超时
 }
 
 func (dp *TcpReverseProxy) dialTimeout() time.Duration {
@@ -69,10 +75,13 @@ func (dp *TcpReverseProxy) ServeTCP(ctx context.Context, src net.Conn) {
 	if dp.DialTimeout >= 0 {
 		ctx, cancel = context.WithTimeout(ctx, dp.dialTimeout())
 	}
+	stats := dp.connStats()
+	dialStart := time.Now()
 	dst, err := dp.dialContext()(ctx, "tcp", dp.Addr)
 	if cancel != nil {
 		cancel()
 	}
+	stats.ObserveDial(time.Since(dialStart), err)
 	if err != nil {
 		dp.onDialError()(src, err)
 		return
@@ -80,6 +89,15 @@ func (dp *TcpReverseProxy) ServeTCP(ctx context.Context, src net.Conn) {
 
 	defer func() { go dst.Close() }() //记得退出下游连接
 
+	if dp.TLSConfig != nil {
+		tlsDst, err := dp.tlsClient(src, dst)
+		if err != nil {
+			dp.onDialError()(src, err)
+			return
+		}
+		dst = tlsDst
+	}
+
 	//设置dst的 keepAlive 参数,在数据请求之前
 	if ka := dp.keepAlivePeriod(); ka > 0 {
 		if c, ok := dst.(*net.TCPConn); ok {
@@ -87,12 +105,78 @@ func (dp *TcpReverseProxy) ServeTCP(ctx context.Context, src net.Conn) {
 			c.SetKeepAlivePeriod(ka)
 		}
 	}
-	errc := make(chan error, 1)
-	go dp.proxyCopy(errc, src, dst)
-	go dp.proxyCopy(errc, dst, src)
+	// 客户端连接开启了空闲超时时，下游连接共享活跃时间，任意方向有数据都不会超时
+	idleSrc, hasIdle := src.(*tcp_server.IdleConn)
+	if hasIdle {
+		dst = idleSrc.Join(dst)
+	}
+
+	// 流量在转发过程中实时计入统计，长连接不需要等到关闭才上报
+	stats.ConnOpen()
+	defer stats.ConnClose()
+	errc := make(chan error, 2)
+	go dp.proxyCopy(errc, src, dst, stats.AddBytesOut)
+	go dp.proxyCopy(errc, dst, src, stats.AddBytesIn)
+	// 一个方向正常结束时已经关闭了对端的写方向，继续等待另一个方向的数据转发完成
+	if err := <-errc; err != nil {
+		return
+	}
+	if !hasIdle {
+		deadline := time.Now().Add(dp.halfCloseTimeout())
+		src.SetReadDeadline(deadline)
+		dst.SetReadDeadline(deadline)
+	}
 	<-errc
 }
 
+// halfCloseTimeout 未开启空闲超时时，一个方向结束后等待另一个方向的最长时间，避免半开连接一直占用
+func (dp *TcpReverseProxy) halfCloseTimeout() time.Duration {
+	if dp.HalfCloseTimeout > 0 {
+		return dp.HalfCloseTimeout
+	}
+	return time.Minute
+}
+
+// connStats 服务和当前下游的统计项
+func (dp *TcpReverseProxy) connStats() public.TcpConnStatGroup {
+	return tcpConnStats(dp.ServiceName, dp.Addr)
+}
+
+// tcpConnStats 服务和下游的统计项，服务名为空时不统计
+func tcpConnStats(serviceName, addr string) public.TcpConnStatGroup {
+	if serviceName == "" {
+		return nil
+	}
+	return public.TcpConnStatGroup{
+		public.TcpConnStatHandler.GetStat(serviceName),
+		public.TcpConnStatHandler.GetStat(public.TcpConnStatName(serviceName, addr)),
+	}
+}
+
+// tlsClient 与下游完成TLS握手，客户端协商出的ALPN协议继续传递给下游
+func (dp *TcpReverseProxy) tlsClient(src, dst net.Conn) (net.Conn, error) {
+	config := dp.TLSConfig.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(dp.Addr)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerName = host
+	}
+	if srcTLS, ok := tcp_server.UnwrapConn(src).(*tls.Conn); ok {
+		if proto := srcTLS.ConnectionState().NegotiatedProtocol; proto != "" {
+			config.NextProtos = []string{proto}
+		}
+	}
+	tlsDst := tls.Client(dst, config)
+	tlsDst.SetDeadline(time.Now().Add(dp.dialTimeout()))
+	if err := tlsDst.Handshake(); err != nil {
+		return nil, err
+	}
+	tlsDst.SetDeadline(time.Time{})
+	return tlsDst, nil
+}
+
 func (dp *TcpReverseProxy) onDialError() func(src net.Conn, dstDialErr error) {
 	if dp.OnDialError != nil {
 		return dp.OnDialError
@@ -103,7 +187,25 @@ func (dp *TcpReverseProxy) onDialError() func(src net.Conn, dstDialErr error) {
 	}
 }
 
-func (dp *TcpReverseProxy) proxyCopy(errc chan<- error, dst, src net.Conn) {
-	_, err := io.Copy(dst, src)
+// proxyCopy src读取到EOF后关闭dst的写方向，dst不支持半关闭时返回错误，由调用方关闭整个连接
+func (dp *TcpReverseProxy) proxyCopy(errc chan<- error, dst, src net.Conn, count func(n int64)) {
+	_, err := io.Copy(&countWriter{w: dst, count: count}, src)
+	if err == nil {
+		err = tcp_server.CloseWrite(dst)
+	}
 	errc <- err
 }
+
+// countWriter 转发过程中实时累计写入的字节数，count中以原子操作累加，由统计项定时取出
+type countWriter struct {
+	w     io.Writer
+	count func(n int64)
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	if n > 0 {
+		cw.count(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,173 @@
+package reverse_proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/public/test_cert"
+	"github.com/zhj/go_gateway/tcp_server"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTcpReverseProxyConnStat(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	proxy := &TcpReverseProxy{Addr: l.Addr().String(), ServiceName: "tcp_stat_test"}
+	serviceStat := public.TcpConnStatHandler.GetStat("tcp_stat_test")
+	backendStat := public.TcpConnStatHandler.GetStat(public.TcpConnStatName("tcp_stat_test", l.Addr().String()))
+	clientConn, proxyConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		proxy.ServeTCP(context.Background(), proxyConn)
+		proxyConn.Close()
+		close(done)
+	}()
+
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := clientConn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(clientConn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("echo = %q, err = %v", buf, err)
+	}
+	if serviceStat.Active() != 1 || backendStat.Active() != 1 {
+		t.Fatalf("active = %d/%d, want 1", serviceStat.Active(), backendStat.Active())
+	}
+	clientConn.Close()
+	<-done
+	if serviceStat.Active() != 0 || backendStat.Active() != 0 {
+		t.Fatalf("active after close = %d/%d, want 0", serviceStat.Active(), backendStat.Active())
+	}
+}
+
+func TestTcpReverseProxyHalfClose(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	// 下游读取到EOF后才返回数据，代理不能在客户端关闭写方向后立即断开
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		time.Sleep(100 * time.Millisecond)
+		conn.Write(append([]byte("got:"), data...))
+	}()
+
+	frontend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer frontend.Close()
+	proxy := &TcpReverseProxy{Addr: backend.Addr().String()}
+	go func() {
+		conn, err := frontend.Accept()
+		if err != nil {
+			return
+		}
+		proxy.ServeTCP(context.Background(), conn)
+		conn.Close()
+	}()
+
+	clientConn, err := net.Dial("tcp", frontend.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	clientConn.Write([]byte("hello"))
+	clientConn.(*net.TCPConn).CloseWrite()
+	reply, err := ioutil.ReadAll(clientConn)
+	if err != nil || string(reply) != "got:hello" {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+}
+
+func TestTcpReverseProxyForwardAlpn(t *testing.T) {
+	cert := test_cert.NewServer(t, nil, "127.0.0.1").TLSCertificate()
+	backend, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"http/1.1", "h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	// 下游返回与代理协商出的ALPN协议
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		tlsConn.Write([]byte("alpn:" + tlsConn.ConnectionState().NegotiatedProtocol))
+	}()
+
+	frontend, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer frontend.Close()
+	proxy := &TcpReverseProxy{
+		Addr:      backend.Addr().String(),
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	go func() {
+		conn, err := frontend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if err := conn.(*tls.Conn).Handshake(); err != nil {
+			return
+		}
+		// 开启空闲超时的服务中客户端连接被IdleConn包装
+		proxy.ServeTCP(context.Background(), tcp_server.NewIdleConn(conn, 5*time.Second, 0))
+	}()
+
+	clientConn, err := tls.Dial("tcp", frontend.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	reply, err := ioutil.ReadAll(clientConn)
+	if string(reply) != "alpn:h2" {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+}
@@ -0,0 +1,72 @@
+package reverse_proxy
+
+import (
+	"context"
+	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
+	"github.com/zhj/go_gateway/udp_server"
+	"log"
+	"net"
+)
+
+// udpMaxPacketSize 下游返回的UDP数据包的最大长度
+const udpMaxPacketSize = 64 * 1024
+
+// UdpReverseProxy UDP反向代理，每个客户端会话在第一个数据包到达时选择下游并建立连接
+// 按照客户端IP从负载均衡器选择下游，使用一致性hash时同一客户端的会话固定到同一个下游
+type UdpReverseProxy struct {
+	lb load_balance.LoadBalance
+}
+
+func NewUdpLoadBalanceReverseProxy(lb load_balance.LoadBalance) *UdpReverseProxy {
+	return &UdpReverseProxy{lb: lb}
+}
+
+// ServeUDP 将客户端的数据包转发到会话对应的下游
+func (dp *UdpReverseProxy) ServeUDP(ctx context.Context, session *udp_server.UdpSession, packet []byte) {
+	dst, ok := session.Value().(net.Conn)
+	if !ok {
+		// 通过中间件后才登记会话并连接下游
+		if !session.Open() {
+			log.Printf(" [ERROR] udp_proxy %v open session fail, max sessions reached or server closed\n", session.RemoteAddr())
+			return
+		}
+		nextAddr, err := dp.lb.Get(session.ClientIP())
+		if err != nil || nextAddr == "" {
+			log.Printf(" [ERROR] udp_proxy %v get next addr fail:%v\n", session.RemoteAddr(), err)
+			return
+		}
+		dst, err = net.Dial("udp", nextAddr)
+		if err != nil {
+			log.Printf(" [ERROR] udp_proxy %v dial %v err:%v\n", session.RemoteAddr(), nextAddr, err)
+			return
+		}
+		if !session.SetValue(dst) {
+			return
+		}
+		go dp.copyResponse(session, dst)
+	}
+	if _, err := dst.Write(packet); err != nil {
+		log.Printf(" [ERROR] udp_proxy %v write %v err:%v\n", session.RemoteAddr(), dst.RemoteAddr(), err)
+	}
+}
+
+// copyResponse 将下游返回的数据包发送给客户端，会话关闭后下游连接被关闭，读取返回错误后退出
+func (dp *UdpReverseProxy) copyResponse(session *udp_server.UdpSession, dst net.Conn) {
+	buf := make([]byte, udpMaxPacketSize)
+	for {
+		n, err := dst.Read(buf)
+		if err != nil {
+			select {
+			case <-session.Done():
+			default:
+				// 下游端口不可达等错误，关闭会话，下一个数据包重新选择下游
+				log.Printf(" [ERROR] udp_proxy %v read %v err:%v\n", session.RemoteAddr(), dst.RemoteAddr(), err)
+				session.Close()
+			}
+			return
+		}
+		if _, err := session.Write(buf[:n]); err != nil {
+			log.Printf(" [ERROR] udp_proxy %v write back err:%v\n", session.RemoteAddr(), err)
+		}
+	}
+}
@@ -135,6 +135,62 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		controller.AppRegister(appRouter)
 	}
 
+	// 租户授权功能路由注册
+	appGrantRouter := router.Group("/app_grant")
+	// 在appGrantRouter中使用中间件
+	appGrantRouter.Use(
+		sessions.Sessions("mysession", store),
+		middleware.RecoveryMiddleware(),
+		middleware.RequestLog(),
+		middleware.SessionAuthMiddleware(),
+		middleware.TranslationMiddleware(),
+	)
+	{
+		controller.AppGrantRegister(appGrantRouter)
+	}
+
+	// 外部JWT签发方功能路由注册
+	jwtIssuerRouter := router.Group("/jwt_issuer")
+	// 在jwtIssuerRouter中使用中间件
+	jwtIssuerRouter.Use(
+		sessions.Sessions("mysession", store),
+		middleware.RecoveryMiddleware(),
+		middleware.RequestLog(),
+		middleware.SessionAuthMiddleware(),
+		middleware.TranslationMiddleware(),
+	)
+	{
+		controller.JwtIssuerRegister(jwtIssuerRouter)
+	}
+
+	// grpc方法规则功能路由注册
+	methodRuleRouter := router.Group("/method_rule")
+	// 在methodRuleRouter中使用中间件
+	methodRuleRouter.Use(
+		sessions.Sessions("mysession", store),
+		middleware.RecoveryMiddleware(),
+		middleware.RequestLog(),
+		middleware.SessionAuthMiddleware(),
+		middleware.TranslationMiddleware(),
+	)
+	{
+		controller.MethodRuleRegister(methodRuleRouter)
+	}
+
+	// 证书管理功能路由注册
+	certificateRouter := router.Group("/certificate")
+	// 在certificateRouter中使用中间件
+	certificateRouter.Use(
+		sessions.Sessions("mysession", store),
+		middleware.RecoveryMiddleware(),
+		middleware.RequestLog(),
+		middleware.SessionAuthMiddleware(),
+		middleware.TranslationMiddleware(),
+	)
+	{
+		controller.CertificateRegister(certificateRouter)
+	}
+
 	// 首页大盘部分功能路由注册
 	dashboardRouter := router.Group("/dashboard")
 	// 在dashboardRouter中使用中间件
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
+	"log"
 	"strings"
 )
 
@@ -42,6 +43,14 @@ func TCPBlackListMiddleware() func(c *TcpSliceRouterContext) {
 				return
 			}
 		}
+		// 共享端口按照SNI路由时，SNI不能在主机黑名单中
+		if serverName, ok := c.Get(public.SniContextKey).(string); ok && serviceDetail.AccessControl.OpenAuth == 1 {
+			if serviceDetail.AccessControl.MatchBlackHost(serverName) {
+				log.Printf(" [ERROR] tcp_black_host %v sni:%v in black host list\n", c.conn.RemoteAddr(), serverName)
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
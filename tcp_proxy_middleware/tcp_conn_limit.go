@@ -0,0 +1,57 @@
+package tcp_proxy_middleware
+
+import (
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/tcp_server"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// tcpServiceConns 各服务当前实例的并发连接数
+var tcpServiceConns = struct {
+	sync.Mutex
+	m map[string]*int64
+}{m: map[string]*int64{}}
+
+func tcpServiceConnCounter(serviceName string) *int64 {
+	tcpServiceConns.Lock()
+	defer tcpServiceConns.Unlock()
+	counter, ok := tcpServiceConns.m[serviceName]
+	if !ok {
+		counter = new(int64)
+		tcpServiceConns.m[serviceName] = counter
+	}
+	return counter
+}
+
+// TCPConnLimitMiddleware 服务的最大并发连接数、连接空闲超时和最大生命周期
+// 超过最大并发连接数的连接直接关闭，空闲超时在两个方向都没有数据时生效
+func TCPConnLimitMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			c.conn.Write([]byte("get service empty"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		tcpRule := serviceDetail.TCPRule
+
+		if tcpRule.MaxConns > 0 {
+			counter := tcpServiceConnCounter(serviceDetail.Info.ServiceName)
+			defer atomic.AddInt64(counter, -1)
+			if conns := atomic.AddInt64(counter, 1); conns > int64(tcpRule.MaxConns) {
+				log.Printf(" [WARN] tcp_conn_limit %v service %v max conns %v reached\n",
+					c.conn.RemoteAddr(), serviceDetail.Info.ServiceName, tcpRule.MaxConns)
+				c.Abort()
+				return
+			}
+		}
+
+		if tcpRule.IdleTimeout > 0 || tcpRule.MaxLifetime > 0 {
+			c.conn = tcp_server.NewIdleConn(c.conn, tcpRule.ConnIdleTimeout(), tcpRule.ConnMaxLifetime())
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,222 @@
+package tcp_proxy_middleware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/tcp_server"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// TcpMatchFunc 分组的匹配条件
+type TcpMatchFunc func(c *TcpSliceRouterContext) bool
+
+// 按照客户端发送的前几个字节识别的协议
+const (
+	TcpSniffTLS     = "tls"
+	TcpSniffHTTP    = "http"
+	TcpSniffSSH     = "ssh"
+	TcpSniffUnknown = "unknown"
+)
+
+// PeekTimeout 预读客户端数据的超时时间，服务端先发送数据的协议在超时后识别为unknown
+var PeekTimeout = 5 * time.Second
+
+var httpMethodPrefixes = [][]byte{
+	[]byte("GET "), []byte("POST"), []byte("PUT "), []byte("HEAD"), []byte("DELE"),
+	[]byte("OPTI"), []byte("PATC"), []byte("CONN"), []byte("TRAC"),
+	[]byte("PRI "), // HTTP/2 明文连接前言
+}
+
+// SniffProtocol 通过连接的前几个字节识别协议
+func SniffProtocol(b []byte) string {
+	if len(b) >= 3 && b[0] == 0x16 && b[1] == 0x03 {
+		return TcpSniffTLS
+	}
+	if bytes.HasPrefix(b, []byte("SSH-")) {
+		return TcpSniffSSH
+	}
+	for _, prefix := range httpMethodPrefixes {
+		if bytes.HasPrefix(b, prefix) {
+			return TcpSniffHTTP
+		}
+	}
+	return TcpSniffUnknown
+}
+
+// Peek 预读连接的前n个字节但不消费，之后中间件和核心处理函数读取连接时会重放这些数据
+func (c *TcpSliceRouterContext) Peek(n int) ([]byte, error) {
+	peekConn, ok := c.conn.(*tcp_server.PeekConn)
+	if !ok {
+		peekConn = tcp_server.NewPeekConn(c.conn)
+		c.conn = peekConn
+	}
+	return peekConn.Peek(n, PeekTimeout)
+}
+
+// Protocol 识别客户端的协议，监听器终止TLS时识别的是TLS内部的协议
+func (c *TcpSliceRouterContext) Protocol() string {
+	if c.protocol == "" {
+		b, _ := c.Peek(4)
+		c.protocol = SniffProtocol(b)
+	}
+	return c.protocol
+}
+
+// ServerName 获取客户端的SNI，依次从共享端口的SNI路由、监听器的TLS握手、预读的ClientHello中获取
+func (c *TcpSliceRouterContext) ServerName() string {
+	if c.serverName != nil {
+		return *c.serverName
+	}
+	serverName := c.readServerName()
+	c.serverName = &serverName
+	return serverName
+}
+
+func (c *TcpSliceRouterContext) readServerName() string {
+	if serverName, ok := c.Get(public.SniContextKey).(string); ok {
+		return serverName
+	}
+	if tlsConn, ok := tcp_server.UnwrapConn(c.conn).(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return ""
+		}
+		return tlsConn.ConnectionState().ServerName
+	}
+	if c.Protocol() != TcpSniffTLS {
+		return ""
+	}
+	// TLS记录头5个字节，后两个字节为记录长度，ClientHello在第一个记录中
+	header, err := c.Peek(5)
+	if err != nil {
+		return ""
+	}
+	record, err := c.Peek(5 + (int(header[3])<<8 | int(header[4])))
+	if err != nil {
+		return ""
+	}
+	serverName, err := tcp_server.ParseServerName(c.conn, record)
+	if err != nil {
+		log.Printf(" [ERROR] tcp_slice_match %v parse client hello err:%v\n", c.conn.RemoteAddr(), err)
+	}
+	return serverName
+}
+
+// MatchClientCIDR 客户端IP在任意一个网段内时匹配，也可以直接填写IP
+func MatchClientCIDR(cidrs ...string) (TcpMatchFunc, error) {
+	nets := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, err
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		nets = append(nets, ipNet)
+	}
+	return func(c *TcpSliceRouterContext) bool {
+		host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+		if err != nil {
+			return false
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, ipNet := range nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// MatchSni SNI匹配任意一个域名时匹配，支持*.example.com
+func MatchSni(hosts ...string) TcpMatchFunc {
+	return func(c *TcpSliceRouterContext) bool {
+		serverName := c.ServerName()
+		if serverName == "" {
+			return false
+		}
+		for _, host := range hosts {
+			if public.MatchServerName(host, serverName) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchProtocol 识别的协议为任意一个时匹配，协议为TcpSniff开头的常量
+func MatchProtocol(protocols ...string) TcpMatchFunc {
+	return func(c *TcpSliceRouterContext) bool {
+		protocol := c.Protocol()
+		for _, item := range protocols {
+			if item == protocol {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchLocalPort 连接的本地端口为任意一个时匹配，用于一个服务监听多个端口
+func MatchLocalPort(ports ...int) TcpMatchFunc {
+	return func(c *TcpSliceRouterContext) bool {
+		_, portStr, err := net.SplitHostPort(c.conn.LocalAddr().String())
+		if err != nil {
+			return false
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return false
+		}
+		for _, item := range ports {
+			if item == port {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TcpRouteMatchers 将服务配置的分流规则转换为分组的匹配条件
+func TcpRouteMatchers(route *dao.TcpRoute) ([]TcpMatchFunc, error) {
+	matchers := []TcpMatchFunc{}
+	if len(route.Protocols) > 0 {
+		for _, protocol := range route.Protocols {
+			switch protocol {
+			case TcpSniffTLS, TcpSniffHTTP, TcpSniffSSH, TcpSniffUnknown:
+			default:
+				return nil, fmt.Errorf("unsupported protocol %q", protocol)
+			}
+		}
+		matchers = append(matchers, MatchProtocol(route.Protocols...))
+	}
+	if len(route.ClientCIDR) > 0 {
+		matcher, err := MatchClientCIDR(route.ClientCIDR...)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	if len(route.SniHosts) > 0 {
+		matchers = append(matchers, MatchSni(route.SniHosts...))
+	}
+	if len(route.LocalPorts) > 0 {
+		matchers = append(matchers, MatchLocalPort(route.LocalPorts...))
+	}
+	return matchers, nil
+}
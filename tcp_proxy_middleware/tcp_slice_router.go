@@ -3,6 +3,7 @@ package tcp_proxy_middleware
 import (
 	"context"
 	"github.com/zhj/go_gateway/tcp_server"
+	"log"
 	"math"
 	"net"
 )
@@ -11,16 +12,18 @@ const abortIndex int8 = math.MaxInt8 / 2 //最多 63 个中间件
 
 type TcpHandlerFunc func(*TcpSliceRouterContext)
 
-// TcpSliceRouter router 结构体
+// TcpSliceRouter router 结构体，连接按照分组的添加顺序进入第一个匹配的分组
 type TcpSliceRouter struct {
 	groups []*TcpSliceGroup
 }
 
-// TcpSliceGroup  group 结构体
+// TcpSliceGroup  group 结构体，每个分组有独立的中间件和核心处理函数
 type TcpSliceGroup struct {
 	*TcpSliceRouter
 	path     string
+	matchers []TcpMatchFunc
 	handlers []TcpHandlerFunc
+	coreFunc func(*TcpSliceRouterContext) tcp_server.TCPHandler
 }
 
 // TcpSliceRouterContext router上下文
@@ -29,14 +32,27 @@ type TcpSliceRouterContext struct {
 	Ctx  context.Context
 	*TcpSliceGroup
 	index int8
+
+	// 分组匹配时预读得到的结果，同一个连接只解析一次
+	protocol   string
+	serverName *string
 }
 
+// newTcpSliceRouterContext 选择连接匹配的分组，没有分组匹配时返回nil
 func newTcpSliceRouterContext(conn net.Conn, r *TcpSliceRouter, ctx context.Context) *TcpSliceRouterContext {
-	newTcpSliceGroup := &TcpSliceGroup{}
-	*newTcpSliceGroup = *r.groups[0] //浅拷贝数组指针,只会使用第一个分组
-	c := &TcpSliceRouterContext{conn: conn, TcpSliceGroup: newTcpSliceGroup, Ctx: ctx}
-	c.Reset()
-	return c
+	c := &TcpSliceRouterContext{conn: conn, Ctx: ctx}
+	for _, group := range r.groups {
+		if group.match(c) {
+			newTcpSliceGroup := &TcpSliceGroup{}
+			*newTcpSliceGroup = *group
+			// 复制中间件切片，避免并发的连接追加核心处理函数时共用底层数组
+			newTcpSliceGroup.handlers = append([]TcpHandlerFunc{}, group.handlers...)
+			c.TcpSliceGroup = newTcpSliceGroup
+			c.Reset()
+			return c
+		}
+	}
+	return nil
 }
 
 func (c *TcpSliceRouterContext) Get(key interface{}) interface{} {
@@ -47,6 +63,16 @@ func (c *TcpSliceRouterContext) Set(key, val interface{}) {
 	c.Ctx = context.WithValue(c.Ctx, key, val)
 }
 
+// Conn 当前连接，分组匹配预读数据后是可以重放预读数据的连接
+func (c *TcpSliceRouterContext) Conn() net.Conn {
+	return c.conn
+}
+
+// Path 连接进入的分组名称
+func (c *TcpSliceRouterContext) Path() string {
+	return c.path
+}
+
 type TcpSliceRouterHandler struct {
 	coreFunc func(*TcpSliceRouterContext) tcp_server.TCPHandler
 	router   *TcpSliceRouter
@@ -54,13 +80,22 @@ type TcpSliceRouterHandler struct {
 
 func (w *TcpSliceRouterHandler) ServeTCP(ctx context.Context, conn net.Conn) {
 	c := newTcpSliceRouterContext(conn, w.router, ctx)
+	if c == nil {
+		log.Printf(" [ERROR] tcp_slice_router %v no group matched\n", conn.RemoteAddr())
+		return
+	}
+	coreFunc := w.coreFunc
+	if c.coreFunc != nil {
+		coreFunc = c.coreFunc
+	}
 	c.handlers = append(c.handlers, func(c *TcpSliceRouterContext) {
-		w.coreFunc(c).ServeTCP(ctx, conn)
+		coreFunc(c).ServeTCP(c.Ctx, c.conn)
 	})
 	c.Reset()
 	c.Next()
 }
 
+// NewTcpSliceRouterHandler coreFunc为没有设置核心处理函数的分组使用的默认处理函数
 func NewTcpSliceRouterHandler(coreFunc func(*TcpSliceRouterContext) tcp_server.TCPHandler, router *TcpSliceRouter) *TcpSliceRouterHandler {
 	return &TcpSliceRouterHandler{
 		coreFunc: coreFunc,
@@ -73,30 +108,46 @@ func NewTcpSliceRouter() *TcpSliceRouter {
 	return &TcpSliceRouter{}
 }
 
-// Group 创建 Group
-func (g *TcpSliceRouter) Group(path string) *TcpSliceGroup {
-	if path != "/" {
-		panic("only accept path=/")
-	}
+// Group 创建 Group，path为分组名称，连接满足全部条件时进入分组，没有条件的分组匹配所有连接
+func (g *TcpSliceRouter) Group(path string, matchers ...TcpMatchFunc) *TcpSliceGroup {
 	return &TcpSliceGroup{
 		TcpSliceRouter: g,
 		path:           path,
+		matchers:       matchers,
 	}
 }
 
 // Use 构造回调方法
 func (g *TcpSliceGroup) Use(middlewares ...TcpHandlerFunc) *TcpSliceGroup {
 	g.handlers = append(g.handlers, middlewares...)
-	existsFlag := false
+	g.register()
+	return g
+}
+
+// Handle 设置分组的核心处理函数，未设置时使用TcpSliceRouterHandler的默认处理函数
+func (g *TcpSliceGroup) Handle(coreFunc func(*TcpSliceRouterContext) tcp_server.TCPHandler) *TcpSliceGroup {
+	g.coreFunc = coreFunc
+	g.register()
+	return g
+}
+
+// register 分组在第一次设置中间件或处理函数时加入router
+func (g *TcpSliceGroup) register() {
 	for _, oldGroup := range g.TcpSliceRouter.groups {
 		if oldGroup == g {
-			existsFlag = true
+			return
 		}
 	}
-	if !existsFlag {
-		g.TcpSliceRouter.groups = append(g.TcpSliceRouter.groups, g)
+	g.TcpSliceRouter.groups = append(g.TcpSliceRouter.groups, g)
+}
+
+func (g *TcpSliceGroup) match(c *TcpSliceRouterContext) bool {
+	for _, matcher := range g.matchers {
+		if !matcher(c) {
+			return false
+		}
 	}
-	return g
+	return true
 }
 
 // Next 从最先加入中间件开始回调
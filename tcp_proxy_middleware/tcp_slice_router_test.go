@@ -0,0 +1,195 @@
+package tcp_proxy_middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/tcp_server"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+type tcpHandlerFunc func(ctx context.Context, conn net.Conn)
+
+func (f tcpHandlerFunc) ServeTCP(ctx context.Context, conn net.Conn) {
+	f(ctx, conn)
+}
+
+func handleWith(f tcpHandlerFunc) func(*TcpSliceRouterContext) tcp_server.TCPHandler {
+	return func(c *TcpSliceRouterContext) tcp_server.TCPHandler {
+		return f
+	}
+}
+
+// clientHello 生成携带SNI的ClientHello
+func clientHello(t *testing.T, serverName string) []byte {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		tls.Client(clientConn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+		clientConn.Close()
+	}()
+	buf := make([]byte, 16*1024)
+	n, err := serverConn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func startTestRouter(t *testing.T, router *TcpSliceRouter) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	handler := NewTcpSliceRouterHandler(handleWith(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte("default"))
+	}), router)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				handler.ServeTCP(context.Background(), conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func roundTrip(t *testing.T, addr string, data []byte) string {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if len(data) > 0 {
+		if _, err := conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(reply)
+}
+
+func TestTcpSliceRouterGroups(t *testing.T) {
+	oldTimeout := PeekTimeout
+	PeekTimeout = 200 * time.Millisecond
+	defer func() { PeekTimeout = oldTimeout }()
+
+	router := NewTcpSliceRouter()
+	router.Group("api_tls", MatchSni("*.example.com")).Handle(handleWith(func(ctx context.Context, conn net.Conn) {
+		// 核心处理函数读取到的是完整的ClientHello
+		serverName, _, err := tcp_server.PeekServerName(conn, time.Second)
+		if err != nil {
+			conn.Write([]byte(err.Error()))
+			return
+		}
+		conn.Write([]byte("sni:" + serverName))
+	}))
+	router.Group("http", MatchProtocol(TcpSniffHTTP)).Handle(handleWith(func(ctx context.Context, conn net.Conn) {
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte("http:" + line))
+	}))
+	otherNetwork, err := MatchClientCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.Group("other_network", otherNetwork).Handle(handleWith(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte("other_network"))
+	}))
+	loopback, err := MatchClientCIDR("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.Group("/", loopback).Use(func(c *TcpSliceRouterContext) {
+		c.Set("group", c.Path())
+		c.Next()
+	}).Handle(handleWith(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte("group:" + ctx.Value("group").(string)))
+	}))
+	addr := startTestRouter(t, router)
+
+	if reply := roundTrip(t, addr, clientHello(t, "api.example.com")); reply != "sni:api.example.com" {
+		t.Fatalf("tls reply = %q", reply)
+	}
+	if reply := roundTrip(t, addr, []byte("GET / HTTP/1.1\r\n\r\n")); reply != "http:GET / HTTP/1.1\r\n" {
+		t.Fatalf("http reply = %q", reply)
+	}
+	// 其他域名的TLS流量和服务端先发送数据的协议进入按客户端网段匹配的分组
+	if reply := roundTrip(t, addr, clientHello(t, "other.com")); reply != "group:/" {
+		t.Fatalf("other sni reply = %q", reply)
+	}
+	if reply := roundTrip(t, addr, nil); reply != "group:/" {
+		t.Fatalf("silent client reply = %q", reply)
+	}
+}
+
+func TestTcpSliceRouterNoGroupMatched(t *testing.T) {
+	router := NewTcpSliceRouter()
+	router.Group("ssh", MatchProtocol(TcpSniffSSH)).Use(func(c *TcpSliceRouterContext) {
+		c.Next()
+	})
+	addr := startTestRouter(t, router)
+	if reply := roundTrip(t, addr, []byte("SSH-2.0-OpenSSH_9.0\r\n")); reply != "default" {
+		t.Fatalf("ssh reply = %q", reply)
+	}
+	if reply := roundTrip(t, addr, []byte("GET / HTTP/1.1\r\n\r\n")); reply != "" {
+		t.Fatalf("unmatched reply = %q, want connection closed", reply)
+	}
+}
+
+func TestTcpRouteMatchers(t *testing.T) {
+	routes, err := (&dao.TcpRule{RouteRule: "protocol=ssh;cidr=127.0.0.0/8 10.0.0.5:22"}).GetTcpRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matchers, err := TcpRouteMatchers(routes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewTcpSliceRouter()
+	router.Group("/route/0", matchers...).Handle(handleWith(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte("route"))
+	}))
+	router.Group("/").Use(func(c *TcpSliceRouterContext) {
+		c.Next()
+	})
+	addr := startTestRouter(t, router)
+	if reply := roundTrip(t, addr, []byte("SSH-2.0-OpenSSH_9.0\r\n")); reply != "route" {
+		t.Fatalf("ssh reply = %q", reply)
+	}
+	if reply := roundTrip(t, addr, []byte("GET / HTTP/1.1\r\n\r\n")); reply != "default" {
+		t.Fatalf("http reply = %q", reply)
+	}
+	if _, err := TcpRouteMatchers(&dao.TcpRoute{Protocols: []string{"ftp"}}); err == nil {
+		t.Fatal("unknown protocol should be rejected")
+	}
+}
+
+func TestSniffProtocol(t *testing.T) {
+	cases := map[string]string{
+		"\x16\x03\x01\x02\x00": TcpSniffTLS,
+		"SSH-2.0-Go":           TcpSniffSSH,
+		"POST /a HTTP/1.1":     TcpSniffHTTP,
+		"PRI * HTTP/2.0":       TcpSniffHTTP,
+		"*1\r\n$4\r\nPING":     TcpSniffUnknown,
+		"":                     TcpSniffUnknown,
+	}
+	for data, want := range cases {
+		if got := SniffProtocol([]byte(data)); got != want {
+			t.Errorf("SniffProtocol(%q) = %s, want %s", data, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package tcp_proxy_middleware
+
+import (
+	"crypto/tls"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/tcp_server"
+	"log"
+	"time"
+)
+
+// TCPTlsHandshakeMiddleware TCP服务TLS握手中间件
+// 监听器开启TLS时在这里完成握手，记录握手耗时，握手失败计入流量统计；
+// 客户端证书链已经在握手时按照服务的CA证书包校验，握手后取出证书身份
+func TCPTlsHandshakeMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			c.conn.Write([]byte("get service empty"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		tlsConn, ok := tcp_server.UnwrapConn(c.conn).(*tls.Conn)
+		if !ok {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		// 握手失败时无法再向客户端写入明文错误信息，直接断开
+		if err := tlsConn.Handshake(); err != nil {
+			log.Printf(" [ERROR] tcp_tls_handshake %v err:%v\n", c.conn.RemoteAddr(), err)
+			if errCounter, err := public.FlowCounterHandler.GetCounter(public.FlowTlsErrorPrefix + serviceDetail.Info.ServiceName); err == nil {
+				errCounter.Increase()
+			}
+			c.Abort()
+			return
+		}
+		public.TLSHandshakeStatHandler.GetStat(serviceDetail.Info.ServiceName).Observe(time.Since(start))
+		if peerCerts := tlsConn.ConnectionState().PeerCertificates; len(peerCerts) > 0 {
+			c.Set(public.ClientCertIdentityKey, public.CertIdentities(peerCerts[0]))
+		}
+		c.Next()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/zhj/go_gateway/dao"
 	"github.com/zhj/go_gateway/public"
+	"log"
 	"strings"
 )
 
@@ -35,6 +36,23 @@ func TCPWhiteListMiddleware() func(c *TcpSliceRouterContext) {
 				return
 			}
 		}
+		// 共享端口按照SNI路由时，SNI需要在主机白名单中
+		if serverName, ok := c.Get(public.SniContextKey).(string); ok && serviceDetail.AccessControl.OpenAuth == 1 {
+			if !serviceDetail.AccessControl.MatchWhiteHost(serverName) {
+				log.Printf(" [ERROR] tcp_white_host %v sni:%v not in white host list\n", c.conn.RemoteAddr(), serverName)
+				c.Abort()
+				return
+			}
+		}
+		// 开启客户端证书校验时，证书身份需要在证书白名单中
+		if serviceDetail.AccessControl.OpenAuth == 1 && serviceDetail.AccessControl.OpenMtls() {
+			identities, _ := c.Get(public.ClientCertIdentityKey).([]string)
+			if !serviceDetail.AccessControl.MatchWhiteCert(identities) {
+				c.conn.Write([]byte("client certificate not in white cert list"))
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
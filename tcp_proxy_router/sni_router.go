@@ -0,0 +1,105 @@
+package tcp_proxy_router
+
+import (
+	"context"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/tcp_server"
+	"log"
+	"net"
+	"time"
+)
+
+// sniRoute 共享端口上的一个TCP服务
+type sniRoute struct {
+	serviceDetail *dao.ServiceDetail
+	handler       tcp_server.TCPHandler
+}
+
+// tcpSniHandler 共享端口的TLS透传路由，读取ClientHello中的SNI后转给对应服务，不终止TLS
+type tcpSniHandler struct {
+	routes       []*sniRoute
+	defaultRoute *sniRoute
+	peekTimeout  time.Duration
+}
+
+// match 按照精确匹配、通配符匹配、默认服务的顺序选择服务
+func (h *tcpSniHandler) match(serverName string) *sniRoute {
+	var wildcardRoute *sniRoute
+	for _, route := range h.routes {
+		matched, exact := route.serviceDetail.TCPRule.MatchSni(serverName)
+		if exact {
+			return route
+		}
+		if matched && wildcardRoute == nil {
+			wildcardRoute = route
+		}
+	}
+	if wildcardRoute != nil {
+		return wildcardRoute
+	}
+	return h.defaultRoute
+}
+
+func (h *tcpSniHandler) ServeTCP(ctx context.Context, src net.Conn) {
+	serverName, conn, err := tcp_server.PeekServerName(src, h.peekTimeout)
+	if err != nil {
+		log.Printf(" [ERROR] tcp_sni_peek %v err:%v\n", src.RemoteAddr(), err)
+		return
+	}
+	route := h.match(serverName)
+	if route == nil {
+		log.Printf(" [ERROR] tcp_sni_route %v sni:%v no service matched\n", src.RemoteAddr(), serverName)
+		return
+	}
+	ctx = context.WithValue(ctx, "service", route.serviceDetail)
+	ctx = context.WithValue(ctx, public.SniContextKey, serverName)
+	route.handler.ServeTCP(ctx, conn)
+}
+
+// tcpSniAddr 共享端口的监听地址，未配置地址或没有服务接入SNI路由时返回空
+func tcpSniAddr(tcpServiceList []*dao.ServiceDetail) string {
+	for _, serviceDetail := range tcpServiceList {
+		if serviceDetail.TCPRule.OpenSni() {
+			return lib.GetStringConf("proxy.tcp_sni.addr")
+		}
+	}
+	return ""
+}
+
+// tcpSniServerRun 启动共享端口的SNI路由服务器，addr为tcpSniAddr返回的地址
+func tcpSniServerRun(addr string, tcpServiceList []*dao.ServiceDetail) {
+	peekTimeout := time.Duration(lib.GetIntConf("proxy.tcp_sni.peek_timeout")) * time.Second
+	if peekTimeout <= 0 {
+		peekTimeout = 5 * time.Second
+	}
+	sniHandler := &tcpSniHandler{peekTimeout: peekTimeout}
+	for _, serviceDetail := range tcpServiceList {
+		if !serviceDetail.TCPRule.OpenSni() {
+			continue
+		}
+		routerHandler, err := newTcpRouterHandler(serviceDetail)
+		if err != nil {
+			log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
+			return
+		}
+		route := &sniRoute{serviceDetail: serviceDetail, handler: routerHandler}
+		sniHandler.routes = append(sniHandler.routes, route)
+		if serviceDetail.TCPRule.SniDefault == 1 && sniHandler.defaultRoute == nil {
+			sniHandler.defaultRoute = route
+		}
+	}
+	tcpServer := &tcp_server.TcpServer{
+		Addr:    addr,
+		Handler: sniHandler,
+		Listen:  public.UpgraderHandler.Listen,
+	}
+	if !addTcpServer(tcpServer) {
+		return
+	}
+	log.Printf(" [INFO] tcp_sni_proxy_run %v\n", addr)
+	if err := tcpServer.ListenAndServe(); err != nil && err != tcp_server.ErrServerClosed {
+		log.Fatalf(" [INFO] tcp_sni_proxy_run %v err:%v\n", addr, err)
+	}
+}
@@ -4,15 +4,36 @@ import (
 	"context"
 	"fmt"
 	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
 	"github.com/zhj/go_gateway/reverse_proxy"
+	"github.com/zhj/go_gateway/reverse_proxy/load_balance"
 	"github.com/zhj/go_gateway/tcp_proxy_middleware"
 	"github.com/zhj/go_gateway/tcp_server"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 )
 
 var tcpServerList = []*tcp_server.TcpServer{}
 
+// tcpServerLocker 保护服务器列表，开始关闭后不再登记和启动新的服务器
+var (
+	tcpServerLocker  sync.Mutex
+	tcpServerStopped bool
+)
+
+// addTcpServer 登记TCP服务器，已经开始关闭时返回false
+func addTcpServer(server *tcp_server.TcpServer) bool {
+	tcpServerLocker.Lock()
+	defer tcpServerLocker.Unlock()
+	if tcpServerStopped {
+		return false
+	}
+	tcpServerList = append(tcpServerList, server)
+	return true
+}
+
 type tcpHandler struct{}
 
 func (t *tcpHandler) ServeTCP(c context.Context, src net.Conn) {
@@ -24,47 +45,157 @@ func TCPServerRun() {
 	tcpServiceList := dao.ServiceManagerHandler.GetTcpServiceList()
 	for _, serviceItem := range tcpServiceList {
 		tmpItem := serviceItem
+		// 只接入共享端口SNI路由的服务没有独立端口
+		if tmpItem.TCPRule.Port == 0 {
+			continue
+		}
+		public.HealthHandler.ExpectListener("tcp", fmt.Sprintf(":%d", tmpItem.TCPRule.Port))
 		go func(serviceDetail *dao.ServiceDetail) {
 			// 获取TCP的地址（主要是端口号）
 			addr := fmt.Sprintf(":%d", serviceDetail.TCPRule.Port)
-			lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+			routerHandler, err := newTcpRouterHandler(serviceDetail)
 			if err != nil {
 				log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
 				return
 			}
-			//构建路由及设置中间件
-			router := tcp_proxy_middleware.NewTcpSliceRouter()
-			router.Group("/").Use(
-				tcp_proxy_middleware.TCPFlowCountMiddleware(),
-				tcp_proxy_middleware.TCPFlowLimitMiddleware(),
-				tcp_proxy_middleware.TCPWhiteListMiddleware(),
-				tcp_proxy_middleware.TCPBlackListMiddleware(),
-			)
-
-			//构建回调handler
-			routerHandler := tcp_proxy_middleware.NewTcpSliceRouterHandler(
-				func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
-					return reverse_proxy.NewTcpLoadBalanceReverseProxy(c, lb)
-				}, router)
 			baseCtx := context.WithValue(context.Background(), "service", serviceDetail)
 			tcpServer := &tcp_server.TcpServer{
 				Addr:    addr,
 				Handler: routerHandler,
 				BaseCtx: baseCtx,
+				Listen:  public.UpgraderHandler.Listen,
+			}
+			// 开启TLS终止或客户端证书校验的服务在监听器上终止TLS
+			tlsConfig, err := serviceDetail.TCPServerTLSConfig()
+			if err != nil {
+				log.Fatalf(" [INFO] tcp_proxy_tls_config %v err:%v\n", addr, err)
+				return
+			}
+			tcpServer.TLSConfig = tlsConfig
+			if !addTcpServer(tcpServer) {
+				return
 			}
-			tcpServerList = append(tcpServerList, tcpServer)
 			log.Printf(" [INFO] tcp_proxy_run %v\n", addr)
 			if err := tcpServer.ListenAndServe(); err != nil && err != tcp_server.ErrServerClosed {
 				log.Fatalf(" [INFO] tcp_proxy_run %v err:%v\n", addr, err)
 			}
 		}(tmpItem)
 	}
+	// 在启动之前登记共享端口，进程就绪前需要完成监听
+	if sniAddr := tcpSniAddr(tcpServiceList); sniAddr != "" {
+		public.HealthHandler.ExpectListener("tcp", sniAddr)
+		go tcpSniServerRun(sniAddr, tcpServiceList)
+	}
+}
+
+// newTcpRouterHandler 构建服务的中间件路由和反向代理
+func newTcpRouterHandler(serviceDetail *dao.ServiceDetail) (tcp_server.TCPHandler, error) {
+	lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	//构建回调handler，开启TLS发起时使用TLS连接下游
+	upstreamTLSConfig, err := serviceDetail.LoadBalance.UpstreamTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	//构建路由及设置中间件，按照分流规则的顺序匹配，未匹配的连接进入默认分组
+	router := tcp_proxy_middleware.NewTcpSliceRouter()
+	routes, err := serviceDetail.TCPRule.GetTcpRoutes()
+	if err != nil {
+		return nil, err
+	}
+	for index, route := range routes {
+		matchers, err := tcp_proxy_middleware.TcpRouteMatchers(route)
+		if err != nil {
+			return nil, err
+		}
+		routeLb, err := dao.LoadBalancerHandler.GetTcpRouteLoadBalancer(serviceDetail, index, route)
+		if err != nil {
+			return nil, err
+		}
+		router.Group(fmt.Sprintf("/route/%d", index), matchers...).Use(tcpMiddlewares()...).Handle(
+			func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
+				proxy := reverse_proxy.NewTcpLoadBalanceReverseProxy(c, routeLb)
+				proxy.TLSConfig = upstreamTLSConfig
+				proxy.ServiceName = serviceDetail.Info.ServiceName
+				return proxy
+			})
+	}
+	router.Group("/").Use(tcpMiddlewares()...)
+
+	if serviceDetail.TCPRule.OpenRedis() {
+		redisProxy, err := newRedisReverseProxy(serviceDetail, lb)
+		if err != nil {
+			return nil, err
+		}
+		redisProxy.TLSConfig = upstreamTLSConfig
+		return tcp_proxy_middleware.NewTcpSliceRouterHandler(
+			func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
+				return redisProxy
+			}, router), nil
+	}
+	return tcp_proxy_middleware.NewTcpSliceRouterHandler(
+		func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
+			proxy := reverse_proxy.NewTcpLoadBalanceReverseProxy(c, lb)
+			proxy.TLSConfig = upstreamTLSConfig
+			proxy.ServiceName = serviceDetail.Info.ServiceName
+			return proxy
+		}, router), nil
 }
 
-// TCPServerStop 遍历所有TCP服务器并关闭
-func TCPServerStop() {
-	for _, tcpServer := range tcpServerList {
-		tcpServer.Close()
-		log.Printf(" [INFO] tcp_proxy_stop %v stopped\n", tcpServer.Addr)
+// tcpMiddlewares 每个分组使用的中间件
+func tcpMiddlewares() []tcp_proxy_middleware.TcpHandlerFunc {
+	return []tcp_proxy_middleware.TcpHandlerFunc{
+		tcp_proxy_middleware.TCPFlowCountMiddleware(),
+		tcp_proxy_middleware.TCPConnLimitMiddleware(),
+		tcp_proxy_middleware.TCPTlsHandshakeMiddleware(),
+		tcp_proxy_middleware.TCPFlowLimitMiddleware(),
+		tcp_proxy_middleware.TCPWhiteListMiddleware(),
+		tcp_proxy_middleware.TCPBlackListMiddleware(),
+	}
+}
+
+// newRedisReverseProxy 构建redis协议的反向代理，IP列表为主节点，从节点列表用于读写分离
+func newRedisReverseProxy(serviceDetail *dao.ServiceDetail, lb load_balance.LoadBalance) (*reverse_proxy.RedisReverseProxy, error) {
+	replicaLb, err := dao.LoadBalancerHandler.GetRedisReplicaLoadBalancer(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	redisProxy := reverse_proxy.NewRedisLoadBalanceReverseProxy(lb, replicaLb)
+	redisProxy.CommandAllowed = serviceDetail.TCPRule.RedisCommandAllowed
+	redisProxy.ServiceName = serviceDetail.Info.ServiceName
+	redisProxy.OnCommand = func(name string) {
+		counter, err := public.FlowCounterHandler.GetCounter(public.FlowRedisCommandPrefix + serviceDetail.Info.ServiceName + "_" + name)
+		if err != nil {
+			return
+		}
+		counter.Increase()
+	}
+	return redisProxy, nil
+}
+
+// TCPServerStop 遍历所有TCP服务器，停止接受新连接并等待连接结束，ctx到期后关闭剩余连接，返回被中断的连接数
+func TCPServerStop(ctx context.Context) int {
+	tcpServerLocker.Lock()
+	tcpServerStopped = true
+	serverList := tcpServerList
+	tcpServerLocker.Unlock()
+
+	var cut int64
+	wg := sync.WaitGroup{}
+	for _, tcpServer := range serverList {
+		wg.Add(1)
+		go func(tcpServer *tcp_server.TcpServer) {
+			defer wg.Done()
+			if err := tcpServer.Shutdown(ctx); err != nil {
+				atomic.AddInt64(&cut, int64(tcpServer.ActiveConns()))
+				tcpServer.Close()
+			}
+			log.Printf(" [INFO] tcp_proxy_stop %v stopped\n", tcpServer.Addr)
+		}(tcpServer)
 	}
+	wg.Wait()
+	return int(cut)
 }
@@ -48,6 +48,7 @@ func (c *conn) serve(ctx context.Context) {
 			fmt.Printf("tcp: panic serving %v: %v\n%s", c.remoteAddr, err, buf)
 		}
 		c.close()
+		c.server.trackConn(c, false)
 	}()
 	c.remoteAddr = c.rwc.RemoteAddr().String()
 	ctx = context.WithValue(ctx, LocalAddrContextKey, c.rwc.LocalAddr())
@@ -0,0 +1,86 @@
+package tcp_server
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// IdleConn 读写时刷新活跃时间的连接，超过空闲超时没有数据或者超过最大生命周期后读写返回超时错误
+// 代理的两端通过Join共享活跃时间，任意一个方向有数据都视为连接活跃
+type IdleConn struct {
+	net.Conn
+	idleTimeout time.Duration
+	deadline    time.Time //最大生命周期到期时间，零值为不限制
+	lastActive  *int64
+}
+
+// NewIdleConn idleTimeout和maxLifetime为0时不限制
+func NewIdleConn(conn net.Conn, idleTimeout, maxLifetime time.Duration) *IdleConn {
+	c := &IdleConn{
+		Conn:        conn,
+		idleTimeout: idleTimeout,
+		lastActive:  new(int64),
+	}
+	if maxLifetime > 0 {
+		c.deadline = time.Now().Add(maxLifetime)
+	}
+	c.touch()
+	return c
+}
+
+// Join 包装另一个连接，与当前连接共享活跃时间和最大生命周期
+func (c *IdleConn) Join(conn net.Conn) *IdleConn {
+	return &IdleConn{
+		Conn:        conn,
+		idleTimeout: c.idleTimeout,
+		deadline:    c.deadline,
+		lastActive:  c.lastActive,
+	}
+}
+
+func (c *IdleConn) touch() {
+	atomic.StoreInt64(c.lastActive, time.Now().UnixNano())
+}
+
+// nextDeadline 按照最近活跃时间计算的超时时间，不超过最大生命周期
+func (c *IdleConn) nextDeadline(from time.Time) time.Time {
+	deadline := time.Time{}
+	if c.idleTimeout > 0 {
+		deadline = from.Add(c.idleTimeout)
+	}
+	if !c.deadline.IsZero() && (deadline.IsZero() || c.deadline.Before(deadline)) {
+		deadline = c.deadline
+	}
+	return deadline
+}
+
+func (c *IdleConn) Read(p []byte) (int, error) {
+	for {
+		lastActive := time.Unix(0, atomic.LoadInt64(c.lastActive))
+		c.Conn.SetReadDeadline(c.nextDeadline(lastActive))
+		n, err := c.Conn.Read(p)
+		if n > 0 {
+			c.touch()
+			return n, err
+		}
+		// 等待期间另一个方向有数据，重新计算超时时间继续等待
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			newActive := time.Unix(0, atomic.LoadInt64(c.lastActive))
+			if newActive.After(lastActive) && time.Now().Before(c.nextDeadline(newActive)) {
+				continue
+			}
+		}
+		return n, err
+	}
+}
+
+// Write 写入阻塞超过空闲超时视为对端不再接收数据
+func (c *IdleConn) Write(p []byte) (int, error) {
+	c.Conn.SetWriteDeadline(c.nextDeadline(time.Now()))
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
@@ -0,0 +1,87 @@
+package tcp_server
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+func TestIdleConnTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := NewIdleConn(server, 100*time.Millisecond, 0)
+	defer conn.Close()
+
+	go client.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := conn.Read(buf); !isTimeout(err) {
+		t.Fatalf("read err = %v, want timeout", err)
+	}
+	if d := time.Since(start); d < 80*time.Millisecond || d > time.Second {
+		t.Fatalf("idle timeout after %v", d)
+	}
+}
+
+func TestIdleConnJoinSharesActivity(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	upstream, backend := net.Pipe()
+	defer backend.Close()
+	go io.Copy(ioutil.Discard, backend)
+
+	conn := NewIdleConn(server, 150*time.Millisecond, 0)
+	defer conn.Close()
+	joined := conn.Join(upstream)
+	defer joined.Close()
+	// 只有下游方向有数据时，客户端方向的读取也不会超时
+	go func() {
+		for i := 0; i < 8; i++ {
+			time.Sleep(50 * time.Millisecond)
+			joined.Write([]byte("data"))
+		}
+		client.Write([]byte("done"))
+	}()
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "done" {
+		t.Fatalf("read = %q, err = %v", buf, err)
+	}
+}
+
+func TestIdleConnMaxLifetime(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := NewIdleConn(server, 0, 150*time.Millisecond)
+	defer conn.Close()
+	go func() {
+		for i := 0; i < 10; i++ {
+			if _, err := client.Write([]byte("x")); err != nil {
+				return
+			}
+			time.Sleep(30 * time.Millisecond)
+		}
+	}()
+	start := time.Now()
+	buf := make([]byte, 1)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			if !isTimeout(err) {
+				t.Fatalf("read err = %v, want timeout", err)
+			}
+			break
+		}
+	}
+	if d := time.Since(start); d > 250*time.Millisecond {
+		t.Fatalf("connection lived %v after max lifetime", d)
+	}
+}
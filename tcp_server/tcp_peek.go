@@ -0,0 +1,67 @@
+package tcp_server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"time"
+)
+
+// peekBufferSize 可以预读的最大字节数，能够容纳一个完整的TLS记录
+const peekBufferSize = 16*1024 + 5
+
+// PeekConn 可以预读数据的连接，预读的数据在之后的Read中重放
+type PeekConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func NewPeekConn(conn net.Conn) *PeekConn {
+	return &PeekConn{Conn: conn, reader: bufio.NewReaderSize(conn, peekBufferSize)}
+}
+
+func (c *PeekConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// Peek 预读n个字节但不消费，超时或连接关闭时返回已经读取到的数据和错误
+func (c *PeekConn) Peek(n int, timeout time.Duration) ([]byte, error) {
+	if n > peekBufferSize {
+		n = peekBufferSize
+	}
+	if c.reader.Buffered() >= n {
+		return c.reader.Peek(n)
+	}
+	if timeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(timeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+	}
+	return c.reader.Peek(n)
+}
+
+// UnwrapConn 获取PeekConn、IdleConn和SNI路由包装的原始连接，用于判断连接是否为*tls.Conn
+func UnwrapConn(conn net.Conn) net.Conn {
+	for {
+		switch wrapped := conn.(type) {
+		case *PeekConn:
+			conn = wrapped.Conn
+		case *IdleConn:
+			conn = wrapped.Conn
+		case *replayConn:
+			conn = wrapped.Conn
+		default:
+			return conn
+		}
+	}
+}
+
+// ErrCloseWriteUnsupported 连接不支持只关闭写方向
+var ErrCloseWriteUnsupported = errors.New("tcp: close write unsupported")
+
+// CloseWrite 关闭连接的写方向，对端读取到EOF后仍然可以继续发送数据
+func CloseWrite(conn net.Conn) error {
+	if cw, ok := UnwrapConn(conn).(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return ErrCloseWriteUnsupported
+}
@@ -2,6 +2,7 @@ package tcp_server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -41,15 +42,22 @@ type TcpServer struct {
 	Handler TCPHandler
 	err     error
 	BaseCtx context.Context
+	// TLSConfig 不为空时在监听器上终止TLS
+	TLSConfig *tls.Config
 
 	WriteTimeout     time.Duration
 	ReadTimeout      time.Duration
 	KeepAliveTimeout time.Duration
+	// IdleTimeout 连接读写时刷新的空闲超时，WriteTimeout和ReadTimeout为建立连接后的绝对超时
+	IdleTimeout time.Duration
+	// Listen ListenAndServe创建监听器的方法，为空时使用net.Listen
+	Listen func(network, address string) (net.Listener, error)
 
 	mu         sync.Mutex
 	inShutdown int32
 	doneChan   chan struct{}
 	l          *onceCloseListener
+	activeConn map[*conn]struct{}
 }
 
 func (s *TcpServer) shuttingDown() bool {
@@ -68,25 +76,100 @@ func (srv *TcpServer) ListenAndServe() error {
 		return errors.New("need addr")
 	}
 	//fmt.Println("addr:", addr)
-	ln, err := net.Listen("tcp", addr)
+	listen := srv.Listen
+	if listen == nil {
+		listen = net.Listen
+	}
+	ln, err := listen("tcp", addr)
 	if err != nil {
 		//fmt.Println("net.Listen err: ", err)
 		return err
 	}
-	return srv.Serve(tcpKeepAliveListener{
-		ln.(*net.TCPListener)})
+	if tcpLn, ok := ln.(*net.TCPListener); ok {
+		ln = tcpKeepAliveListener{tcpLn}
+	}
+	if srv.TLSConfig != nil {
+		return srv.Serve(tls.NewListener(ln, srv.TLSConfig))
+	}
+	return srv.Serve(ln)
 }
 
-func (srv *TcpServer) Close() error {
+// closeListener 停止接受新连接
+func (srv *TcpServer) closeListener() error {
 	atomic.StoreInt32(&srv.inShutdown, 1)
-	close(srv.doneChan) //关闭channel
-	srv.l.Close()       //执行listener关闭
+	srv.mu.Lock()
+	doneChan := srv.getDoneChanLocked()
+	select {
+	case <-doneChan:
+	default:
+		close(doneChan)
+	}
+	l := srv.l
+	srv.mu.Unlock()
+	if l != nil {
+		return l.Close()
+	}
 	return nil
 }
 
+// Close 停止接受新连接并立即关闭所有正在处理的连接
+func (srv *TcpServer) Close() error {
+	err := srv.closeListener()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for c := range srv.activeConn {
+		c.close()
+	}
+	return err
+}
+
+// shutdownPollInterval Shutdown检查连接是否全部结束的间隔
+const shutdownPollInterval = 100 * time.Millisecond
+
+// Shutdown 停止接受新连接并等待正在处理的连接全部结束
+// ctx到期时返回ctx.Err()，剩余的连接需要调用Close关闭
+func (srv *TcpServer) Shutdown(ctx context.Context) error {
+	err := srv.closeListener()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.ActiveConns() == 0 {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ActiveConns 正在处理的连接数
+func (srv *TcpServer) ActiveConns() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.activeConn)
+}
+
+func (srv *TcpServer) trackConn(c *conn, add bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.activeConn == nil {
+		srv.activeConn = make(map[*conn]struct{})
+	}
+	if add {
+		srv.activeConn[c] = struct{}{}
+	} else {
+		delete(srv.activeConn, c)
+	}
+}
+
 func (srv *TcpServer) Serve(l net.Listener) error {
-	srv.l = &onceCloseListener{Listener: l}
-	defer srv.l.Close() //执行listener关闭
+	ol := &onceCloseListener{Listener: l}
+	srv.mu.Lock()
+	srv.l = ol
+	srv.mu.Unlock()
+	defer ol.Close() //执行listener关闭
 	if srv.BaseCtx == nil {
 		srv.BaseCtx = context.Background()
 	}
@@ -104,6 +187,7 @@ func (srv *TcpServer) Serve(l net.Listener) error {
 			continue
 		}
 		c := srv.newConn(rw)
+		srv.trackConn(c, true)
 		go c.serve(ctx)
 	}
 	return nil
@@ -127,12 +211,19 @@ func (srv *TcpServer) newConn(rwc net.Conn) *conn {
 			tcpConn.SetKeepAlivePeriod(d)
 		}
 	}
+	if d := c.server.IdleTimeout; d != 0 {
+		c.rwc = NewIdleConn(c.rwc, d, 0)
+	}
 	return c
 }
 
 func (s *TcpServer) getDoneChan() <-chan struct{} {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.getDoneChanLocked()
+}
+
+func (s *TcpServer) getDoneChanLocked() chan struct{} {
 	if s.doneChan == nil {
 		s.doneChan = make(chan struct{})
 	}
@@ -0,0 +1,53 @@
+package tcp_server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+type tcpHandlerFunc func(ctx context.Context, conn net.Conn)
+
+func (f tcpHandlerFunc) ServeTCP(ctx context.Context, conn net.Conn) {
+	f(ctx, conn)
+}
+
+func TestTcpServerShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 处理函数在客户端关闭连接后返回
+	srv := &TcpServer{Handler: tcpHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		conn.Read(make([]byte, 1))
+	})}
+	go srv.Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.ActiveConns() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("active conns = %d, want 1", srv.ActiveConns())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("shutdown err = %v, want deadline exceeded", err)
+	}
+	if _, err := net.DialTimeout("tcp", l.Addr().String(), time.Second); err == nil {
+		t.Fatal("listener still accepting after shutdown")
+	}
+	// 连接结束后Shutdown正常返回
+	conn.Close()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown err = %v", err)
+	}
+	srv.Close()
+}
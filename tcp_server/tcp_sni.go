@@ -0,0 +1,72 @@
+package tcp_server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+var errClientHelloPeeked = errors.New("tcp: client hello peeked")
+
+// sniPeekConn 只读连接，握手过程中读取的数据同时写入缓冲区，写入的alert全部丢弃
+type sniPeekConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *sniPeekConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *sniPeekConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// replayConn 先重放已经读取的ClientHello，再继续读取原连接
+type replayConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// PeekServerName 读取TLS ClientHello中的SNI但不终止TLS，返回的连接会重放已读取的数据
+// 客户端没有携带SNI时返回空字符串，不是TLS流量时返回错误
+func PeekServerName(conn net.Conn, timeout time.Duration) (string, net.Conn, error) {
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+	buf := &bytes.Buffer{}
+	serverName, helloRead, err := readServerName(conn, io.TeeReader(conn, buf))
+	if !helloRead {
+		return "", nil, err
+	}
+	return serverName, &replayConn{Conn: conn, reader: io.MultiReader(buf, conn)}, nil
+}
+
+// ParseServerName 从已经读取的TLS记录中解析ClientHello的SNI，conn只用于构造握手，不会被读写
+func ParseServerName(conn net.Conn, record []byte) (string, error) {
+	serverName, helloRead, err := readServerName(conn, bytes.NewReader(record))
+	if !helloRead {
+		return "", err
+	}
+	return serverName, nil
+}
+
+// readServerName 通过标准库解析ClientHello，读取到ClientHello后立即中止握手
+func readServerName(conn net.Conn, reader io.Reader) (serverName string, helloRead bool, err error) {
+	err = tls.Server(&sniPeekConn{Conn: conn, reader: reader}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			helloRead = true
+			return nil, errClientHelloPeeked
+		},
+	}).Handshake()
+	return serverName, helloRead, err
+}
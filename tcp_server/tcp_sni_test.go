@@ -0,0 +1,49 @@
+package tcp_server
+
+import (
+	"crypto/tls"
+	"github.com/zhj/go_gateway/public/test_cert"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPeekServerName(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		client := tls.Client(clientConn, &tls.Config{ServerName: "api.example.com", InsecureSkipVerify: true})
+		if err := client.Handshake(); err != nil {
+			return
+		}
+		client.Write([]byte("hello"))
+		client.Close()
+	}()
+
+	serverName, conn, err := PeekServerName(serverConn, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverName != "api.example.com" {
+		t.Fatalf("unexpected server name %q", serverName)
+	}
+	// 透传给下游的连接需要重放ClientHello，下游可以正常完成握手
+	upstream := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{test_cert.NewServer(t, nil, serverName).TLSCertificate()}})
+	body, err := ioutil.ReadAll(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestPeekServerNameNotTLS(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go clientConn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	if _, _, err := PeekServerName(serverConn, time.Second); err == nil {
+		t.Fatal("plain tcp should fail")
+	}
+}
@@ -0,0 +1,40 @@
+package udp_proxy_middleware
+
+import (
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"log"
+	"strings"
+)
+
+// UDPBlackListMiddleware UDP黑名单中间件，黑名单中的客户端的数据包直接丢弃
+func UDPBlackListMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serviceDetail, ok := c.Get("service").(*dao.ServiceDetail)
+		if !ok {
+			log.Printf(" [ERROR] udp_black_list %v get service empty\n", c.Session.RemoteAddr())
+			c.Abort()
+			return
+		}
+
+		// 白名单在黑名单之前验证
+		whileIpList := []string{}
+		if serviceDetail.AccessControl.WhiteList != "" {
+			whileIpList = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
+		}
+
+		blackIpList := []string{}
+		if serviceDetail.AccessControl.BlackList != "" {
+			blackIpList = strings.Split(serviceDetail.AccessControl.BlackList, ",")
+		}
+		// 验证黑名单要前提是服务开启了验证
+		if serviceDetail.AccessControl.OpenAuth == 1 && len(whileIpList) == 0 && len(blackIpList) > 0 {
+			if clientIP := c.Session.ClientIP(); public.InStringSlice(blackIpList, clientIP) {
+				log.Printf(" [ERROR] udp_black_list %v in black ip list\n", clientIP)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package udp_proxy_middleware
+
+import (
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"log"
+)
+
+// UDPFlowCountMiddleware UDP流量计数中间件，每个数据包计数一次
+func UDPFlowCountMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serviceDetail, ok := c.Get("service").(*dao.ServiceDetail)
+		if !ok {
+			log.Printf(" [ERROR] udp_flow_count %v get service empty\n", c.Session.RemoteAddr())
+			c.Abort()
+			return
+		}
+
+		//统计项 1 全站 2 服务
+		totalCounter, err := public.FlowCounterHandler.GetCounter(public.FlowTotal)
+		if err != nil {
+			log.Printf(" [ERROR] udp_flow_count %v err:%v\n", c.Session.RemoteAddr(), err)
+			c.Abort()
+			return
+		}
+		totalCounter.Increase()
+
+		serviceCounter, err := public.FlowCounterHandler.GetCounter(public.FlowServicePrefix + serviceDetail.Info.ServiceName)
+		if err != nil {
+			log.Printf(" [ERROR] udp_flow_count %v err:%v\n", c.Session.RemoteAddr(), err)
+			c.Abort()
+			return
+		}
+		serviceCounter.Increase()
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package udp_proxy_middleware
+
+import (
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"log"
+)
+
+// UDPFlowLimitMiddleware UDP限流中间件，按照每秒数据包数限流，超出限制的数据包直接丢弃
+func UDPFlowLimitMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serviceDetail, ok := c.Get("service").(*dao.ServiceDetail)
+		if !ok {
+			log.Printf(" [ERROR] udp_flow_limit %v get service empty\n", c.Session.RemoteAddr())
+			c.Abort()
+			return
+		}
+
+		if serviceDetail.AccessControl.ServiceFlowLimit != 0 {
+			serviceLimiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName,
+				float64(serviceDetail.AccessControl.ServiceFlowLimit))
+			if err != nil {
+				log.Printf(" [ERROR] udp_flow_limit %v err:%v\n", c.Session.RemoteAddr(), err)
+				c.Abort()
+				return
+			}
+			if !serviceLimiter.Allow() {
+				c.Abort()
+				return
+			}
+		}
+
+		clientIP := c.Session.ClientIP()
+		if serviceDetail.AccessControl.ClientIPFlowLimit > 0 {
+			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+clientIP,
+				float64(serviceDetail.AccessControl.ClientIPFlowLimit))
+			if err != nil {
+				log.Printf(" [ERROR] udp_flow_limit %v err:%v\n", c.Session.RemoteAddr(), err)
+				c.Abort()
+				return
+			}
+			if !clientLimiter.Allow() {
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,90 @@
+package udp_proxy_middleware
+
+import (
+	"context"
+	"github.com/zhj/go_gateway/udp_server"
+	"math"
+)
+
+const abortIndex int8 = math.MaxInt8 / 2 //最多 63 个中间件
+
+type UdpHandlerFunc func(*UdpSliceRouterContext)
+
+// UdpSliceRouter router 结构体，UDP服务的每个数据包都依次经过中间件
+type UdpSliceRouter struct {
+	handlers []UdpHandlerFunc
+}
+
+// UdpSliceRouterContext router上下文
+type UdpSliceRouterContext struct {
+	Session  *udp_server.UdpSession
+	Packet   []byte
+	Ctx      context.Context
+	handlers []UdpHandlerFunc
+	index    int8
+}
+
+func (c *UdpSliceRouterContext) Get(key interface{}) interface{} {
+	return c.Ctx.Value(key)
+}
+
+func (c *UdpSliceRouterContext) Set(key, val interface{}) {
+	c.Ctx = context.WithValue(c.Ctx, key, val)
+}
+
+type UdpSliceRouterHandler struct {
+	coreFunc func(*UdpSliceRouterContext) udp_server.UDPHandler
+	router   *UdpSliceRouter
+}
+
+func (w *UdpSliceRouterHandler) ServeUDP(ctx context.Context, session *udp_server.UdpSession, packet []byte) {
+	c := &UdpSliceRouterContext{Session: session, Packet: packet, Ctx: ctx}
+	c.handlers = append(c.handlers, w.router.handlers...)
+	c.handlers = append(c.handlers, func(c *UdpSliceRouterContext) {
+		w.coreFunc(c).ServeUDP(c.Ctx, session, packet)
+	})
+	c.Reset()
+	c.Next()
+}
+
+func NewUdpSliceRouterHandler(coreFunc func(*UdpSliceRouterContext) udp_server.UDPHandler, router *UdpSliceRouter) *UdpSliceRouterHandler {
+	return &UdpSliceRouterHandler{
+		coreFunc: coreFunc,
+		router:   router,
+	}
+}
+
+// NewUdpSliceRouter 构造 router
+func NewUdpSliceRouter() *UdpSliceRouter {
+	return &UdpSliceRouter{}
+}
+
+// Use 构造回调方法
+func (r *UdpSliceRouter) Use(middlewares ...UdpHandlerFunc) *UdpSliceRouter {
+	r.handlers = append(r.handlers, middlewares...)
+	return r
+}
+
+// Next 从最先加入中间件开始回调
+func (c *UdpSliceRouterContext) Next() {
+	c.index++
+	for c.index < int8(len(c.handlers)) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort 跳出中间件方法，数据包被丢弃
+func (c *UdpSliceRouterContext) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted 是否跳过了回调
+func (c *UdpSliceRouterContext) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// Reset 重置回调
+func (c *UdpSliceRouterContext) Reset() {
+	c.index = -1
+}
@@ -0,0 +1,33 @@
+package udp_proxy_middleware
+
+import (
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"log"
+	"strings"
+)
+
+// UDPWhiteListMiddleware UDP白名单中间件，不在白名单中的客户端的数据包直接丢弃
+func UDPWhiteListMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serviceDetail, ok := c.Get("service").(*dao.ServiceDetail)
+		if !ok {
+			log.Printf(" [ERROR] udp_white_list %v get service empty\n", c.Session.RemoteAddr())
+			c.Abort()
+			return
+		}
+		iplist := []string{}
+		if serviceDetail.AccessControl.WhiteList != "" {
+			iplist = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
+		}
+		// 验证白名单要前提是服务开启了验证
+		if serviceDetail.AccessControl.OpenAuth == 1 && len(iplist) > 0 {
+			if clientIP := c.Session.ClientIP(); !public.InStringSlice(iplist, clientIP) {
+				log.Printf(" [ERROR] udp_white_list %v not in white ip list\n", clientIP)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,113 @@
+package udp_proxy_router
+
+import (
+	"context"
+	"fmt"
+	"github.com/e421083458/golang_common/lib"
+	"github.com/zhj/go_gateway/dao"
+	"github.com/zhj/go_gateway/public"
+	"github.com/zhj/go_gateway/reverse_proxy"
+	"github.com/zhj/go_gateway/udp_proxy_middleware"
+	"github.com/zhj/go_gateway/udp_server"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+var udpServerList = []*udp_server.UdpServer{}
+
+// udpServerLocker 保护服务器列表，开始关闭后不再登记和启动新的服务器
+var (
+	udpServerLocker  sync.Mutex
+	udpServerStopped bool
+)
+
+// addUdpServer 登记UDP服务器，已经开始关闭时返回false
+func addUdpServer(server *udp_server.UdpServer) bool {
+	udpServerLocker.Lock()
+	defer udpServerLocker.Unlock()
+	if udpServerStopped {
+		return false
+	}
+	udpServerList = append(udpServerList, server)
+	return true
+}
+
+func UDPServerRun() {
+	// 获取udp服务列表
+	udpServiceList := dao.ServiceManagerHandler.GetUdpServiceList()
+	for _, serviceItem := range udpServiceList {
+		tmpItem := serviceItem
+		public.HealthHandler.ExpectListener("udp", fmt.Sprintf(":%d", tmpItem.UDPRule.Port))
+		go func(serviceDetail *dao.ServiceDetail) {
+			// 获取UDP的地址（主要是端口号）
+			addr := fmt.Sprintf(":%d", serviceDetail.UDPRule.Port)
+			routerHandler, err := newUdpRouterHandler(serviceDetail)
+			if err != nil {
+				log.Fatalf(" [INFO] GetUdpLoadBalancer %v err:%v\n", addr, err)
+				return
+			}
+			baseCtx := context.WithValue(context.Background(), "service", serviceDetail)
+			udpServer := &udp_server.UdpServer{
+				Addr:        addr,
+				Handler:     routerHandler,
+				BaseCtx:     baseCtx,
+				IdleTimeout: serviceDetail.UDPRule.SessionIdleTimeout(),
+				MaxSessions: lib.GetIntConf("proxy.udp.max_sessions"),
+				Listen:      public.UpgraderHandler.ListenUDP,
+			}
+			if !addUdpServer(udpServer) {
+				return
+			}
+			log.Printf(" [INFO] udp_proxy_run %v\n", addr)
+			if err := udpServer.ListenAndServe(); err != nil && err != udp_server.ErrServerClosed {
+				log.Fatalf(" [INFO] udp_proxy_run %v err:%v\n", addr, err)
+			}
+		}(tmpItem)
+	}
+}
+
+// newUdpRouterHandler 构建服务的中间件路由和反向代理
+func newUdpRouterHandler(serviceDetail *dao.ServiceDetail) (udp_server.UDPHandler, error) {
+	lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	//构建路由及设置中间件
+	router := udp_proxy_middleware.NewUdpSliceRouter()
+	router.Use(
+		udp_proxy_middleware.UDPFlowCountMiddleware(),
+		udp_proxy_middleware.UDPFlowLimitMiddleware(),
+		udp_proxy_middleware.UDPWhiteListMiddleware(),
+		udp_proxy_middleware.UDPBlackListMiddleware(),
+	)
+	proxy := reverse_proxy.NewUdpLoadBalanceReverseProxy(lb)
+	return udp_proxy_middleware.NewUdpSliceRouterHandler(
+		func(c *udp_proxy_middleware.UdpSliceRouterContext) udp_server.UDPHandler {
+			return proxy
+		}, router), nil
+}
+
+// UDPServerStop 遍历所有UDP服务器，不再接受新的客户端并等待已有会话空闲超时，ctx到期后关闭剩余会话，返回被中断的会话数
+func UDPServerStop(ctx context.Context) int {
+	udpServerLocker.Lock()
+	udpServerStopped = true
+	serverList := udpServerList
+	udpServerLocker.Unlock()
+
+	var cut int64
+	wg := sync.WaitGroup{}
+	for _, udpServer := range serverList {
+		wg.Add(1)
+		go func(udpServer *udp_server.UdpServer) {
+			defer wg.Done()
+			if err := udpServer.Shutdown(ctx); err != nil {
+				atomic.AddInt64(&cut, int64(udpServer.SessionCount()))
+				udpServer.Close()
+			}
+			log.Printf(" [INFO] udp_proxy_stop %v stopped\n", udpServer.Addr)
+		}(udpServer)
+	}
+	wg.Wait()
+	return int(cut)
+}
@@ -0,0 +1,280 @@
+package udp_server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrServerClosed  = errors.New("udp: Server closed")
+	ServerContextKey = &contextKey{"udp-server"}
+)
+
+const (
+	// DefaultIdleTimeout 会话默认空闲超时时间
+	DefaultIdleTimeout = 60 * time.Second
+	// maxPacketSize UDP数据包的最大长度
+	maxPacketSize = 64 * 1024
+)
+
+type contextKey struct {
+	name string
+}
+
+func (k *contextKey) String() string {
+	return "udp_proxy context value " + k.name
+}
+
+// UDPHandler 处理客户端发来的数据包，同一个监听器上的数据包按照到达顺序依次处理
+type UDPHandler interface {
+	ServeUDP(ctx context.Context, session *UdpSession, packet []byte)
+}
+
+// UdpServer UDP服务器，按照客户端地址维护会话，会话空闲超过IdleTimeout后关闭
+type UdpServer struct {
+	Addr        string
+	Handler     UDPHandler
+	BaseCtx     context.Context
+	IdleTimeout time.Duration
+	// MaxSessions 最大会话数，为0时不限制
+	MaxSessions int
+	// Listen ListenAndServe创建socket的方法，为空时使用net.ListenUDP
+	Listen func(network, address string) (*net.UDPConn, error)
+
+	mu         sync.Mutex
+	inShutdown int32
+	doneChan   chan struct{}
+	conn       *net.UDPConn
+	sessions   map[string]*UdpSession
+}
+
+func (srv *UdpServer) shuttingDown() bool {
+	return atomic.LoadInt32(&srv.inShutdown) != 0
+}
+
+func (srv *UdpServer) idleTimeout() time.Duration {
+	if srv.IdleTimeout > 0 {
+		return srv.IdleTimeout
+	}
+	return DefaultIdleTimeout
+}
+
+func listenUDP(network, address string) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP(network, addr)
+}
+
+func (srv *UdpServer) ListenAndServe() error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
+	if srv.Addr == "" {
+		return errors.New("need addr")
+	}
+	listen := srv.Listen
+	if listen == nil {
+		listen = listenUDP
+	}
+	conn, err := listen("udp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(conn)
+}
+
+// Serve 读取数据包并分发到客户端对应的会话
+func (srv *UdpServer) Serve(conn *net.UDPConn) error {
+	srv.mu.Lock()
+	srv.conn = conn
+	if srv.sessions == nil {
+		srv.sessions = map[string]*UdpSession{}
+	}
+	srv.mu.Unlock()
+	defer conn.Close()
+	if srv.BaseCtx == nil {
+		srv.BaseCtx = context.Background()
+	}
+	ctx := context.WithValue(srv.BaseCtx, ServerContextKey, srv)
+	go srv.expireSessions()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, clientAddr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-srv.getDoneChan():
+				return ErrServerClosed
+			default:
+			}
+			fmt.Printf("read fail, err: %v\n", err)
+			continue
+		}
+		// 处理函数可能异步使用数据包，每个数据包使用独立的内存
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+		session := srv.getSession(clientAddr)
+		if session == nil {
+			continue
+		}
+		srv.serve(ctx, session, packet)
+	}
+}
+
+func (srv *UdpServer) serve(ctx context.Context, session *UdpSession, packet []byte) {
+	defer func() {
+		if err := recover(); err != nil {
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			fmt.Printf("udp: panic serving %v: %v\n%s", session.RemoteAddr(), err, buf)
+		}
+	}()
+	if srv.Handler == nil {
+		panic("handler empty")
+	}
+	session.touch()
+	srv.Handler.ServeUDP(ctx, session, packet)
+}
+
+// getSession 获取客户端地址对应的会话，不存在时新建，关闭过程中不再新建会话并返回nil
+// 新建的会话在处理函数调用Open后才登记到会话表，被中间件丢弃的数据包不会占用会话
+func (srv *UdpServer) getSession(clientAddr *net.UDPAddr) *UdpSession {
+	key := clientAddr.String()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if session, ok := srv.sessions[key]; ok {
+		return session
+	}
+	if srv.shuttingDown() {
+		return nil
+	}
+	return newUdpSession(srv, clientAddr)
+}
+
+// addSession 登记会话，关闭过程中或者超过最大会话数时返回false
+func (srv *UdpServer) addSession(session *UdpSession) bool {
+	key := session.clientAddr.String()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if exist, ok := srv.sessions[key]; ok {
+		return exist == session
+	}
+	if srv.shuttingDown() {
+		return false
+	}
+	if srv.MaxSessions > 0 && len(srv.sessions) >= srv.MaxSessions {
+		return false
+	}
+	srv.sessions[key] = session
+	return true
+}
+
+// removeSession 会话关闭后从会话表中移除
+func (srv *UdpServer) removeSession(session *UdpSession) {
+	key := session.clientAddr.String()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.sessions[key] == session {
+		delete(srv.sessions, key)
+	}
+}
+
+// SessionCount 当前会话数
+func (srv *UdpServer) SessionCount() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.sessions)
+}
+
+// expireSessions 定时关闭空闲超时的会话
+func (srv *UdpServer) expireSessions() {
+	interval := srv.idleTimeout() / 2
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.getDoneChan():
+			return
+		case now := <-ticker.C:
+			expired := []*UdpSession{}
+			srv.mu.Lock()
+			for _, session := range srv.sessions {
+				if now.Sub(session.lastActiveTime()) >= srv.idleTimeout() {
+					expired = append(expired, session)
+				}
+			}
+			srv.mu.Unlock()
+			for _, session := range expired {
+				session.Close()
+			}
+		}
+	}
+}
+
+// shutdownPollInterval Shutdown检查会话是否全部结束的间隔
+const shutdownPollInterval = 100 * time.Millisecond
+
+// Shutdown 不再接受新的客户端，已有会话继续转发直到空闲超时，全部结束后关闭监听器
+// ctx到期时返回ctx.Err()，剩余的会话需要调用Close关闭
+func (srv *UdpServer) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for srv.SessionCount() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return srv.Close()
+}
+
+// Close 关闭监听器和所有会话
+func (srv *UdpServer) Close() error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	doneChan := srv.getDoneChanLocked()
+	select {
+	case <-doneChan:
+	default:
+		close(doneChan)
+	}
+	conn := srv.conn
+	sessions := []*UdpSession{}
+	for _, session := range srv.sessions {
+		sessions = append(sessions, session)
+	}
+	srv.mu.Unlock()
+	for _, session := range sessions {
+		session.Close()
+	}
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+func (srv *UdpServer) getDoneChan() <-chan struct{} {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.getDoneChanLocked()
+}
+
+func (srv *UdpServer) getDoneChanLocked() chan struct{} {
+	if srv.doneChan == nil {
+		srv.doneChan = make(chan struct{})
+	}
+	return srv.doneChan
+}
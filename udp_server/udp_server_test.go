@@ -0,0 +1,116 @@
+package udp_server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type echoHandler struct{}
+
+func (h *echoHandler) ServeUDP(ctx context.Context, session *UdpSession, packet []byte) {
+	// 以drop开头的数据包模拟被中间件丢弃
+	if strings.HasPrefix(string(packet), "drop") || !session.Open() {
+		return
+	}
+	session.Write(packet)
+}
+
+func startTestServer(t *testing.T, idleTimeout time.Duration) (*UdpServer, *net.UDPConn) {
+	srv := &UdpServer{Handler: &echoHandler{}, IdleTimeout: idleTimeout}
+	return srv, startServer(t, srv)
+}
+
+func startServer(t *testing.T, srv *UdpServer) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Addr = conn.LocalAddr().String()
+	go srv.Serve(conn)
+	t.Cleanup(func() { srv.Close() })
+	return dialServer(t, srv)
+}
+
+func dialServer(t *testing.T, srv *UdpServer) *net.UDPConn {
+	addr, err := net.ResolveUDPAddr("udp", srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestUdpServerEcho(t *testing.T) {
+	srv, client := startTestServer(t, time.Minute)
+	buf := make([]byte, 64)
+	for _, msg := range []string{"ping", "pong"} {
+		if _, err := client.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != msg {
+			t.Fatalf("echo = %q, want %q", buf[:n], msg)
+		}
+	}
+	if count := srv.SessionCount(); count != 1 {
+		t.Fatalf("session count = %d, want 1", count)
+	}
+}
+
+func TestUdpServerSessionIdleExpire(t *testing.T) {
+	srv, client := startTestServer(t, 200*time.Millisecond)
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := client.Read(make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.SessionCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("session not expired, count = %d", srv.SessionCount())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestUdpServerMaxSessions(t *testing.T) {
+	srv := &UdpServer{Handler: &echoHandler{}, IdleTimeout: time.Minute, MaxSessions: 1}
+	client := startServer(t, srv)
+	// 被丢弃的数据包不占用会话
+	if _, err := client.Write([]byte("drop")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if n, err := client.Read(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("echo = %q, err = %v", buf[:n], err)
+	}
+	// 超过最大会话数的客户端没有响应
+	other := dialServer(t, srv)
+	if _, err := other.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	other.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	if _, err := other.Read(buf); err == nil {
+		t.Fatal("session over limit should be dropped")
+	}
+	if count := srv.SessionCount(); count != 1 {
+		t.Fatalf("session count = %d, want 1", count)
+	}
+}
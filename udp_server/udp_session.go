@@ -0,0 +1,114 @@
+package udp_server
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UdpSession 同一客户端地址的数据包属于同一个会话，处理函数可以在会话中保存到下游的连接
+type UdpSession struct {
+	server     *UdpServer
+	clientAddr *net.UDPAddr
+	lastActive int64
+
+	mu     sync.Mutex
+	value  io.Closer
+	done   chan struct{}
+	closed bool
+	opened bool
+}
+
+func newUdpSession(server *UdpServer, clientAddr *net.UDPAddr) *UdpSession {
+	session := &UdpSession{
+		server:     server,
+		clientAddr: clientAddr,
+		done:       make(chan struct{}),
+	}
+	session.touch()
+	return session
+}
+
+// RemoteAddr 客户端地址
+func (s *UdpSession) RemoteAddr() net.Addr {
+	return s.clientAddr
+}
+
+// ClientIP 客户端IP
+func (s *UdpSession) ClientIP() string {
+	return s.clientAddr.IP.String()
+}
+
+// Write 通过监听器向客户端发送数据包
+func (s *UdpSession) Write(b []byte) (int, error) {
+	s.touch()
+	return s.server.conn.WriteToUDP(b, s.clientAddr)
+}
+
+// Open 将会话登记到服务器，处理函数在建立下游连接前调用
+// 会话已经关闭、服务器关闭过程中或者超过最大会话数时返回false，数据包应当被丢弃
+func (s *UdpSession) Open() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if !s.opened {
+		s.opened = s.server.addSession(s)
+	}
+	return s.opened
+}
+
+// Value 获取会话中保存的值
+func (s *UdpSession) Value() io.Closer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+// SetValue 在会话中保存值，会话关闭时一并关闭；会话已经关闭时立即关闭并返回false
+func (s *UdpSession) SetValue(value io.Closer) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		value.Close()
+		return false
+	}
+	s.value = value
+	s.mu.Unlock()
+	return true
+}
+
+// Done 会话关闭时关闭的channel
+func (s *UdpSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close 关闭会话并从服务器的会话表中移除，之后同一地址的数据包会建立新的会话
+func (s *UdpSession) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	value := s.value
+	close(s.done)
+	s.mu.Unlock()
+	s.server.removeSession(s)
+	if value != nil {
+		return value.Close()
+	}
+	return nil
+}
+
+// touch 收发数据包时刷新会话的活跃时间
+func (s *UdpSession) touch() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+}
+
+func (s *UdpSession) lastActiveTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastActive))
+}